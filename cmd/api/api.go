@@ -29,7 +29,9 @@ import (
 	"viz/internal/jobs"
 	"viz/internal/jobs/workers"
 	imalog "viz/internal/logger"
+	"viz/internal/maintenance"
 	"viz/internal/settings"
+	"viz/internal/storage"
 	"viz/internal/utils"
 )
 
@@ -126,6 +128,7 @@ func (server APIServer) Launch(router *chi.Mux) *http.Server {
 					auth.ImagesUploadScope,
 				}))
 				r.Mount("/images", routes.ImagesRouter(dbClient, logger))
+				r.Mount("/iiif", routes.IIIFRouter(dbClient, logger))
 			})
 			r.Group(func(r chi.Router) {
 				r.Use(libhttp.ScopeMiddleware([]auth.Scope{
@@ -242,6 +245,31 @@ func main() {
 
 	appConfig = config.AppConfig
 
+	store, err := storage.New(appConfig.Storage, appConfig.BaseDir)
+	if err != nil {
+		logger.Error("failed to set up storage", slog.Any("error", err))
+		panic(err)
+	}
+
+	images.Store = store
+	logger.Info("using storage backend", slog.String("backend", store.Name()))
+
+	locations := make(map[string]storage.Storage, len(appConfig.Storage.Locations))
+	for name, locationConfig := range appConfig.Storage.Locations {
+		location, err := storage.NewLocation(locationConfig, appConfig.BaseDir)
+		if err != nil {
+			logger.Error("failed to set up storage location", slog.String("location", name), slog.Any("error", err))
+			panic(err)
+		}
+
+		locations[name] = location
+	}
+
+	if err := images.SetLocations(locations); err != nil {
+		logger.Error("failed to set up storage locations", slog.Any("error", err))
+		panic(err)
+	}
+
 	apiServer.Database = &db.DB{
 		Address: func() string {
 			if host := os.Getenv("DB_HOST"); host != "" {
@@ -282,13 +310,21 @@ func main() {
 	// Lmao I hate this
 	client := apiServer.ConnectToDatabase(
 		entities.ImageAsset{},
+		entities.SavedSearch{},
 		entities.Collection{},
 		entities.Session{},
 		entities.APIKey{},
 		entities.User{},
 		entities.DownloadToken{},
 		entities.WorkerJob{},
+		entities.WorkerJobDependency{},
+		entities.Pipeline{},
+		entities.CronJob{},
+		entities.TransformPreset{},
+		entities.ColourProfile{},
 		entities.UserWithPassword{},
+		entities.ImageWithExifValues{},
+		entities.ImageWithEditHistory{},
 		entities.SettingDefault{},
 		entities.SettingOverride{},
 	)
@@ -296,6 +332,14 @@ func main() {
 
 	settings.SeedDefaultSettings(client, logger)
 
+	if err := images.LoadPresets(client, appConfig.Transforms.Presets); err != nil {
+		logger.Error("failed to load transform presets", slog.Any("error", err))
+	}
+
+	if err := images.LoadColourProfiles(client); err != nil {
+		logger.Error("failed to load colour profiles", slog.Any("error", err))
+	}
+
 	// http server stuff
 	if apiPortEnv := os.Getenv("API_PORT"); apiPortEnv != "" {
 		var p int
@@ -341,7 +385,7 @@ func main() {
 	libvips.SetLogging(libvipsLogHandler, libvipsLogLevel)
 	imageops.WarmupAllOps(appConfig.Libvips)
 
-	StorageStatsHolder = images.NewStorageStatsHolder(appConfig.BaseDir)
+	StorageStatsHolder = images.NewStorageStatsHolder(appConfig.BaseDir, images.Store)
 
 	httpServer := apiServer.Launch(router)
 
@@ -349,29 +393,24 @@ func main() {
 	ctx, globalCancel := context.WithCancel(context.Background())
 	defer globalCancel()
 
-	// Start transform cache GC if enabled in config.
-	if appConfig.Cache.GCEnabled {
-		images.StartTransformCacheGC(ctx, logger, client)
-	} else {
-		logger.Debug("transform cache gc: disabled by config")
-	}
-
-	if appConfig.StorageMetrics.Enabled {
-		interval := time.Duration(appConfig.StorageMetrics.IntervalSeconds) * time.Second
-		if interval <= 0 {
-			interval = 5 * time.Minute
-		}
-
-		go StorageStatsHolder.StartStorageStatsWorker(ctx, logger, interval)
+	// Maintenance tasks run as cron jobs whose schedules can be changed
+	// at runtime through the jobs API.
+	cronJobs := maintenance.CronJobs(client, logger, appConfig, StorageStatsHolder)
+	if err := jobs.StartCronJobs(ctx, client, logger, cronJobs...); err != nil {
+		logger.Error("failed to start cron jobs", slog.Any("error", err))
 	}
 
 	imageWorker := workers.NewImageWorker(client, apiServer.WSBroker)
 	xmpWorker := workers.NewXMPWorker(client, apiServer.WSBroker)
 	exifWorker := workers.NewExifWorker(client, apiServer.WSBroker)
+	migrationWorker := workers.NewStorageMigrationWorker(client, apiServer.WSBroker)
+	pyramidWorker := workers.NewTilePyramidWorker(client, apiServer.WSBroker)
+	presetWorker := workers.NewPresetTransformWorker(client, apiServer.WSBroker)
+	jobs.Broker = apiServer.WSBroker
 
 	// Run the job router in a goroutine so we can wait for shutdown signals here
 	go func() {
-		jobs.RunJobQueue(appConfig.Queue, logger, imageWorker, xmpWorker, exifWorker)
+		jobs.RunJobQueue(appConfig.Queue, client, logger, imageWorker, xmpWorker, exifWorker, migrationWorker, pyramidWorker, presetWorker)
 	}()
 
 	sigCh := make(chan os.Signal, 1)
@@ -394,6 +433,10 @@ func main() {
 		_ = jobs.Router.Close()
 	}
 
+	if jobs.Scheduler != nil {
+		_ = jobs.Shutdown()
+	}
+
 	time.Sleep(500 * time.Millisecond)
 	logger.Info("shutdown complete")
 }
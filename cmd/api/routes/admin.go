@@ -3,6 +3,9 @@ package routes
 
 import (
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"runtime"
 	"time"
@@ -12,12 +15,15 @@ import (
 	"gorm.io/gorm"
 	"log/slog"
 
+	"viz/internal/config"
 	"viz/internal/crypto"
 
 	"viz/internal/dto"
 	"viz/internal/entities"
 	libhttp "viz/internal/http"
 	"viz/internal/images"
+	"viz/internal/jobs"
+	"viz/internal/jobs/workers"
 	libos "viz/internal/os"
 	"viz/internal/settings"
 	"viz/internal/uid"
@@ -67,7 +73,7 @@ func AdminRouter(db *gorm.DB, logger *slog.Logger, storageStats *images.StorageS
 
 	// Get cache status
 	r.Get("/cache/status", func(res http.ResponseWriter, req *http.Request) {
-		status, err := images.GetCacheStatus()
+		status, err := images.GetCacheStatus(req.Context())
 		if err != nil {
 			logger.Error("failed to get cache status", slog.Any("error", err))
 			render.Status(req, http.StatusInternalServerError)
@@ -87,7 +93,7 @@ func AdminRouter(db *gorm.DB, logger *slog.Logger, storageStats *images.StorageS
 
 	// Clear image cache
 	r.Delete("/cache", func(res http.ResponseWriter, req *http.Request) {
-		err := images.ClearCache(logger)
+		err := images.ClearCache(req.Context(), logger)
 		if err != nil {
 			logger.Error("failed to clear image cache", slog.Any("error", err))
 			render.Status(req, http.StatusInternalServerError)
@@ -98,6 +104,246 @@ func AdminRouter(db *gorm.DB, logger *slog.Logger, storageStats *images.StorageS
 		render.JSON(res, req, dto.MessageResponse{Message: "Image cache cleared"})
 	})
 
+	// Storage locations with the number of images in each
+	r.Get("/storage/locations", func(res http.ResponseWriter, req *http.Request) {
+		items := []dto.StorageLocation{}
+		for _, name := range images.LocationNames() {
+			store, err := images.StoreAt(name)
+			if err != nil {
+				logger.Error("failed to get storage location", slog.String("location", name), slog.Any("error", err))
+				render.Status(req, http.StatusInternalServerError)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Failed to get storage locations"})
+				return
+			}
+
+			var count int64
+			if err := db.Unscoped().Model(&entities.ImageAsset{}).Scopes(images.InLocation(name)).Count(&count).Error; err != nil {
+				logger.Error("failed to count images in storage location", slog.String("location", name), slog.Any("error", err))
+				render.Status(req, http.StatusInternalServerError)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Failed to get storage locations"})
+				return
+			}
+
+			items = append(items, dto.StorageLocation{
+				Name:       name,
+				Backend:    store.Name(),
+				Default:    name == images.DefaultLocation,
+				ImageCount: count,
+			})
+		}
+
+		render.Status(req, http.StatusOK)
+		render.JSON(res, req, dto.StorageLocationsResponse{Items: items})
+	})
+
+	// Start moving the images of one storage location to another in the background
+	r.Post("/storage/migrations", func(res http.ResponseWriter, req *http.Request) {
+		var body dto.StorageMigrationRequest
+		if err := render.DecodeJSON(req.Body, &body); err != nil {
+			render.Status(req, http.StatusBadRequest)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Invalid body"})
+			return
+		}
+
+		job := workers.StorageMigrationJob{
+			Source:       body.Source,
+			Target:       body.Target,
+			DeleteSource: body.DeleteSource != nil && *body.DeleteSource,
+		}
+
+		if body.Concurrency != nil {
+			job.Concurrency = *body.Concurrency
+		}
+
+		if err := job.Validate(); err != nil {
+			render.Status(req, http.StatusBadRequest)
+			render.JSON(res, req, dto.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		var ownerUid *string
+		if authUser, ok := libhttp.UserFromContext(req); ok {
+			ownerUid = &authUser.Uid
+		}
+
+		jobUid, err := jobs.Enqueue(db, workers.TopicStorageMigration, &job, nil, nil, jobs.PriorityBackfill, ownerUid)
+		if err != nil {
+			logger.Error("failed to enqueue storage migration", slog.Any("error", err))
+			render.Status(req, http.StatusInternalServerError)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to start storage migration"})
+			return
+		}
+
+		var wj entities.WorkerJob
+		if err := db.Where("uid = ?", jobUid).First(&wj).Error; err != nil {
+			logger.Error("failed to load storage migration job", slog.Any("error", err))
+			render.Status(req, http.StatusInternalServerError)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to start storage migration"})
+			return
+		}
+
+		render.Status(req, http.StatusAccepted)
+		render.JSON(res, req, wj.DTO())
+	})
+
+	// Transform presets, the built in ones first
+	r.Get("/presets", func(res http.ResponseWriter, req *http.Request) {
+		items := []dto.TransformPreset{}
+		for _, preset := range images.GetAllPresets() {
+			items = append(items, presetDTO(preset))
+		}
+
+		render.Status(req, http.StatusOK)
+		render.JSON(res, req, dto.TransformPresetListResponse{Items: items})
+	})
+
+	// Save a transform preset and regenerate it for every image
+	r.Put("/presets/{name}", func(res http.ResponseWriter, req *http.Request) {
+		name := chi.URLParam(req, "name")
+
+		var body dto.TransformPresetUpdate
+		if err := render.DecodeJSON(req.Body, &body); err != nil {
+			render.Status(req, http.StatusBadRequest)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Invalid body"})
+			return
+		}
+
+		params, err := images.ParsePreset(name, body.Params)
+		if err != nil {
+			render.Status(req, http.StatusBadRequest)
+			render.JSON(res, req, dto.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		// stored normalised so the listing shows what will be generated
+		preset := entities.TransformPreset{Name: name, Params: params.ToQueryString()}
+		err = db.Where(entities.TransformPreset{Name: name}).
+			Assign(entities.TransformPreset{Params: preset.Params}).
+			FirstOrCreate(&preset).Error
+		if err != nil {
+			logger.Error("failed to save transform preset", slog.String("preset", name), slog.Any("error", err))
+			render.Status(req, http.StatusInternalServerError)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to save preset"})
+			return
+		}
+
+		if err := images.LoadPresets(db, config.AppConfig.Transforms.Presets); err != nil {
+			logger.Warn("some transform presets are invalid", slog.Any("error", err))
+		}
+
+		count, err := regeneratePreset(db, logger, name)
+		if err != nil {
+			logger.Error("failed to regenerate transform preset", slog.String("preset", name), slog.Any("error", err))
+			render.Status(req, http.StatusInternalServerError)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Preset saved but regenerating it failed"})
+			return
+		}
+
+		render.Status(req, http.StatusOK)
+		render.JSON(res, req, dto.TransformPresetSaveResponse{
+			Preset:       presetDTO(images.Preset{Name: images.PermanentTransformName(name), Params: params, Source: images.PresetSourceCustom}),
+			Regenerating: count,
+		})
+	})
+
+	// Remove a transform preset saved through the API, its files are left
+	// for the cache cleanup
+	r.Delete("/presets/{name}", func(res http.ResponseWriter, req *http.Request) {
+		name := chi.URLParam(req, "name")
+
+		result := db.Where("name = ?", name).Delete(&entities.TransformPreset{})
+		if result.Error != nil {
+			logger.Error("failed to delete transform preset", slog.String("preset", name), slog.Any("error", result.Error))
+			render.Status(req, http.StatusInternalServerError)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to delete preset"})
+			return
+		}
+
+		if result.RowsAffected == 0 {
+			if _, ok := images.GetPermanentTransformParams(images.PermanentTransformName(name)); ok {
+				render.Status(req, http.StatusBadRequest)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Built in and config presets can't be removed"})
+				return
+			}
+
+			render.Status(req, http.StatusNotFound)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Preset not found"})
+			return
+		}
+
+		if err := images.LoadPresets(db, config.AppConfig.Transforms.Presets); err != nil {
+			logger.Warn("some transform presets are invalid", slog.Any("error", err))
+		}
+
+		// the config's preset of the same name is back in charge
+		if _, ok := config.AppConfig.Transforms.Presets[name]; ok {
+			if _, err := regeneratePreset(db, logger, name); err != nil {
+				logger.Error("failed to regenerate transform preset", slog.String("preset", name), slog.Any("error", err))
+			}
+		}
+
+		res.WriteHeader(http.StatusNoContent)
+	})
+
+	// Uploaded ICC profiles transforms convert to or soft-proof against
+	r.Get("/colour-profiles", func(res http.ResponseWriter, req *http.Request) {
+		items := []dto.ColourProfile{}
+		for _, profile := range images.GetColourProfiles() {
+			items = append(items, colourProfileDTO(profile))
+		}
+
+		render.Status(req, http.StatusOK)
+		render.JSON(res, req, dto.ColourProfileListResponse{Items: items})
+	})
+
+	r.Put("/colour-profiles/{name}", func(res http.ResponseWriter, req *http.Request) {
+		name := chi.URLParam(req, "name")
+
+		data, err := io.ReadAll(http.MaxBytesReader(res, req.Body, images.MaxColourProfileSize))
+		if err != nil {
+			render.Status(req, http.StatusBadRequest)
+			render.JSON(res, req, dto.ErrorResponse{Error: fmt.Sprintf("Profile must be an ICC profile of at most %d MB", images.MaxColourProfileSize>>20)})
+			return
+		}
+
+		profile, err := images.SaveColourProfile(req.Context(), db, name, data)
+		if errors.Is(err, images.ErrInvalidColourProfile) {
+			render.Status(req, http.StatusBadRequest)
+			render.JSON(res, req, dto.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		if err != nil {
+			logger.Error("failed to save colour profile", slog.String("profile", name), slog.Any("error", err))
+			render.Status(req, http.StatusInternalServerError)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to save profile"})
+			return
+		}
+
+		render.Status(req, http.StatusOK)
+		render.JSON(res, req, colourProfileDTO(profile))
+	})
+
+	r.Delete("/colour-profiles/{name}", func(res http.ResponseWriter, req *http.Request) {
+		name := chi.URLParam(req, "name")
+
+		found, err := images.DeleteColourProfile(db, name)
+		if err != nil {
+			logger.Error("failed to delete colour profile", slog.String("profile", name), slog.Any("error", err))
+			render.Status(req, http.StatusInternalServerError)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to delete profile"})
+			return
+		}
+
+		if !found {
+			render.Status(req, http.StatusNotFound)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Profile not found"})
+			return
+		}
+
+		res.WriteHeader(http.StatusNoContent)
+	})
+
 	// System Stats
 	r.Get("/system/stats", func(res http.ResponseWriter, req *http.Request) {
 		var m runtime.MemStats
@@ -121,6 +367,7 @@ func AdminRouter(db *gorm.DB, logger *slog.Logger, storageStats *images.StorageS
 			SysMemory:        int64(m.Sys),
 			StorageUsedBytes: storageStats.GetTotalSize(),
 			StoragePath:      storageStats.GetPath(),
+			StorageBackend:   storageStats.GetBackend(),
 			TotalSystemSpaceBytes: int64(totalSystemSpace),
 			TotalAvailableSpaceBytes: int64(freeBytes),
 		}
@@ -356,3 +603,54 @@ func AdminRouter(db *gorm.DB, logger *slog.Logger, storageStats *images.StorageS
 
 	return r
 }
+
+func presetDTO(preset images.Preset) dto.TransformPreset {
+	return dto.TransformPreset{
+		Name:   string(preset.Name),
+		Params: preset.Params.ToQueryString(),
+		Source: dto.TransformPresetSource(preset.Source),
+	}
+}
+
+// regeneratePreset queues a job generating a preset for every image and
+// returns how many there are. Jobs are queued in the background at backfill
+// priority so uploads aren't held up behind a large library.
+func regeneratePreset(db *gorm.DB, logger *slog.Logger, name string) (int, error) {
+	var count int64
+	if err := db.Model(&entities.ImageAsset{}).Where("deleted_at IS NULL").Count(&count).Error; err != nil {
+		return 0, err
+	}
+
+	go func() {
+		var imgs []entities.ImageAsset
+		err := db.Where("deleted_at IS NULL").FindInBatches(&imgs, 100, func(tx *gorm.DB, batch int) error {
+			for _, img := range imgs {
+				job := &workers.PresetTransformJob{Image: img, Preset: name}
+				if _, err := jobs.Enqueue(db, workers.TopicPresetTransform, job, nil, &img.Uid, jobs.PriorityBackfill, img.OwnerID); err != nil {
+					logger.Error("failed to enqueue preset job", slog.String("preset", name), slog.String("uid", img.Uid), slog.Any("error", err))
+				}
+			}
+			return nil
+		}).Error
+		if err != nil {
+			logger.Error("failed to enqueue preset jobs", slog.String("preset", name), slog.Any("error", err))
+			return
+		}
+
+		logger.Info("preset jobs enqueued", slog.String("preset", name), slog.Int64("count", count))
+	}()
+
+	return int(count), nil
+}
+
+func colourProfileDTO(profile entities.ColourProfile) dto.ColourProfile {
+	return dto.ColourProfile{
+		Name:        profile.Name,
+		Checksum:    profile.Checksum,
+		ColourSpace: profile.ColourSpace,
+		Class:       profile.Class,
+		Size:        profile.Size,
+		CreatedAt:   profile.CreatedAt,
+		UpdatedAt:   profile.UpdatedAt,
+	}
+}
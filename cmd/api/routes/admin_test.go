@@ -18,6 +18,7 @@ import (
 	"viz/internal/dto"
 	"viz/internal/entities"
 	"viz/internal/images"
+	"viz/internal/storage"
 )
 
 // Helper function to create a new test logger
@@ -49,7 +50,7 @@ func newTestDB(t *testing.T) *gorm.DB {
 func TestAdminSystemStats(t *testing.T) {
 	db := newTestDB(t)
 	logger := newTestLogger()
-	storageStats := images.NewStorageStatsHolder(os.TempDir()) // Use temp dir for stats
+	storageStats := images.NewStorageStatsHolder(os.TempDir(), storage.NewLocal(os.TempDir())) // Use temp dir for stats
 
 	r := chi.NewRouter()
 	r.Mount("/admin", routes.AdminRouter(db, logger, storageStats))
@@ -82,7 +83,7 @@ func TestAdminSystemStats(t *testing.T) {
 func TestAdminDatabaseStats(t *testing.T) {
 	db := newTestDB(t)
 	logger := newTestLogger()
-	storageStats := images.NewStorageStatsHolder(os.TempDir())
+	storageStats := images.NewStorageStatsHolder(os.TempDir(), storage.NewLocal(os.TempDir()))
 
 	r := chi.NewRouter()
 	r.Mount("/admin", routes.AdminRouter(db, logger, storageStats))
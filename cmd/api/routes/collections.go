@@ -15,11 +15,15 @@ import (
 	"viz/internal/dto"
 	"viz/internal/entities"
 	libhttp "viz/internal/http"
+	"viz/internal/search"
+	"viz/internal/settings"
 	"viz/internal/uid"
 	"viz/internal/utils"
 )
 
 var ErrCollectionUnauthorised = errors.New("unauthorized")
+var ErrSmartCollectionImages = errors.New("smart collection images are defined by its saved search")
+var ErrSavedSearchNotFound = errors.New("saved search not found")
 
 func findCollectionImages(db *gorm.DB, imgUIDs []string, collection entities.Collection, limit, offset int) ([]dto.ImagesResponse, error) {
 	var images []entities.ImageAsset
@@ -55,14 +59,121 @@ func findCollectionImages(db *gorm.DB, imgUIDs []string, collection entities.Col
 	return imgResponse, nil
 }
 
+// smartCollectionQuery runs a smart collection's saved search at read time.
+// Results are limited to images the viewer can see, and dates in the query
+// are resolved in the collection owner's timezone so every viewer gets the
+// same set.
+func smartCollectionQuery(tx *gorm.DB, collection entities.Collection, viewerID string) (*gorm.DB, error) {
+	if collection.SavedSearch == nil {
+		if err := tx.Preload("SavedSearch").First(&collection, "uid = ?", collection.Uid).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	// The saved search was deleted out from under the collection
+	if collection.SavedSearch == nil {
+		return tx.Model(&entities.ImageAsset{}).Where("1 = 0"), nil
+	}
+
+	query, err := search.ParseQuery(collection.SavedSearch.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	engine := search.NewEngine().WithLocation(settings.GetLocation(tx, collection.OwnerID))
+	return engine.Apply(tx, query).Scopes(visibilityScope(viewerID)), nil
+}
+
+// findSmartCollectionImages returns a page of a smart collection's images,
+// newest first, along with the total number of matches
+func findSmartCollectionImages(tx *gorm.DB, collection entities.Collection, viewerID string, limit, offset int) ([]dto.ImagesResponse, int, error) {
+	query, err := smartCollectionQuery(tx, collection, viewerID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var images []entities.ImageAsset
+	if err := query.Preload("Owner").Preload("UploadedBy").
+		Order("taken_at DESC NULLS LAST").Order("created_at DESC").
+		Limit(limit).Offset(offset).
+		Find(&images).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Images match the query rather than being added, so use their upload time
+	imgResponse := make([]dto.ImagesResponse, len(images))
+	for i, img := range images {
+		imgResponse[i] = dto.ImagesResponse{
+			AddedAt: img.CreatedAt,
+			Image:   img.DTO(),
+		}
+	}
+
+	return imgResponse, int(total), nil
+}
+
+// collectionImageUIDs returns the UIDs of every image in a collection, running
+// the saved search for smart collections
+func collectionImageUIDs(tx *gorm.DB, collection entities.Collection, viewerID string) ([]string, error) {
+	if collection.SavedSearchID == nil {
+		var uids []string
+		if collection.Images != nil {
+			for _, img := range *collection.Images {
+				uids = append(uids, img.Uid)
+			}
+		}
+
+		return uids, nil
+	}
+
+	query, err := smartCollectionQuery(tx, collection, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var uids []string
+	if err := query.Order("taken_at DESC NULLS LAST").Order("created_at DESC").Pluck("uid", &uids).Error; err != nil {
+		return nil, err
+	}
+
+	return uids, nil
+}
+
+// findOwnedSavedSearch checks that a saved search exists and belongs to the
+// given user before a collection is linked to it
+func findOwnedSavedSearch(tx *gorm.DB, savedSearchUID string, ownerID *string) error {
+	if ownerID == nil {
+		return ErrSavedSearchNotFound
+	}
+
+	var count int64
+	if err := tx.Model(&entities.SavedSearch{}).Where("uid = ? AND owner_id = ?", savedSearchUID, *ownerID).Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrSavedSearchNotFound
+	}
+
+	return nil
+}
+
 func CollectionsRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 	router := chi.NewRouter()
 
 	router.Post("/", func(res http.ResponseWriter, req *http.Request) {
 		var create struct {
-			Description *string `json:"description,omitempty"`
-			Name        string  `json:"name"`
-			Private     *bool   `json:"private"`
+			Description    *string `json:"description,omitempty"`
+			Name           string  `json:"name"`
+			Private        *bool   `json:"private"`
+			SavedSearchUid *string `json:"saved_search_uid,omitempty"`
 		}
 
 		err := render.DecodeJSON(req.Body, &create)
@@ -98,12 +209,35 @@ func CollectionsRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 			OwnerID:     &authUser.Uid,
 		}
 
+		if create.SavedSearchUid != nil && *create.SavedSearchUid != "" {
+			err = findOwnedSavedSearch(db, *create.SavedSearchUid, &authUser.Uid)
+			if err != nil {
+				if errors.Is(err, ErrSavedSearchNotFound) {
+					render.Status(req, http.StatusBadRequest)
+					render.JSON(res, req, dto.ErrorResponse{Error: "Saved search not found"})
+					return
+				}
+
+				libhttp.ServerError(res, req, err, logger, nil,
+					"Failed to find saved search",
+					"Something went wrong, please try again later",
+				)
+				return
+			}
+
+			collection.SavedSearchID = create.SavedSearchUid
+		}
+
 		err = db.Create(&collection).Error
 		if err != nil {
 			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to create collection"})
 			return
 		}
 
+		if collection.SavedSearchID != nil {
+			_ = db.Preload("SavedSearch").First(&collection, "uid = ?", collection.Uid).Error
+		}
+
 		render.Status(req, http.StatusCreated)
 		render.JSON(res, req, collection.DTO())
 	})
@@ -140,7 +274,7 @@ func CollectionsRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 			}
 
 			// Fetch current page
-			return query.Preload("Thumbnail").Preload("CreatedBy").
+			return query.Preload("Thumbnail").Preload("CreatedBy").Preload("SavedSearch").
 				Limit(limit).
 				Offset(page * limit).
 				Find(&collections).Error
@@ -197,9 +331,10 @@ func CollectionsRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 
 		var collection entities.Collection
 		var imgResponse []dto.ImagesResponse
+		var totalImages int
 
 		err := db.Transaction(func(tx *gorm.DB) error {
-			if err := tx.Preload("Thumbnail").Preload("CreatedBy").First(&collection, "uid = ?", uid).Error; err != nil {
+			if err := tx.Preload("Thumbnail").Preload("CreatedBy").Preload("SavedSearch").First(&collection, "uid = ?", uid).Error; err != nil {
 				return err
 			}
 
@@ -213,6 +348,18 @@ func CollectionsRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 				}
 			}
 
+			if collection.SavedSearchID != nil {
+				smartImages, total, err := findSmartCollectionImages(tx, collection, requestUserID(req), defaultImageLimit, defaultImageOffset)
+				if err != nil {
+					return err
+				}
+
+				imgResponse = smartImages
+				totalImages = total
+				collection.ImageCount = total
+				return nil
+			}
+
 			var collectionImages []dto.CollectionImage
 			if collection.Images != nil {
 				collectionImages = *collection.Images
@@ -229,6 +376,7 @@ func CollectionsRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 			}
 
 			imgResponse = allColImages
+			totalImages = len(collectionImages)
 			return nil
 		})
 
@@ -250,11 +398,6 @@ func CollectionsRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 		href := fmt.Sprintf("/collections/%s/images/?page=%d&limit=%d", uid, defaultImagePage, defaultImageLimit)
 
 		var next *string
-		var totalImages int
-		if collection.Images != nil {
-			totalImages = len(*collection.Images)
-		}
-
 		if totalImages > defaultImageLimit {
 			nxPtr := fmt.Sprintf("/collections/%s/images/?page=%d&limit=%d", uid, defaultImagePage+1, defaultImageLimit)
 			next = &nxPtr
@@ -288,6 +431,7 @@ func CollectionsRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 			UpdatedAt:   collectionDTO.UpdatedAt,
 			Description: collectionDTO.Description,
 			Thumbnail:   collectionDTO.Thumbnail,
+			SavedSearch: collectionDTO.SavedSearch,
 		}
 
 		render.JSON(res, req, result)
@@ -315,6 +459,17 @@ func CollectionsRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 				return fmt.Errorf("unauthorized")
 			}
 
+			if update.SavedSearchUID != nil && *update.SavedSearchUID != "" {
+				owner := collection.OwnerID
+				if update.OwnerUID != nil {
+					owner = update.OwnerUID
+				}
+
+				if err := findOwnedSavedSearch(tx, *update.SavedSearchUID, owner); err != nil {
+					return err
+				}
+			}
+
 			updateCollectionFromDTO(&collection, update)
 
 			if err := tx.Save(&collection).Error; err != nil {
@@ -322,7 +477,7 @@ func CollectionsRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 			}
 
 			// Reload to ensure updated data is sent to clients
-			return tx.Preload("Thumbnail").Preload("CreatedBy").First(&collection, "uid = ?", uid).Error
+			return tx.Preload("Thumbnail").Preload("CreatedBy").Preload("SavedSearch").First(&collection, "uid = ?", uid).Error
 		})
 
 		if err != nil {
@@ -338,6 +493,12 @@ func CollectionsRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 				return
 			}
 
+			if errors.Is(err, ErrSavedSearchNotFound) {
+				render.Status(req, http.StatusBadRequest)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Saved search not found"})
+				return
+			}
+
 			libhttp.ServerError(res, req, err, logger, nil,
 				"Failed to update collection",
 				"Something went wrong, please try again later",
@@ -407,9 +568,10 @@ func CollectionsRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 
 		var imgResponse []dto.ImagesResponse
 		var collection entities.Collection
+		var totalImages int
 
 		err = db.Transaction(func(tx *gorm.DB) error {
-			if err := tx.Select("images", "private", "owner_id").First(&collection, "uid = ?", uid).Error; err != nil {
+			if err := tx.Select("uid", "images", "private", "owner_id", "saved_search_id").Preload("SavedSearch").First(&collection, "uid = ?", uid).Error; err != nil {
 				return err
 			}
 
@@ -421,6 +583,11 @@ func CollectionsRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 				}
 			}
 
+			if collection.SavedSearchID != nil {
+				imgResponse, totalImages, err = findSmartCollectionImages(tx, collection, requestUserID(req), limit, offset)
+				return err
+			}
+
 			var collectionImages []dto.CollectionImage
 			if collection.Images != nil {
 				collectionImages = *collection.Images
@@ -436,6 +603,7 @@ func CollectionsRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 				return err
 			}
 
+			totalImages = len(collectionImages)
 			return nil
 		})
 
@@ -462,11 +630,6 @@ func CollectionsRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 		}
 
 		var next *string
-		var totalImages int
-		if collection.Images != nil {
-			totalImages = len(*collection.Images)
-		}
-
 		if offset+limit < totalImages {
 			nx := fmt.Sprintf("/collections/%s/images/?offset=%d&limit=%d", uid, offset+limit, limit)
 			next = &nx
@@ -511,6 +674,10 @@ func CollectionsRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 				return fmt.Errorf("unauthorized")
 			}
 
+			if collection.SavedSearchID != nil {
+				return ErrSmartCollectionImages
+			}
+
 			for _, imgUID := range colImage.UIDs {
 				var img entities.ImageAsset
 
@@ -552,6 +719,12 @@ func CollectionsRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 				return
 			}
 
+			if err == ErrSmartCollectionImages {
+				render.Status(req, http.StatusBadRequest)
+				render.JSON(res, req, dto.AddImagesResponse{Added: false, Error: utils.StringPtr("Images can't be added to a smart collection, edit its saved search instead")})
+				return
+			}
+
 			libhttp.ServerError(res, req, err, logger, nil,
 				"",
 				"Something went wrong, please try again later",
@@ -586,6 +759,10 @@ func CollectionsRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 				return ErrCollectionUnauthorised
 			}
 
+			if collection.SavedSearchID != nil {
+				return ErrSmartCollectionImages
+			}
+
 			var images []dto.CollectionImage
 			if collection.Images != nil {
 				images = *collection.Images
@@ -635,6 +812,12 @@ func CollectionsRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 				return
 			}
 
+			if err == ErrSmartCollectionImages {
+				render.Status(req, http.StatusBadRequest)
+				render.JSON(res, req, dto.DeleteImagesResponse{Deleted: false, Error: utils.StringPtr("images can't be removed from a smart collection, edit its saved search instead")})
+				return
+			}
+
 			libhttp.ServerError(res, req, err, logger, nil,
 				"Failed to remove images from collection",
 				"Something went wrong, please try again later",
@@ -673,4 +856,11 @@ func updateCollectionFromDTO(collection *entities.Collection, update dto.Collect
 	if update.OwnerUID != nil {
 		collection.OwnerID = update.OwnerUID
 	}
+	if update.SavedSearchUID != nil {
+		if *update.SavedSearchUID == "" {
+			collection.SavedSearchID = nil
+		} else {
+			collection.SavedSearchID = update.SavedSearchUID
+		}
+	}
 }
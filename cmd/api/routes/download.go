@@ -2,14 +2,15 @@ package routes
 
 import (
 	"archive/zip"
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -19,17 +20,30 @@ import (
 	"viz/internal/downloads"
 	"viz/internal/dto"
 	"viz/internal/entities"
+	"viz/internal/imageops"
 	"viz/internal/images"
+	"viz/internal/settings"
+	"viz/internal/transform"
 	"viz/internal/utils"
 )
 
 // writeImagesToZip queries images for the given uids and writes them into the provided zip.Writer
 // in the order of the provided uids slice. Missing or unreadable files are skipped and logged.
-func writeImagesToZip(ctx context.Context, db *gorm.DB, logger *slog.Logger, zw *zip.Writer, uids []string) error {
+// Originals are replaced by copies with the token's watermark, and without the metadata the
+// token or the image's owner hold back.
+func writeImagesToZip(ctx context.Context, db *gorm.DB, logger *slog.Logger, zw *zip.Writer, uids []string, token *entities.DownloadToken) error {
 	if len(uids) == 0 {
 		return nil
 	}
 
+	var wm *transform.Watermark
+	if token.Watermark != nil {
+		var err error
+		if wm, err = images.LoadWatermark(ctx, *token.Watermark); err != nil {
+			return err
+		}
+	}
+
 	var imgs []entities.ImageAsset
 	if err := db.WithContext(ctx).Where("uid IN ? AND deleted_at IS NULL", uids).Find(&imgs).Error; err != nil {
 		return err
@@ -47,17 +61,35 @@ func writeImagesToZip(ctx context.Context, db *gorm.DB, logger *slog.Logger, zw
 			continue
 		}
 
-		diskPath := images.GetImagePath(imageEntity.Uid, imageEntity.ImageMetadata.FileName)
-		f, err := os.Open(diskPath)
-		if err != nil {
-			logger.Error("failed to open image file for export", slog.Any("error", err), slog.String("path", diskPath))
-			continue
-		}
-
 		safeName := filepath.Base(imageEntity.ImageMetadata.FileName)
 		// Use the original filename inside the ZIP (do not prefix with UID)
 		zipFileName := safeName
 
+		metadata, err := metadataLimit(db.WithContext(ctx), "", &imageEntity, token)
+		if err != nil {
+			return err
+		}
+
+		var f io.ReadCloser
+		if wm != nil || metadata != transform.MetadataKeep {
+			var format string
+			f, format, err = openRenderedImage(ctx, imageEntity, wm, metadata)
+			if err != nil {
+				logger.Error("failed to render image for export", slog.Any("error", err), slog.String("uid", imageEntity.Uid))
+				continue
+			}
+
+			if !strings.EqualFold(strings.TrimPrefix(filepath.Ext(safeName), "."), format) {
+				zipFileName = strings.TrimSuffix(safeName, filepath.Ext(safeName)) + "." + format
+			}
+		} else {
+			f, err = images.OpenImage(ctx, imageEntity, imageEntity.ImageMetadata.FileName)
+			if err != nil {
+				logger.Error("failed to open image file for export", slog.Any("error", err), slog.String("key", images.ImageKey(imageEntity.Uid, imageEntity.ImageMetadata.FileName)))
+				continue
+			}
+		}
+
 		fileHeader := &zip.FileHeader{
 			Name:   zipFileName,
 			Method: zip.Deflate,
@@ -85,9 +117,27 @@ func writeImagesToZip(ctx context.Context, db *gorm.DB, logger *slog.Logger, zw
 	return nil
 }
 
+// openRenderedImage renders a copy of an image's original with a
+// watermark, nil for none, and the metadata a policy keeps, and returns it
+// with the format it's in
+func openRenderedImage(ctx context.Context, img entities.ImageAsset, wm *transform.Watermark, metadata string) (io.ReadCloser, string, error) {
+	params := &transform.TransformParams{
+		Format:    imageops.WatermarkFormat(img.ImageMetadata.FileType),
+		Watermark: wm,
+		Metadata:  metadata,
+	}
+
+	result, err := imageops.GenerateTransformFromSource(params, img, images.FileOpener(ctx, img))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return io.NopCloser(bytes.NewReader(result.ImageData)), params.Format, nil
+}
+
 // streamZipResponse streams a zip of the given uids to the http.ResponseWriter using an io.Pipe
 // to avoid buffering the entire archive in memory.
-func streamZipResponse(res http.ResponseWriter, req *http.Request, db *gorm.DB, logger *slog.Logger, uids []string, filename string) {
+func streamZipResponse(res http.ResponseWriter, req *http.Request, db *gorm.DB, logger *slog.Logger, uids []string, filename string, token *entities.DownloadToken) {
 	if filename == "" {
 		filename = fmt.Sprintf("%s_export_%s.zip", utils.AppName, time.Now().Format("20060102T150405"))
 	}
@@ -101,7 +151,7 @@ func streamZipResponse(res http.ResponseWriter, req *http.Request, db *gorm.DB,
 	go func() {
 		// Ensure any writer-side errors are propagated to the reader via CloseWithError
 		zw := zip.NewWriter(pw)
-		if err := writeImagesToZip(req.Context(), db, logger, zw, uids); err != nil {
+		if err := writeImagesToZip(req.Context(), db, logger, zw, uids, token); err != nil {
 			logger.Error("error while creating zip", slog.Any("error", err))
 			_ = zw.Close()
 			_ = pw.CloseWithError(err)
@@ -142,6 +192,39 @@ func DownloadRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 			return
 		}
 
+		// A collection expands to its images at signing time. For smart
+		// collections that means whatever its saved search matches right now.
+		if body.CollectionUid != nil && *body.CollectionUid != "" {
+			var collection entities.Collection
+			if err := db.Preload("SavedSearch").First(&collection, "uid = ?", *body.CollectionUid).Error; err != nil {
+				render.Status(req, http.StatusNotFound)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Collection not found"})
+				return
+			}
+
+			viewerID := requestUserID(req)
+			if collection.Private != nil && *collection.Private && (collection.OwnerID == nil || *collection.OwnerID != viewerID) {
+				render.Status(req, http.StatusNotFound)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Collection not found"})
+				return
+			}
+
+			collectionUIDs, err := collectionImageUIDs(db, collection, viewerID)
+			if err != nil {
+				logger.Error("failed to resolve collection images for download", slog.Any("error", err))
+				render.Status(req, http.StatusInternalServerError)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Failed to resolve collection images"})
+				return
+			}
+
+			uids := collectionUIDs
+			if body.Uids != nil {
+				uids = append(*body.Uids, collectionUIDs...)
+			}
+
+			body.Uids = &uids
+		}
+
 		// require uids present
 		if body.Uids == nil || len(*body.Uids) == 0 {
 			render.Status(req, http.StatusBadRequest)
@@ -171,6 +254,33 @@ func DownloadRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 			opts.Description = *body.Description
 		}
 
+		// The signer's profile is copied onto the token, the link keeps its
+		// watermark when the profile changes later
+		if body.Watermark != nil && *body.Watermark {
+			signerID := requestUserID(req)
+			if signerID == "" {
+				render.Status(req, http.StatusUnauthorized)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Sign in to create a watermarked link"})
+				return
+			}
+
+			profile, err := settings.GetWatermarkProfile(db, &signerID)
+			if err != nil {
+				logger.Error("failed to fetch watermark profile", slog.Any("error", err))
+				render.Status(req, http.StatusInternalServerError)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Failed to fetch watermark profile"})
+				return
+			}
+
+			if transform.WatermarkDrawsNothing(profile) {
+				render.Status(req, http.StatusBadRequest)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Set up a watermark before creating a watermarked link"})
+				return
+			}
+
+			opts.Watermark = &profile
+		}
+
 		token, err := downloads.CreateTokenWithOptions(db, *body.Uids, opts)
 		if err != nil {
 			logger.Error("failed to create download token", slog.Any("error", err))
@@ -251,7 +361,7 @@ func DownloadRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 		if body.FileName != nil {
 			filename = *body.FileName
 		}
-		streamZipResponse(res, req, db, logger, body.Uids, filename)
+		streamZipResponse(res, req, db, logger, body.Uids, filename, tokenEntity)
 	})
 
 	return router
@@ -0,0 +1,213 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"gorm.io/gorm"
+
+	"viz/internal/config"
+	"viz/internal/dto"
+	"viz/internal/entities"
+	libhttp "viz/internal/http"
+	"viz/internal/iiif"
+	"viz/internal/imageops"
+	"viz/internal/images"
+	"viz/internal/transform"
+)
+
+// IIIFRouter serves images through the IIIF Image API 3.0. Requests are
+// rendered from an image's tile pyramid when it has one and otherwise from
+// its original, both streamed from storage so only the pixels a request
+// covers are decoded.
+func IIIFRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
+	router := chi.NewRouter()
+
+	router.Get("/{uid}", func(res http.ResponseWriter, req *http.Request) {
+		http.Redirect(res, req, req.URL.Path+"/info.json", http.StatusSeeOther)
+	})
+
+	router.Get("/{uid}/info.json", func(res http.ResponseWriter, req *http.Request) {
+		imgEnt, ok := iiifImageAsset(res, req, db, logger)
+		if !ok {
+			return
+		}
+
+		img, err := openIIIFImage(req.Context(), imgEnt)
+		if err != nil {
+			logger.Error("failed to open image for iiif", slog.String("uid", imgEnt.Uid), slog.Any("error", err))
+			render.Status(req, http.StatusInternalServerError)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to read image"})
+			return
+		}
+		defer img.Close()
+
+		info := iiif.NewInfo(iiifBaseURI(req, imgEnt.Uid), img.Width(), img.Height())
+
+		res.Header().Set("Content-Type", `application/ld+json;profile="http://iiif.io/api/image/3/context.json"`)
+		res.Header().Set("Access-Control-Allow-Origin", "*")
+		res.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", config.AppConfig.Cache.Images.HTTPMaxAgeSeconds))
+		render.Status(req, http.StatusOK)
+		render.JSON(res, req, info)
+	})
+
+	router.Get("/{uid}/{region}/{size}/{rotation}/{qualityFormat}", func(res http.ResponseWriter, req *http.Request) {
+		iiifReq, err := iiif.ParseRequest(
+			chi.URLParam(req, "region"),
+			chi.URLParam(req, "size"),
+			chi.URLParam(req, "rotation"),
+			chi.URLParam(req, "qualityFormat"),
+		)
+		if err != nil {
+			render.Status(req, http.StatusBadRequest)
+			render.JSON(res, req, dto.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		imgEnt, ok := iiifImageAsset(res, req, db, logger)
+		if !ok {
+			return
+		}
+
+		img, err := openIIIFImage(req.Context(), imgEnt)
+		if err != nil {
+			logger.Error("failed to open image for iiif", slog.String("uid", imgEnt.Uid), slog.Any("error", err))
+			render.Status(req, http.StatusInternalServerError)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to read image"})
+			return
+		}
+		defer img.Close()
+
+		plan, err := iiifReq.Resolve(img.Width(), img.Height())
+		if err != nil {
+			render.Status(req, http.StatusBadRequest)
+			render.JSON(res, req, dto.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		etag := iiifEtag(imgEnt, plan)
+		res.Header().Set("Access-Control-Allow-Origin", "*")
+
+		if match := req.Header.Get("If-None-Match"); match != "" && strings.Trim(match, `"`) == etag {
+			res.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		data, err := images.ReadCachedTransform(req.Context(), imgEnt, etag, plan.Format)
+		if err != nil {
+			data, err = img.Render(plan)
+			if err != nil {
+				logger.Error("failed to render iiif request", slog.String("uid", imgEnt.Uid), slog.String("plan", plan.String()), slog.Any("error", err))
+				render.Status(req, http.StatusInternalServerError)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Failed to render image"})
+				return
+			}
+
+			// Write to cache in the background
+			go func() {
+				if err := images.WriteCachedTransform(context.Background(), imgEnt, etag, plan.Format, data); err != nil {
+					logger.Warn("failed to write iiif image to cache", slog.Any("error", err))
+				}
+			}()
+		}
+
+		res.Header().Set("Content-Type", iiif.ContentTypes[plan.Format])
+		res.Header().Set("Etag", fmt.Sprintf(`"%s"`, etag))
+		res.Header().Set("Last-Modified", imgEnt.UpdatedAt.UTC().Format(http.TimeFormat))
+		// the etag changes with the original and its edits, the image for a
+		// request never does
+		res.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", config.AppConfig.Cache.Images.HTTPPermanentMaxAgeSeconds))
+		res.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		res.WriteHeader(http.StatusOK)
+		res.Write(data)
+	})
+
+	return router
+}
+
+// iiifImageAsset loads the image a IIIF request is for, it has responded
+// with an error when ok is false
+func iiifImageAsset(res http.ResponseWriter, req *http.Request, db *gorm.DB, logger *slog.Logger) (entities.ImageAsset, bool) {
+	uid := chi.URLParam(req, "uid")
+
+	var imgEnt entities.ImageAsset
+	result := db.Model(&entities.ImageAsset{}).Where("uid = ? AND deleted_at IS NULL", uid).First(&imgEnt)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			render.Status(req, http.StatusNotFound)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Image not found"})
+			return imgEnt, false
+		}
+
+		logger.Error("failed to fetch image", slog.String("uid", uid), slog.Any("error", result.Error))
+		render.Status(req, http.StatusInternalServerError)
+		render.JSON(res, req, dto.ErrorResponse{Error: "Something went wrong, please try again later"})
+		return imgEnt, false
+	}
+
+	if imgEnt.Private {
+		authUser, ok := libhttp.UserFromContext(req)
+		// Return 404 to avoid leaking existence
+		if !ok || (imgEnt.OwnerID != nil && *imgEnt.OwnerID != authUser.Uid) {
+			render.Status(req, http.StatusNotFound)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Image not found"})
+			return imgEnt, false
+		}
+	}
+
+	if imgEnt.ImageMetadata == nil {
+		logger.Error("Image metadata is missing", slog.String("uid", uid))
+		render.Status(req, http.StatusInternalServerError)
+		render.JSON(res, req, dto.ErrorResponse{Error: "Image is corrupted (missing metadata)"})
+		return imgEnt, false
+	}
+
+	return imgEnt, true
+}
+
+// openIIIFImage opens the tile pyramid of an image if it has an up to date
+// one and its original otherwise
+func openIIIFImage(ctx context.Context, imgEnt entities.ImageAsset) (*imageops.IIIFImage, error) {
+	pyramid, err := images.HasPyramid(ctx, imgEnt)
+	if err != nil {
+		return nil, err
+	}
+
+	var open imageops.Opener
+	if pyramid {
+		open = func() (io.ReadSeekCloser, error) {
+			return images.OpenPyramid(ctx, imgEnt)
+		}
+	}
+
+	return imageops.OpenIIIFImage(images.FileOpener(ctx, imgEnt), imgEnt, open)
+}
+
+// iiifEtag identifies the image rendered for a plan, it's also the key it's
+// cached under with the image's transforms
+func iiifEtag(imgEnt entities.ImageAsset, plan *iiif.Plan) string {
+	etag := imgEnt.ImageMetadata.Checksum + "-iiif:" + plan.String()
+	if imgEnt.Edits != nil && len(*imgEnt.Edits) > 0 {
+		etag += "-edits:" + transform.EditsHash(*imgEnt.Edits)
+	}
+
+	return etag
+}
+
+// iiifBaseURI returns the URI IIIF requests for an image are made against,
+// the id in its info.json
+func iiifBaseURI(req *http.Request, uid string) string {
+	scheme := "http"
+	if req.TLS != nil || req.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+
+	return fmt.Sprintf("%s://%s/api/iiif/%s", scheme, req.Host, uid)
+}
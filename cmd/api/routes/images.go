@@ -2,9 +2,11 @@ package routes
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha1"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -21,6 +23,7 @@ import (
 	"github.com/go-chi/render"
 	_ "github.com/joho/godotenv/autoload"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"viz/internal/config"
 	"viz/internal/downloads"
@@ -32,12 +35,16 @@ import (
 	"viz/internal/images"
 	"viz/internal/jobs"
 	"viz/internal/jobs/workers"
-	libos "viz/internal/os"
+	"viz/internal/mediatype"
+	"viz/internal/settings"
 	"viz/internal/transform"
 	"viz/internal/uid"
 	"viz/internal/utils"
 )
 
+var ErrImageUnauthorised = errors.New("unauthorized")
+var ErrNothingToUndo = errors.New("nothing to undo")
+
 type ImageUpload struct {
 	Name    string `json:"name,omitempty"`
 	Private bool   `json:"private"`
@@ -50,7 +57,7 @@ type ImageUploadError struct {
 	Error     string `json:"error"`
 }
 
-func createNewImageEntity(logger *slog.Logger, fileName string, libvipsImg *libvips.Image) (*entities.ImageAsset, error) {
+func createNewImageEntity(logger *slog.Logger, fileName string, format string, libvipsImg *libvips.Image) (*entities.ImageAsset, error) {
 	logger.Info("Generating ID", slog.String("file", fileName))
 	id, err := uid.Generate()
 
@@ -102,10 +109,16 @@ func createNewImageEntity(logger *slog.Logger, fileName string, libvipsImg *libv
 
 	label := dto.ImageMetadataLabelNone
 
+	pages := libvipsImg.Pages()
+	animated := mediatype.CanAnimate(format) && pages > 1
+
 	metadata := dto.ImageMetadata{
 		FileName:         fileName,
 		OriginalFileName: &fileName,
-		FileType:         string(libvipsImg.Format()),
+		FileType:         format,
+		MimeType:         utils.StringPtr(mediatype.MIMEType(format)),
+		Pages:            &pages,
+		Animated:         &animated,
 		ColorSpace:       imageops.GetColourSpaceString(libvipsImg),
 		FileModifiedAt:   fileModifiedAt,
 		FileCreatedAt:    fileCreatedAt,
@@ -116,6 +129,12 @@ func createNewImageEntity(logger *slog.Logger, fileName string, libvipsImg *libv
 	// Seed canonical rating into the stored image metadata (NULL = unrated)
 	metadata.Rating = initialRating
 
+	// RAW files are named by their extension, most are TIFF inside
+	if entities.IsRAWFile(fileName) {
+		metadata.FileType = strings.TrimPrefix(strings.ToLower(filepath.Ext(fileName)), ".")
+		metadata.MimeType = nil
+	}
+
 	// Construct paths with reasonable defaults matching the {uid}/file route params
 	originalPath := fmt.Sprintf("/images/%s/file", id)
 
@@ -150,12 +169,6 @@ func createNewImageEntity(logger *slog.Logger, fileName string, libvipsImg *libv
 	return &allImageData, nil
 }
 
-// moveDirWithFallback attempts to rename src->dst. If rename fails (e.g., cross-device),
-// it copies the directory contents to dst and removes the src directory.
-func moveDirWithFallback(src, dst string) error {
-	return libos.MoveDirWithFallback(src, dst)
-}
-
 func ImagesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 	router := chi.NewRouter()
 
@@ -307,6 +320,32 @@ func ImagesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 			return
 		}
 
+		// a preset replaces the other parameters, it's usually generated
+		// already and otherwise rendered like any other transform
+		if name := req.URL.Query().Get("preset"); name != "" {
+			presetParams, ok := images.GetPermanentTransformParams(images.PermanentTransformName(name))
+			if !ok {
+				render.Status(req, http.StatusBadRequest)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Unknown preset"})
+				return
+			}
+
+			*params = presetParams
+		}
+
+		// auto picks the format from the ones the browser accepts, so caches
+		// have to keep a response per Accept header
+		if params.Format == transform.FormatAuto {
+			res.Header().Add("Vary", "Accept")
+			params.Format = transform.NegotiateFormat(req.Header.Get("Accept"))
+		}
+
+		if err := images.LookupColourProfiles(params); err != nil {
+			render.Status(req, http.StatusBadRequest)
+			render.JSON(res, req, dto.ErrorResponse{Error: err.Error()})
+			return
+		}
+
 		var imgEnt entities.ImageAsset
 		if result := db.Model(&entities.ImageAsset{}).Where("uid = ? AND deleted_at IS NULL", uid).First(&imgEnt); result.Error != nil {
 			if result.Error == gorm.ErrRecordNotFound {
@@ -328,9 +367,17 @@ func ImagesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 			return
 		}
 
+		if pages := imgEnt.ImageMetadata.Pages; params.Page > 0 && pages != nil && params.Page > *pages {
+			render.Status(req, http.StatusBadRequest)
+			render.JSON(res, req, dto.ErrorResponse{Error: fmt.Sprintf("Page out of range, the image has %d", *pages)})
+			return
+		}
+
 		isDownload := req.URL.Query().Get("download") == "1"
+		var tokenEntity *entities.DownloadToken
 		if isDownload {
-			if !validateDownloadRequest(res, req, db, uid) {
+			var ok bool
+			if tokenEntity, ok = validateDownloadRequest(res, req, db, uid); !ok {
 				return
 			}
 		} else {
@@ -347,12 +394,87 @@ func ImagesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 			}
 		}
 
-		hasTransformParams := params.Format != "" || params.Width > 0 || params.Height > 0 || params.Quality > 0 || params.Rotate > 0 || params.Flip != ""
+		// A share link's watermark can't be opted out of, any other request
+		// asks for the owner's
+		var watermark *dto.WatermarkProfile
+		if tokenEntity != nil && tokenEntity.Watermark != nil {
+			watermark = tokenEntity.Watermark
+		} else if req.URL.Query().Get("watermark") == "1" {
+			profile, err := settings.GetWatermarkProfile(db, imgEnt.OwnerID)
+			if err != nil {
+				logger.Error("failed to fetch watermark profile", slog.Any("error", err))
+				render.Status(req, http.StatusInternalServerError)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Failed to fetch watermark profile"})
+				return
+			}
+
+			if transform.WatermarkDrawsNothing(profile) {
+				render.Status(req, http.StatusBadRequest)
+				render.JSON(res, req, dto.ErrorResponse{Error: "The image owner has no watermark set up"})
+				return
+			}
+
+			watermark = &profile
+		}
+
+		if watermark != nil {
+			params.Watermark = &transform.Watermark{Profile: *watermark}
+
+			// a watermarked original is a transform in the original's format,
+			// or JPEG when libvips can't write that
+			if params.Format == "" {
+				params.Format = imageops.WatermarkFormat(imgEnt.ImageMetadata.FileType)
+			}
+		}
+
+		// colour options alone are a transform in the original's format,
+		// like a watermark
+		if params.Format == "" && params.ManagesColour() {
+			params.Format = imageops.WatermarkFormat(imgEnt.ImageMetadata.FileType)
+		}
+
+		viewerID := requestUserID(req)
+		limit, err := metadataLimit(db, viewerID, &imgEnt, tokenEntity)
+		if err != nil {
+			logger.Error("failed to fetch metadata policy", slog.Any("error", err))
+			render.Status(req, http.StatusInternalServerError)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to fetch metadata policy"})
+			return
+		}
+
+		// signed in viewers can be served metadata others aren't, caches
+		// mustn't hand their responses to anyone else
+		if viewerID != "" {
+			res.Header().Add("Vary", "Authorization, Cookie")
+		}
+
+		hasTransformParams := params.Format != "" || params.Width > 0 || params.Height > 0 || params.Quality > 0 || params.Rotate > 0 || params.Flip != "" ||
+			params.Fit != "" || params.Crop != nil || params.Gravity != "" || params.Watermark != nil || params.Develop != "" || params.Page > 0
+
+		// Transforms keep no metadata unless asked to, originals keep it
+		// all. Either is cut down to what the requester may see, an
+		// original that loses any is a transform in its own format.
+		if !hasTransformParams && params.Metadata == "" {
+			params.Metadata = transform.MetadataKeep
+		}
+		params.Metadata = transform.StricterMetadata(params.Metadata, limit)
+
+		if !hasTransformParams && params.Metadata != transform.MetadataKeep {
+			params.Format = imageops.WatermarkFormat(imgEnt.ImageMetadata.FileType)
+			hasTransformParams = true
+		}
+
 		if !hasTransformParams {
 			serveOriginalImage(res, req, logger, &imgEnt, isDownload)
 			return
 		}
 
+		// transforms without a format are in the original's, or JPEG for
+		// the ones libvips can't write, like camera RAW files and PSDs
+		if params.Format == "" {
+			params.Format = imageops.WatermarkFormat(imgEnt.ImageMetadata.FileType)
+		}
+
 		serveTransformedImage(res, req, logger, &imgEnt, params, isDownload)
 	})
 
@@ -393,26 +515,74 @@ func ImagesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 			return
 		}
 
-		imageFile, err := images.ReadImage(imgEnt.Uid, imgEnt.ImageMetadata.FileName)
+		libvipsImg, err := imageops.LoadOriginal(images.FileOpener(req.Context(), imgEnt), imgEnt, nil)
 		if err != nil {
 			render.Status(req, http.StatusInternalServerError)
-			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to read image"})
+			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to process image"})
 			return
 		}
+		defer libvipsImg.Close()
 
-		libvipsImg, err := libvips.NewImageFromBuffer(imageFile, libvips.DefaultLoadOptions())
+		exifData := libvipsImg.Exif()
+
+		render.Status(req, http.StatusOK)
+		render.JSON(res, req, exifData)
+	})
+
+	router.Get("/{uid}/pipeline", func(res http.ResponseWriter, req *http.Request) {
+		uid := chi.URLParam(req, "uid")
+
+		var imgEnt entities.ImageAsset
+		result := db.Model(&entities.ImageAsset{}).Where("uid = ? AND deleted_at IS NULL", uid).First(&imgEnt)
+
+		if result.Error != nil {
+			if result.Error == gorm.ErrRecordNotFound {
+				render.Status(req, http.StatusNotFound)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Image not found"})
+				return
+			}
 
-		if err != nil {
 			render.Status(req, http.StatusInternalServerError)
-			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to process image"})
+			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to retrieve image"})
 			return
 		}
-		defer libvipsImg.Close()
 
-		exifData := libvipsImg.Exif()
+		// Access Control: same as viewing the image
+		if imgEnt.Private {
+			authUser, ok := libhttp.UserFromContext(req)
+			if !ok || (imgEnt.OwnerID != nil && *imgEnt.OwnerID != authUser.Uid) {
+				render.Status(req, http.StatusNotFound)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Image not found"})
+				return
+			}
+		}
+
+		var pipeline entities.Pipeline
+		if err := db.Where("image_uid = ?", uid).Order("created_at desc").First(&pipeline).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				render.Status(req, http.StatusNotFound)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Pipeline not found"})
+				return
+			}
+
+			libhttp.ServerError(res, req, err, logger, nil,
+				"Failed to get pipeline",
+				"Something went wrong, please try again later",
+			)
+			return
+		}
+
+		resp, err := pipelineResponse(db, pipeline)
+		if err != nil {
+			libhttp.ServerError(res, req, err, logger, nil,
+				"Failed to get pipeline steps",
+				"Something went wrong, please try again later",
+			)
+			return
+		}
 
 		render.Status(req, http.StatusOK)
-		render.JSON(res, req, exifData)
+		render.JSON(res, req, resp)
 	})
 
 	router.Get("/{uid}", func(res http.ResponseWriter, req *http.Request) {
@@ -459,6 +629,12 @@ func ImagesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 			return
 		}
 
+		if fp := update.FocalPoint; fp != nil && (fp.X < 0 || fp.X > 1 || fp.Y < 0 || fp.Y > 1) {
+			render.Status(req, http.StatusBadRequest)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Focal point coordinates must be between 0 and 1"})
+			return
+		}
+
 		var img entities.ImageAsset
 		err := db.Transaction(func(tx *gorm.DB) error {
 			if e := tx.First(&img, "uid = ? AND deleted_at IS NULL", uid); e.Error != nil {
@@ -473,7 +649,9 @@ func ImagesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 
 			updateImageFromDTO(&img, update)
 
-			if err := tx.Save(&img).Error; err != nil {
+			// the storage location is only changed by storage migrations and
+			// the edit stack by its own routes
+			if err := tx.Omit("storage_location", "edits").Save(&img).Error; err != nil {
 				return err
 			}
 
@@ -505,7 +683,7 @@ func ImagesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 		}
 
 		logger.Info("triggering background xmp update", slog.String("uid", img.Uid))
-		_, err = jobs.Enqueue(db, workers.TopicXMPGeneration, &workers.XMPGenerationJob{Image: img}, nil, &img.Uid)
+		_, err = jobs.Enqueue(db, workers.TopicXMPGeneration, &workers.XMPGenerationJob{Image: img}, nil, &img.Uid, jobs.PriorityNormal, img.OwnerID)
 		if err != nil {
 			logger.Error("failed to enqueue xmp generation job", slog.Any("error", err))
 		}
@@ -514,6 +692,72 @@ func ImagesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 		render.JSON(res, req, img.DTO())
 	})
 
+	router.Get("/{uid}/edits", func(res http.ResponseWriter, req *http.Request) {
+		uid := chi.URLParam(req, "uid")
+
+		var img entities.ImageWithEditHistory
+		if err := db.Where("uid = ? AND deleted_at IS NULL", uid).First(&img).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				render.Status(req, http.StatusNotFound)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Image not found"})
+				return
+			}
+
+			render.Status(req, http.StatusInternalServerError)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to retrieve image"})
+			return
+		}
+
+		// Access Control: If private, only owner can view
+		if img.Private {
+			authUser, ok := libhttp.UserFromContext(req)
+			if !ok || (img.OwnerID != nil && *img.OwnerID != authUser.Uid) {
+				render.Status(req, http.StatusNotFound)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Image not found"})
+				return
+			}
+		}
+
+		render.Status(req, http.StatusOK)
+		render.JSON(res, req, images.EditStack(img))
+	})
+
+	router.Put("/{uid}/edits", func(res http.ResponseWriter, req *http.Request) {
+		var update dto.ImageEditStackUpdate
+		if err := render.DecodeJSON(req.Body, &update); err != nil {
+			render.Status(req, http.StatusBadRequest)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Invalid request body"})
+			return
+		}
+
+		if err := transform.ValidateEdits(update.Edits); err != nil {
+			render.Status(req, http.StatusBadRequest)
+			render.JSON(res, req, dto.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		changeImageEdits(res, req, db, logger, func(img *entities.ImageWithEditHistory) (bool, error) {
+			return images.ReplaceEdits(img, update.Edits), nil
+		})
+	})
+
+	router.Post("/{uid}/edits/undo", func(res http.ResponseWriter, req *http.Request) {
+		changeImageEdits(res, req, db, logger, func(img *entities.ImageWithEditHistory) (bool, error) {
+			if !images.UndoEdits(img) {
+				return false, ErrNothingToUndo
+			}
+
+			return true, nil
+		})
+	})
+
+	// Resetting is undoable like any other change
+	router.Delete("/{uid}/edits", func(res http.ResponseWriter, req *http.Request) {
+		changeImageEdits(res, req, db, logger, func(img *entities.ImageWithEditHistory) (bool, error) {
+			return images.ReplaceEdits(img, nil), nil
+		})
+	})
+
 	// Dedicated download route: creates a short-lived signed redirect to the
 	// file endpoint with download=1 so clients (or browsers) can follow a URL
 	// that forces a download and is authorized by HMAC signature.
@@ -532,6 +776,168 @@ func ImagesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 		http.Redirect(res, req, redirectURL, http.StatusFound)
 	})
 
+	router.Get("/{uid}/srcset", func(res http.ResponseWriter, req *http.Request) {
+		uid := chi.URLParam(req, "uid")
+		q := req.URL.Query()
+
+		var breakpoints []int
+		if widthsParam := q.Get("widths"); widthsParam != "" {
+			for _, part := range strings.Split(widthsParam, ",") {
+				w, err := strconv.Atoi(strings.TrimSpace(part))
+				if err != nil || w <= 0 {
+					render.Status(req, http.StatusBadRequest)
+					render.JSON(res, req, dto.ErrorResponse{Error: "Widths must be positive integers"})
+					return
+				}
+				breakpoints = append(breakpoints, w)
+			}
+		}
+
+		format := q.Get("format")
+		if format == "" {
+			format = transform.FormatAuto
+		}
+		if !slices.Contains([]string{transform.FormatAuto, "webp", "png", "jpg", "jpeg", "avif", "heif", "jxl"}, format) {
+			render.Status(req, http.StatusBadRequest)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Invalid format"})
+			return
+		}
+
+		var quality int64
+		if qualityParam := q.Get("quality"); qualityParam != "" {
+			var err error
+			quality, err = strconv.ParseInt(qualityParam, 10, 64)
+			if err != nil || quality < 0 || quality > 100 {
+				render.Status(req, http.StatusBadRequest)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Quality must be between 0 and 100"})
+				return
+			}
+		}
+
+		sizes := q.Get("sizes")
+		if sizes == "" {
+			sizes = config.AppConfig.Responsive.Sizes
+		}
+
+		var imgEnt entities.ImageAsset
+		if result := db.Model(&entities.ImageAsset{}).Where("uid = ? AND deleted_at IS NULL", uid).First(&imgEnt); result.Error != nil {
+			if result.Error == gorm.ErrRecordNotFound {
+				render.Status(req, http.StatusNotFound)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Image not found"})
+				return
+			}
+
+			logger.Error("failed to fetch image from database", slog.String("uid", uid), slog.Any("error", result.Error))
+			render.Status(req, http.StatusInternalServerError)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to fetch image from database"})
+			return
+		}
+
+		// Access Control: If private, only owner can view
+		if imgEnt.Private {
+			authUser, ok := libhttp.UserFromContext(req)
+			if !ok || (imgEnt.OwnerID != nil && *imgEnt.OwnerID != authUser.Uid) {
+				render.Status(req, http.StatusNotFound)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Image not found"})
+				return
+			}
+		}
+
+		// the stored size is before auto-rotation, images turned on their
+		// side by their orientation are displayed as wide as they're tall
+		maxWidth := int(imgEnt.Width)
+		if imgEnt.Exif != nil && imgEnt.Exif.Orientation != nil {
+			if orientation, err := imageops.ConvertOrientation(*imgEnt.Exif.Orientation); err == nil && orientation >= 5 {
+				maxWidth = int(imgEnt.Height)
+			}
+		}
+		widths := transform.SrcsetWidths(config.AppConfig.Responsive.Widths, breakpoints, maxWidth)
+
+		srcset := dto.ImageSrcset{Sizes: sizes, Sources: make([]dto.ImageSrcsetSource, 0, len(widths))}
+		candidates := make([]string, 0, len(widths))
+		for _, w := range widths {
+			params := transform.TransformParams{Format: format, Width: int64(w), Quality: quality}
+			src := fmt.Sprintf("/images/%s/file?%s", imgEnt.Uid, params.ToQueryString())
+
+			srcset.Sources = append(srcset.Sources, dto.ImageSrcsetSource{Width: w, Url: src})
+			candidates = append(candidates, fmt.Sprintf("%s %dw", src, w))
+			srcset.Src = src
+		}
+		srcset.Srcset = strings.Join(candidates, ", ")
+
+		render.Status(req, http.StatusOK)
+		render.JSON(res, req, srcset)
+	})
+
+	router.Get("/{uid}/raw", func(res http.ResponseWriter, req *http.Request) {
+		uid := chi.URLParam(req, "uid")
+
+		var imgEnt entities.ImageAsset
+		if result := db.Model(&entities.ImageAsset{}).Where("uid = ? AND deleted_at IS NULL", uid).First(&imgEnt); result.Error != nil {
+			if result.Error == gorm.ErrRecordNotFound {
+				render.Status(req, http.StatusNotFound)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Image not found"})
+				return
+			}
+
+			logger.Error("failed to fetch image from database", slog.String("uid", uid), slog.Any("error", result.Error))
+			render.Status(req, http.StatusInternalServerError)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to fetch image from database"})
+			return
+		}
+
+		// Access Control: If private, only owner can view
+		if imgEnt.Private {
+			authUser, ok := libhttp.UserFromContext(req)
+			if !ok || (imgEnt.OwnerID != nil && *imgEnt.OwnerID != authUser.Uid) {
+				render.Status(req, http.StatusNotFound)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Image not found"})
+				return
+			}
+		}
+
+		if imgEnt.ImageMetadata == nil || imgEnt.ImageMetadata.RawFileName == nil {
+			render.Status(req, http.StatusNotFound)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Image has no RAW file"})
+			return
+		}
+
+		// RAW files can't be rewritten without their metadata, only those
+		// allowed all of it get them
+		limit, err := metadataLimit(db, requestUserID(req), &imgEnt, nil)
+		if err != nil {
+			logger.Error("failed to fetch metadata policy", slog.String("uid", uid), slog.Any("error", err))
+			render.Status(req, http.StatusInternalServerError)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to fetch metadata policy"})
+			return
+		}
+
+		if limit != transform.MetadataKeep {
+			render.Status(req, http.StatusForbidden)
+			render.JSON(res, req, dto.ErrorResponse{Error: "The owner's metadata policy doesn't allow downloading the RAW file"})
+			return
+		}
+
+		rawFileName := *imgEnt.ImageMetadata.RawFileName
+		rawFile, err := images.OpenImageSeekable(req.Context(), imgEnt, rawFileName)
+		if err != nil {
+			logger.Error("failed to read RAW file", slog.String("uid", uid), slog.Any("error", err))
+			render.Status(req, http.StatusInternalServerError)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to read RAW file"})
+			return
+		}
+		defer rawFile.Close()
+
+		if imgEnt.ImageMetadata.RawChecksum != nil {
+			res.Header().Set("Etag", fmt.Sprintf(`"%s"`, *imgEnt.ImageMetadata.RawChecksum))
+		}
+		res.Header().Set("Cache-Control", "private, no-cache")
+		res.Header().Set("Content-Type", "application/octet-stream")
+		res.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, rawFileName))
+
+		http.ServeContent(res, req, rawFileName, imgEnt.UpdatedAt, rawFile)
+	})
+
 	router.Post("/", func(res http.ResponseWriter, req *http.Request) {
 		var fileImageUpload dto.ImageUploadRequest
 
@@ -592,7 +998,14 @@ func ImagesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 			}
 		}
 
-		libvipsImg, err := libvips.NewImageFromBuffer(imageFileData, libvips.DefaultLoadOptions())
+		format, ok := uploadFormat(fileImageUpload.FileName, imageFileData)
+		if !ok {
+			render.Status(req, http.StatusBadRequest)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Unsupported image format"})
+			return
+		}
+
+		libvipsImg, err := loadUploadedImage(fileImageUpload.FileName, imageFileData)
 		if err != nil {
 			render.Status(req, http.StatusBadRequest)
 			render.JSON(res, req, dto.ErrorResponse{Error: "Invalid image data"})
@@ -600,7 +1013,7 @@ func ImagesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 		}
 		defer libvipsImg.Close()
 
-		imageEntity, err := createNewImageEntity(logger, fileImageUpload.FileName, libvipsImg)
+		imageEntity, err := createNewImageEntity(logger, fileImageUpload.FileName, format, libvipsImg.Image)
 		if err != nil {
 			logger.Error("Failed to process image data", slog.Any("error", err))
 			render.Status(req, http.StatusInternalServerError)
@@ -628,9 +1041,12 @@ func ImagesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 		fileSize := int64(len(imageFileData))
 		imageEntity.ImageMetadata.FileSize = &fileSize
 		imageEntity.ImageMetadata.Checksum = checksum
+		if images.IsRAWOnly(*imageEntity) {
+			images.SetRAWFile(imageEntity, imageEntity.ImageMetadata.FileName, checksum)
+		}
 
 		var existing entities.ImageAsset
-		dupErr := db.Where("image_metadata->>'checksum' = ?", checksum).First(&existing).Error
+		dupErr := db.Where("image_metadata->>'checksum' = ? OR image_metadata->>'raw_checksum' = ?", checksum, checksum).First(&existing).Error
 		if dupErr == nil {
 			render.Status(req, http.StatusOK)
 			render.JSON(res, req, dto.ImageUploadResponse{Uid: existing.Uid})
@@ -642,6 +1058,29 @@ func ImagesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 			return
 		}
 
+		paired, err := pairUpload(req.Context(), db, logger, *imageEntity, imageFileData)
+		if err != nil {
+			logger.Error("Failed to pair image", slog.Any("error", err))
+			render.Status(req, http.StatusInternalServerError)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to pair image"})
+			return
+		}
+
+		if paired != nil {
+			logger.Info("paired upload with image", slog.String("id", paired.Uid), slog.String("file", fileImageUpload.FileName))
+
+			render.Status(req, http.StatusOK)
+			render.JSON(res, req, dto.ImageUploadResponse{
+				Uid: paired.Uid,
+				Metadata: &map[string]interface{}{
+					"file_name": fileImageUpload.FileName,
+					"duplicate": false,
+					"paired":    true,
+				},
+			})
+			return
+		}
+
 		logger.Info("adding images to database", slog.String("uid", imageEntity.Uid))
 		dbCreateTx := db.Create(&imageEntity)
 		if dbCreateTx.Error != nil {
@@ -652,11 +1091,8 @@ func ImagesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 		}
 
 		logger.Info("starting image processing", slog.String("uid", imageEntity.Uid))
-		workerJob := &workers.ImageProcessJob{
-			Image: *imageEntity,
-		}
 
-		err = images.SaveImage(imageFileData, imageEntity.Uid, imageEntity.ImageMetadata.FileName)
+		err = images.SaveImage(req.Context(), imageFileData, *imageEntity, imageEntity.ImageMetadata.FileName)
 		if err != nil {
 			logger.Error("Failed to save image", slog.Any("error", err))
 			render.Status(req, http.StatusInternalServerError)
@@ -664,7 +1100,7 @@ func ImagesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 			return
 		}
 
-		jobUid, err := jobs.Enqueue(db, workers.TopicImageProcess, workerJob, nil, &imageEntity.Uid)
+		pipeline, err := jobs.EnqueuePipeline(db, workers.PipelineUpload, workers.UploadPipeline(*imageEntity), &imageEntity.Uid, jobs.PriorityInteractive, imageEntity.OwnerID)
 		if err != nil {
 			logger.Error("Failed to create image", slog.Any("error", err))
 			render.Status(req, http.StatusInternalServerError)
@@ -672,15 +1108,22 @@ func ImagesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 			return
 		}
 
+		// job_uid predates pipelines, it's still the image_process job
+		jobUid, err := jobs.PipelineStepUid(db, pipeline.Uid, workers.TopicImageProcess)
+		if err != nil {
+			logger.Warn("failed to fetch the image_process job of the upload pipeline", slog.String("pipeline_uid", pipeline.Uid), slog.Any("error", err))
+		}
+
 		logger.Info("upload images success", slog.String("id", imageEntity.Uid))
 
 		render.Status(req, http.StatusCreated)
 		render.JSON(res, req, dto.ImageUploadResponse{
 			Uid: imageEntity.Uid,
 			Metadata: &map[string]interface{}{
-				"job_uid":   jobUid,
-				"file_name": fileImageUpload.FileName,
-				"duplicate": dupErr == nil,
+				"job_uid":      jobUid,
+				"pipeline_uid": pipeline.Uid,
+				"file_name":    fileImageUpload.FileName,
+				"duplicate":    dupErr == nil,
 			},
 		})
 	})
@@ -728,14 +1171,21 @@ func ImagesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 		}
 
 		fileName, _ := strings.CutPrefix(urlParsed.Path, "/")
-		libvipsImg, err := libvips.NewImageFromBuffer(fileBytes, libvips.DefaultLoadOptions())
+		format, ok := uploadFormat(fileName, fileBytes)
+		if !ok {
+			render.Status(req, http.StatusBadRequest)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Unsupported image format"})
+			return
+		}
+
+		libvipsImg, err := loadUploadedImage(fileName, fileBytes)
 		if err != nil {
 			render.Status(req, http.StatusBadRequest)
 			render.JSON(res, req, dto.ErrorResponse{Error: "Invalid request body"})
 			return
 		}
 		defer libvipsImg.Close()
-		imageEntity, err := createNewImageEntity(logger, fileName, libvipsImg)
+		imageEntity, err := createNewImageEntity(logger, fileName, format, libvipsImg.Image)
 
 		if err != nil {
 			logger.Error("Failed to process image data", slog.Any("error", err))
@@ -761,9 +1211,12 @@ func ImagesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 		imageEntity.ImageMetadata.FileSize = &fileSize
 		checksum := hex.EncodeToString(hasher.Sum(nil))
 		imageEntity.ImageMetadata.Checksum = checksum
+		if images.IsRAWOnly(*imageEntity) {
+			images.SetRAWFile(imageEntity, imageEntity.ImageMetadata.FileName, checksum)
+		}
 
 		var existing entities.ImageAsset
-		dupErr := db.Where("image_metadata->>'checksum' = ?", checksum).First(&existing).Error
+		dupErr := db.Where("image_metadata->>'checksum' = ? OR image_metadata->>'raw_checksum' = ?", checksum, checksum).First(&existing).Error
 		if dupErr == nil {
 			// Duplicate: return existing UID as an ImageUploadResponse (200)
 			render.Status(req, http.StatusOK)
@@ -776,6 +1229,20 @@ func ImagesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 			return
 		}
 
+		paired, err := pairUpload(req.Context(), db, logger, *imageEntity, fileBytes)
+		if err != nil {
+			logger.Error("Failed to pair image", slog.Any("error", err))
+			render.Status(req, http.StatusInternalServerError)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to pair image"})
+			return
+		}
+
+		if paired != nil {
+			render.Status(req, http.StatusOK)
+			render.JSON(res, req, dto.ImageUploadResponse{Uid: paired.Uid})
+			return
+		}
+
 		logger.Info("adding image to database", slog.String("id", imageEntity.Uid))
 		dbCreateTx := db.Create(&imageEntity)
 
@@ -787,11 +1254,8 @@ func ImagesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 		}
 
 		logger.Info("starting image processing", slog.String("id", imageEntity.Uid))
-		workerJob := &workers.ImageProcessJob{
-			Image: *imageEntity,
-		}
 
-		err = images.SaveImage(fileBytes, imageEntity.Uid, imageEntity.ImageMetadata.FileName)
+		err = images.SaveImage(req.Context(), fileBytes, *imageEntity, imageEntity.ImageMetadata.FileName)
 		if err != nil {
 			logger.Error("Failed to process image", slog.Any("error", err))
 			render.Status(req, http.StatusInternalServerError)
@@ -799,7 +1263,7 @@ func ImagesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 			return
 		}
 
-		_, err = jobs.Enqueue(db, workers.TopicImageProcess, workerJob, nil, &imageEntity.Uid)
+		_, err = jobs.EnqueuePipeline(db, workers.PipelineUpload, workers.UploadPipeline(*imageEntity), &imageEntity.Uid, jobs.PriorityInteractive, imageEntity.OwnerID)
 		if err != nil {
 			logger.Error("Failed to process image", slog.Any("error", err))
 			render.Status(req, http.StatusInternalServerError)
@@ -831,17 +1295,6 @@ func ImagesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 			return
 		}
 
-		libDir := images.Directory
-		trashDir := images.TrashDirectory
-
-		if !body.Force {
-			if err := os.MkdirAll(trashDir, 0755); err != nil {
-				render.Status(req, http.StatusInternalServerError)
-				render.JSON(res, req, dto.DeleteAssetsResponse{Results: nil})
-				return
-			}
-		}
-
 		resultsArr := make([]map[string]any, 0, len(body.Uids))
 		var anyFailed bool
 
@@ -854,13 +1307,12 @@ func ImagesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 		}
 
 		for _, id := range body.Uids {
-			src := filepath.Join(libDir, id)
 			var deleted bool
 			var errMsg *string
 
 			// Check ownership before deleting
-			var img entities.ImageAsset
-			if err := db.Select("owner_id").First(&img, "uid = ?", id).Error; err != nil {
+			img := entities.ImageAsset{Uid: id}
+			if err := db.Select("owner_id", "storage_location").First(&img, "uid = ?", id).Error; err != nil {
 				if err != gorm.ErrRecordNotFound {
 					logger.Error("failed to check ownership", slog.String("uid", id), slog.Any("error", err))
 					e := "failed to check ownership"
@@ -889,7 +1341,7 @@ func ImagesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 					errMsg = &e
 					deleted = false
 					anyFailed = true
-				} else if err := os.RemoveAll(src); err != nil {
+				} else if err := images.DeleteImageDir(req.Context(), img); err != nil {
 					logger.Error("failed to force delete asset dir", slog.String("uid", id), slog.Any("error", err))
 					e := err.Error()
 					errMsg = &e
@@ -907,8 +1359,7 @@ func ImagesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 					deleted = false
 					anyFailed = true
 				} else {
-					dst := filepath.Join(trashDir, id)
-					if err := moveDirWithFallback(src, dst); err != nil {
+					if err := images.MoveToTrash(req.Context(), img); err != nil {
 						logger.Error("failed to move asset to trash", slog.String("uid", id), slog.Any("error", err))
 						e := err.Error()
 						errMsg = &e
@@ -977,16 +1428,21 @@ func ImagesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 }
 
 func serveOriginalImage(res http.ResponseWriter, req *http.Request, logger *slog.Logger, imgEnt *entities.ImageAsset, isDownload bool) {
-	imageData, err := images.ReadImage(imgEnt.Uid, imgEnt.ImageMetadata.FileName)
+	imageFile, err := images.OpenImageSeekable(req.Context(), *imgEnt, imgEnt.ImageMetadata.FileName)
 	if err != nil {
 		logger.Error("failed to read original image", slog.Any("error", err))
 		render.Status(req, http.StatusInternalServerError)
 		render.JSON(res, req, dto.ErrorResponse{Error: "Failed to read original image"})
 		return
 	}
+	defer imageFile.Close()
 
 	res.Header().Set("Etag", fmt.Sprintf(`"%s"`, imgEnt.ImageMetadata.Checksum))
 	res.Header().Set("Last-Modified", imgEnt.UpdatedAt.UTC().Format(http.TimeFormat))
+	// the name of the file says little about HEIC, JXL or PSD files
+	if imgEnt.ImageMetadata.MimeType != nil {
+		res.Header().Set("Content-Type", *imgEnt.ImageMetadata.MimeType)
+	}
 	// Prevent XSS if the image is an SVG or other dangerous type
 	res.Header().Set("Content-Security-Policy", "sandbox")
 
@@ -997,13 +1453,14 @@ func serveOriginalImage(res http.ResponseWriter, req *http.Request, logger *slog
 		res.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, immutable", config.AppConfig.Cache.Images.HTTPPermanentMaxAgeSeconds))
 	}
 
-	http.ServeContent(res, req, imgEnt.ImageMetadata.FileName, imgEnt.UpdatedAt, bytes.NewReader(imageData))
+	http.ServeContent(res, req, imgEnt.ImageMetadata.FileName, imgEnt.UpdatedAt, imageFile)
 }
 
 func serveTransformedImage(res http.ResponseWriter, req *http.Request, logger *slog.Logger, imgEnt *entities.ImageAsset, params *transform.TransformParams, isDownload bool) {
 	// 1. Determine if this is a "permanent" transform path
 	reqURI := req.URL.String()
-	isPermanent := imgEnt.ImagePaths.Thumbnail == reqURI || imgEnt.ImagePaths.Preview == reqURI
+	// named presets are generated with the image like its thumbnail
+	isPermanent := imgEnt.ImagePaths.Thumbnail == reqURI || imgEnt.ImagePaths.Preview == reqURI || req.URL.Query().Get("preset") != ""
 
 	// Check if a 'v' (version/checksum) query parameter is present
 	hasVersionParam := req.URL.Query().Get("v") != ""
@@ -1037,11 +1494,11 @@ func serveTransformedImage(res http.ResponseWriter, req *http.Request, logger *s
 		ext = imgEnt.ImageMetadata.FileType
 	}
 
-	res.Header().Set("Content-Type", "image/"+ext)
+	res.Header().Set("Content-Type", mediatype.MIMEType(ext))
 
 	// 4. Check our server-side cache
 	cacheKey := strings.Trim(transformETag, `"`)
-	cachedData, err := images.ReadCachedTransform(imgEnt.Uid, cacheKey, ext)
+	cachedData, err := images.ReadCachedTransform(req.Context(), *imgEnt, cacheKey, ext)
 
 	if err == nil {
 		// Cache HIT: Serve the cached file
@@ -1067,7 +1524,9 @@ func serveTransformedImage(res http.ResponseWriter, req *http.Request, logger *s
 	}
 
 	// 5a. If permanent path and not in cache, it's still processing.
-	if isPermanent {
+	// Processing saves the thumbhash last, a processed image's permanent
+	// transform is missing when its ETag changed and is rendered below.
+	if isPermanent && imgEnt.ImageMetadata.Thumbhash == nil {
 		logger.Info("permanent transform not ready, telling client to retry", slog.String("path", reqURI), slog.String("uid", imgEnt.Uid))
 		res.Header().Set("Retry-After", "10") // Tell client to retry after 10 seconds
 		res.WriteHeader(http.StatusAccepted)
@@ -1076,15 +1535,23 @@ func serveTransformedImage(res http.ResponseWriter, req *http.Request, logger *s
 
 	// 5b. If not permanent, generate on-the-fly
 	logger.Info("server-side cache miss, generating on-demand transform", slog.String("key", cacheKey))
-	originalData, err := images.ReadImage(imgEnt.Uid, imgEnt.ImageMetadata.FileName)
-	if err != nil {
-		logger.Error("failed to read original for transform", slog.Any("error", err))
+	if params.Watermark != nil {
+		if params.Watermark, err = images.LoadWatermark(req.Context(), params.Watermark.Profile); err != nil {
+			logger.Error("failed to load watermark", slog.Any("error", err))
+			render.Status(req, http.StatusInternalServerError)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to load watermark"})
+			return
+		}
+	}
+
+	if err := images.FetchColourProfiles(req.Context(), params); err != nil {
+		logger.Error("failed to fetch colour profiles", slog.Any("error", err))
 		render.Status(req, http.StatusInternalServerError)
-		render.JSON(res, req, dto.ErrorResponse{Error: "Failed to read original for transform"})
+		render.JSON(res, req, dto.ErrorResponse{Error: "Failed to load colour profile"})
 		return
 	}
 
-	tresult, err := imageops.GenerateTransform(params, *imgEnt, originalData)
+	tresult, err := imageops.GenerateTransformFromSource(params, *imgEnt, images.FileOpener(req.Context(), *imgEnt))
 	if err != nil {
 		logger.Error("failed to generate transform", slog.Any("error", err))
 		render.Status(req, http.StatusInternalServerError)
@@ -1094,7 +1561,7 @@ func serveTransformedImage(res http.ResponseWriter, req *http.Request, logger *s
 
 	// Write to cache in the background
 	go func() {
-		if err := images.WriteCachedTransform(imgEnt.Uid, cacheKey, ext, tresult.ImageData); err != nil {
+		if err := images.WriteCachedTransform(context.Background(), *imgEnt, cacheKey, ext, tresult.ImageData); err != nil {
 			logger.Warn("failed to write on-demand transform to cache", slog.Any("error", err))
 		}
 	}()
@@ -1119,14 +1586,16 @@ func serveTransformedImage(res http.ResponseWriter, req *http.Request, logger *s
 	res.Write(tresult.ImageData)
 }
 
-func validateDownloadRequest(res http.ResponseWriter, req *http.Request, db *gorm.DB, uid string) bool {
+// validateDownloadRequest checks the token of a download=1 request for uid
+// and returns it, it has responded with an error when ok is false
+func validateDownloadRequest(res http.ResponseWriter, req *http.Request, db *gorm.DB, uid string) (*entities.DownloadToken, bool) {
 	token := req.URL.Query().Get("token")
 	password := req.URL.Query().Get("password")
 
 	if token == "" {
 		render.Status(req, http.StatusBadRequest)
 		render.JSON(res, req, dto.ErrorResponse{Error: "Missing token query param"})
-		return false
+		return nil, false
 	}
 
 	uids, tokenEntity, ok := downloads.ValidateTokenWithPassword(db, token, password)
@@ -1134,32 +1603,133 @@ func validateDownloadRequest(res http.ResponseWriter, req *http.Request, db *gor
 		if tokenEntity != nil && tokenEntity.Password != nil {
 			render.Status(req, http.StatusUnauthorized)
 			render.JSON(res, req, dto.ErrorResponse{Error: "Invalid or missing password"})
-			return false
+			return nil, false
 		}
 		render.Status(req, http.StatusUnauthorized)
 		render.JSON(res, req, dto.ErrorResponse{Error: "Invalid or expired token"})
-		return false
+		return nil, false
 	}
 
 	if !tokenEntity.AllowDownload {
 		render.Status(req, http.StatusForbidden)
 		render.JSON(res, req, dto.ErrorResponse{Error: "Downloads not permitted for this token"})
-		return false
+		return nil, false
 	}
 
 	if !downloads.ValidateEmbedAccess(tokenEntity, req) {
 		render.Status(req, http.StatusForbidden)
 		render.JSON(res, req, dto.ErrorResponse{Error: "Embedding not allowed for this token"})
-		return false
+		return nil, false
 	}
 
 	if !slices.Contains(uids, uid) {
 		render.Status(req, http.StatusUnauthorized)
 		render.JSON(res, req, dto.ErrorResponse{Error: "Token not valid for this resource"})
-		return false
+		return nil, false
+	}
+
+	return tokenEntity, true
+}
+
+// metadataLimit returns the most of an image's metadata a request may be
+// served, one of the transform.Metadata* policies. The owner sees all of
+// it and anyone else what the owner's policy keeps, or nothing through a
+// share link hiding metadata or when the owner strips downloads.
+func metadataLimit(db *gorm.DB, viewerID string, img *entities.ImageAsset, token *entities.DownloadToken) (string, error) {
+	if token == nil && viewerID != "" && (img.OwnerID == nil || *img.OwnerID == viewerID) {
+		return transform.MetadataKeep, nil
+	}
+
+	if token != nil {
+		if !token.ShowMetadata {
+			return transform.MetadataNone, nil
+		}
+
+		strip, err := settings.GetSetting(db, settings.SettingNameStripMetadata, img.OwnerID)
+		if err != nil {
+			return "", err
+		}
+
+		if strip == "true" {
+			return transform.MetadataNone, nil
+		}
+	}
+
+	policy, err := settings.GetSetting(db, settings.SettingNameMetadataPolicy, img.OwnerID)
+	if err != nil {
+		return "", err
+	}
+
+	// an unknown policy keeps nothing
+	return transform.StricterMetadata(policy, transform.MetadataKeep), nil
+}
+
+// changeImageEdits applies change to the edit stack of the image in the
+// route, saves it and regenerates the image's permanent transforms, which
+// the edits are part of. The original file is left untouched.
+func changeImageEdits(res http.ResponseWriter, req *http.Request, db *gorm.DB, logger *slog.Logger, change func(img *entities.ImageWithEditHistory) (bool, error)) {
+	uid := chi.URLParam(req, "uid")
+
+	var img entities.ImageWithEditHistory
+	var changed bool
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// the row stays locked until the edits are saved, so concurrent
+		// changes build on each other instead of the last one winning
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&img, "uid = ? AND deleted_at IS NULL", uid).Error; err != nil {
+			return err
+		}
+
+		// Access Control: Only owner can edit
+		authUser, ok := libhttp.UserFromContext(req)
+		if !ok || (img.OwnerID != nil && *img.OwnerID != authUser.Uid) {
+			return ErrImageUnauthorised
+		}
+
+		var err error
+		changed, err = change(&img)
+		if err != nil || !changed {
+			return err
+		}
+
+		return tx.Model(&img).Select("edits", "edit_history").Updates(&img).Error
+	})
+
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			render.Status(req, http.StatusNotFound)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Image not found"})
+		case errors.Is(err, ErrImageUnauthorised):
+			render.Status(req, http.StatusForbidden)
+			render.JSON(res, req, dto.ErrorResponse{Error: "You do not have permission to edit this image"})
+		case errors.Is(err, ErrNothingToUndo):
+			render.Status(req, http.StatusConflict)
+			render.JSON(res, req, dto.ErrorResponse{Error: "There are no edits to undo"})
+		default:
+			logger.Error("failed to update image edits", slog.String("uid", uid), slog.Any("error", err))
+			render.Status(req, http.StatusInternalServerError)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Something went wrong, please try again later"})
+		}
+		return
+	}
+
+	if changed {
+		_, err = jobs.Enqueue(db, workers.TopicImageProcess, &workers.ImageProcessJob{Image: img.ImageAsset}, nil, &img.Uid, jobs.PriorityNormal, img.OwnerID)
+		if err != nil {
+			logger.Error("failed to enqueue transform regeneration after edit", slog.String("uid", uid), slog.Any("error", err))
+		}
+
+		// the pyramid of the old edits is no longer read, build the new one
+		if images.NeedsPyramid(img.ImageAsset, config.AppConfig.IIIF.PyramidMinMegapixels) {
+			_, err = jobs.Enqueue(db, workers.TopicTilePyramid, &workers.TilePyramidJob{Image: img.ImageAsset}, nil, &img.Uid, jobs.PriorityNormal, img.OwnerID)
+			if err != nil {
+				logger.Error("failed to enqueue tile pyramid after edit", slog.String("uid", uid), slog.Any("error", err))
+			}
+		}
 	}
 
-	return true
+	render.Status(req, http.StatusOK)
+	render.JSON(res, req, images.EditStack(img))
 }
 
 // updateImageFromDTO updates image entity fields from a small ImageUpdate
@@ -1180,6 +1750,10 @@ func updateImageFromDTO(image *entities.ImageAsset, update dto.ImageUpdate) {
 		image.Favourited = update.Favourited
 	}
 
+	if update.FocalPoint != nil {
+		image.FocalPoint = update.FocalPoint
+	}
+
 	if update.Exif != nil {
 		image.Exif = update.Exif
 	}
@@ -1223,3 +1797,104 @@ func updateImageFromDTO(image *entities.ImageAsset, update dto.ImageUpdate) {
 		image.OwnerID = update.OwnerUid
 	}
 }
+
+// loadUploadedImage opens an uploaded file to read its size and metadata.
+// Camera RAW files are developed, as TIFF based ones like NEF and DNG would
+// otherwise open as the preview in their first IFD, and files libvips
+// doesn't detect get the RAW loader too.
+func loadUploadedImage(fileName string, data []byte) (*imageops.SourceImage, error) {
+	if entities.IsRAWFile(fileName) {
+		return imageops.DevelopRAW(imageops.BytesOpener(data))
+	}
+
+	return imageops.LoadSource(imageops.BytesOpener(data), nil)
+}
+
+// uploadFormat identifies an upload by its magic bytes rather than its
+// name. It's one of the supported image types, or a camera RAW file in a
+// container cameras write for files named like one.
+func uploadFormat(fileName string, data []byte) (string, bool) {
+	format := mediatype.Detect(data[:min(len(data), mediatype.HeaderSize)])
+	if entities.IsRAWFile(fileName) {
+		return format, mediatype.IsRAWContainer(format)
+	}
+
+	return format, entities.IsSupportedImageType(format)
+}
+
+// pairUpload adds an uploaded file to the image of the same owner it was
+// shot with as a RAW+JPEG pair, found by the file name both share. A RAW
+// file is stored alongside the JPEG, a JPEG becomes the original of a RAW
+// only image and keeps the RAW file paired with it. It returns nil when
+// there's no image to pair with.
+func pairUpload(ctx context.Context, db *gorm.DB, logger *slog.Logger, upload entities.ImageAsset, data []byte) (*entities.ImageAsset, error) {
+	if upload.OwnerID == nil {
+		return nil, nil
+	}
+
+	meta := upload.ImageMetadata
+	isRAW := entities.IsRAWFile(meta.FileName)
+
+	var candidates []entities.ImageAsset
+	err := db.Where("owner_id = ? AND deleted_at IS NULL AND lower(image_metadata->>'file_name') LIKE ?", *upload.OwnerID, images.PairPattern(meta.FileName)).
+		Order("created_at").
+		Find(&candidates).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find images to pair with: %w", err)
+	}
+
+	var pair *entities.ImageAsset
+	for i, candidate := range candidates {
+		if candidate.ImageMetadata == nil || images.PairName(candidate.ImageMetadata.FileName) != images.PairName(meta.FileName) {
+			continue
+		}
+
+		if isRAW && !images.IsRAWOnly(candidate) && candidate.ImageMetadata.RawFileName == nil ||
+			!isRAW && images.IsRAWOnly(candidate) && !images.HasPairedRAW(candidate) {
+			pair = &candidates[i]
+			break
+		}
+	}
+
+	if pair == nil {
+		return nil, nil
+	}
+
+	if err := images.SaveImage(ctx, data, *pair, meta.FileName); err != nil {
+		return nil, fmt.Errorf("failed to save paired file: %w", err)
+	}
+
+	if isRAW {
+		images.SetRAWFile(pair, meta.FileName, meta.Checksum)
+		if err := db.Model(pair).Select("image_metadata", "image_paths").Updates(pair).Error; err != nil {
+			return nil, fmt.Errorf("failed to pair RAW file: %w", err)
+		}
+
+		return pair, nil
+	}
+
+	// the JPEG replaces the RAW file as the original everything is rendered
+	// from, the RAW file is still developed on request
+	pair.ImageMetadata.FileName = meta.FileName
+	pair.ImageMetadata.FileType = meta.FileType
+	pair.ImageMetadata.Checksum = meta.Checksum
+	pair.ImageMetadata.FileSize = meta.FileSize
+	pair.ImageMetadata.ColorSpace = meta.ColorSpace
+	pair.Width = upload.Width
+	pair.Height = upload.Height
+	if err := db.Model(pair).Select("image_metadata", "width", "height").Updates(pair).Error; err != nil {
+		return nil, fmt.Errorf("failed to pair JPEG file: %w", err)
+	}
+
+	if store, err := images.StoreFor(*pair); err != nil {
+		logger.Warn("failed to open storage of paired image", slog.String("uid", pair.Uid), slog.Any("error", err))
+	} else if err := images.PurgeTransformsForUID(ctx, store, pair.Uid); err != nil {
+		logger.Warn("failed to purge transforms of paired image", slog.String("uid", pair.Uid), slog.Any("error", err))
+	}
+
+	if _, err := jobs.EnqueuePipeline(db, workers.PipelineUpload, workers.UploadPipeline(*pair), &pair.Uid, jobs.PriorityInteractive, pair.OwnerID); err != nil {
+		return nil, fmt.Errorf("failed to reprocess paired image: %w", err)
+	}
+
+	return pair, nil
+}
@@ -1,11 +1,11 @@
 package routes
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
-	"os"
-	"path/filepath"
 	"slices"
 	"strings"
 
@@ -13,6 +13,7 @@ import (
 	"github.com/go-chi/render"
 	"gorm.io/gorm"
 
+	"viz/internal/config"
 	"viz/internal/dto"
 	"viz/internal/entities"
 	libhttp "viz/internal/http"
@@ -20,12 +21,15 @@ import (
 	"viz/internal/images"
 	"viz/internal/jobs"
 	"viz/internal/jobs/workers"
+	"viz/internal/storage"
 	"viz/internal/transform"
 )
 
 type ActiveBrief struct {
 	Uid      string         `json:"uid"`
 	ImageUid *string        `json:"image_uid,omitempty"`
+	OwnerUid string         `json:"owner_uid"`
+	Priority jobs.Priority  `json:"priority"`
 	Topic    string         `json:"topic"`
 	Type     string         `json:"type"`
 	Status   jobs.JobStatus `json:"status"`
@@ -51,7 +55,7 @@ func handleImageProcessing(db *gorm.DB, logger *slog.Logger, body dto.WorkerJobC
 		}
 
 		job := &workers.ImageProcessJob{Image: img}
-		_, err := jobs.Enqueue(db, workers.TopicImageProcess, job, nil, &img.Uid)
+		_, err := jobs.Enqueue(db, workers.TopicImageProcess, job, nil, &img.Uid, jobs.PriorityNormal, img.OwnerID)
 		if err != nil {
 			render.Status(req, http.StatusInternalServerError)
 			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to enqueue job"})
@@ -77,7 +81,7 @@ func handleImageProcessing(db *gorm.DB, logger *slog.Logger, body dto.WorkerJobC
 		}
 
 		// Find UIDs of images with missing permanent transforms
-		transformsMissing, err := findMissingTransforms(db, logger)
+		transformsMissing, err := findMissingTransforms(req.Context(), db, logger)
 		if err != nil {
 			render.Status(req, http.StatusInternalServerError)
 			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to identify images with missing permanent transforms"})
@@ -137,7 +141,7 @@ func handleImageProcessing(db *gorm.DB, logger *slog.Logger, body dto.WorkerJobC
 
 			for _, img := range imgs {
 				job := &workers.ImageProcessJob{Image: img}
-				_, _ = jobs.Enqueue(db, workers.TopicImageProcess, job, nil, &img.Uid)
+				_, _ = jobs.Enqueue(db, workers.TopicImageProcess, job, nil, &img.Uid, jobs.PriorityBackfill, img.OwnerID)
 			}
 		}
 		logger.Info("image processing jobs enqueued", "command", command, "count", count)
@@ -171,7 +175,7 @@ func handleXMPGeneration(db *gorm.DB, logger *slog.Logger, body dto.WorkerJobCre
 		}
 
 		job := &workers.XMPGenerationJob{Image: img}
-		_, err := jobs.Enqueue(db, workers.TopicXMPGeneration, job, nil, &img.Uid)
+		_, err := jobs.Enqueue(db, workers.TopicXMPGeneration, job, nil, &img.Uid, jobs.PriorityNormal, img.OwnerID)
 		if err != nil {
 			render.Status(req, http.StatusInternalServerError)
 			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to enqueue job"})
@@ -186,40 +190,33 @@ func handleXMPGeneration(db *gorm.DB, logger *slog.Logger, body dto.WorkerJobCre
 
 	switch command {
 	case "missing":
-		// Scan filesystem first to find UIDs without XMP files
-		libraryPath := images.Directory
-		entries, err := os.ReadDir(libraryPath)
+		// Scan storage first to find UIDs without XMP files
+		hasXMP := make(map[string]bool)
+		err := images.ForEachLocation(func(name string, store storage.Storage) error {
+			return store.List(req.Context(), images.LibraryPrefix, func(obj storage.ObjectInfo) error {
+				uid, name, ok := storage.SplitFolder(images.LibraryPrefix, obj.Key)
+				if !ok {
+					return nil
+				}
+
+				// only files directly in the image's folder count
+				isXMP := !strings.Contains(name, "/") && strings.HasSuffix(strings.ToLower(name), ".xmp")
+				hasXMP[uid] = hasXMP[uid] || isXMP
+				return nil
+			})
+		})
 		if err != nil {
 			render.Status(req, http.StatusInternalServerError)
 			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to read library directory"})
 			return
 		}
 
-		for _, entry := range entries {
-			if !entry.IsDir() {
-				continue
-			}
-			uid := entry.Name()
-			dirPath := filepath.Join(libraryPath, uid)
-
-			// Check if any .xmp file exists in this directory
-			files, err := os.ReadDir(dirPath)
-			if err != nil {
-				continue
-			}
-
-			hasXMP := false
-			for _, file := range files {
-				if !file.IsDir() && strings.HasSuffix(strings.ToLower(file.Name()), ".xmp") {
-					hasXMP = true
-					break
-				}
-			}
-
-			if !hasXMP {
+		for uid, ok := range hasXMP {
+			if !ok {
 				uidsWithoutXMP = append(uidsWithoutXMP, uid)
 			}
 		}
+		slices.Sort(uidsWithoutXMP)
 
 		count = int64(len(uidsWithoutXMP))
 	case "all":
@@ -266,7 +263,7 @@ func handleXMPGeneration(db *gorm.DB, logger *slog.Logger, body dto.WorkerJobCre
 
 				for _, img := range batchImgs {
 					job := &workers.XMPGenerationJob{Image: img}
-					if _, err := jobs.Enqueue(db, workers.TopicXMPGeneration, job, nil, &img.Uid); err != nil {
+					if _, err := jobs.Enqueue(db, workers.TopicXMPGeneration, job, nil, &img.Uid, jobs.PriorityNormal, img.OwnerID); err != nil {
 						logger.Error("failed to enqueue XMP job", "image_uid", img.Uid, "error", err)
 					} else {
 						processed++
@@ -279,7 +276,7 @@ func handleXMPGeneration(db *gorm.DB, logger *slog.Logger, body dto.WorkerJobCre
 			query.FindInBatches(&imgs, 100, func(tx *gorm.DB, batch int) error {
 				for _, img := range imgs {
 					job := &workers.XMPGenerationJob{Image: img}
-					if _, err := jobs.Enqueue(db, workers.TopicXMPGeneration, job, nil, &img.Uid); err != nil {
+					if _, err := jobs.Enqueue(db, workers.TopicXMPGeneration, job, nil, &img.Uid, jobs.PriorityNormal, img.OwnerID); err != nil {
 						logger.Error("failed to enqueue XMP job", "image_uid", img.Uid, "error", err)
 					} else {
 						processed++
@@ -319,7 +316,7 @@ func handleExifProcessing(db *gorm.DB, logger *slog.Logger, body dto.WorkerJobCr
 		}
 
 		job := &workers.ExifProcessJob{Image: img}
-		_, err := jobs.Enqueue(db, workers.TopicExifProcess, job, nil, &img.Uid)
+		_, err := jobs.Enqueue(db, workers.TopicExifProcess, job, nil, &img.Uid, jobs.PriorityNormal, img.OwnerID)
 		if err != nil {
 			render.Status(req, http.StatusInternalServerError)
 			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to enqueue job"})
@@ -372,7 +369,7 @@ func handleExifProcessing(db *gorm.DB, logger *slog.Logger, body dto.WorkerJobCr
 		query.FindInBatches(&imgs, 100, func(tx *gorm.DB, batch int) error {
 			for _, img := range imgs {
 				job := &workers.ExifProcessJob{Image: img}
-				_, _ = jobs.Enqueue(db, workers.TopicExifProcess, job, nil, &img.Uid)
+				_, _ = jobs.Enqueue(db, workers.TopicExifProcess, job, nil, &img.Uid, jobs.PriorityBackfill, img.OwnerID)
 			}
 			return nil
 		})
@@ -388,6 +385,105 @@ func handleExifProcessing(db *gorm.DB, logger *slog.Logger, body dto.WorkerJobCr
 	})
 }
 
+// handleTilePyramid processes tile pyramid build job requests. A single
+// image gets its pyramid built whatever its size, the bulk commands only
+// cover images at or above the configured size.
+func handleTilePyramid(db *gorm.DB, logger *slog.Logger, body dto.WorkerJobCreateRequest, res http.ResponseWriter, req *http.Request) {
+	command := string(body.Command)
+	if command == "" {
+		command = "all"
+	}
+
+	if body.Uids != nil && len(*body.Uids) == 1 {
+		var img entities.ImageAsset
+		if err := db.Where("uid = ?", (*body.Uids)[0]).First(&img).Error; err != nil {
+			render.Status(req, http.StatusNotFound)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Image not found"})
+			return
+		}
+
+		job := &workers.TilePyramidJob{Image: img}
+		_, err := jobs.Enqueue(db, workers.TopicTilePyramid, job, nil, &img.Uid, jobs.PriorityNormal, img.OwnerID)
+		if err != nil {
+			render.Status(req, http.StatusInternalServerError)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to enqueue job"})
+			return
+		}
+
+		c := 1
+		render.Status(req, http.StatusAccepted)
+		render.JSON(res, req, dto.WorkerJobEnqueueResponse{Message: "Tile pyramid job enqueued", Count: &c})
+		return
+	}
+
+	if command != "missing" && command != "all" {
+		render.Status(req, http.StatusBadRequest)
+		render.JSON(res, req, dto.ErrorResponse{Error: fmt.Sprintf("unknown command: %s", command)})
+		return
+	}
+
+	var targetUids []string
+	minMegapixels := config.AppConfig.IIIF.PyramidMinMegapixels
+	if minMegapixels > 0 {
+		var imgs []entities.ImageAsset
+		query := db.Where("CAST(width AS BIGINT) * height >= ?", int64(minMegapixels)*1_000_000)
+		err := query.FindInBatches(&imgs, 100, func(tx *gorm.DB, batch int) error {
+			for _, img := range imgs {
+				if command == "missing" {
+					ok, err := images.HasPyramid(req.Context(), img)
+					if err != nil {
+						return err
+					}
+
+					if ok {
+						continue
+					}
+				}
+
+				targetUids = append(targetUids, img.Uid)
+			}
+			return nil
+		}).Error
+		if err != nil {
+			render.Status(req, http.StatusInternalServerError)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Failed to identify images needing tile pyramids"})
+			return
+		}
+	}
+
+	if len(targetUids) == 0 {
+		zeroCount := 0
+		render.Status(req, http.StatusOK)
+		render.JSON(res, req, dto.WorkerJobEnqueueResponse{Message: "No images to process", Count: &zeroCount})
+		return
+	}
+
+	go func(uids []string) {
+		for i := 0; i < len(uids); i += 100 {
+			end := min(i+100, len(uids))
+
+			var imgs []entities.ImageAsset
+			if err := db.Where("uid IN ?", uids[i:end]).Find(&imgs).Error; err != nil {
+				logger.Error("failed to fetch images for tile pyramids", slog.Any("error", err))
+				continue
+			}
+
+			for _, img := range imgs {
+				job := &workers.TilePyramidJob{Image: img}
+				_, _ = jobs.Enqueue(db, workers.TopicTilePyramid, job, nil, &img.Uid, jobs.PriorityBackfill, img.OwnerID)
+			}
+		}
+		logger.Info("tile pyramid jobs enqueued", "command", command, "count", len(uids))
+	}(targetUids)
+
+	jobCount := len(targetUids)
+	render.Status(req, http.StatusAccepted)
+	render.JSON(res, req, dto.WorkerJobEnqueueResponse{
+		Message: fmt.Sprintf("tile pyramid jobs enqueued (%s)", command),
+		Count:   &jobCount,
+	})
+}
+
 // containsUid is a helper for checking if a slice contains a UID.
 func containsUid(s []string, e string) bool {
 	return slices.Contains(s, e)
@@ -395,7 +491,7 @@ func containsUid(s []string, e string) bool {
 
 // checkMissingTransforms checks if a given image path's transform is missing from the cache.
 // If missing, it adds the image's UID to the missingUids slice.
-func checkMissingTransforms(img entities.ImageAsset, transformPath string, missingUids *[]string, logger *slog.Logger) error {
+func checkMissingTransforms(ctx context.Context, img entities.ImageAsset, transformPath string, missingUids *[]string, logger *slog.Logger) error {
 	if transformPath == "" {
 		return nil // No path to check
 	}
@@ -421,7 +517,7 @@ func checkMissingTransforms(img entities.ImageAsset, transformPath string, missi
 		ext = img.ImageMetadata.FileType // Fallback if format isn't specified in path
 	}
 
-	_, exists, err := images.FindCachedTransform(img.Uid, transformEtag, ext)
+	_, exists, err := images.FindCachedTransform(ctx, img, transformEtag, ext)
 	if err != nil {
 		logger.Error("failed to check for cached transform", slog.String("uid", img.Uid), slog.String("path", transformPath), slog.Any("error", err))
 		// Treat error as missing
@@ -441,25 +537,25 @@ func checkMissingTransforms(img entities.ImageAsset, transformPath string, missi
 
 // findMissingTransforms finds UIDs of images that have permanent paths (thumbnail/preview) defined
 // but the corresponding cached transform files are missing.
-func findMissingTransforms(db *gorm.DB, logger *slog.Logger) ([]string, error) {
+func findMissingTransforms(ctx context.Context, db *gorm.DB, logger *slog.Logger) ([]string, error) {
 	var allImages []entities.ImageAsset
 	var err error
-	// Fetch uid, image_paths, and image_metadata needed for cache key calculation
-	if err = db.Select("uid", "image_paths", "image_metadata").Find(&allImages).Error; err != nil {
+	// Fetch what's needed for the cache key calculation and to find the cache
+	if err = db.Select("uid", "image_paths", "image_metadata", "edits", "storage_location").Find(&allImages).Error; err != nil {
 		return nil, fmt.Errorf("failed to fetch all images: %w", err)
 	}
 
 	var missing []string
 	for _, img := range allImages {
 		// Check thumbnail path
-		err = checkMissingTransforms(img, img.ImagePaths.Thumbnail, &missing, logger)
+		err = checkMissingTransforms(ctx, img, img.ImagePaths.Thumbnail, &missing, logger)
 		if err != nil {
 			return nil, err
 		}
 
 		// Check preview path (only if not already added by thumbnail check)
 		if !containsUid(missing, img.Uid) { // Only check preview if not already identified as missing
-			err = checkMissingTransforms(img, img.ImagePaths.Preview, &missing, logger)
+			err = checkMissingTransforms(ctx, img, img.ImagePaths.Preview, &missing, logger)
 			if err != nil {
 				return nil, err
 			}
@@ -469,6 +565,107 @@ func findMissingTransforms(db *gorm.DB, logger *slog.Logger) ([]string, error) {
 	return missing, nil
 }
 
+// replayJob re-queues a failed, cancelled or dead-lettered job and responds
+// with its reset state
+func replayJob(db *gorm.DB, logger *slog.Logger, uid string, res http.ResponseWriter, req *http.Request) {
+	wj, err := jobs.Replay(db, uid)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			render.Status(req, http.StatusNotFound)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Job not found"})
+		case errors.Is(err, jobs.ErrJobNotReplayable), errors.Is(err, jobs.ErrPayloadUnavailable):
+			render.Status(req, http.StatusConflict)
+			render.JSON(res, req, dto.ErrorResponse{Error: err.Error()})
+		default:
+			libhttp.ServerError(res, req, err, logger, nil,
+				"Failed to replay job",
+				"Something went wrong, please try again later",
+			)
+		}
+
+		return
+	}
+
+	render.Status(req, http.StatusAccepted)
+	render.JSON(res, req, wj.DTO())
+}
+
+// pipelineResponse builds the API view of a pipeline with its steps and
+// their live progress
+func pipelineResponse(db *gorm.DB, pipeline entities.Pipeline) (dto.Pipeline, error) {
+	steps, parents, err := jobs.GetPipelineSteps(db, pipeline.Uid)
+	if err != nil {
+		return dto.Pipeline{}, err
+	}
+
+	items := make([]dto.PipelineStep, 0, len(steps))
+	for _, step := range steps {
+		dependsOn := parents[step.Uid]
+		if dependsOn == nil {
+			dependsOn = []string{}
+		}
+
+		items = append(items, dto.PipelineStep{
+			Uid:         step.Uid,
+			Topic:       step.Topic,
+			Status:      step.Status,
+			Progress:    jobs.StepProgress(step),
+			DependsOn:   dependsOn,
+			ErrorMsg:    step.ErrorMsg,
+			StartedAt:   step.StartedAt,
+			CompletedAt: step.CompletedAt,
+		})
+	}
+
+	return dto.Pipeline{
+		Uid:         pipeline.Uid,
+		Name:        pipeline.Name,
+		ImageUid:    pipeline.ImageUid,
+		OwnerUid:    pipeline.OwnerUid,
+		Status:      pipeline.Status,
+		Progress:    jobs.PipelineProgress(steps),
+		Steps:       items,
+		CreatedAt:   pipeline.CreatedAt,
+		CompletedAt: pipeline.CompletedAt,
+	}, nil
+}
+
+// cronJobResponse builds the API view of a cron job
+func cronJobResponse(info jobs.CronJobInfo) dto.CronJob {
+	return dto.CronJob{
+		Name:           info.Name,
+		Description:    info.Description,
+		Schedule:       info.Schedule,
+		Paused:         info.Paused,
+		Running:        info.Running,
+		LastRunAt:      info.LastRunAt,
+		LastDurationMs: info.LastDurationMs,
+		LastError:      info.LastError,
+		NextRunAt:      info.NextRunAt,
+	}
+}
+
+// cronJobError responds to a failed cron job action
+func cronJobError(logger *slog.Logger, err error, msg string, res http.ResponseWriter, req *http.Request) {
+	switch {
+	case errors.Is(err, jobs.ErrCronJobNotFound):
+		render.Status(req, http.StatusNotFound)
+		render.JSON(res, req, dto.ErrorResponse{Error: "Cron job not found"})
+	case errors.Is(err, jobs.ErrCronJobRunning):
+		render.Status(req, http.StatusConflict)
+		render.JSON(res, req, dto.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, jobs.ErrInvalidSchedule):
+		render.Status(req, http.StatusBadRequest)
+		render.JSON(res, req, dto.ErrorResponse{Error: err.Error()})
+	default:
+		libhttp.ServerError(res, req, err, logger, nil,
+			msg,
+			"Something went wrong, please try again later",
+		)
+	}
+}
+
 // JobsRouter returns a router with admin-only job endpoints.
 // It applies AuthMiddleware and AdminMiddleware internally so it can be
 // mounted anywhere (we mount it under /admin/jobs in api.go).
@@ -501,9 +698,15 @@ func JobsRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 			if imgUid != "" {
 				imgUidPtr = &imgUid
 			}
+			ownerUid := j.GetOwnerUid()
+			if ownerUid == "" {
+				ownerUid = jobs.SystemOwner
+			}
 			active = append(active, ActiveBrief{
 				Uid:      id,
 				ImageUid: imgUidPtr,
+				OwnerUid: ownerUid,
+				Priority: j.Priority(),
 				Topic:    j.Topic(),
 				Type:     j.Topic(),
 				Status:   j.GetStatus(),
@@ -517,9 +720,12 @@ func JobsRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 		// We can’t access the broker instance directly here without a global. For now, omit nextCursor in response.
 		// Frontend can fetch /events/since with cursor=0 to bootstrap missed events if needed.
 		snap := map[string]any{
-			"active":           active,
-			"running_by_topic": stats.RunningByTopic,
-			"queued_by_topic":  stats.QueuedByTopic,
+			"active":             active,
+			"running_by_topic":   stats.RunningByTopic,
+			"queued_by_topic":    stats.QueuedByTopic,
+			"running_by_owner":   stats.RunningByOwner,
+			"queued_by_owner":    stats.QueuedByOwner,
+			"queued_by_priority": stats.QueuedByPriority,
 			// nextCursor intentionally omitted due to scope isolation
 		}
 		render.Status(req, http.StatusOK)
@@ -643,6 +849,10 @@ func JobsRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 			handleExifProcessing(db, logger, body, res, req)
 			return
 
+		case workers.JobTypeTilePyramid:
+			handleTilePyramid(db, logger, body, res, req)
+			return
+
 		default:
 			render.Status(req, http.StatusBadRequest)
 			render.JSON(res, req, dto.ErrorResponse{Error: fmt.Sprintf("unsupported job type: %s", body.Type)})
@@ -683,30 +893,301 @@ func JobsRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 
 	r.Delete("/{uid}", func(res http.ResponseWriter, req *http.Request) {
 		uid := chi.URLParam(req, "uid")
-		all := jobs.GetAllJobs()
-		if j, ok := all[uid]; ok {
-			j.SetStatus(jobs.WorkerJobStatusCancelled)
-			delete(all, uid)
-			_ = jobs.UpdateWorkerJobStatus(db, uid, jobs.WorkerJobStatusCancelled, nil, nil, nil, nil)
 
-			render.Status(req, http.StatusOK)
-			render.JSON(res, req, dto.MessageResponse{Message: "Job cancelled"})
+		// Running jobs stop at their next step, queued ones are dropped when delivered
+		if err := jobs.CancelJob(db, uid); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				render.Status(req, http.StatusNotFound)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Job not found"})
+				return
+			}
+
+			libhttp.ServerError(res, req, err, logger, nil,
+				"Failed to cancel job",
+				"Something went wrong, please try again later",
+			)
 			return
 		}
 
-		if err := jobs.UpdateWorkerJobStatus(db, uid, jobs.WorkerJobStatusCancelled, nil, nil, nil, nil); err == nil {
-			render.Status(req, http.StatusOK)
-			render.JSON(res, req, dto.MessageResponse{Message: "Job cancelled"})
+		render.Status(req, http.StatusOK)
+		render.JSON(res, req, dto.MessageResponse{Message: "Job cancelled"})
+	})
+
+	r.Post("/{uid}", func(res http.ResponseWriter, req *http.Request) {
+		replayJob(db, logger, chi.URLParam(req, "uid"), res, req)
+	})
+
+	// GET /dead-letter: jobs that ran out of attempts or failed permanently
+	r.Get("/dead-letter", func(res http.ResponseWriter, req *http.Request) {
+		topic := req.URL.Query().Get("topic")
+
+		limit := 25
+		page := 0
+		if q := req.URL.Query().Get("limit"); q != "" {
+			fmt.Sscanf(q, "%d", &limit)
+		}
+		if q := req.URL.Query().Get("page"); q != "" {
+			fmt.Sscanf(q, "%d", &page)
+		}
+
+		query := db.Model(&entities.WorkerJob{}).Where("status = ?", jobs.WorkerJobStatusDeadLetter)
+		if topic != "" {
+			query = query.Where("topic = ?", topic)
+		}
+		query = query.Session(&gorm.Session{})
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			libhttp.ServerError(res, req, err, logger, nil,
+				"Failed to count dead-lettered jobs",
+				"Something went wrong, please try again later",
+			)
 			return
 		}
 
-		render.Status(req, http.StatusNotFound)
-		render.JSON(res, req, dto.ErrorResponse{Error: "Job not found"})
+		var ents []entities.WorkerJob
+		if err := query.Order("completed_at desc").Limit(limit).Offset(page * limit).Find(&ents).Error; err != nil {
+			libhttp.ServerError(res, req, err, logger, nil,
+				"Failed to list dead-lettered jobs",
+				"Something went wrong, please try again later",
+			)
+			return
+		}
+
+		items := make([]dto.WorkerJob, 0, len(ents))
+		for _, e := range ents {
+			items = append(items, e.DTO())
+		}
+
+		render.Status(req, http.StatusOK)
+		render.JSON(res, req, dto.WorkerJobsResponse{Items: items, Total: int(total)})
 	})
 
-	r.Post("/{uid}", func(res http.ResponseWriter, req *http.Request) {
-		render.Status(req, http.StatusNotImplemented)
-		render.JSON(res, req, dto.ErrorResponse{Error: "Retry not implemented"})
+	r.Post("/dead-letter/replay", func(res http.ResponseWriter, req *http.Request) {
+		topic := req.URL.Query().Get("topic")
+
+		replayed, err := jobs.ReplayDeadLetters(db, topic)
+		if err != nil {
+			libhttp.ServerError(res, req, err, logger, nil,
+				"Failed to replay dead-lettered jobs",
+				"Something went wrong, please try again later",
+			)
+			return
+		}
+
+		logger.Info("replayed dead-lettered jobs", slog.String("topic", topic), slog.Int("count", replayed))
+
+		render.Status(req, http.StatusAccepted)
+		render.JSON(res, req, dto.WorkerJobEnqueueResponse{Message: fmt.Sprintf("replayed %d dead-lettered jobs", replayed), Count: &replayed})
+	})
+
+	r.Post("/dead-letter/{uid}/replay", func(res http.ResponseWriter, req *http.Request) {
+		uid := chi.URLParam(req, "uid")
+
+		var ent entities.WorkerJob
+		err := db.Where("uid = ? AND status = ?", uid, jobs.WorkerJobStatusDeadLetter).First(&ent).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				render.Status(req, http.StatusNotFound)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Dead-lettered job not found"})
+				return
+			}
+
+			libhttp.ServerError(res, req, err, logger, nil,
+				"Failed to get dead-lettered job",
+				"Something went wrong, please try again later",
+			)
+			return
+		}
+
+		replayJob(db, logger, uid, res, req)
+	})
+
+	// GET /pipelines: pipelines of jobs run for an image, e.g. on upload
+	r.Get("/pipelines", func(res http.ResponseWriter, req *http.Request) {
+		status := req.URL.Query().Get("status")
+		imageUid := req.URL.Query().Get("image_uid")
+
+		limit := 25
+		page := 0
+		if q := req.URL.Query().Get("limit"); q != "" {
+			fmt.Sscanf(q, "%d", &limit)
+		}
+		if q := req.URL.Query().Get("page"); q != "" {
+			fmt.Sscanf(q, "%d", &page)
+		}
+
+		query := db.Model(&entities.Pipeline{})
+		if status != "" {
+			query = query.Where("status = ?", status)
+		}
+		if imageUid != "" {
+			query = query.Where("image_uid = ?", imageUid)
+		}
+		query = query.Session(&gorm.Session{})
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			libhttp.ServerError(res, req, err, logger, nil,
+				"Failed to count pipelines",
+				"Something went wrong, please try again later",
+			)
+			return
+		}
+
+		var ents []entities.Pipeline
+		if err := query.Order("created_at desc").Limit(limit).Offset(page * limit).Find(&ents).Error; err != nil {
+			libhttp.ServerError(res, req, err, logger, nil,
+				"Failed to list pipelines",
+				"Something went wrong, please try again later",
+			)
+			return
+		}
+
+		items := make([]dto.Pipeline, 0, len(ents))
+		for _, e := range ents {
+			item, err := pipelineResponse(db, e)
+			if err != nil {
+				libhttp.ServerError(res, req, err, logger, nil,
+					"Failed to get pipeline steps",
+					"Something went wrong, please try again later",
+				)
+				return
+			}
+
+			items = append(items, item)
+		}
+
+		render.Status(req, http.StatusOK)
+		render.JSON(res, req, dto.PipelinesResponse{Items: items, Total: int(total)})
+	})
+
+	r.Get("/pipelines/{uid}", func(res http.ResponseWriter, req *http.Request) {
+		uid := chi.URLParam(req, "uid")
+
+		var ent entities.Pipeline
+		if err := db.Where("uid = ?", uid).First(&ent).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				render.Status(req, http.StatusNotFound)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Pipeline not found"})
+				return
+			}
+
+			libhttp.ServerError(res, req, err, logger, nil,
+				"Failed to get pipeline",
+				"Something went wrong, please try again later",
+			)
+			return
+		}
+
+		resp, err := pipelineResponse(db, ent)
+		if err != nil {
+			libhttp.ServerError(res, req, err, logger, nil,
+				"Failed to get pipeline steps",
+				"Something went wrong, please try again later",
+			)
+			return
+		}
+
+		render.Status(req, http.StatusOK)
+		render.JSON(res, req, resp)
+	})
+
+	r.Delete("/pipelines/{uid}", func(res http.ResponseWriter, req *http.Request) {
+		uid := chi.URLParam(req, "uid")
+
+		if err := jobs.CancelPipeline(db, uid); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				render.Status(req, http.StatusNotFound)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Pipeline not found"})
+				return
+			}
+
+			libhttp.ServerError(res, req, err, logger, nil,
+				"Failed to cancel pipeline",
+				"Something went wrong, please try again later",
+			)
+			return
+		}
+
+		render.Status(req, http.StatusOK)
+		render.JSON(res, req, dto.MessageResponse{Message: "Pipeline cancelled"})
+	})
+
+	r.Get("/cron", func(res http.ResponseWriter, req *http.Request) {
+		infos, err := jobs.ListCronJobs(db)
+		if err != nil {
+			libhttp.ServerError(res, req, err, logger, nil,
+				"Failed to list cron jobs",
+				"Something went wrong, please try again later",
+			)
+			return
+		}
+
+		items := make([]dto.CronJob, 0, len(infos))
+		for _, info := range infos {
+			items = append(items, cronJobResponse(info))
+		}
+
+		render.Status(req, http.StatusOK)
+		render.JSON(res, req, dto.CronJobsResponse{Items: items})
+	})
+
+	r.Post("/cron/{name}/run", func(res http.ResponseWriter, req *http.Request) {
+		name := chi.URLParam(req, "name")
+
+		if err := jobs.TriggerCronJob(db, name); err != nil {
+			cronJobError(logger, err, "Failed to run cron job", res, req)
+			return
+		}
+
+		render.Status(req, http.StatusAccepted)
+		render.JSON(res, req, dto.MessageResponse{Message: fmt.Sprintf("%s started", name)})
+	})
+
+	r.Post("/cron/{name}/pause", func(res http.ResponseWriter, req *http.Request) {
+		info, err := jobs.PauseCronJob(db, chi.URLParam(req, "name"), true)
+		if err != nil {
+			cronJobError(logger, err, "Failed to pause cron job", res, req)
+			return
+		}
+
+		render.Status(req, http.StatusOK)
+		render.JSON(res, req, cronJobResponse(*info))
+	})
+
+	r.Post("/cron/{name}/resume", func(res http.ResponseWriter, req *http.Request) {
+		info, err := jobs.PauseCronJob(db, chi.URLParam(req, "name"), false)
+		if err != nil {
+			cronJobError(logger, err, "Failed to resume cron job", res, req)
+			return
+		}
+
+		render.Status(req, http.StatusOK)
+		render.JSON(res, req, cronJobResponse(*info))
+	})
+
+	r.Put("/cron/{name}/schedule", func(res http.ResponseWriter, req *http.Request) {
+		var body dto.CronScheduleUpdate
+		if err := render.DecodeJSON(req.Body, &body); err != nil {
+			render.Status(req, http.StatusBadRequest)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Invalid body"})
+			return
+		}
+
+		info, err := jobs.RescheduleCronJob(db, chi.URLParam(req, "name"), strings.TrimSpace(body.Schedule))
+		if err != nil {
+			cronJobError(logger, err, "Failed to reschedule cron job", res, req)
+			return
+		}
+
+		logger.Info("cron job rescheduled",
+			slog.String("name", info.Name),
+			slog.String("schedule", info.Schedule),
+		)
+
+		render.Status(req, http.StatusOK)
+		render.JSON(res, req, cronJobResponse(*info))
 	})
 
 	r.Post("/types/{type}/stop", func(res http.ResponseWriter, req *http.Request) {
@@ -719,14 +1200,13 @@ func JobsRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 
 		topic := jobType
 
-		all := jobs.GetAllJobs()
-		cancelled := 0
-		for id, j := range all {
-			if j.Topic() == topic {
-				j.SetStatus(jobs.WorkerJobStatusCancelled)
-				delete(all, id)
-				cancelled++
-			}
+		cancelled, err := jobs.CancelTopic(db, topic)
+		if err != nil {
+			libhttp.ServerError(res, req, err, logger, nil,
+				"Failed to stop jobs",
+				"Something went wrong, please try again later",
+			)
+			return
 		}
 
 		render.Status(req, http.StatusOK)
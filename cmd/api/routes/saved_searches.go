@@ -0,0 +1,220 @@
+package routes
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"gorm.io/gorm"
+
+	"viz/internal/dto"
+	"viz/internal/entities"
+	libhttp "viz/internal/http"
+	"viz/internal/search"
+	"viz/internal/uid"
+)
+
+var ErrSavedSearchInUse = errors.New("saved search is used by a collection")
+
+// SavedSearchesRouter manages the current user's saved search queries.
+// Saved searches are always private to their owner.
+func SavedSearchesRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
+	router := chi.NewRouter()
+
+	router.Get("/", func(res http.ResponseWriter, req *http.Request) {
+		userID := requestUserID(req)
+
+		var saved []entities.SavedSearch
+		if err := db.Where("owner_id = ?", userID).Order("name ASC").Find(&saved).Error; err != nil {
+			libhttp.ServerError(res, req, err, logger, nil,
+				"Failed to list saved searches",
+				"Something went wrong, please try again later",
+			)
+			return
+		}
+
+		items := make([]dto.SavedSearch, len(saved))
+		for i := range saved {
+			items[i] = saved[i].DTO()
+		}
+
+		render.Status(req, http.StatusOK)
+		render.JSON(res, req, dto.SavedSearchListResponse{Items: items})
+	})
+
+	router.Post("/", func(res http.ResponseWriter, req *http.Request) {
+		var create dto.SavedSearchCreate
+		if err := render.DecodeJSON(req.Body, &create); err != nil {
+			render.Status(req, http.StatusBadRequest)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Invalid request body"})
+			return
+		}
+
+		create.Name = strings.TrimSpace(create.Name)
+		if create.Name == "" {
+			render.Status(req, http.StatusBadRequest)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Name is required"})
+			return
+		}
+
+		if _, err := search.ParseQuery(create.Query); err != nil {
+			render.Status(req, http.StatusBadRequest)
+			render.JSON(res, req, dto.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		savedUid, err := uid.Generate()
+		if err != nil {
+			libhttp.ServerError(res, req, err, logger, nil,
+				"Failed to generate saved search ID",
+				"Something went wrong, please try again later",
+			)
+			return
+		}
+
+		userID := requestUserID(req)
+		saved := entities.SavedSearch{
+			Uid:     savedUid,
+			Name:    create.Name,
+			Query:   create.Query,
+			OwnerID: &userID,
+		}
+
+		if err := db.Create(&saved).Error; err != nil {
+			libhttp.ServerError(res, req, err, logger, nil,
+				"Failed to create saved search",
+				"Something went wrong, please try again later",
+			)
+			return
+		}
+
+		render.Status(req, http.StatusCreated)
+		render.JSON(res, req, saved.DTO())
+	})
+
+	router.Get("/{uid}", func(res http.ResponseWriter, req *http.Request) {
+		var saved entities.SavedSearch
+		err := db.First(&saved, "uid = ? AND owner_id = ?", chi.URLParam(req, "uid"), requestUserID(req)).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				render.Status(req, http.StatusNotFound)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Saved search not found"})
+				return
+			}
+
+			libhttp.ServerError(res, req, err, logger, nil,
+				"Failed to get saved search",
+				"Something went wrong, please try again later",
+			)
+			return
+		}
+
+		render.Status(req, http.StatusOK)
+		render.JSON(res, req, saved.DTO())
+	})
+
+	router.Patch("/{uid}", func(res http.ResponseWriter, req *http.Request) {
+		var update dto.SavedSearchUpdate
+		if err := render.DecodeJSON(req.Body, &update); err != nil {
+			render.Status(req, http.StatusBadRequest)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Invalid request body"})
+			return
+		}
+
+		if update.Name != nil && strings.TrimSpace(*update.Name) == "" {
+			render.Status(req, http.StatusBadRequest)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Name cannot be empty"})
+			return
+		}
+
+		if update.Query != nil {
+			if _, err := search.ParseQuery(*update.Query); err != nil {
+				render.Status(req, http.StatusBadRequest)
+				render.JSON(res, req, dto.ErrorResponse{Error: err.Error()})
+				return
+			}
+		}
+
+		var saved entities.SavedSearch
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.First(&saved, "uid = ? AND owner_id = ?", chi.URLParam(req, "uid"), requestUserID(req)).Error; err != nil {
+				return err
+			}
+
+			if update.Name != nil {
+				saved.Name = strings.TrimSpace(*update.Name)
+			}
+
+			if update.Query != nil {
+				saved.Query = *update.Query
+			}
+
+			return tx.Save(&saved).Error
+		})
+
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				render.Status(req, http.StatusNotFound)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Saved search not found"})
+				return
+			}
+
+			libhttp.ServerError(res, req, err, logger, nil,
+				"Failed to update saved search",
+				"Something went wrong, please try again later",
+			)
+			return
+		}
+
+		render.Status(req, http.StatusOK)
+		render.JSON(res, req, saved.DTO())
+	})
+
+	router.Delete("/{uid}", func(res http.ResponseWriter, req *http.Request) {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var saved entities.SavedSearch
+			if err := tx.First(&saved, "uid = ? AND owner_id = ?", chi.URLParam(req, "uid"), requestUserID(req)).Error; err != nil {
+				return err
+			}
+
+			// Deleting it would silently empty every smart collection built on it
+			var inUse int64
+			if err := tx.Model(&entities.Collection{}).Where("saved_search_id = ?", saved.Uid).Count(&inUse).Error; err != nil {
+				return err
+			}
+
+			if inUse > 0 {
+				return ErrSavedSearchInUse
+			}
+
+			return tx.Delete(&saved).Error
+		})
+
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				render.Status(req, http.StatusNotFound)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Saved search not found"})
+				return
+			}
+
+			if errors.Is(err, ErrSavedSearchInUse) {
+				render.Status(req, http.StatusConflict)
+				render.JSON(res, req, dto.ErrorResponse{Error: "This saved search is used by a smart collection"})
+				return
+			}
+
+			libhttp.ServerError(res, req, err, logger, nil,
+				"Failed to delete saved search",
+				"Something went wrong, please try again later",
+			)
+			return
+		}
+
+		res.WriteHeader(http.StatusNoContent)
+	})
+
+	return router
+}
@@ -4,6 +4,7 @@ import (
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
@@ -13,38 +14,192 @@ import (
 	"viz/internal/entities"
 	libhttp "viz/internal/http"
 	"viz/internal/search"
+	"viz/internal/settings"
 )
 
+// requestUserID returns the UID of the user making the request, whether they
+// authenticated with a session or an API key, or "" if neither is present
+func requestUserID(req *http.Request) string {
+	if user, ok := libhttp.UserFromContext(req); ok && user != nil {
+		return user.Uid
+	}
+
+	if apiKey, ok := libhttp.APIKeyFromContext(req); ok && apiKey != nil && apiKey.User != nil {
+		return apiKey.User.Uid
+	}
+
+	return ""
+}
+
+// visibilityScope limits a query to rows the user can see:
+// private = false OR (private = true AND owner_id = :user)
+func visibilityScope(userID string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if userID != "" {
+			// allow public items OR their own private items
+			return db.Where("private = ? OR (private = ? AND owner_id = ?)", false, true, userID)
+		}
+
+		// Fallback (should be covered by middleware, but safe default): only public
+		return db.Where("private = ?", false)
+	}
+}
+
+// profileVisibilityScope limits a user query to profiles the
+// privacy_profile_visibility setting makes public, plus the user's own
+func profileVisibilityScope(userID string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(`users.uid = ? OR COALESCE(
+			(SELECT value FROM setting_overrides WHERE setting_overrides.user_id = users.uid AND setting_overrides.name = ? AND setting_overrides.deleted_at IS NULL),
+			(SELECT value FROM setting_defaults WHERE setting_defaults.name = ? AND setting_defaults.deleted_at IS NULL)
+		) = ?`, userID, settings.SettingNameProfileVisibility, settings.SettingNameProfileVisibility, "Public")
+	}
+}
+
+// loadSearchResults loads the entity behind each hit of a mixed search and
+// returns them in the same order. Hits whose entity has since been deleted
+// are left out.
+func loadSearchResults(db *gorm.DB, hits []search.Hit) ([]dto.SearchResult, error) {
+	uids := make(map[search.ResultKind][]string)
+	for _, hit := range hits {
+		uids[hit.Kind] = append(uids[hit.Kind], hit.Uid)
+	}
+
+	images := make(map[string]dto.ImageAsset)
+	if len(uids[search.ResultImage]) > 0 {
+		var found []entities.ImageAsset
+		if err := db.Where("uid IN ?", uids[search.ResultImage]).Find(&found).Error; err != nil {
+			return nil, err
+		}
+
+		for _, img := range found {
+			images[img.Uid] = img.DTO()
+		}
+	}
+
+	collections := make(map[string]dto.Collection)
+	if len(uids[search.ResultCollection]) > 0 {
+		var found []entities.Collection
+		if err := db.Where("uid IN ?", uids[search.ResultCollection]).Find(&found).Error; err != nil {
+			return nil, err
+		}
+
+		for _, col := range found {
+			collections[col.Uid] = col.DTO()
+		}
+	}
+
+	// users are found by anyone, so only their public profile is returned
+	users := make(map[string]dto.PublicUser)
+	if len(uids[search.ResultUser]) > 0 {
+		var found []entities.User
+		if err := db.Where("uid IN ?", uids[search.ResultUser]).Find(&found).Error; err != nil {
+			return nil, err
+		}
+
+		for _, user := range found {
+			users[user.Uid] = publicUserDTO(user)
+		}
+	}
+
+	results := make([]dto.SearchResult, 0, len(hits))
+	for _, hit := range hits {
+		result := dto.SearchResult{
+			Type: dto.SearchResultType(hit.Kind),
+			Uid:  hit.Uid,
+			Rank: hit.Rank,
+		}
+
+		switch hit.Kind {
+		case search.ResultImage:
+			img, ok := images[hit.Uid]
+			if !ok {
+				continue
+			}
+
+			result.Image = &img
+		case search.ResultCollection:
+			col, ok := collections[hit.Uid]
+			if !ok {
+				continue
+			}
+
+			result.Collection = &col
+		case search.ResultUser:
+			user, ok := users[hit.Uid]
+			if !ok {
+				continue
+			}
+
+			result.User = &user
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// publicUserDTO keeps the parts of a user's profile other users may see
+func publicUserDTO(user entities.User) dto.PublicUser {
+	displayName := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if displayName == "" {
+		displayName = user.Username
+	}
+
+	return dto.PublicUser{Uid: user.Uid, Username: user.Username, DisplayName: displayName}
+}
+
+func facetsDTO(facets map[string][]search.FacetBucket) *map[string][]dto.SearchFacetBucket {
+	result := make(map[string][]dto.SearchFacetBucket, len(facets))
+	for name, buckets := range facets {
+		items := make([]dto.SearchFacetBucket, len(buckets))
+		for i, b := range buckets {
+			items[i] = dto.SearchFacetBucket{Value: b.Value, Count: b.Count}
+		}
+
+		result[name] = items
+	}
+
+	return &result
+}
+
 // SearchRouter creates a new router for search-related endpoints
 func SearchRouter(db *gorm.DB, logger *slog.Logger) chi.Router {
 	r := chi.NewRouter()
+	r.Mount("/saved", SavedSearchesRouter(db, logger))
+
 	r.Get("/", func(res http.ResponseWriter, req *http.Request) {
 		queryParam := req.URL.Query().Get("q")
 		limitParam := req.URL.Query().Get("limit")
 		pageParam := req.URL.Query().Get("page")
 
-		criteria := search.ParseQuery(queryParam)
-		engine := search.NewEngine()
-
-		// security filters (private = false OR (private = true AND owner_id = :user))
-		securityScope := func(db *gorm.DB) *gorm.DB {
-			userID := ""
-			if user, ok := libhttp.UserFromContext(req); ok && user != nil {
-				userID = user.Uid
-			} else if apiKey, ok := libhttp.APIKeyFromContext(req); ok && apiKey != nil && apiKey.User != nil {
-				userID = apiKey.User.Uid
-			}
+		query, err := search.ParseQuery(queryParam)
+		if err != nil {
+			render.Status(req, http.StatusBadRequest)
+			render.JSON(res, req, dto.ErrorResponse{Error: err.Error()})
+			return
+		}
 
-			if userID != "" {
-				// allow public items OR their own private items
-				return db.Where("private = ? OR (private = ? AND owner_id = ?)", false, true, userID)
-			}
+		facetNames, err := search.ParseFacets(req.URL.Query().Get("facets"))
+		if err != nil {
+			render.Status(req, http.StatusBadRequest)
+			render.JSON(res, req, dto.ErrorResponse{Error: err.Error()})
+			return
+		}
 
-			// Fallback (should be covered by middleware, but safe default): only public
-			return db.Where("private = ?", false)
+		scope := req.URL.Query().Get("scope")
+		if scope != "" && scope != string(dto.ExecuteSearchParamsScopeAll) {
+			render.Status(req, http.StatusBadRequest)
+			render.JSON(res, req, dto.ErrorResponse{Error: "Invalid scope, expected \"all\""})
+			return
 		}
 
-		imagesQuery := engine.Apply(db, criteria).Scopes(securityScope)
+		userID := requestUserID(req)
+
+		// dates in the query are resolved in the user's timezone
+		engine := search.NewEngine().WithLocation(settings.GetLocation(db, &userID))
+		securityScope := visibilityScope(userID)
 
 		limit := 100
 		page := 0
@@ -56,9 +211,81 @@ func SearchRouter(db *gorm.DB, logger *slog.Logger) chi.Router {
 			page = p
 		}
 
+		var after *search.Hit
+		if cursor := req.URL.Query().Get("cursor"); cursor != "" {
+			after, err = search.ParseCursor(cursor)
+			if err != nil {
+				render.Status(req, http.StatusBadRequest)
+				render.JSON(res, req, dto.ErrorResponse{Error: "Invalid cursor"})
+				return
+			}
+		}
+
+		// Facets are counted over the same filtered query, before ranking and paging
+		filteredImages := engine.Apply(db, query).Scopes(securityScope).Session(&gorm.Session{})
+		imagesQuery := engine.Rank(filteredImages, query)
+
+		if scope == string(dto.ExecuteSearchParamsScopeAll) {
+			scopes := search.MixedScopes{
+				Images:      securityScope,
+				Collections: securityScope,
+				Users:       profileVisibilityScope(userID),
+			}
+
+			// one extra hit tells us whether there's another page
+			hits, err := engine.Mixed(db, query, scopes, after, limit+1)
+			if err != nil {
+				libhttp.ServerError(res, req, err, logger, nil,
+					"Failed to search",
+					"Something went wrong, please try again later",
+				)
+				return
+			}
+
+			var nextCursor *string
+			if len(hits) > limit {
+				hits = hits[:limit]
+				cursor := hits[limit-1].Cursor()
+				nextCursor = &cursor
+			}
+
+			results, err := loadSearchResults(db, hits)
+			if err != nil {
+				libhttp.ServerError(res, req, err, logger, nil,
+					"Failed to load search results",
+					"Something went wrong, please try again later",
+				)
+				return
+			}
+
+			response := dto.SearchListResponse{
+				Images:      make([]dto.ImageAsset, 0),
+				Collections: make([]dto.Collection, 0),
+				Results:     &results,
+				NextCursor:  nextCursor,
+			}
+
+			if len(facetNames) > 0 {
+				facets, err := engine.Facets(db, filteredImages, facetNames)
+				if err != nil {
+					libhttp.ServerError(res, req, err, logger, nil,
+						"Failed to count search facets",
+						"Something went wrong, please try again later",
+					)
+					return
+				}
+
+				response.Facets = facetsDTO(facets)
+			}
+
+			render.Status(req, http.StatusOK)
+			render.JSON(res, req, response)
+			return
+		}
+
 		imagesQuery = imagesQuery.Limit(limit).Offset((page - 1) * limit)
 
-		var images []entities.ImageAsset
+		var images []search.ImageHit
 		if err := imagesQuery.Find(&images).Error; err != nil {
 			logger.Error("failed to search images", slog.Any("error", err))
 			render.Status(req, http.StatusInternalServerError)
@@ -69,7 +296,21 @@ func SearchRouter(db *gorm.DB, logger *slog.Logger) chi.Router {
 			return
 		}
 
-		collectionsQuery := engine.ApplyCollections(db, criteria).Scopes(securityScope)
+		var facets map[string][]search.FacetBucket
+		if len(facetNames) > 0 {
+			facets, err = engine.Facets(db, filteredImages, facetNames)
+			if err != nil {
+				logger.Error("failed to count search facets", slog.Any("error", err))
+				render.Status(req, http.StatusInternalServerError)
+				render.JSON(res, req, dto.ErrorResponse{
+					Error: "Failed to count search facets",
+				})
+
+				return
+			}
+		}
+
+		collectionsQuery := engine.ApplyCollections(db, query).Scopes(securityScope)
 		collectionsQuery = collectionsQuery.Limit(limit).Offset((page - 1) * limit)
 
 		var collections []entities.Collection
@@ -84,8 +325,15 @@ func SearchRouter(db *gorm.DB, logger *slog.Logger) chi.Router {
 		}
 
 		imagesDTO := make([]dto.ImageAsset, 0)
+		highlights := make(map[string]dto.SearchHighlight)
 		for _, img := range images {
 			imagesDTO = append(imagesDTO, img.DTO())
+			if img.Snippet != nil {
+				highlights[img.Uid] = dto.SearchHighlight{
+					Rank:    img.Rank,
+					Snippet: img.Snippet,
+				}
+			}
 		}
 
 		collectionsDTO := make([]dto.Collection, 0)
@@ -94,10 +342,20 @@ func SearchRouter(db *gorm.DB, logger *slog.Logger) chi.Router {
 		}
 
 		render.Status(req, http.StatusOK)
-		render.JSON(res, req, dto.SearchListResponse{
+		response := dto.SearchListResponse{
 			Images:      imagesDTO,
 			Collections: collectionsDTO,
-		})
+		}
+
+		if len(highlights) > 0 {
+			response.Highlights = &highlights
+		}
+
+		if facets != nil {
+			response.Facets = facetsDTO(facets)
+		}
+
+		render.JSON(res, req, response)
 	})
 
 	return r
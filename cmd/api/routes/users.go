@@ -6,6 +6,8 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"image/png"
+	"io"
 	"log/slog"
 	"net/http"
 	"strconv"
@@ -23,7 +25,9 @@ import (
 	"viz/internal/dto"
 	"viz/internal/entities"
 	libhttp "viz/internal/http"
+	"viz/internal/images"
 	"viz/internal/settings"
+	"viz/internal/transform"
 	"viz/internal/uid"
 	"viz/internal/utils"
 )
@@ -559,12 +563,78 @@ func AccountsRouter(db *gorm.DB, logger *slog.Logger) *chi.Mux {
 					})
 				})
 			})
+
+			r.Route("/watermark/logo", func(r chi.Router) {
+				r.Use(libhttp.ScopeMiddleware([]auth.Scope{auth.UserSettingsUpdateScope}))
+				r.Put("/", func(res http.ResponseWriter, req *http.Request) {
+					user, _ := libhttp.UserFromContext(req)
+
+					data, err := io.ReadAll(http.MaxBytesReader(res, req.Body, images.MaxWatermarkLogoSize))
+					if err != nil {
+						render.Status(req, http.StatusBadRequest)
+						render.JSON(res, req, dto.ErrorResponse{Error: fmt.Sprintf("Logo must be a PNG of at most %d MB", images.MaxWatermarkLogoSize>>20)})
+						return
+					}
+
+					if _, err := png.DecodeConfig(bytes.NewReader(data)); err != nil {
+						render.Status(req, http.StatusBadRequest)
+						render.JSON(res, req, dto.ErrorResponse{Error: "Logo must be a PNG image"})
+						return
+					}
+
+					checksum, err := images.SaveWatermarkLogo(req.Context(), data)
+					if err != nil {
+						logger.Error("failed to save watermark logo", slog.Any("error", err))
+						render.Status(req, http.StatusInternalServerError)
+						render.JSON(res, req, dto.ErrorResponse{Error: "Failed to save logo"})
+						return
+					}
+
+					updateWatermarkProfile(res, req, db, logger, user.Uid, &checksum)
+				})
+
+				r.Delete("/", func(res http.ResponseWriter, req *http.Request) {
+					user, _ := libhttp.UserFromContext(req)
+					updateWatermarkProfile(res, req, db, logger, user.Uid, nil)
+				})
+			})
 		})
 	})
 
 	return router
 }
 
+// updateWatermarkProfile sets the logo of a user's watermark profile and
+// responds with the updated profile. The logo file itself is left in
+// storage, share links signed with it still draw it.
+func updateWatermarkProfile(res http.ResponseWriter, req *http.Request, db *gorm.DB, logger *slog.Logger, userID string, logo *string) {
+	profile, err := settings.GetWatermarkProfile(db, &userID)
+	if err != nil {
+		logger.Error("failed to fetch watermark profile", slog.Any("error", err))
+		render.Status(req, http.StatusInternalServerError)
+		render.JSON(res, req, dto.ErrorResponse{Error: "Failed to fetch watermark profile"})
+		return
+	}
+
+	profile.Logo = logo
+	value, err := json.Marshal(profile)
+	if err != nil {
+		logger.Error("failed to encode watermark profile", slog.Any("error", err))
+		render.Status(req, http.StatusInternalServerError)
+		render.JSON(res, req, dto.ErrorResponse{Error: "Failed to save watermark profile"})
+		return
+	}
+
+	if err := settings.SetSetting(db, settings.SettingNameImageWatermark, string(value), &userID); err != nil {
+		logger.Error("failed to save watermark profile", slog.Any("error", err))
+		render.Status(req, http.StatusInternalServerError)
+		render.JSON(res, req, dto.ErrorResponse{Error: "Failed to save watermark profile"})
+		return
+	}
+
+	render.JSON(res, req, profile)
+}
+
 // validateSettingValue checks if the provided value conforms to the setting definition.
 func validateSettingValue(value string, def entities.SettingDefault) error {
 	switch dto.SettingDefaultValueType(def.ValueType) {
@@ -595,6 +665,17 @@ func validateSettingValue(value string, def entities.SettingDefault) error {
 		if err := json.Unmarshal([]byte(value), &js); err != nil {
 			return fmt.Errorf("invalid JSON value: %s", value)
 		}
+
+		if def.Name == settings.SettingNameImageWatermark {
+			var profile dto.WatermarkProfile
+			if err := json.Unmarshal(js, &profile); err != nil {
+				return fmt.Errorf("invalid watermark profile: %w", err)
+			}
+
+			if err := transform.ValidateWatermark(profile); err != nil {
+				return fmt.Errorf("invalid watermark profile: %w", err)
+			}
+		}
 	case dto.String:
 		// Any string is valid
 	default:
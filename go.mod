@@ -34,6 +34,7 @@ require (
 require (
 	github.com/Rican7/retry v0.3.1 // indirect
 	github.com/ThreeDotsLabs/watermill-redisstream v1.4.4
+	github.com/ThreeDotsLabs/watermill-sql/v3 v3.1.0
 	github.com/apapsch/go-jsonmerge/v2 v2.0.0 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
 	github.com/golang/protobuf v1.5.4 // indirect
@@ -51,7 +52,7 @@ require (
 	github.com/lithammer/shortuuid/v3 v3.0.7 // indirect
 	github.com/oklog/ulid v1.3.1 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
-	github.com/robfig/cron/v3 v3.0.1 // indirect
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/sony/gobreaker v1.0.0 // indirect
 	github.com/trimmer-io/go-xmp v1.0.0
 	gopkg.in/yaml.v2 v2.4.0 // indirect
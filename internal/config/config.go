@@ -32,8 +32,14 @@ func ReadConfig() (viper.Viper, error) {
 	_ = v.BindEnv("database.password", "DB_PASSWORD")
 	_ = v.BindEnv("database.name", "DB_NAME")
 	_ = v.BindEnv("redis.password", "REDIS_PASSWORD")
+	_ = v.BindEnv("redis.backend", "QUEUE_BACKEND")
 	_ = v.BindEnv("base_directory", "BASE_DIRECTORY")
 	_ = v.BindEnv("upload.location", "UPLOAD_LOCATION")
+	_ = v.BindEnv("storage.backend", "STORAGE_BACKEND")
+	_ = v.BindEnv("storage.s3.endpoint", "S3_ENDPOINT")
+	_ = v.BindEnv("storage.s3.bucket", "S3_BUCKET")
+	_ = v.BindEnv("storage.s3.access_key_id", "S3_ACCESS_KEY_ID")
+	_ = v.BindEnv("storage.s3.secret_access_key", "S3_SECRET_ACCESS_KEY")
 
 	// Set Defaults
 	v.SetDefault("baseUrl", "localhost")
@@ -48,6 +54,7 @@ func ReadConfig() (viper.Viper, error) {
 	v.SetDefault("database.name", "imagine")
 
 	v.SetDefault("redis.enabled", false)
+	v.SetDefault("redis.backend", "")
 	v.SetDefault("redis.host", "localhost")
 	v.SetDefault("redis.port", 6379)
 	v.SetDefault("redis.db", 0)
@@ -63,9 +70,20 @@ func ReadConfig() (viper.Viper, error) {
 	v.SetDefault("libvips.cache_max_operations", 0)
 	v.SetDefault("libvips.concurrency", 1)
 
+	v.SetDefault("storage.backend", "local")
+	v.SetDefault("storage.s3.region", "us-east-1")
+	v.SetDefault("storage.s3.use_path_style", true)
+
 	v.SetDefault("storage_metrics.enabled", true)
 	v.SetDefault("storage_metrics.interval_seconds", 300)
 
+	v.SetDefault("trash.retention_days", 30)
+
+	v.SetDefault("iiif.pyramid_min_megapixels", 100)
+
+	v.SetDefault("responsive.widths", []int{320, 480, 640, 768, 1024, 1280, 1600, 1920, 2560, 3840})
+	v.SetDefault("responsive.sizes", "100vw")
+
 	v.SetDefault("user_management.allow_manual_registration", true)
 
 	// Cache defaults
@@ -9,6 +9,7 @@ import (
 	"gorm.io/gorm"
 	
 	"viz/internal/db"
+	"viz/internal/search"
 	"viz/internal/settings"
 	libhttp "viz/internal/http"
 	_ "github.com/joho/godotenv/autoload"
@@ -69,5 +70,11 @@ func (server VizServer) ConnectToDatabase(dst ...any) *gorm.DB {
 	// Run backfill for ownership
 	db.BackfillOwnership(client, logger)
 
+	// Generated columns and GIN indexes can't be expressed through AutoMigrate
+	search.EnsureSearchIndex(client, logger)
+
+	// Fill numeric EXIF columns for images processed before they existed
+	search.BackfillExifValues(client, logger)
+
 	return client
 }
\ No newline at end of file
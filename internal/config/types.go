@@ -26,6 +26,9 @@ type RedisConfig struct {
 // QueueConfig holds the configuration for the job queue.
 type QueueConfig struct {
 	RedisConfig `mapstructure:",squash"`
+	// Backend is "redis", "postgres" or "memory". When empty, Redis is used if
+	// enabled and memory otherwise, Postgres has to be chosen.
+	Backend string `json:"backend" mapstructure:"backend"`
 }
 
 // DatabaseConfig holds the configuration for the database connection.
@@ -63,6 +66,71 @@ type StorageMetricsConfig struct {
 	IntervalSeconds int  `json:"interval_seconds" mapstructure:"interval_seconds"`
 }
 
+// StorageConfig holds the configuration for where image files are stored.
+type StorageConfig struct {
+	// Backend is "local" to keep files in the base directory or "s3" for an
+	// S3-compatible bucket
+	Backend string   `json:"backend" mapstructure:"backend"`
+	S3      S3Config `json:"s3" mapstructure:"s3"`
+	// Locations are additional named backends, such as the target of a
+	// storage migration. Each image records the location holding its files,
+	// the backend above is the default one.
+	Locations map[string]StorageLocation `json:"locations" mapstructure:"locations"`
+}
+
+// StorageLocation holds the configuration for a named storage location.
+type StorageLocation struct {
+	Backend string `json:"backend" mapstructure:"backend"`
+	// Directory keeps the files of a local location, relative to the base
+	// directory unless absolute
+	Directory string   `json:"directory" mapstructure:"directory"`
+	S3        S3Config `json:"s3" mapstructure:"s3"`
+}
+
+// S3Config holds the configuration for an S3-compatible storage bucket.
+type S3Config struct {
+	// Endpoint is the URL of the service, AWS in Region when empty
+	Endpoint string `json:"endpoint" mapstructure:"endpoint"`
+	Region   string `json:"region" mapstructure:"region"`
+	Bucket   string `json:"bucket" mapstructure:"bucket"`
+	// Prefix is prepended to every key, to share a bucket
+	Prefix          string `json:"prefix" mapstructure:"prefix"`
+	AccessKeyID     string `json:"access_key_id" mapstructure:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key" mapstructure:"secret_access_key"`
+	// UsePathStyle addresses the bucket as endpoint/bucket instead of
+	// bucket.endpoint, most self-hosted servers need it
+	UsePathStyle bool `json:"use_path_style" mapstructure:"use_path_style"`
+}
+
+// TrashConfig holds configuration for deleted images kept in the trash.
+type TrashConfig struct {
+	RetentionDays int `json:"retention_days" mapstructure:"retention_days"`
+}
+
+// IIIFConfig holds configuration for the IIIF Image API.
+type IIIFConfig struct {
+	// PyramidMinMegapixels builds a tile pyramid for uploaded and edited
+	// images at least this large, 0 only builds them when asked for
+	PyramidMinMegapixels int `json:"pyramid_min_megapixels" mapstructure:"pyramid_min_megapixels"`
+}
+
+// ResponsiveConfig holds configuration for responsive image delivery.
+type ResponsiveConfig struct {
+	// Widths is the ladder srcset widths are picked from, requested widths
+	// are snapped to it so few distinct transforms get cached
+	Widths []int `json:"widths" mapstructure:"widths"`
+	// Sizes is the sizes attribute returned when a request has none
+	Sizes string `json:"sizes" mapstructure:"sizes"`
+}
+
+// TransformsConfig holds configuration for image transforms.
+type TransformsConfig struct {
+	// Presets maps the name of a transform preset to its transform as a
+	// query string, like "format=webp&w=1080&h=1080&fit=cover". Presets
+	// saved through the admin API take precedence over these.
+	Presets map[string]string `json:"presets" mapstructure:"presets"`
+}
+
 // ImageCacheConfig holds caching configuration specific to images.
 type ImageCacheConfig struct {
 	HTTPMaxAgeSeconds          int `json:"http_max_age_seconds" mapstructure:"http_max_age_seconds"`
@@ -95,11 +163,16 @@ type VizConfig struct {
 	Logging        LoggingConfig        `json:"logging" mapstructure:"logging"`
 	BaseDir        string               `json:"base_directory" mapstructure:"base_directory"`
 	Upload         UploadConfig         `json:"upload" mapstructure:"upload"`
+	Storage        StorageConfig        `json:"storage" mapstructure:"storage"`
 	Database       DatabaseConfig       `json:"database" mapstructure:"database"`
 	Queue          QueueConfig          `json:"redis" mapstructure:"redis"`
 	Libvips        LibvipsConfig        `json:"libvips" mapstructure:"libvips"`
 	Cache          CacheConfig          `json:"cache" mapstructure:"cache"`
 	UserManagement UserManagementConfig	`json:"user_management" mapstructure:"user_management"`
 	StorageMetrics StorageMetricsConfig `json:"storage_metrics" mapstructure:"storage_metrics"`
+	Trash          TrashConfig          `json:"trash" mapstructure:"trash"`
 	Security       SecurityConfig       `json:"security" mapstructure:"security"`
+	IIIF           IIIFConfig           `json:"iiif" mapstructure:"iiif"`
+	Responsive     ResponsiveConfig     `json:"responsive" mapstructure:"responsive"`
+	Transforms     TransformsConfig     `json:"transforms" mapstructure:"transforms"`
 }
@@ -10,6 +10,7 @@ import (
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 
+	"viz/internal/dto"
 	"viz/internal/entities"
 )
 
@@ -21,6 +22,7 @@ type TokenOptions struct {
 	ShowMetadata  bool
 	Password      string // Plain text password (will be hashed)
 	Description   string
+	Watermark     *dto.WatermarkProfile // Drawn on everything served with the token
 }
 
 // CreateToken stores a random opaque token (32 bytes) in the database.
@@ -71,6 +73,7 @@ func CreateTokenWithOptions(db *gorm.DB, uids []string, opts TokenOptions) (stri
 		Password:      passwordHash,
 		Description:   description,
 		ExpiresAt:     expires,
+		Watermark:     opts.Watermark,
 		CreatedAt:     time.Now(),
 	}
 
@@ -1,5 +1,11 @@
 package entities
 
+import (
+	"time"
+
+	"viz/internal/dto"
+)
+
 // Custom, non-generated entity types live here. This file is safe from code
 // generation and can be used to add fields that shouldn't appear in the DTOs
 // produced by the OpenAPI generator.
@@ -32,3 +38,100 @@ func FromUser(u User, password *string) UserWithPassword {
 func (ImageAsset) TableName() string {
 	return "images"
 }
+
+// ImageWithExifValues embeds the generated ImageAsset entity and adds numeric
+// copies of the EXIF fields that search can compare and range over. The EXIF
+// values in the generated type are kept as strings (e.g. "1/250 sec.",
+// "f/2.8") so they can't be indexed or compared directly.
+type ImageWithExifValues struct {
+	ImageAsset
+	ExifIso             *float64 `gorm:"column:exif_iso;index"`
+	ExifFNumber         *float64 `gorm:"column:exif_f_number;index"`
+	ExifFocalLength     *float64 `gorm:"column:exif_focal_length;index"`
+	ExifFocalLength35mm *float64 `gorm:"column:exif_focal_length_35mm"`
+	// ExifExposureTime is the shutter speed in seconds
+	ExifExposureTime *float64 `gorm:"column:exif_exposure_time;index"`
+	// ExifValuesParsedAt is when the columns were last filled from the
+	// EXIF, whether or not any value could be parsed
+	ExifValuesParsedAt *time.Time `gorm:"column:exif_values_parsed_at"`
+}
+
+// TableName ensures GORM uses the same table as the generated ImageAsset type.
+func (ImageWithExifValues) TableName() string {
+	return "images"
+}
+
+// ImageWithEditHistory embeds the generated ImageAsset entity and adds the
+// earlier edit stacks kept for undo, which aren't part of the API's image.
+type ImageWithEditHistory struct {
+	ImageAsset
+	// EditHistory holds earlier edit stacks, most recent last
+	EditHistory [][]dto.ImageEdit `gorm:"column:edit_history;serializer:json;type:JSONB"`
+}
+
+// TableName ensures GORM uses the same table as the generated ImageAsset type.
+func (ImageWithEditHistory) TableName() string {
+	return "images"
+}
+
+// Pipeline groups the worker jobs run for one image, each step starting
+// once the steps it depends on have completed. The steps are the worker
+// jobs with this pipeline's UID, linked by WorkerJobDependency rows.
+type Pipeline struct {
+	ID          uint `gorm:"primarykey"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Uid         string `gorm:"uniqueIndex"`
+	Name        string
+	ImageUid    *string `gorm:"index"`
+	OwnerUid    *string
+	Status      string
+	CompletedAt *time.Time
+}
+
+// WorkerJobDependency links a pipeline step to a step it waits for
+type WorkerJobDependency struct {
+	ID          uint   `gorm:"primarykey"`
+	PipelineUid string `gorm:"index"`
+	ParentUid   string `gorm:"index"`
+	ChildUid    string `gorm:"index"`
+}
+
+// CronJob is the state of a scheduled maintenance job, its schedule can be
+// changed at runtime so it's kept here rather than in the config
+type CronJob struct {
+	ID             uint `gorm:"primarykey"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Name           string `gorm:"uniqueIndex"`
+	Schedule       string
+	Paused         bool
+	LastRunAt      *time.Time
+	LastDurationMs *int64
+	LastError      *string
+	NextRunAt      *time.Time
+}
+
+// TransformPreset is a named transform saved through the admin API, it's
+// generated for every image like the thumbnail and preview
+type TransformPreset struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Name      string `gorm:"uniqueIndex"`
+	Params    string
+}
+
+// ColourProfile is an ICC profile uploaded through the admin API for
+// transforms to convert to or soft-proof against, by name. The profile
+// itself is in storage under its checksum.
+type ColourProfile struct {
+	ID          uint `gorm:"primarykey"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Name        string `gorm:"uniqueIndex"`
+	Checksum    string
+	ColourSpace string
+	Class       string
+	Size        int64
+}
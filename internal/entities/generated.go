@@ -13,6 +13,8 @@ type WorkerJob struct {
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	// Attempts Number of times the job has run, including retries
+	Attempts int
 	// Command Job command
 	Command *string
 	// CompletedAt Completed timestamp
@@ -25,8 +27,14 @@ type WorkerJob struct {
 	ErrorMsg *string
 	// ImageUid Related image UID
 	ImageUid *string
+	// OwnerUid User the job runs for, jobs are shared fairly between owners
+	OwnerUid *string
 	// Payload Job payload
 	Payload *string
+	// PipelineUid Pipeline the job is a step of
+	PipelineUid *string `gorm:"index:idx_worker_jobs_pipeline_uid,priority:1"`
+	// Priority Scheduling priority, higher runs first (interactive 10, normal 0, backfill -10)
+	Priority int
 	// StartedAt Started timestamp
 	StartedAt *time.Time
 	// Status Job status
@@ -41,13 +49,17 @@ type WorkerJob struct {
 
 func (e WorkerJob) DTO() dto.WorkerJob {
 	return dto.WorkerJob{
+		Attempts:    e.Attempts,
 		Command:     e.Command,
 		CompletedAt: e.CompletedAt,
 		EnqueuedAt:  e.EnqueuedAt,
 		ErrorCode:   e.ErrorCode,
 		ErrorMsg:    e.ErrorMsg,
 		ImageUid:    e.ImageUid,
+		OwnerUid:    e.OwnerUid,
 		Payload:     e.Payload,
+		PipelineUid: e.PipelineUid,
+		Priority:    e.Priority,
 		StartedAt:   e.StartedAt,
 		Status:      e.Status,
 		Topic:       e.Topic,
@@ -58,13 +70,17 @@ func (e WorkerJob) DTO() dto.WorkerJob {
 
 func WorkerJobFromDTO(d dto.WorkerJob) WorkerJob {
 	return WorkerJob{
+		Attempts:    d.Attempts,
 		Command:     d.Command,
 		CompletedAt: d.CompletedAt,
 		EnqueuedAt:  d.EnqueuedAt,
 		ErrorCode:   d.ErrorCode,
 		ErrorMsg:    d.ErrorMsg,
 		ImageUid:    d.ImageUid,
+		OwnerUid:    d.OwnerUid,
 		Payload:     d.Payload,
+		PipelineUid: d.PipelineUid,
+		Priority:    d.Priority,
 		StartedAt:   d.StartedAt,
 		Status:      d.Status,
 		Topic:       d.Topic,
@@ -137,9 +153,11 @@ type CollectionDetailResponse struct {
 	OwnerID *string
 	Owner   *User `gorm:"foreignKey:OwnerID;references:Uid"`
 	// Private Is private
-	Private     *bool
-	ThumbnailID *string
-	Thumbnail   *ImageAsset `gorm:"foreignKey:ThumbnailID;references:Uid"`
+	Private       *bool
+	SavedSearchID *string
+	SavedSearch   *SavedSearch `gorm:"foreignKey:SavedSearchID;references:Uid"`
+	ThumbnailID   *string
+	Thumbnail     *ImageAsset `gorm:"foreignKey:ThumbnailID;references:Uid"`
 	// Uid Collection UID
 	Uid string `gorm:"uniqueIndex"`
 }
@@ -167,6 +185,13 @@ func (e CollectionDetailResponse) DTO() dto.CollectionDetailResponse {
 			return nil
 		}(),
 		Private: e.Private,
+		SavedSearch: func() *dto.SavedSearch {
+			if e.SavedSearch != nil {
+				d := e.SavedSearch.DTO()
+				return &d
+			}
+			return nil
+		}(),
 		Thumbnail: func() *dto.ImageAsset {
 			if e.Thumbnail != nil {
 				d := e.Thumbnail.DTO()
@@ -199,6 +224,12 @@ func CollectionDetailResponseFromDTO(d dto.CollectionDetailResponse) CollectionD
 			return nil
 		}(),
 		Private: d.Private,
+		SavedSearchID: func() *string {
+			if d.SavedSearch != nil {
+				return &d.SavedSearch.Uid
+			}
+			return nil
+		}(),
 		ThumbnailID: func() *string {
 			if d.Thumbnail != nil {
 				return &d.Thumbnail.Uid
@@ -230,9 +261,11 @@ type Collection struct {
 	OwnerID *string
 	Owner   *User `gorm:"foreignKey:OwnerID;references:Uid"`
 	// Private Is private
-	Private     *bool
-	ThumbnailID *string
-	Thumbnail   *ImageAsset `gorm:"foreignKey:ThumbnailID;references:Uid"`
+	Private       *bool
+	SavedSearchID *string
+	SavedSearch   *SavedSearch `gorm:"foreignKey:SavedSearchID;references:Uid"`
+	ThumbnailID   *string
+	Thumbnail     *ImageAsset `gorm:"foreignKey:ThumbnailID;references:Uid"`
 	// Uid Collection UID
 	Uid string `gorm:"uniqueIndex"`
 }
@@ -261,6 +294,13 @@ func (e Collection) DTO() dto.Collection {
 			return nil
 		}(),
 		Private: e.Private,
+		SavedSearch: func() *dto.SavedSearch {
+			if e.SavedSearch != nil {
+				d := e.SavedSearch.DTO()
+				return &d
+			}
+			return nil
+		}(),
 		Thumbnail: func() *dto.ImageAsset {
 			if e.Thumbnail != nil {
 				d := e.Thumbnail.DTO()
@@ -294,6 +334,12 @@ func CollectionFromDTO(d dto.Collection) Collection {
 			return nil
 		}(),
 		Private: d.Private,
+		SavedSearchID: func() *string {
+			if d.SavedSearch != nil {
+				return &d.SavedSearch.Uid
+			}
+			return nil
+		}(),
 		ThumbnailID: func() *string {
 			if d.Thumbnail != nil {
 				return &d.Thumbnail.Uid
@@ -342,9 +388,12 @@ type ImageAsset struct {
 	UpdatedAt time.Time
 	// Description Image description
 	Description *string
-	Exif        *dto.ImageEXIF `gorm:"serializer:json;type:JSONB"`
+	// Edits Non-destructive edits applied to every rendition of the image, in order
+	Edits *[]dto.ImageEdit `gorm:"serializer:json;type:JSONB"`
+	Exif  *dto.ImageEXIF   `gorm:"serializer:json;type:JSONB"`
 	// Favourited Is favourited
 	Favourited *bool
+	FocalPoint *dto.FocalPoint `gorm:"serializer:json;type:JSONB"`
 	// Height Image height
 	Height        int32
 	ImageMetadata *dto.ImageMetadata `gorm:"serializer:json;type:JSONB"`
@@ -357,6 +406,8 @@ type ImageAsset struct {
 	Private bool
 	// Processed Is processed
 	Processed bool
+	// StorageLocation Storage location holding the image's files, the default location when empty
+	StorageLocation *string `gorm:"index:idx_images_storage_location,priority:1"`
 	// TakenAt Taken time
 	TakenAt *time.Time
 	// Uid Image UID
@@ -372,8 +423,10 @@ func (e ImageAsset) DTO() dto.ImageAsset {
 		CreatedAt:     e.CreatedAt,
 		UpdatedAt:     e.UpdatedAt,
 		Description:   e.Description,
+		Edits:         e.Edits,
 		Exif:          e.Exif,
 		Favourited:    e.Favourited,
+		FocalPoint:    e.FocalPoint,
 		Height:        e.Height,
 		ImageMetadata: e.ImageMetadata,
 		ImagePaths:    e.ImagePaths,
@@ -385,10 +438,11 @@ func (e ImageAsset) DTO() dto.ImageAsset {
 			}
 			return nil
 		}(),
-		Private:   e.Private,
-		Processed: e.Processed,
-		TakenAt:   e.TakenAt,
-		Uid:       e.Uid,
+		Private:         e.Private,
+		Processed:       e.Processed,
+		StorageLocation: e.StorageLocation,
+		TakenAt:         e.TakenAt,
+		Uid:             e.Uid,
 		UploadedBy: func() *dto.User {
 			if e.UploadedBy != nil {
 				d := e.UploadedBy.DTO()
@@ -405,8 +459,10 @@ func ImageAssetFromDTO(d dto.ImageAsset) ImageAsset {
 		CreatedAt:     d.CreatedAt,
 		UpdatedAt:     d.UpdatedAt,
 		Description:   d.Description,
+		Edits:         d.Edits,
 		Exif:          d.Exif,
 		Favourited:    d.Favourited,
+		FocalPoint:    d.FocalPoint,
 		Height:        d.Height,
 		ImageMetadata: d.ImageMetadata,
 		ImagePaths:    d.ImagePaths,
@@ -417,10 +473,11 @@ func ImageAssetFromDTO(d dto.ImageAsset) ImageAsset {
 			}
 			return nil
 		}(),
-		Private:   d.Private,
-		Processed: d.Processed,
-		TakenAt:   d.TakenAt,
-		Uid:       d.Uid,
+		Private:         d.Private,
+		Processed:       d.Processed,
+		StorageLocation: d.StorageLocation,
+		TakenAt:         d.TakenAt,
+		Uid:             d.Uid,
 		UploadedByID: func() *string {
 			if d.UploadedBy != nil {
 				return &d.UploadedBy.Uid
@@ -453,6 +510,8 @@ type DownloadToken struct {
 	ShowMetadata bool
 	// Uid 64-character hex token that serves as both unique identifier and authorization key
 	Uid string `gorm:"uniqueIndex"`
+	// Watermark Watermark drawn on every image served through this token, regardless of the request's parameters
+	Watermark *dto.WatermarkProfile `gorm:"serializer:json;type:JSONB"`
 }
 
 func (e DownloadToken) DTO() dto.DownloadToken {
@@ -467,6 +526,7 @@ func (e DownloadToken) DTO() dto.DownloadToken {
 		Password:      e.Password,
 		ShowMetadata:  e.ShowMetadata,
 		Uid:           e.Uid,
+		Watermark:     e.Watermark,
 	}
 }
 
@@ -482,6 +542,7 @@ func DownloadTokenFromDTO(d dto.DownloadToken) DownloadToken {
 		Password:      d.Password,
 		ShowMetadata:  d.ShowMetadata,
 		Uid:           d.Uid,
+		Watermark:     d.Watermark,
 	}
 }
 
@@ -767,3 +828,52 @@ func ImageUploadResponseFromDTO(d dto.ImageUploadResponse) ImageUploadResponse {
 		Uid:      d.Uid,
 	}
 }
+
+// SavedSearch is a GORM entity inferred from dto.SavedSearch
+type SavedSearch struct {
+	ID        uint           `gorm:"primarykey" json:"-"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// Name Saved search name
+	Name    string
+	OwnerID *string
+	Owner   *User `gorm:"foreignKey:OwnerID;references:Uid"`
+	// Query Raw search query, as accepted by /search
+	Query string
+	// Uid Saved search UID
+	Uid string `gorm:"uniqueIndex"`
+}
+
+func (e SavedSearch) DTO() dto.SavedSearch {
+	return dto.SavedSearch{
+		CreatedAt: e.CreatedAt,
+		UpdatedAt: e.UpdatedAt,
+		Name:      e.Name,
+		Owner: func() *dto.User {
+			if e.Owner != nil {
+				d := e.Owner.DTO()
+				return &d
+			}
+			return nil
+		}(),
+		Query: e.Query,
+		Uid:   e.Uid,
+	}
+}
+
+func SavedSearchFromDTO(d dto.SavedSearch) SavedSearch {
+	return SavedSearch{
+		CreatedAt: d.CreatedAt,
+		UpdatedAt: d.UpdatedAt,
+		Name:      d.Name,
+		OwnerID: func() *string {
+			if d.Owner != nil {
+				return &d.Owner.Uid
+			}
+			return nil
+		}(),
+		Query: d.Query,
+		Uid:   d.Uid,
+	}
+}
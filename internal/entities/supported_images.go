@@ -1,5 +1,11 @@
 package entities
 
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
 type SupportedImageTypes string
 
 const (
@@ -7,6 +13,13 @@ const (
 	JPG  SupportedImageTypes = "jpg"
 	PNG  SupportedImageTypes = "png"
 	TIFF SupportedImageTypes = "tiff"
+	GIF  SupportedImageTypes = "gif"
+	WEBP SupportedImageTypes = "webp"
+	HEIC SupportedImageTypes = "heic"
+	HEIF SupportedImageTypes = "heif"
+	AVIF SupportedImageTypes = "avif"
+	JXL  SupportedImageTypes = "jxl"
+	PSD  SupportedImageTypes = "psd"
 )
 
 var SUPPORTED_IMAGE_TYPES = []SupportedImageTypes{
@@ -14,6 +27,13 @@ var SUPPORTED_IMAGE_TYPES = []SupportedImageTypes{
 	JPG,
 	PNG,
 	TIFF,
+	GIF,
+	WEBP,
+	HEIC,
+	HEIF,
+	AVIF,
+	JXL,
+	PSD,
 }
 
 /*
@@ -35,6 +55,7 @@ const (
 	DATA SupportedRAWFiles = "data"
 	DCR  SupportedRAWFiles = "dcr"
 	DCS  SupportedRAWFiles = "dcs"
+	DNG  SupportedRAWFiles = "dng"
 	DRF  SupportedRAWFiles = "drf"
 	EIP  SupportedRAWFiles = "eip"
 	ERF  SupportedRAWFiles = "erf"
@@ -78,6 +99,7 @@ var SUPPORTED_RAW_FILES = []SupportedRAWFiles{
 	DATA,
 	DCR,
 	DCS,
+	DNG,
 	DRF,
 	EIP,
 	ERF,
@@ -108,3 +130,16 @@ var SUPPORTED_RAW_FILES = []SupportedRAWFiles{
 	SRW,
 	X3F,
 }
+
+// IsSupportedImageType reports whether images of a file type, as detected
+// from the file's contents, can be uploaded
+func IsSupportedImageType(fileType string) bool {
+	return slices.Contains(SUPPORTED_IMAGE_TYPES, SupportedImageTypes(fileType))
+}
+
+// IsRAWFile reports whether a file name has the extension of a camera RAW
+// format
+func IsRAWFile(fileName string) bool {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(fileName)), ".")
+	return slices.Contains(SUPPORTED_RAW_FILES, SupportedRAWFiles(ext))
+}
@@ -0,0 +1,170 @@
+// Package icc reads the headers of ICC colour profiles and writes the
+// simple matrix profiles libvips has no built in copy of.
+package icc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+)
+
+const headerSize = 128
+
+// Colour spaces of the profiles transforms use
+const (
+	ColourSpaceRGB  = "RGB"
+	ColourSpaceCMYK = "CMYK"
+	ColourSpaceGray = "GRAY"
+)
+
+// Header is the part of an ICC profile header deciding what it can be used for
+type Header struct {
+	// Class is the profile's device class, like mntr for displays and prtr
+	// for printers
+	Class string
+	// ColourSpace is the colour space of the device, one of the
+	// ColourSpace constants for the profiles transforms accept
+	ColourSpace string
+	// Version is the profile's major version
+	Version int
+}
+
+// ParseHeader reads the header of an ICC profile, checking it's one
+func ParseHeader(data []byte) (Header, error) {
+	if len(data) < headerSize+4 {
+		return Header{}, fmt.Errorf("not an ICC profile: too short")
+	}
+
+	if string(data[36:40]) != "acsp" {
+		return Header{}, fmt.Errorf("not an ICC profile: missing signature")
+	}
+
+	if size := binary.BigEndian.Uint32(data[0:4]); int(size) > len(data) || size < headerSize+4 {
+		return Header{}, fmt.Errorf("ICC profile is truncated, %d of %d bytes", len(data), size)
+	}
+
+	return Header{
+		Class:       strings.TrimSpace(string(data[12:16])),
+		ColourSpace: strings.TrimSpace(string(data[16:20])),
+		Version:     int(data[8]),
+	}, nil
+}
+
+// XYZ is a CIE XYZ colour relative to the D50 illuminant of the profile
+// connection space
+type XYZ struct {
+	X, Y, Z float64
+}
+
+// D50 is the white point of the profile connection space
+var D50 = XYZ{0.9642, 1.0, 0.8249}
+
+// MatrixProfile is an RGB display profile defined by its primaries and a
+// single gamma, which covers the common working spaces
+type MatrixProfile struct {
+	Description string
+	Copyright   string
+	// Red, Green and Blue are the primaries chromatically adapted to D50
+	Red, Green, Blue XYZ
+	Gamma            float64
+}
+
+// AdobeRGB is a profile compatible with Adobe RGB (1998), the usual print
+// working space, which libvips has no built in profile for
+var AdobeRGB = MatrixProfile{
+	Description: "Compatible with Adobe RGB (1998)",
+	Copyright:   "No copyright, use freely",
+	Red:         XYZ{0.6097, 0.3111, 0.0195},
+	Green:       XYZ{0.2053, 0.6257, 0.0609},
+	Blue:        XYZ{0.1492, 0.0632, 0.7446},
+	Gamma:       563.0 / 256.0,
+}
+
+// Bytes encodes the profile as a version 2 ICC profile
+func (p MatrixProfile) Bytes() []byte {
+	curve := curveTag(p.Gamma)
+	tags := []struct {
+		sig  string
+		data []byte
+	}{
+		{"desc", descTag(p.Description)},
+		{"cprt", textTag(p.Copyright)},
+		{"wtpt", xyzTag(D50)},
+		{"rXYZ", xyzTag(p.Red)},
+		{"gXYZ", xyzTag(p.Green)},
+		{"bXYZ", xyzTag(p.Blue)},
+		{"rTRC", curve},
+		{"gTRC", curve},
+		{"bTRC", curve},
+	}
+
+	var table, data bytes.Buffer
+	offset := headerSize + 4 + 12*len(tags)
+
+	_ = binary.Write(&table, binary.BigEndian, uint32(len(tags)))
+	at := 0
+	for i, tag := range tags {
+		// the three curves are the same, they share their data
+		if i == 0 || !bytes.Equal(tag.data, tags[i-1].data) {
+			at = offset + data.Len()
+			data.Write(tag.data)
+			for data.Len()%4 != 0 {
+				data.WriteByte(0)
+			}
+		}
+
+		table.WriteString(tag.sig)
+		_ = binary.Write(&table, binary.BigEndian, uint32(at))
+		_ = binary.Write(&table, binary.BigEndian, uint32(len(tag.data)))
+	}
+
+	header := make([]byte, headerSize)
+	binary.BigEndian.PutUint32(header[0:], uint32(offset+data.Len()))
+	binary.BigEndian.PutUint32(header[8:], 0x02100000)
+	copy(header[12:], "mntr")
+	copy(header[16:], "RGB ")
+	copy(header[20:], "XYZ ")
+	copy(header[36:], "acsp")
+	copy(header[68:], xyzNumber(D50))
+
+	out := append(header, table.Bytes()...)
+	return append(out, data.Bytes()...)
+}
+
+func s15Fixed16(v float64) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(int32(math.Round(v*65536))))
+	return b
+}
+
+func xyzNumber(c XYZ) []byte {
+	return append(append(s15Fixed16(c.X), s15Fixed16(c.Y)...), s15Fixed16(c.Z)...)
+}
+
+func xyzTag(c XYZ) []byte {
+	return append([]byte("XYZ \x00\x00\x00\x00"), xyzNumber(c)...)
+}
+
+func curveTag(gamma float64) []byte {
+	b := []byte("curv\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00")
+	binary.BigEndian.PutUint16(b[12:], uint16(math.Round(gamma*256)))
+	return b
+}
+
+func textTag(text string) []byte {
+	return append([]byte("text\x00\x00\x00\x00"+text), 0)
+}
+
+// descTag is a version 2 textDescriptionType holding only the ASCII
+// description, the Unicode and ScriptCode parts are left empty
+func descTag(text string) []byte {
+	var b bytes.Buffer
+	b.WriteString("desc\x00\x00\x00\x00")
+	_ = binary.Write(&b, binary.BigEndian, uint32(len(text)+1))
+	b.WriteString(text)
+	b.WriteByte(0)
+	b.Write(make([]byte, 4+4+2+1+67))
+	return b.Bytes()
+}
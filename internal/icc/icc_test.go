@@ -0,0 +1,63 @@
+package icc
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestMatrixProfileBytes(t *testing.T) {
+	data := AdobeRGB.Bytes()
+
+	header, err := ParseHeader(data)
+	if err != nil {
+		t.Fatalf("ParseHeader() error = %v", err)
+	}
+	if header.Class != "mntr" || header.ColourSpace != ColourSpaceRGB || header.Version != 2 {
+		t.Errorf("header = %+v", header)
+	}
+	if size := binary.BigEndian.Uint32(data); int(size) != len(data) {
+		t.Errorf("declared size %d, profile is %d bytes", size, len(data))
+	}
+
+	tags := map[string][2]uint32{}
+	count := binary.BigEndian.Uint32(data[headerSize:])
+	for i := range count {
+		entry := data[headerSize+4+12*i:]
+		offset, size := binary.BigEndian.Uint32(entry[4:]), binary.BigEndian.Uint32(entry[8:])
+		if offset%4 != 0 || int(offset+size) > len(data) {
+			t.Errorf("tag %s at %d+%d is misaligned or outside the profile", entry[:4], offset, size)
+		}
+		tags[string(entry[:4])] = [2]uint32{offset, size}
+	}
+
+	for _, sig := range []string{"desc", "cprt", "wtpt", "rXYZ", "gXYZ", "bXYZ", "rTRC", "gTRC", "bTRC"} {
+		if _, ok := tags[sig]; !ok {
+			t.Errorf("missing tag %s", sig)
+		}
+	}
+	if tags["rTRC"] != tags["bTRC"] {
+		t.Error("the curves aren't shared")
+	}
+
+	trc := data[tags["rTRC"][0]:]
+	if string(trc[:4]) != "curv" || binary.BigEndian.Uint16(trc[12:]) != 563 {
+		t.Errorf("rTRC = %x", trc[:14])
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	if _, err := ParseHeader([]byte("not a profile")); err == nil {
+		t.Error("short data parsed as a profile")
+	}
+
+	data := AdobeRGB.Bytes()
+	if _, err := ParseHeader(data[:len(data)-10]); err == nil {
+		t.Error("truncated profile parsed")
+	}
+
+	broken := append([]byte{}, data...)
+	copy(broken[36:], "xxxx")
+	if _, err := ParseHeader(broken); err == nil {
+		t.Error("profile without signature parsed")
+	}
+}
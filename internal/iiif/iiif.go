@@ -0,0 +1,379 @@
+// Package iiif parses IIIF Image API 3.0 requests and describes images in
+// its info.json format, see https://iiif.io/api/image/3.0/. Requests name
+// a region of the image, the size to scale it to, a rotation, a quality
+// and a format, and are resolved against the size of the image into the
+// Plan that's rendered.
+package iiif
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	Context  = "http://iiif.io/api/image/3/context.json"
+	Protocol = "http://iiif.io/api/image"
+	Type     = "ImageService3"
+	Profile  = "level2"
+	// TileSize is the width and height of the tiles viewers are told to
+	// request
+	TileSize = 512
+	// MaxWidth, MaxHeight and MaxArea bound the image a single request
+	// renders, larger views are put together from tiles
+	MaxWidth  = 10000
+	MaxHeight = 10000
+	MaxArea   = 50_000_000
+)
+
+const (
+	QualityDefault = "default"
+	QualityColor   = "color"
+	QualityGray    = "gray"
+	QualityBitonal = "bitonal"
+)
+
+const (
+	FormatJPG  = "jpg"
+	FormatPNG  = "png"
+	FormatWebP = "webp"
+)
+
+// ContentTypes are the media types of the formats images are served in
+var ContentTypes = map[string]string{
+	FormatJPG:  "image/jpeg",
+	FormatPNG:  "image/png",
+	FormatWebP: "image/webp",
+}
+
+// Region is the part of the image a request is for, in pixels or as
+// percentages of the image size
+type Region struct {
+	Full    bool
+	Square  bool
+	Percent bool
+	X, Y    float64
+	W, H    float64
+}
+
+// Size is what a request scales its region to. W or H is 0 when the
+// request leaves it out to keep the aspect ratio.
+type Size struct {
+	// Upscale allows sizes larger than the region, the ^ prefix
+	Upscale bool
+	Max     bool
+	// Percent is the scale of a pct:n size
+	Percent float64
+	W, H    int
+	// Confined scales to fit in W x H keeping the aspect ratio, the !
+	// prefix
+	Confined bool
+}
+
+// Rotation is the clockwise rotation in degrees applied after scaling,
+// Mirror flips the image horizontally first
+type Rotation struct {
+	Mirror  bool
+	Degrees float64
+}
+
+// Request is a parsed image request
+type Request struct {
+	Region   Region
+	Size     Size
+	Rotation Rotation
+	Quality  string
+	Format   string
+}
+
+// Rect is a rectangle of the image in pixels
+type Rect struct {
+	X, Y, W, H int
+}
+
+// Plan is a request resolved against the size of an image
+type Plan struct {
+	Region Rect
+	// Width and Height are the size the region is scaled to, before it's
+	// rotated
+	Width, Height int
+	Rotation      Rotation
+	Quality       string
+	Format        string
+}
+
+// ParseRequest parses the region, size, rotation and quality.format path
+// segments of an image request
+func ParseRequest(region, size, rotation, qualityFormat string) (*Request, error) {
+	var req Request
+	var err error
+
+	if req.Region, err = ParseRegion(region); err != nil {
+		return nil, err
+	}
+
+	if req.Size, err = ParseSize(size); err != nil {
+		return nil, err
+	}
+
+	if req.Rotation, err = ParseRotation(rotation); err != nil {
+		return nil, err
+	}
+
+	quality, format, ok := strings.Cut(qualityFormat, ".")
+	if !ok {
+		return nil, fmt.Errorf("missing format in %q", qualityFormat)
+	}
+
+	switch quality {
+	case QualityDefault, QualityColor, QualityGray, QualityBitonal:
+		req.Quality = quality
+	default:
+		return nil, fmt.Errorf("unsupported quality %q", quality)
+	}
+
+	if _, ok := ContentTypes[format]; !ok {
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+	req.Format = format
+
+	return &req, nil
+}
+
+// ParseRegion parses full, square, x,y,w,h and pct:x,y,w,h
+func ParseRegion(s string) (Region, error) {
+	switch s {
+	case "full":
+		return Region{Full: true}, nil
+	case "square":
+		return Region{Square: true}, nil
+	}
+
+	var r Region
+	s, r.Percent = strings.CutPrefix(s, "pct:")
+
+	values, err := parseNumbers(s, 4, r.Percent)
+	if err != nil {
+		return Region{}, fmt.Errorf("invalid region: %w", err)
+	}
+
+	r.X, r.Y, r.W, r.H = values[0], values[1], values[2], values[3]
+	if r.W <= 0 || r.H <= 0 {
+		return Region{}, fmt.Errorf("invalid region: width and height must be above 0")
+	}
+
+	return r, nil
+}
+
+// ParseSize parses max, w,, ,h, pct:n, w,h and !w,h, each optionally
+// prefixed with ^ to allow upscaling
+func ParseSize(s string) (Size, error) {
+	var size Size
+	s, size.Upscale = strings.CutPrefix(s, "^")
+
+	if s == "max" {
+		size.Max = true
+		return size, nil
+	}
+
+	if pct, ok := strings.CutPrefix(s, "pct:"); ok {
+		values, err := parseNumbers(pct, 1, true)
+		if err != nil || values[0] <= 0 {
+			return Size{}, fmt.Errorf("invalid size %q", s)
+		}
+
+		size.Percent = values[0]
+		return size, nil
+	}
+
+	s, size.Confined = strings.CutPrefix(s, "!")
+
+	w, h, ok := strings.Cut(s, ",")
+	if !ok {
+		return Size{}, fmt.Errorf("invalid size %q", s)
+	}
+
+	var err error
+	if w != "" {
+		if size.W, err = strconv.Atoi(w); err != nil || size.W <= 0 {
+			return Size{}, fmt.Errorf("invalid size width %q", w)
+		}
+	}
+
+	if h != "" {
+		if size.H, err = strconv.Atoi(h); err != nil || size.H <= 0 {
+			return Size{}, fmt.Errorf("invalid size height %q", h)
+		}
+	}
+
+	if size.W == 0 && size.H == 0 {
+		return Size{}, fmt.Errorf("invalid size %q", s)
+	}
+
+	if size.Confined && (size.W == 0 || size.H == 0) {
+		return Size{}, fmt.Errorf("a confined size needs a width and a height")
+	}
+
+	return size, nil
+}
+
+// ParseRotation parses n and !n for 0 <= n <= 360
+func ParseRotation(s string) (Rotation, error) {
+	var r Rotation
+	s, r.Mirror = strings.CutPrefix(s, "!")
+
+	values, err := parseNumbers(s, 1, true)
+	if err != nil || values[0] > 360 {
+		return Rotation{}, fmt.Errorf("invalid rotation %q", s)
+	}
+
+	r.Degrees = math.Mod(values[0], 360)
+	return r, nil
+}
+
+// parseNumbers parses n comma separated numbers that aren't negative,
+// decimals only when allowed
+func parseNumbers(s string, n int, decimals bool) ([]float64, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != n {
+		return nil, fmt.Errorf("expected %d numbers in %q", n, s)
+	}
+
+	values := make([]float64, n)
+	for i, part := range parts {
+		var err error
+		if decimals {
+			values[i], err = strconv.ParseFloat(part, 64)
+		} else {
+			var v int
+			v, err = strconv.Atoi(part)
+			values[i] = float64(v)
+		}
+
+		if err != nil || values[i] < 0 || math.IsInf(values[i], 0) || math.IsNaN(values[i]) {
+			return nil, fmt.Errorf("invalid number %q", part)
+		}
+	}
+
+	return values, nil
+}
+
+// Resolve works out the pixels a request is for in an imgW x imgH image
+// and the size they are scaled to
+func (r *Request) Resolve(imgW, imgH int) (*Plan, error) {
+	rect, err := r.Region.Rect(imgW, imgH)
+	if err != nil {
+		return nil, err
+	}
+
+	w, h, err := r.Size.Resolve(rect.W, rect.H)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Plan{
+		Region:   rect,
+		Width:    w,
+		Height:   h,
+		Rotation: r.Rotation,
+		Quality:  r.Quality,
+		Format:   r.Format,
+	}, nil
+}
+
+// Rect returns the pixels of an imgW x imgH image the region covers. A
+// region reaching past the image is cut off at its edges, one entirely
+// outside it is an error.
+func (r Region) Rect(imgW, imgH int) (Rect, error) {
+	switch {
+	case r.Full:
+		return Rect{W: imgW, H: imgH}, nil
+	case r.Square:
+		side := min(imgW, imgH)
+		return Rect{X: (imgW - side) / 2, Y: (imgH - side) / 2, W: side, H: side}, nil
+	}
+
+	x, y, w, h := r.X, r.Y, r.W, r.H
+	if r.Percent {
+		x, w = x*float64(imgW)/100, w*float64(imgW)/100
+		y, h = y*float64(imgH)/100, h*float64(imgH)/100
+	}
+
+	left, top := int(math.Round(x)), int(math.Round(y))
+	right, bottom := min(int(math.Round(x+w)), imgW), min(int(math.Round(y+h)), imgH)
+	if left >= imgW || top >= imgH || right <= left || bottom <= top {
+		return Rect{}, fmt.Errorf("region is outside the %dx%d image", imgW, imgH)
+	}
+
+	return Rect{X: left, Y: top, W: right - left, H: bottom - top}, nil
+}
+
+// Resolve returns the width and height a regionW x regionH region is
+// scaled to
+func (s Size) Resolve(regionW, regionH int) (w, h int, err error) {
+	rw, rh := float64(regionW), float64(regionH)
+
+	switch {
+	case s.Max:
+		scale := math.Min(math.Min(MaxWidth/rw, MaxHeight/rh), math.Sqrt(MaxArea/(rw*rh)))
+		if !s.Upscale {
+			scale = math.Min(scale, 1)
+		}
+		// round down so the limits hold
+		w, h = int(rw*scale), int(rh*scale)
+	case s.Percent > 0:
+		if !s.Upscale && s.Percent > 100 {
+			return 0, 0, fmt.Errorf("size above 100%% needs ^ to upscale")
+		}
+		w, h = int(math.Round(rw*s.Percent/100)), int(math.Round(rh*s.Percent/100))
+	case s.Confined:
+		scale := math.Min(float64(s.W)/rw, float64(s.H)/rh)
+		if !s.Upscale {
+			scale = math.Min(scale, 1)
+		}
+		w, h = int(math.Round(rw*scale)), int(math.Round(rh*scale))
+	case s.H == 0:
+		w, h = s.W, int(math.Round(rh*float64(s.W)/rw))
+	case s.W == 0:
+		w, h = int(math.Round(rw*float64(s.H)/rh)), s.H
+	default:
+		w, h = s.W, s.H
+	}
+
+	w, h = max(w, 1), max(h, 1)
+
+	if !s.Upscale && (w > regionW || h > regionH) {
+		return 0, 0, fmt.Errorf("size %dx%d is larger than the %dx%d region, use ^ to upscale", w, h, regionW, regionH)
+	}
+
+	if w > MaxWidth || h > MaxHeight || w*h > MaxArea {
+		return 0, 0, fmt.Errorf("size %dx%d is over the %dx%d and %d pixel limits", w, h, MaxWidth, MaxHeight, MaxArea)
+	}
+
+	return w, h, nil
+}
+
+// String returns the plan as the path of the request it's the canonical
+// form of, it identifies the rendered image
+func (p *Plan) String() string {
+	rotation := strconv.FormatFloat(p.Rotation.Degrees, 'f', -1, 64)
+	if p.Rotation.Mirror {
+		rotation = "!" + rotation
+	}
+
+	return fmt.Sprintf("%d,%d,%d,%d/%d,%d/%s/%s.%s",
+		p.Region.X, p.Region.Y, p.Region.W, p.Region.H, p.Width, p.Height, rotation, p.Quality, p.Format)
+}
+
+// ScaleFactors returns the factors an imgW x imgH image is shrunk by for
+// its tiles, down to the factor where one tile covers it
+func ScaleFactors(imgW, imgH int) []int {
+	factors := []int{1}
+	for f := 1; max(imgW, imgH) > TileSize*f; {
+		f *= 2
+		factors = append(factors, f)
+	}
+
+	return factors
+}
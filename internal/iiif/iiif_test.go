@@ -0,0 +1,124 @@
+package iiif
+
+import (
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	// a 4000x3000 image
+	tests := []struct {
+		region, size, rotation, qualityFormat string
+		want                                  string
+	}{
+		{"full", "max", "0", "default.jpg", "0,0,4000,3000/4000,3000/0/default.jpg"},
+		{"square", "500,", "90", "gray.png", "500,0,3000,3000/500,500/90/gray.png"},
+		{"0,0,512,512", "256,", "0", "default.jpg", "0,0,512,512/256,256/0/default.jpg"},
+		// cut off at the image's edge
+		{"3584,2560,512,512", ",100", "!0", "color.webp", "3584,2560,416,440/95,100/!0/color.webp"},
+		{"pct:50,50,50,50", "pct:10", "180", "bitonal.jpg", "2000,1500,2000,1500/200,150/180/bitonal.jpg"},
+		{"full", "!400,400", "0", "default.jpg", "0,0,4000,3000/400,300/0/default.jpg"},
+		{"full", "400,400", "0", "default.jpg", "0,0,4000,3000/400,400/0/default.jpg"},
+		{"0,0,100,100", "^200,", "22.5", "default.png", "0,0,100,100/200,200/22.5/default.png"},
+		{"0,0,100,100", "^!300,200", "0", "default.png", "0,0,100,100/200,200/0/default.png"},
+		{"0,0,100,100", "^max", "0", "default.png", "0,0,100,100/7071,7071/0/default.png"},
+	}
+	for _, tt := range tests {
+		req, err := ParseRequest(tt.region, tt.size, tt.rotation, tt.qualityFormat)
+		if err != nil {
+			t.Errorf("ParseRequest(%s/%s/%s/%s) = %v", tt.region, tt.size, tt.rotation, tt.qualityFormat, err)
+			continue
+		}
+
+		plan, err := req.Resolve(4000, 3000)
+		if err != nil {
+			t.Errorf("Resolve(%s/%s) = %v", tt.region, tt.size, err)
+			continue
+		}
+
+		if got := plan.String(); got != tt.want {
+			t.Errorf("Resolve(%s/%s/%s/%s) = %s, want %s", tt.region, tt.size, tt.rotation, tt.qualityFormat, got, tt.want)
+		}
+	}
+}
+
+func TestResolveMaxFollowsLimits(t *testing.T) {
+	req, err := ParseRequest("full", "max", "0", "default.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := req.Resolve(40000, 20000)
+	if err != nil {
+		t.Fatalf("Resolve() = %v", err)
+	}
+
+	if plan.Width > MaxWidth || plan.Height > MaxHeight || plan.Width*plan.Height > MaxArea || plan.Width != 2*plan.Height {
+		t.Errorf("Resolve() of max = %dx%d", plan.Width, plan.Height)
+	}
+}
+
+func TestInvalidRequests(t *testing.T) {
+	invalid := [][4]string{
+		{"full", "max", "0", "default"},
+		{"full", "max", "0", "default.gif"},
+		{"full", "max", "0", "sepia.jpg"},
+		{"full", "max", "361", "default.jpg"},
+		{"full", "max", "-90", "default.jpg"},
+		{"0,0,0,10", "max", "0", "default.jpg"},
+		{"0,0,10", "max", "0", "default.jpg"},
+		{"0.5,0,10,10", "max", "0", "default.jpg"},
+		{"full", ",", "0", "default.jpg"},
+		{"full", "!100,", "0", "default.jpg"},
+		{"full", "pct:0", "0", "default.jpg"},
+	}
+	for _, segments := range invalid {
+		if _, err := ParseRequest(segments[0], segments[1], segments[2], segments[3]); err == nil {
+			t.Errorf("ParseRequest(%v) succeeded", segments)
+		}
+	}
+
+	// valid syntax, but not for a 4000x3000 image
+	unresolvable := [][2]string{
+		{"4000,0,10,10", "max"},
+		{"0,0,100,100", "200,"},
+		{"full", "pct:150"},
+		{"full", "^20000,"},
+	}
+	for _, segments := range unresolvable {
+		req, err := ParseRequest(segments[0], segments[1], "0", "default.jpg")
+		if err != nil {
+			t.Errorf("ParseRequest(%v) = %v", segments, err)
+			continue
+		}
+
+		if _, err := req.Resolve(4000, 3000); err == nil {
+			t.Errorf("Resolve(%v) succeeded", segments)
+		}
+	}
+}
+
+func TestNewInfo(t *testing.T) {
+	info := NewInfo("https://example.com/api/iiif/abc", 30000, 20000)
+
+	tiles := *info.Tiles
+	if len(tiles) != 1 || tiles[0].Width != TileSize {
+		t.Fatalf("NewInfo() tiles = %+v", tiles)
+	}
+
+	// 30000 / 64 = 469, one tile wide
+	want := []int{1, 2, 4, 8, 16, 32, 64}
+	if got := tiles[0].ScaleFactors; len(got) != len(want) || got[len(got)-1] != 64 {
+		t.Errorf("NewInfo() scale factors = %v, want %v", got, want)
+	}
+
+	sizes := *info.Sizes
+	if len(sizes) == 0 || sizes[0].Width != 469 || sizes[0].Height != 313 {
+		t.Fatalf("NewInfo() sizes = %+v", sizes)
+	}
+
+	for _, size := range sizes {
+		if size.Width > MaxWidth || size.Width*size.Height > MaxArea {
+			t.Errorf("NewInfo() lists %dx%d, over the limits", size.Width, size.Height)
+		}
+	}
+}
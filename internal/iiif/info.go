@@ -0,0 +1,48 @@
+package iiif
+
+import (
+	"viz/internal/dto"
+)
+
+// NewInfo describes a width x height image served under id, the base URI
+// its requests are made against
+func NewInfo(id string, width, height int) dto.IIIFImageInfo {
+	factors := ScaleFactors(width, height)
+
+	// the whole image at each tile level that fits the limits, smallest
+	// first
+	var sizes []dto.IIIFSize
+	for i := len(factors) - 1; i >= 0; i-- {
+		w, h := ceilDiv(width, factors[i]), ceilDiv(height, factors[i])
+		if w > MaxWidth || h > MaxHeight || w*h > MaxArea {
+			break
+		}
+		sizes = append(sizes, dto.IIIFSize{Width: w, Height: h})
+	}
+
+	maxWidth, maxHeight, maxArea := MaxWidth, MaxHeight, MaxArea
+
+	return dto.IIIFImageInfo{
+		Context:   Context,
+		Id:        id,
+		Type:      Type,
+		Protocol:  Protocol,
+		Profile:   Profile,
+		Width:     width,
+		Height:    height,
+		MaxWidth:  &maxWidth,
+		MaxHeight: &maxHeight,
+		MaxArea:   &maxArea,
+		Tiles: &[]dto.IIIFTiles{
+			{Width: TileSize, ScaleFactors: factors},
+		},
+		Sizes:          &sizes,
+		ExtraQualities: &[]string{QualityColor, QualityGray, QualityBitonal},
+		ExtraFormats:   &[]string{FormatWebP},
+		ExtraFeatures:  &[]string{"mirroring", "rotationArbitrary", "sizeUpscaling"},
+	}
+}
+
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
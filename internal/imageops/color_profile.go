@@ -1,7 +1,15 @@
 package imageops
 
 import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"viz/internal/icc"
 	libvips "viz/internal/imageops/vips"
+	"viz/internal/transform"
 )
 
 // NormalizeToSRGB converts the image to sRGB color space.
@@ -42,3 +50,147 @@ func NormalizeToSRGB(img *libvips.Image) error {
 
 	return nil
 }
+
+// adobeRGB is the Adobe RGB compatible profile, written to a file the
+// first time it's used since libvips only reads profiles from files
+var adobeRGB struct {
+	once sync.Once
+	path string
+	err  error
+}
+
+func adobeRGBProfile() (string, error) {
+	adobeRGB.once.Do(func() {
+		data := icc.AdobeRGB.Bytes()
+		adobeRGB.path = filepath.Join(os.TempDir(), fmt.Sprintf("viz-adobe-rgb-%x.icc", sha256.Sum256(data)))
+		adobeRGB.err = os.WriteFile(adobeRGB.path, data, 0o644)
+	})
+	return adobeRGB.path, adobeRGB.err
+}
+
+// profilePath returns what libvips loads a profile from, the name of its
+// built in profiles or a file
+func profilePath(name string, uploaded map[string]transform.ColourProfile) (string, error) {
+	switch name {
+	case "", transform.ProfileSRGB:
+		return "srgb", nil
+	case transform.ProfileDisplayP3:
+		return "p3", nil
+	case transform.ProofCMYK:
+		return "cmyk", nil
+	case transform.ProfileAdobeRGB:
+		return adobeRGBProfile()
+	}
+
+	profile, ok := uploaded[name]
+	if !ok || profile.Path == "" {
+		return "", fmt.Errorf("colour profile %s is not loaded", name)
+	}
+	return profile.Path, nil
+}
+
+func vipsIntent(intent string) libvips.Intent {
+	switch intent {
+	case transform.IntentRelative:
+		return libvips.IntentRelative
+	case transform.IntentSaturation:
+		return libvips.IntentSaturation
+	case transform.IntentAbsolute:
+		return libvips.IntentAbsolute
+	default:
+		return libvips.IntentPerceptual
+	}
+}
+
+// ConvertColour converts the image to the output profile of a transform.
+// Transforms that leave colour alone, and images without params, are
+// normalised to sRGB as they always were. Images without a profile are
+// taken to be sRGB, as browsers do.
+func ConvertColour(img *libvips.Image, params *transform.TransformParams) error {
+	if params == nil || !params.ManagesColour() {
+		return NormalizeToSRGB(img)
+	}
+
+	depth := 8
+	if params.Depth == 16 {
+		depth = 16
+	}
+
+	if !img.HasICCProfile() && img.Interpretation() != libvips.InterpretationSrgb && img.Interpretation() != libvips.InterpretationRgb16 {
+		if err := img.Colourspace(libvips.InterpretationSrgb, nil); err != nil {
+			return err
+		}
+	}
+
+	if params.Profile == transform.ProfileOriginal {
+		return setDepth(img, depth)
+	}
+
+	out, err := profilePath(params.Profile, params.ColourProfiles)
+	if err != nil {
+		return err
+	}
+
+	return img.IccTransform(out, &libvips.IccTransformOptions{
+		Embedded:     true,
+		InputProfile: "srgb",
+		Intent:       vipsIntent(params.Intent),
+		Depth:        depth,
+	})
+}
+
+// setDepth converts an image left in its own colour space to 8 or 16 bits
+// per band
+func setDepth(img *libvips.Image, depth int) error {
+	sixteen := img.BandFormat() == libvips.BandFormatUshort
+	if sixteen == (depth == 16) {
+		return nil
+	}
+
+	// CMYK has no 16-bit interpretation of its own to convert to
+	if img.Interpretation() == libvips.InterpretationCmyk {
+		format := libvips.BandFormatUchar
+		if depth == 16 {
+			format = libvips.BandFormatUshort
+		}
+		return img.Cast(format, &libvips.CastOptions{Shift: true})
+	}
+
+	if depth == 16 {
+		return img.Colourspace(libvips.InterpretationRgb16, nil)
+	}
+	return img.Colourspace(libvips.InterpretationSrgb, nil)
+}
+
+// softProof renders the transform through the proof profile and back, so
+// it shows the colours the press can print, and strips the output profile
+// when the transform asks for it
+func softProof(img *libvips.Image, params *transform.TransformParams) error {
+	if params.Proof != "" {
+		proof, err := profilePath(params.Proof, params.ColourProfiles)
+		if err != nil {
+			return err
+		}
+
+		out, err := profilePath(params.Profile, params.ColourProfiles)
+		if err != nil {
+			return err
+		}
+
+		if err := img.IccTransform(proof, &libvips.IccTransformOptions{Embedded: true, InputProfile: out, Intent: vipsIntent(params.Intent)}); err != nil {
+			return fmt.Errorf("failed to proof against %s: %w", params.Proof, err)
+		}
+
+		// relative colorimetric back, so paper white is shown as white
+		depth := max(params.Depth, 8)
+		if err := img.IccTransform(out, &libvips.IccTransformOptions{Embedded: true, Intent: libvips.IntentRelative, Depth: depth}); err != nil {
+			return fmt.Errorf("failed to proof against %s: %w", params.Proof, err)
+		}
+	}
+
+	if params.StripProfile {
+		return img.RemoveICCProfile()
+	}
+
+	return nil
+}
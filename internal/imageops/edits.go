@@ -0,0 +1,178 @@
+package imageops
+
+import (
+	"fmt"
+	"math"
+
+	"viz/internal/dto"
+	libvips "viz/internal/imageops/vips"
+	"viz/internal/transform"
+)
+
+// vignetteMaskSize is the size of the vignette mask before it's stretched
+// over the image, the falloff is smooth enough that this doesn't show
+const vignetteMaskSize = 256
+
+// ApplyEdits applies an image's edit stack, in order, to an auto-rotated
+// sRGB image. Only the in-memory image changes, the original file is
+// never written.
+func ApplyEdits(img *libvips.Image, edits []dto.ImageEdit) error {
+	if len(edits) == 0 {
+		return nil
+	}
+
+	for _, edit := range edits {
+		var err error
+		switch edit.Op {
+		case dto.ImageEditOpCrop:
+			err = cropEdit(img, *edit.Crop)
+		case dto.ImageEditOpStraighten:
+			err = straighten(img, float64(*edit.Angle))
+		case dto.ImageEditOpExposure:
+			gain := math.Pow(2, float64(*edit.Amount))
+			err = linearColour(img, [3]float64{gain, gain, gain}, [3]float64{})
+		case dto.ImageEditOpContrast:
+			// stretch around the midpoint, -100 flattens to grey
+			f := 1 + float64(*edit.Amount)/100
+			b := 128 * (1 - f)
+			err = linearColour(img, [3]float64{f, f, f}, [3]float64{b, b, b})
+		case dto.ImageEditOpSaturation:
+			err = saturate(img, 1+float64(*edit.Amount)/100)
+		case dto.ImageEditOpWhiteBalance:
+			var temperature, tint float64
+			if edit.Temperature != nil {
+				temperature = float64(*edit.Temperature) / 200
+			}
+			if edit.Tint != nil {
+				tint = float64(*edit.Tint) / 200
+			}
+			err = linearColour(img, [3]float64{1 + temperature, 1 - tint, 1 - temperature}, [3]float64{})
+		case dto.ImageEditOpSharpen:
+			amount := float64(*edit.Amount) / 100
+			err = img.Sharpen(&libvips.SharpenOptions{Sigma: 0.5 + amount*1.5, M2: 1 + amount*5})
+		case dto.ImageEditOpVignette:
+			err = vignette(img, float64(*edit.Amount)/100)
+		default:
+			err = fmt.Errorf("unknown edit operation %q", edit.Op)
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to apply %s edit: %w", edit.Op, err)
+		}
+	}
+
+	// the colour operations work in float, go back to 8 bit like the rest
+	// of the pipeline expects
+	if err := img.Cast(libvips.BandFormatUchar, nil); err != nil {
+		return fmt.Errorf("failed to cast edited image: %w", err)
+	}
+
+	return nil
+}
+
+func cropEdit(img *libvips.Image, c dto.ImageEditCrop) error {
+	rect := transform.Crop{X: c.X, Y: c.Y, Width: c.Width, Height: c.Height}
+	crop, ok := rect.Clamp(int64(img.Width()), int64(img.Height()))
+	if !ok {
+		return fmt.Errorf("crop %s is outside the %dx%d image", rect, img.Width(), img.Height())
+	}
+
+	return img.ExtractArea(int(crop.X), int(crop.Y), int(crop.Width), int(crop.Height))
+}
+
+// straighten rotates the image and crops away the empty corners
+func straighten(img *libvips.Image, degrees float64) error {
+	if degrees == 0 {
+		return nil
+	}
+
+	w, h := img.Width(), img.Height()
+	cw, ch := transform.StraightenSize(w, h, degrees)
+
+	if err := img.Rotate(degrees, &libvips.RotateOptions{}); err != nil {
+		return err
+	}
+
+	left := (img.Width() - cw) / 2
+	top := (img.Height() - ch) / 2
+	return img.ExtractArea(left, top, cw, ch)
+}
+
+// linearColour scales and offsets the colour bands, leaving alpha alone
+func linearColour(img *libvips.Image, a, b [3]float64) error {
+	as, bs := a[:], b[:]
+	for range img.Bands() - 3 {
+		as = append(as, 1)
+		bs = append(bs, 0)
+	}
+
+	return img.Linear(as, bs, nil)
+}
+
+// saturate scales the chroma of the image in LCh
+func saturate(img *libvips.Image, factor float64) error {
+	if err := img.Colourspace(libvips.InterpretationLch, nil); err != nil {
+		return err
+	}
+
+	if err := linearColour(img, [3]float64{1, factor, 1}, [3]float64{}); err != nil {
+		return err
+	}
+
+	return img.Colourspace(libvips.InterpretationSrgb, nil)
+}
+
+// vignette darkens the corners by up to amount, 1 being black
+func vignette(img *libvips.Image, amount float64) error {
+	bands := img.Bands()
+	alpha := img.HasAlpha()
+	mask := make([]byte, vignetteMaskSize*vignetteMaskSize*bands)
+
+	centre := float64(vignetteMaskSize-1) / 2
+	for y := range vignetteMaskSize {
+		for x := range vignetteMaskSize {
+			// 0 in the middle, 1 in the corners
+			dx, dy := (float64(x)-centre)/centre, (float64(y)-centre)/centre
+			d := math.Min(math.Sqrt(dx*dx+dy*dy)/math.Sqrt2, 1)
+			v := byte(math.Round(255 * (1 - amount*d*d)))
+
+			i := (y*vignetteMaskSize + x) * bands
+			for band := range bands {
+				mask[i+band] = v
+			}
+			if alpha {
+				mask[i+bands-1] = 255
+			}
+		}
+	}
+
+	maskImg, err := libvips.NewImageFromMemory(mask, vignetteMaskSize, vignetteMaskSize, bands)
+	if err != nil {
+		return err
+	}
+	defer maskImg.Close()
+
+	hscale := float64(img.Width()) / vignetteMaskSize
+	vscale := float64(img.Height()) / vignetteMaskSize
+	if err := maskImg.Resize(hscale, &libvips.ResizeOptions{Kernel: libvips.KernelLinear, Vscale: vscale}); err != nil {
+		return err
+	}
+
+	// rounding can leave the mask a pixel off, and arithmetic pads the
+	// smaller image with black
+	if err := maskImg.Embed(0, 0, img.Width(), img.Height(), &libvips.EmbedOptions{Extend: libvips.ExtendCopy}); err != nil {
+		return err
+	}
+
+	if err := img.Multiply(maskImg); err != nil {
+		return err
+	}
+
+	scale := make([]float64, bands)
+	offset := make([]float64, bands)
+	for band := range scale {
+		scale[band] = 1.0 / 255
+	}
+
+	return img.Linear(scale, offset, nil)
+}
@@ -166,9 +166,11 @@ func BuildImageEXIF(exifData map[string]string) (dto.ImageEXIF, time.Time, time.
 		ModifyDate:       FindExif(exifData, "ModifyDate", "DateTime"),
 		Iso:              FindExif(exifData, "ISO", "ISOSpeedRatings"),
 		FocalLength:      FindExif(exifData, "FocalLength"),
+		FocalLengthIn35mmFormat: FindExif(exifData, "FocalLengthIn35mmFilm", "FocalLengthIn35mmFormat"),
 		ExposureTime:     FindExif(exifData, "ExposureTime"),
 		Flash:            FindExifInt(exifData, "Flash"),
 		WhiteBalance:     FindExif(exifData, "WhiteBalance"),
+		LensMake:         FindExif(exifData, "LensMake"),
 		LensModel:        FindExif(exifData, "LensModel"),
 		Rating:           FindExif(exifData, "Rating"),
 		Orientation:      FindExif(exifData, "Orientation"),
@@ -0,0 +1,249 @@
+package imageops
+
+import (
+	"fmt"
+	"math"
+
+	"viz/internal/dto"
+	"viz/internal/entities"
+	"viz/internal/iiif"
+	libvips "viz/internal/imageops/vips"
+)
+
+// pyramidTileSize is the tile size of tile pyramids, smaller than the
+// tiles IIIF viewers ask for so a request decodes few pixels it doesn't
+// need
+const pyramidTileSize = 256
+
+// IIIFImage is the image IIIF requests are rendered from: the levels of
+// an image's tile pyramid when it has one, otherwise its original turned
+// into the display image the same way transforms are
+type IIIFImage struct {
+	pyramid Opener
+	// full is the full size image, the first level of a pyramid
+	full   *SourceImage
+	levels int
+}
+
+// OpenIIIFImage loads the image IIIF requests for imgEnt are rendered
+// from, its tile pyramid when pyramid isn't nil and otherwise its original
+// opened through files
+func OpenIIIFImage(files FileOpener, imgEnt entities.ImageAsset, pyramid Opener) (*IIIFImage, error) {
+	if pyramid != nil {
+		full, err := loadPyramidLevel(pyramid, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		return &IIIFImage{pyramid: pyramid, full: full, levels: max(full.Pages(), 1)}, nil
+	}
+
+	full, err := LoadOriginal(files, imgEnt, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var edits []dto.ImageEdit
+	if imgEnt.Edits != nil {
+		edits = *imgEnt.Edits
+	}
+
+	if _, _, err := toDisplayImage(full.Image, edits, nil); err != nil {
+		full.Close()
+		return nil, err
+	}
+
+	return &IIIFImage{full: full, levels: 1}, nil
+}
+
+// Width is the width of the full size image
+func (i *IIIFImage) Width() int {
+	return i.full.Width()
+}
+
+// Height is the height of the full size image
+func (i *IIIFImage) Height() int {
+	return i.full.Height()
+}
+
+func (i *IIIFImage) Close() {
+	i.full.Close()
+}
+
+// Render renders a request resolved against the size of the image. The
+// region is cut from the smallest pyramid level that still has at least
+// the pixels asked for, then scaled, rotated and coloured as requested.
+func (i *IIIFImage) Render(plan *iiif.Plan) ([]byte, error) {
+	src := i.full.Image
+	if level := i.level(plan); level > 0 {
+		scaled, err := loadPyramidLevel(i.pyramid, level)
+		if err != nil {
+			return nil, err
+		}
+		defer scaled.Close()
+
+		src = scaled.Image
+	}
+
+	// operations replace an image's pixels, leave the loaded one as it is
+	img, err := src.Copy(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy image: %w", err)
+	}
+	defer img.Close()
+
+	// the region in the level's pixels
+	sx := float64(img.Width()) / float64(i.Width())
+	sy := float64(img.Height()) / float64(i.Height())
+	left := min(int(float64(plan.Region.X)*sx), img.Width()-1)
+	top := min(int(float64(plan.Region.Y)*sy), img.Height()-1)
+	width := min(max(int(math.Round(float64(plan.Region.W)*sx)), 1), img.Width()-left)
+	height := min(max(int(math.Round(float64(plan.Region.H)*sy)), 1), img.Height()-top)
+
+	if err := img.ExtractArea(left, top, width, height); err != nil {
+		return nil, fmt.Errorf("failed to extract region: %w", err)
+	}
+
+	if width != plan.Width || height != plan.Height {
+		hscale, vscale := float64(plan.Width)/float64(width), float64(plan.Height)/float64(height)
+		if err := img.Resize(hscale, &libvips.ResizeOptions{Kernel: libvips.KernelLanczos3, Vscale: vscale}); err != nil {
+			return nil, fmt.Errorf("failed to resize region: %w", err)
+		}
+
+		if err := exactSize(img, plan.Width, plan.Height); err != nil {
+			return nil, err
+		}
+	}
+
+	if plan.Rotation.Mirror {
+		if err := img.Flip(libvips.DirectionHorizontal); err != nil {
+			return nil, fmt.Errorf("failed to mirror region: %w", err)
+		}
+	}
+
+	if plan.Rotation.Degrees != 0 {
+		if err := img.Rotate(plan.Rotation.Degrees, nil); err != nil {
+			return nil, fmt.Errorf("failed to rotate region: %w", err)
+		}
+	}
+
+	switch plan.Quality {
+	case iiif.QualityGray, iiif.QualityBitonal:
+		if err := img.Colourspace(libvips.InterpretationBW, nil); err != nil {
+			return nil, fmt.Errorf("failed to convert region to greyscale: %w", err)
+		}
+
+		if plan.Quality == iiif.QualityBitonal {
+			// 255 where at least mid grey, 0 elsewhere
+			if err := img.RelationalConst(libvips.OperationRelationalMoreeq, []float64{128}); err != nil {
+				return nil, fmt.Errorf("failed to threshold region: %w", err)
+			}
+		}
+	}
+
+	quality := int64(85)
+	if plan.Format == iiif.FormatPNG {
+		// the compression level for PNGs
+		quality = 6
+	}
+
+	// tiles carry no metadata, like the pyramid they're cut from
+	data, err := encodeImage(img, plan.Format, quality, 0, libvips.KeepIcc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode region: %w", err)
+	}
+
+	return data, nil
+}
+
+// level returns the pyramid level a plan is read from, the smallest that
+// isn't scaled down further than the plan asks for
+func (i *IIIFImage) level(plan *iiif.Plan) int {
+	shrink := math.Min(float64(plan.Region.W)/float64(plan.Width), float64(plan.Region.H)/float64(plan.Height))
+
+	level := 0
+	for level+1 < i.levels && math.Pow(2, float64(level+1)) <= shrink {
+		level++
+	}
+
+	return level
+}
+
+// exactSize crops or extends an image by the pixel resizing can be off by
+func exactSize(img *libvips.Image, width, height int) error {
+	if img.Width() == width && img.Height() == height {
+		return nil
+	}
+
+	if img.Width() >= width && img.Height() >= height {
+		return img.ExtractArea(0, 0, width, height)
+	}
+
+	return img.Embed(0, 0, width, height, &libvips.EmbedOptions{Extend: libvips.ExtendCopy})
+}
+
+// loadPyramidLevel loads a level of a tile pyramid, level 0 is full size
+// and every level after it half the size of the one before
+func loadPyramidLevel(open Opener, level int) (*SourceImage, error) {
+	r, err := open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tile pyramid: %w", err)
+	}
+
+	source := libvips.NewSource(r)
+
+	opts := libvips.DefaultTiffloadSourceOptions()
+	opts.Page = level
+	opts.Access = libvips.AccessRandom
+
+	img, err := libvips.NewTiffloadSource(source, opts)
+	if err != nil {
+		source.Close()
+		return nil, fmt.Errorf("failed to load tile pyramid level %d: %w", level, err)
+	}
+
+	return &SourceImage{Image: img, source: source}, nil
+}
+
+// BuildPyramid renders the display image of an original read through files
+// to a tiled pyramidal BigTIFF at path, whose levels IIIF requests for
+// large images are read from. libvips writes the levels as the original
+// is decoded, so neither is held in memory in full.
+func BuildPyramid(files FileOpener, imgEnt entities.ImageAsset, path string) error {
+	img, err := LoadOriginal(files, imgEnt, nil)
+	if err != nil {
+		return err
+	}
+	defer img.Close()
+
+	var edits []dto.ImageEdit
+	if imgEnt.Edits != nil {
+		edits = *imgEnt.Edits
+	}
+
+	if _, _, err := toDisplayImage(img.Image, edits, nil); err != nil {
+		return err
+	}
+
+	opts := libvips.DefaultTiffsaveOptions()
+	opts.Tile = true
+	opts.TileWidth = pyramidTileSize
+	opts.TileHeight = pyramidTileSize
+	opts.Pyramid = true
+	opts.Bigtiff = true
+	opts.Keep = libvips.KeepIcc
+
+	// JPEG compressed TIFFs can't keep transparency
+	if img.HasAlpha() {
+		opts.Compression = libvips.TiffCompressionDeflate
+	} else {
+		opts.Compression = libvips.TiffCompressionJpeg
+		opts.Q = 85
+	}
+
+	if err := img.Tiffsave(path, opts); err != nil {
+		return fmt.Errorf("failed to write tile pyramid: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package imageops
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"viz/internal/dto"
+	"viz/internal/entities"
+	"viz/internal/iiif"
+	libvips "viz/internal/imageops/vips"
+	"viz/internal/transform"
+)
+
+// testFiles returns a FileOpener reading data for any file name, like one
+// streaming files from storage
+func testFiles(data []byte) FileOpener {
+	return func(string) (io.ReadSeekCloser, error) {
+		return BytesOpener(data)()
+	}
+}
+
+// testGradient encodes a w x h PNG
+func testGradient(t *testing.T, w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := range w {
+		for y := range h {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestGenerateTransformFromSource(t *testing.T) {
+	data := testGradient(t, 400, 300)
+	img := entities.ImageAsset{Uid: "abc", Width: 400, Height: 300, ImageMetadata: &dto.ImageMetadata{Checksum: "abc", FileName: "abc.png", FileType: "png"}}
+	params := &transform.TransformParams{Width: 100, Format: "jpg"}
+
+	fromBuffer, err := GenerateTransform(params, img, data)
+	if err != nil {
+		t.Fatalf("GenerateTransform() = %v", err)
+	}
+
+	fromSource, err := GenerateTransformFromSource(params, img, testFiles(data))
+	if err != nil {
+		t.Fatalf("GenerateTransformFromSource() = %v", err)
+	}
+
+	if !bytes.Equal(fromBuffer.ImageData, fromSource.ImageData) {
+		t.Error("transform of a stream differs from the transform of a buffer")
+	}
+}
+
+func TestIIIFRender(t *testing.T) {
+	data := testGradient(t, 1200, 800)
+	imgEnt := entities.ImageAsset{Uid: "abc", Width: 1200, Height: 800, ImageMetadata: &dto.ImageMetadata{Checksum: "abc", FileName: "abc.png", FileType: "png"}}
+
+	pyramidPath := filepath.Join(t.TempDir(), "pyramid.tif")
+	if err := BuildPyramid(testFiles(data), imgEnt, pyramidPath); err != nil {
+		t.Fatalf("BuildPyramid() = %v", err)
+	}
+
+	pyramidData, err := os.ReadFile(pyramidPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pyramid := range []bool{false, true} {
+		var open Opener
+		if pyramid {
+			open = BytesOpener(pyramidData)
+		}
+
+		img, err := OpenIIIFImage(testFiles(data), imgEnt, open)
+		if err != nil {
+			t.Fatalf("OpenIIIFImage(pyramid=%v) = %v", pyramid, err)
+		}
+		defer img.Close()
+
+		if img.Width() != 1200 || img.Height() != 800 {
+			t.Errorf("OpenIIIFImage(pyramid=%v) is %dx%d", pyramid, img.Width(), img.Height())
+		}
+
+		requests := []struct {
+			region, size, rotation, qualityFormat string
+			width, height                         int
+		}{
+			{"full", "300,", "0", "default.jpg", 300, 200},
+			{"0,0,512,512", "128,", "!0", "gray.png", 128, 128},
+			{"1024,512,512,512", "max", "90", "default.webp", 288, 176},
+			{"square", "100,100", "0", "bitonal.png", 100, 100},
+		}
+		for _, r := range requests {
+			req, err := iiif.ParseRequest(r.region, r.size, r.rotation, r.qualityFormat)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			plan, err := req.Resolve(img.Width(), img.Height())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			out, err := img.Render(plan)
+			if err != nil {
+				t.Errorf("Render(%s, pyramid=%v) = %v", plan, pyramid, err)
+				continue
+			}
+
+			rendered, err := libvips.NewImageFromBuffer(out, libvips.DefaultLoadOptions())
+			if err != nil {
+				t.Fatalf("Failed to load rendered image: %v", err)
+			}
+
+			if rendered.Width() != r.width || rendered.Height() != r.height {
+				t.Errorf("Render(%s, pyramid=%v) is %dx%d, want %dx%d", plan, pyramid, rendered.Width(), rendered.Height(), r.width, r.height)
+			}
+			rendered.Close()
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package imageops
+
+import (
+	"slices"
+	"strings"
+
+	libvips "viz/internal/imageops/vips"
+	"viz/internal/transform"
+)
+
+// gpsFieldPrefix is the libvips prefix of the EXIF GPS IFD's tags
+const gpsFieldPrefix = "exif-ifd3-"
+
+// copyrightFields are the EXIF tags MetadataCopyright keeps
+var copyrightFields = []string{"exif-ifd0-Artist", "exif-ifd0-Copyright"}
+
+// applyMetadataPolicy removes what a metadata policy doesn't keep from the
+// image and returns what its encoder should write. libvips rewrites the
+// EXIF block from the image's exif-* fields when saving, so removing a
+// field removes its tag from the output.
+func applyMetadataPolicy(img *libvips.Image, policy string) (libvips.Keep, error) {
+	switch policy {
+	case transform.MetadataKeep:
+		return libvips.KeepAll, nil
+	case transform.MetadataNoGPS:
+		var gps []string
+		for _, field := range img.GetFields() {
+			if strings.HasPrefix(field, gpsFieldPrefix) {
+				gps = append(gps, field)
+			}
+		}
+
+		if err := img.RemoveFields(gps...); err != nil {
+			return 0, err
+		}
+
+		// XMP repeats the location and isn't rewritten like EXIF, it's
+		// left out whole
+		return libvips.KeepAll &^ libvips.KeepXmp, nil
+	case transform.MetadataCopyright:
+		// the EXIF block is rebuilt from the two fields left, RemoveExif
+		// would take the frame delays of animations with it
+		var exif []string
+		for _, field := range img.GetFields() {
+			if strings.HasPrefix(field, "exif-") && !slices.Contains(copyrightFields, field) {
+				exif = append(exif, field)
+			}
+		}
+
+		if err := img.RemoveFields(exif...); err != nil {
+			return 0, err
+		}
+
+		return libvips.KeepExif | libvips.KeepIcc, nil
+	default:
+		// KeepNone isn't sent to libvips, which then keeps everything, the
+		// profile is kept anyway
+		return libvips.KeepIcc, nil
+	}
+}
@@ -0,0 +1,62 @@
+package imageops
+
+import (
+	"math"
+
+	"viz/internal/dto"
+	"viz/internal/entities"
+	libvips "viz/internal/imageops/vips"
+	"viz/internal/transform"
+)
+
+// Animates reports whether a transform of an image is animated, which
+// takes an animated original and a transform keeping its animation
+func Animates(imgEnt entities.ImageAsset, params *transform.TransformParams) bool {
+	meta := imgEnt.ImageMetadata
+	if params == nil || meta == nil || meta.Animated == nil || !*meta.Animated {
+		return false
+	}
+
+	var edits []dto.ImageEdit
+	if imgEnt.Edits != nil {
+		edits = *imgEnt.Edits
+	}
+
+	return params.KeepsAnimation(edits)
+}
+
+// pageOptions returns the options loading the page of a multi-page
+// original a transform renders, or every frame of an animation it keeps.
+// libvips loads the first page otherwise.
+func pageOptions(imgEnt entities.ImageAsset, params *transform.TransformParams) *libvips.LoadOptions {
+	opts := libvips.DefaultLoadOptions()
+	if params == nil {
+		return opts
+	}
+
+	if params.Page > 0 {
+		opts.Page = params.Page - 1
+	} else if Animates(imgEnt, params) {
+		opts.N = -1
+	}
+
+	return opts
+}
+
+// resizeFrames resizes the frames of an animation, which libvips loads
+// stacked into one tall image. Each frame is scaled to a whole number of
+// rows so they stay apart.
+func resizeFrames(img *libvips.Image, params *transform.TransformParams, kernel libvips.Kernel) error {
+	frameHeight := img.PageHeight()
+	frames := img.Height() / frameHeight
+
+	hscale, vscale := params.FitScale(img.Width(), frameHeight)
+	scaled := max(1, int(math.Round(float64(frameHeight)*vscale)))
+	vscale = float64(scaled*frames) / float64(img.Height())
+
+	if err := img.Resize(hscale, &libvips.ResizeOptions{Kernel: kernel, Vscale: vscale}); err != nil {
+		return err
+	}
+
+	return img.SetPageHeight(scaled)
+}
@@ -0,0 +1,114 @@
+package imageops
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"viz/internal/dto"
+	"viz/internal/entities"
+	libvips "viz/internal/imageops/vips"
+	"viz/internal/raw"
+	"viz/internal/transform"
+)
+
+// LoadOriginal loads the image a transform of imgEnt is rendered from,
+// params is nil for work at full size like tile pyramids. RAW only images
+// use the JPEG preview embedded in them when it's large enough and are
+// developed otherwise. Images with a paired RAW file use their JPEG unless
+// the transform asks for the RAW to be developed.
+func LoadOriginal(files FileOpener, imgEnt entities.ImageAsset, params *transform.TransformParams) (*SourceImage, error) {
+	meta := imgEnt.ImageMetadata
+	if meta == nil {
+		return nil, fmt.Errorf("missing image metadata to find the original")
+	}
+
+	develop := params != nil && params.Develop == transform.DevelopFull
+	if develop && meta.RawFileName != nil && *meta.RawFileName != meta.FileName {
+		return DevelopRAW(files.opener(*meta.RawFileName))
+	}
+
+	open := files.opener(meta.FileName)
+	if !entities.IsRAWFile(meta.FileName) {
+		return LoadSource(open, pageOptions(imgEnt, params))
+	}
+
+	if params != nil && !develop {
+		var edits []dto.ImageEdit
+		if imgEnt.Edits != nil {
+			edits = *imgEnt.Edits
+		}
+
+		preview, err := LoadRAWPreview(open)
+		if err == nil {
+			if params.PreviewSuffices(edits, preview.Width(), preview.Height()) {
+				return preview, nil
+			}
+			preview.Close()
+		} else if !errors.Is(err, raw.ErrNoPreview) {
+			return nil, err
+		}
+	}
+
+	return DevelopRAW(open)
+}
+
+// LoadRAWPreview loads the largest JPEG preview embedded in a RAW file.
+// Cameras store previews as the sensor saw them, so the preview gets the
+// orientation recorded in the RAW file unless it has its own.
+func LoadRAWPreview(open Opener) (*SourceImage, error) {
+	r, err := open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open RAW file: %w", err)
+	}
+	defer r.Close()
+
+	orientation := raw.Orientation(r)
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read RAW file: %w", err)
+	}
+
+	preview, err := raw.ExtractPreview(r)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := libvips.NewImageFromBuffer(preview.Data, libvips.DefaultLoadOptions())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load RAW preview: %w", err)
+	}
+
+	if img.Orientation() <= 1 && orientation > 1 {
+		if err := img.SetOrientation(orientation); err != nil {
+			img.Close()
+			return nil, fmt.Errorf("failed to orient RAW preview: %w", err)
+		}
+	}
+
+	return &SourceImage{Image: img}, nil
+}
+
+// DevelopRAW decodes the sensor data of a RAW file with libraw
+func DevelopRAW(open Opener) (*SourceImage, error) {
+	r, err := open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open RAW file: %w", err)
+	}
+
+	source := libvips.NewSource(r)
+	img, err := libvips.NewDcrawloadSource(source, libvips.DefaultDcrawloadSourceOptions())
+	if err != nil {
+		source.Close()
+		return nil, fmt.Errorf("failed to develop RAW file: %w", err)
+	}
+
+	// libraw develops the image upright already, an orientation left on it
+	// would have Autorot turn it a second time
+	if err := img.RemoveOrientation(); err != nil {
+		img.Close()
+		source.Close()
+		return nil, fmt.Errorf("failed to develop RAW file: %w", err)
+	}
+
+	return &SourceImage{Image: img, source: source}, nil
+}
@@ -0,0 +1,87 @@
+package imageops
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	libvips "viz/internal/imageops/vips"
+)
+
+// Opener opens an image file for random access. It's called again when a
+// load has to start over, so it returns a new reader every time.
+type Opener func() (io.ReadSeekCloser, error)
+
+// FileOpener opens one of the files stored for an image by its name, the
+// original or the RAW file paired with it
+type FileOpener func(fileName string) (io.ReadSeekCloser, error)
+
+// opener returns the Opener of one of the files of files
+func (files FileOpener) opener(fileName string) Opener {
+	return func() (io.ReadSeekCloser, error) {
+		return files(fileName)
+	}
+}
+
+// BytesOpener returns an Opener reading data, for files already in memory
+// such as uploads
+func BytesOpener(data []byte) Opener {
+	return func() (io.ReadSeekCloser, error) {
+		return nopSeekCloser{bytes.NewReader(data)}, nil
+	}
+}
+
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error { return nil }
+
+// SourceImage is an image libvips decodes from a stream as its pixels are
+// needed, instead of from a copy of the whole file in memory. Images too
+// large to decode in memory are decoded to a temporary file by libvips.
+// RAW previews are decoded from memory and have no stream.
+type SourceImage struct {
+	*libvips.Image
+	source *libvips.Source
+}
+
+// Close releases the image and then the stream it's read from
+func (s *SourceImage) Close() {
+	s.Image.Close()
+	if s.source != nil {
+		s.source.Close()
+	}
+}
+
+// LoadSource opens an image from the stream open returns, with opts
+// picking its pages, nil for the first. Like GenerateTransform it retries
+// files libvips doesn't detect with the RAW loader.
+func LoadSource(open Opener, opts *libvips.LoadOptions) (*SourceImage, error) {
+	r, err := open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
+	}
+
+	source := libvips.NewSource(r)
+	img, err := libvips.NewImageFromSource(source, opts)
+	if err == nil {
+		return &SourceImage{Image: img, source: source}, nil
+	}
+	source.Close()
+
+	// the failed load has read part of the stream, the RAW loader gets a
+	// new one
+	if r, rawErr := open(); rawErr == nil {
+		source = libvips.NewSource(r)
+		img, rawErr = libvips.NewDcrawloadSource(source, &libvips.DcrawloadSourceOptions{})
+		if rawErr == nil {
+			return &SourceImage{Image: img, source: source}, nil
+		}
+		source.Close()
+
+		return nil, fmt.Errorf("failed to create libvips image from source: %w (RAW fallback: %v)", err, rawErr)
+	}
+
+	return nil, fmt.Errorf("failed to create libvips image from source: %w", err)
+}
@@ -3,7 +3,9 @@ package imageops
 import (
 	"fmt"
 
+	"viz/internal/entities"
 	libvips "viz/internal/imageops/vips"
+	"viz/internal/transform"
 )
 
 // CreateThumbnailWithSize creates a thumbnail using libvips from the input image bytes.
@@ -36,6 +38,64 @@ func CreateThumbnailWithSize(imgBytes []byte, width, height int) ([]byte, error)
 	defer thumb.Close()
 
 	jpegOpts := libvips.DefaultJpegsaveBufferOptions()
+	jpegOpts.Keep = libvips.KeepIcc // thumbnails carry no metadata, GPS included
+	data, err := thumb.JpegsaveBuffer(jpegOpts)
+	if err != nil {
+		return nil, fmt.Errorf("thumbnail encode failed: %w", err)
+	}
+	return data, nil
+}
+
+// CreateThumbnailFromSource is CreateThumbnailWithSize for the original of
+// imgEnt read through files. libvips shrinks the image while decoding it
+// where the format allows, so large originals aren't decoded at full size.
+// RAW only images are thumbnailed from the preview embedded in them.
+func CreateThumbnailFromSource(files FileOpener, imgEnt entities.ImageAsset, width, height int) ([]byte, error) {
+	if imgEnt.ImageMetadata == nil {
+		return nil, fmt.Errorf("missing image metadata to find the original")
+	}
+
+	var thumb *libvips.Image
+	if entities.IsRAWFile(imgEnt.ImageMetadata.FileName) {
+		img, err := LoadOriginal(files, imgEnt, &transform.TransformParams{Width: int64(width), Height: int64(height)})
+		if err != nil {
+			return nil, err
+		}
+		defer img.Close()
+
+		opts := libvips.DefaultThumbnailImageOptions()
+		opts.Height = height
+		opts.OutputProfile = "srgb"
+		opts.InputProfile = "srgb"
+
+		if err := img.ThumbnailImage(width, opts); err != nil {
+			return nil, fmt.Errorf("thumbnail generation failed: %w", err)
+		}
+		thumb = img.Image
+	} else {
+		r, err := files(imgEnt.ImageMetadata.FileName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open image: %w", err)
+		}
+
+		source := libvips.NewSource(r)
+		defer source.Close()
+
+		opts := libvips.DefaultThumbnailSourceOptions()
+		opts.NoRotate = false
+		opts.Height = height
+		opts.OutputProfile = "srgb"
+		opts.InputProfile = "srgb"
+
+		thumb, err = libvips.NewThumbnailSource(source, width, opts)
+		if err != nil {
+			return nil, fmt.Errorf("thumbnail generation failed: %w", err)
+		}
+		defer thumb.Close()
+	}
+
+	jpegOpts := libvips.DefaultJpegsaveBufferOptions()
+	jpegOpts.Keep = libvips.KeepIcc
 	data, err := thumb.JpegsaveBuffer(jpegOpts)
 	if err != nil {
 		return nil, fmt.Errorf("thumbnail encode failed: %w", err)
@@ -2,11 +2,11 @@ package imageops
 
 import (
 	"fmt"
+	"viz/internal/dto"
 	"viz/internal/entities"
 	libvips "viz/internal/imageops/vips"
 	"viz/internal/transform"
 	"net/url"
-	"strconv"
 )
 
 type TransformResult struct {
@@ -21,53 +21,45 @@ func ParseTransformParams(pathStr string) (*transform.TransformParams, error) {
 		return nil, err
 	}
 
-	q := u.Query()
-
-	params := &transform.TransformParams{}
-	params.Format = q.Get("format")
-	params.Flip = q.Get("flip")
-	params.Kernel = q.Get("kernel")
-
-	// Check for 'w' (short for width) first, then 'width'
-	if widthParam := q.Get("w"); widthParam != "" {
-		if w, err := strconv.ParseInt(widthParam, 10, 64); err == nil {
-			params.Width = w
-		}
-	} else if widthParam := q.Get("width"); widthParam != "" {
-		if w, err := strconv.ParseInt(widthParam, 10, 64); err == nil {
-			params.Width = w
-		}
-	}
+	return transform.ParseQuery(u.Query())
+}
 
-	// Check for 'h' (short for height) first, then 'height'
-	if heightParam := q.Get("h"); heightParam != "" {
-		if h, err := strconv.ParseInt(heightParam, 10, 64); err == nil {
-			params.Height = h
-		}
-	} else if heightParam := q.Get("height"); heightParam != "" {
-		if h, err := strconv.ParseInt(heightParam, 10, 64); err == nil {
-			params.Height = h
+// GenerateTransform generates permanent cached transforms for thumbnail/preview paths if present.
+// These are the URLs stored in ImagePaths (e.g. /images/<uid>/file?format=webp&w=400&h=400&quality=85)
+func GenerateTransform(params *transform.TransformParams, imgEnt entities.ImageAsset, originalData []byte) (result *TransformResult, err error) {
+	// Perform transform using libvips similarly to the route
+	libvipsImg, err := libvips.NewImageFromBuffer(originalData, libvips.DefaultLoadOptions())
+	if err != nil {
+		// Fallback: Try explicit RAW load if generic load failed.
+		// This handles cases where vips_image_new_from_buffer doesn't auto-detect the RAW format (e.g. CR3/ARW on Windows)
+		// but the specific dcraw loader is available.
+		var rawErr error
+		libvipsImg, rawErr = libvips.NewDcrawloadBuffer(originalData, &libvips.DcrawloadBufferOptions{})
+		if rawErr != nil {
+			return nil, fmt.Errorf("failed to create libvips image for transform: %w (RAW fallback: %v)", err, rawErr)
 		}
 	}
+	defer libvipsImg.Close()
 
-	if qualityParam := q.Get("quality"); qualityParam != "" {
-		if qn, err := strconv.ParseInt(qualityParam, 10, 64); err == nil {
-			params.Quality = qn
-		}
-	}
+	return renderTransform(params, imgEnt, libvipsImg)
+}
 
-	if rotateParam := q.Get("rotate"); rotateParam != "" {
-		if r, err := strconv.Atoi(rotateParam); err == nil {
-			params.Rotate = r
-		}
+// GenerateTransformFromSource is GenerateTransform for an original read
+// from storage through files, so large originals are never held in memory
+// in full. RAW images are rendered from their JPEG when it's large enough,
+// see LoadOriginal.
+func GenerateTransformFromSource(params *transform.TransformParams, imgEnt entities.ImageAsset, files FileOpener) (*TransformResult, error) {
+	img, err := LoadOriginal(files, imgEnt, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create libvips image for transform: %w", err)
 	}
+	defer img.Close()
 
-	return params, nil
+	return renderTransform(params, imgEnt, img.Image)
 }
 
-// GenerateTransform generates permanent cached transforms for thumbnail/preview paths if present.
-// These are the URLs stored in ImagePaths (e.g. /images/<uid>/file?format=webp&w=400&h=400&quality=85)
-func GenerateTransform(params *transform.TransformParams, imgEnt entities.ImageAsset, originalData []byte) (result *TransformResult, err error) {
+// renderTransform applies a transform to a loaded original and encodes it
+func renderTransform(params *transform.TransformParams, imgEnt entities.ImageAsset, libvipsImg *libvips.Image) (*TransformResult, error) {
 	ext := params.Format
 	if ext == "" {
 		if imgEnt.ImageMetadata == nil {
@@ -79,28 +71,31 @@ func GenerateTransform(params *transform.TransformParams, imgEnt entities.ImageA
 	// Build transform ETag key same as route
 	transformEtag := transform.CreateTransformEtag(imgEnt, params)
 
-	// Perform transform using libvips similarly to the route
-	libvipsImg, err := libvips.NewImageFromBuffer(originalData, libvips.DefaultLoadOptions())
-	if err != nil {
-		// Fallback: Try explicit RAW load if generic load failed.
-		// This handles cases where vips_image_new_from_buffer doesn't auto-detect the RAW format (e.g. CR3/ARW on Windows)
-		// but the specific dcraw loader is available.
-		var rawErr error
-		libvipsImg, rawErr = libvips.NewDcrawloadBuffer(originalData, &libvips.DcrawloadBufferOptions{})
-		if rawErr != nil {
-			return nil, fmt.Errorf("failed to create libvips image for transform: %w (RAW fallback: %v)", err, rawErr)
-		}
+	// The focal point is relative to the auto-rotated original, follow it
+	// through the edits, crop, rotation and flip below
+	var edits []dto.ImageEdit
+	if imgEnt.Edits != nil {
+		edits = *imgEnt.Edits
 	}
-	defer libvipsImg.Close()
 
-	err = libvipsImg.Autorot(&libvips.AutorotOptions{}) // non-fatal
+	// Edits make up the image every transform starts from
+	origW, origH, err := toDisplayImage(libvipsImg, edits, params)
 	if err != nil {
-		return nil, fmt.Errorf("failed to auto-rotate image: %w", err)
+		return nil, err
 	}
 
-	// Ensure consistent color profile (sRGB) for web display
-	if err := NormalizeToSRGB(libvipsImg); err != nil {
-		return nil, fmt.Errorf("failed to normalize to sRGB: %w", err)
+	focal := transform.EditedFocalPoint(edits, imgEnt.FocalPoint, int64(origW), int64(origH))
+	focal = params.FocalPointAfter(focal, int64(libvipsImg.Width()), int64(libvipsImg.Height()))
+
+	if params.Crop != nil {
+		crop, ok := params.Crop.Clamp(int64(libvipsImg.Width()), int64(libvipsImg.Height()))
+		if !ok {
+			return nil, fmt.Errorf("crop %s is outside the %dx%d image", params.Crop, libvipsImg.Width(), libvipsImg.Height())
+		}
+
+		if err := libvipsImg.ExtractArea(int(crop.X), int(crop.Y), int(crop.Width), int(crop.Height)); err != nil {
+			return nil, fmt.Errorf("failed to crop image: %w", err)
+		}
 	}
 
 	if params.Rotate > 0 {
@@ -163,41 +158,40 @@ func GenerateTransform(params *transform.TransformParams, imgEnt entities.ImageA
 			kernel = libvips.KernelLanczos3
 		}
 
-		// Calculate scale
-		scale := 1.0
-		imgW := float64(libvipsImg.Width())
-		imgH := float64(libvipsImg.Height())
+		if Animates(imgEnt, params) && libvipsImg.PageHeight() < libvipsImg.Height() {
+			if err := resizeFrames(libvipsImg, params, kernel); err != nil {
+				return nil, fmt.Errorf("failed to resize animation: %w", err)
+			}
+		} else {
+			hscale, vscale := params.FitScale(libvipsImg.Width(), libvipsImg.Height())
+			if err := libvipsImg.Resize(hscale, &libvips.ResizeOptions{Kernel: kernel, Vscale: vscale}); err != nil {
+				return nil, fmt.Errorf("failed to resize image: %w", err)
+			}
+		}
 
 		if params.Width > 0 && params.Height > 0 {
-			// Both provided: "contain" behavior (fit within box)
-			wScale := float64(params.Width) / imgW
-			hScale := float64(params.Height) / imgH
-			scale = min(wScale, hScale)
-		} else if params.Width > 0 {
-			scale = float64(params.Width) / imgW
-		} else if params.Height > 0 {
-			scale = float64(params.Height) / imgH
+			if err := fitToBox(libvipsImg, params, focal); err != nil {
+				return nil, err
+			}
 		}
+	}
 
-		if err := libvipsImg.Resize(scale, &libvips.ResizeOptions{Kernel: kernel}); err != nil {
-			return nil, fmt.Errorf("failed to resize image: %w", err)
-		}
+	// The watermark goes on last so it's the same size whatever the image
+	// was cropped or scaled to
+	if err := drawWatermark(libvipsImg, params.Watermark); err != nil {
+		return nil, err
 	}
 
-	// Encode
-	var imageData []byte
-	switch params.Format {
-	case "webp":
-		imageData, err = libvipsImg.WebpsaveBuffer(&libvips.WebpsaveBufferOptions{Q: int(params.Quality)})
-	case "png":
-		imageData, err = libvipsImg.PngsaveBuffer(&libvips.PngsaveBufferOptions{Filter: libvips.PngFilterNone, Interlace: false, Palette: false, Compression: int(params.Quality)})
-	case "jpg", "jpeg":
-		imageData, err = libvipsImg.JpegsaveBuffer(&libvips.JpegsaveBufferOptions{Q: int(params.Quality), Interlace: true})
-	case "avif", "heif":
-		imageData, err = libvipsImg.HeifsaveBuffer(&libvips.HeifsaveBufferOptions{Q: int(params.Quality), Bitdepth: 8, Effort: 5, Lossless: false})
-	default:
-		imageData, err = libvipsImg.RawsaveBuffer(&libvips.RawsaveBufferOptions{Keep: libvips.KeepAll})
+	if err := softProof(libvipsImg, params); err != nil {
+		return nil, err
+	}
+
+	keep, err := applyMetadataPolicy(libvipsImg, params.MetadataPolicy())
+	if err != nil {
+		return nil, fmt.Errorf("failed to strip metadata: %w", err)
 	}
+
+	imageData, err := encodeImage(libvipsImg, params.Format, params.Quality, params.Depth, keep)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encode transform: %w", err)
 	}
@@ -208,3 +202,94 @@ func GenerateTransform(params *transform.TransformParams, imgEnt entities.ImageA
 		Ext:           ext,
 	}, nil
 }
+
+// toDisplayImage turns a loaded original into the image its transforms
+// start from: upright, in the output colour space of params, sRGB without
+// them, and with its edits applied. It returns the size of the image
+// before the edits.
+func toDisplayImage(img *libvips.Image, edits []dto.ImageEdit, params *transform.TransformParams) (origW, origH int, err error) {
+	if err := img.Autorot(&libvips.AutorotOptions{}); err != nil {
+		return 0, 0, fmt.Errorf("failed to auto-rotate image: %w", err)
+	}
+
+	// Converted before the edits so they're made in the output's gamut
+	if err := ConvertColour(img, params); err != nil {
+		return 0, 0, fmt.Errorf("failed to convert colour profile: %w", err)
+	}
+
+	origW, origH = img.Width(), img.Height()
+	if err := ApplyEdits(img, edits); err != nil {
+		return 0, 0, err
+	}
+
+	return origW, origH, nil
+}
+
+// encodeImage encodes an image in one of the transform formats, anything
+// else is written as raw pixels. A depth of 16 writes 16-bit PNGs, TIFFs
+// keep the depth of the image. keep is the metadata written with it. GIF
+// and WebP write the frames of an animation.
+func encodeImage(img *libvips.Image, format string, quality int64, depth int, keep libvips.Keep) ([]byte, error) {
+	switch format {
+	case "webp":
+		return img.WebpsaveBuffer(&libvips.WebpsaveBufferOptions{Q: int(quality), Keep: keep})
+	case "png":
+		return img.PngsaveBuffer(&libvips.PngsaveBufferOptions{Filter: libvips.PngFilterNone, Interlace: false, Palette: false, Compression: int(quality), Bitdepth: depth, Keep: keep})
+	case "tiff":
+		return img.TiffsaveBuffer(&libvips.TiffsaveBufferOptions{Compression: libvips.TiffCompressionDeflate, Predictor: libvips.TiffPredictorHorizontal, Keep: keep})
+	case "jpg", "jpeg":
+		return img.JpegsaveBuffer(&libvips.JpegsaveBufferOptions{Q: int(quality), Interlace: true, Keep: keep})
+	case "avif":
+		return img.HeifsaveBuffer(&libvips.HeifsaveBufferOptions{Q: int(quality), Bitdepth: 8, Effort: 5, Lossless: false, Compression: libvips.HeifCompressionAv1, Keep: keep})
+	case "heif", "heic":
+		return img.HeifsaveBuffer(&libvips.HeifsaveBufferOptions{Q: int(quality), Bitdepth: 8, Effort: 5, Lossless: false, Keep: keep})
+	case "jxl":
+		return img.JxlsaveBuffer(&libvips.JxlsaveBufferOptions{Q: int(quality), Keep: keep})
+	case "gif":
+		opts := libvips.DefaultGifsaveBufferOptions()
+		opts.Keep = keep
+		return img.GifsaveBuffer(opts)
+	default:
+		return img.RawsaveBuffer(&libvips.RawsaveBufferOptions{Keep: libvips.KeepAll})
+	}
+}
+
+// fitToBox crops a cover-scaled image, or pads a contained one, to exactly
+// the requested width and height. Other fit modes are left as resized.
+func fitToBox(img *libvips.Image, params *transform.TransformParams, focal *dto.FocalPoint) error {
+	boxW, boxH := int(params.Width), int(params.Height)
+	imgW, imgH := img.Width(), img.Height()
+
+	switch params.Fit {
+	case transform.FitCover:
+		// rounding can leave the scaled image a pixel short of the box
+		boxW, boxH = min(boxW, imgW), min(boxH, imgH)
+		if boxW == imgW && boxH == imgH {
+			return nil
+		}
+
+		if params.Gravity == transform.GravitySmart {
+			if err := img.Smartcrop(boxW, boxH, &libvips.SmartcropOptions{Interesting: libvips.InterestingAttention}); err != nil {
+				return fmt.Errorf("failed to smart crop image: %w", err)
+			}
+			return nil
+		}
+
+		left, top := transform.CropOffset(imgW, imgH, boxW, boxH, params.Gravity, focal)
+		if err := img.ExtractArea(left, top, boxW, boxH); err != nil {
+			return fmt.Errorf("failed to crop image: %w", err)
+		}
+	case transform.FitContain:
+		if boxW == imgW && boxH == imgH {
+			return nil
+		}
+
+		// black is transparent for images with an alpha channel
+		left, top := transform.CropOffset(imgW, imgH, boxW, boxH, params.Gravity, nil)
+		if err := img.Embed(-left, -top, boxW, boxH, &libvips.EmbedOptions{Extend: libvips.ExtendBlack}); err != nil {
+			return fmt.Errorf("failed to pad image: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -1,12 +1,17 @@
 package imageops
 
 import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
 	"viz/internal/config"
 	"viz/internal/dto"
 	"viz/internal/entities"
 	"viz/internal/images"
 	libvips "viz/internal/imageops/vips"
 	"viz/internal/transform"
+	"viz/internal/utils"
 	"os"
 	"path/filepath"
 	"strings"
@@ -94,6 +99,70 @@ func TestGenerateTransform_ModernSamples(t *testing.T) {
 						Quality: 90,
 					},
 				},
+				{
+					name: "Cover 200x100 WebP",
+					params: &transform.TransformParams{
+						Width:   200,
+						Height:  100,
+						Format:  "webp",
+						Quality: 80,
+						Fit:     transform.FitCover,
+					},
+				},
+				{
+					name: "Contain Smart 120x200 PNG",
+					params: &transform.TransformParams{
+						Width:   120,
+						Height:  200,
+						Format:  "png",
+						Fit:     transform.FitContain,
+						Gravity: transform.GravitySmart,
+					},
+				},
+				{
+					name: "Crop Cover Smart 64x64 JPG",
+					params: &transform.TransformParams{
+						Width:   64,
+						Height:  64,
+						Format:  "jpg",
+						Quality: 80,
+						Fit:     transform.FitCover,
+						Gravity: transform.GravitySmart,
+						Crop:    &transform.Crop{X: 10, Y: 10, Width: 100, Height: 80},
+					},
+				},
+				{
+					name: "Text Watermark Width 300 JPG",
+					params: &transform.TransformParams{
+						Width:  300,
+						Format: "jpg",
+						Watermark: &transform.Watermark{Profile: dto.WatermarkProfile{
+							Text:     utils.StringPtr("PROOF <1>"),
+							Position: dto.WatermarkProfilePositionSoutheast,
+							Opacity:  0.5,
+							Scale:    0.3,
+						}},
+					},
+				},
+				{
+					name: "Tiled Logo Watermark Cover 200x200 PNG",
+					params: &transform.TransformParams{
+						Width:  200,
+						Height: 200,
+						Format: "png",
+						Fit:    transform.FitCover,
+						Watermark: &transform.Watermark{
+							Profile: dto.WatermarkProfile{
+								Logo:     utils.StringPtr(strings.Repeat("0", 40)),
+								Position: dto.WatermarkProfilePositionCentre,
+								Opacity:  0.25,
+								Scale:    0.2,
+								Tile:     true,
+							},
+							Logo: testLogo(t),
+						},
+					},
+				},
 			}
 
 			for _, tc := range testCases {
@@ -131,7 +200,11 @@ func TestGenerateTransform_ModernSamples(t *testing.T) {
 					width := int64(resImg.Width())
 					height := int64(resImg.Height())
 
-					if tc.params.Width > 0 && tc.params.Height > 0 {
+					if tc.params.Fit == transform.FitCover || tc.params.Fit == transform.FitContain {
+						if width != tc.params.Width || height != tc.params.Height {
+							t.Errorf("Result image %dx%d is not exactly %dx%d", width, height, tc.params.Width, tc.params.Height)
+						}
+					} else if tc.params.Width > 0 && tc.params.Height > 0 {
 						if width > tc.params.Width || height > tc.params.Height {
 							t.Errorf("Result image %dx%d exceeds bounds %dx%d", width, height, tc.params.Width, tc.params.Height)
 						}
@@ -236,6 +309,58 @@ func TestPermanentTransforms(t *testing.T) {
 	}
 }
 
+func TestParseTransformParamsRoundTrip(t *testing.T) {
+	params := &transform.TransformParams{
+		Format:  "webp",
+		Width:   400,
+		Height:  400,
+		Quality: 85,
+		Rotate:  90,
+		Flip:    "horizontal",
+		Kernel:  "lanczos3",
+		Fit:     transform.FitCover,
+		Crop:    &transform.Crop{X: 10, Y: 20, Width: 300, Height: 200},
+		Gravity: transform.GravityNorthEast,
+	}
+
+	parsed, err := ParseTransformParams("/images/abc/file?" + params.ToQueryString())
+	if err != nil {
+		t.Fatalf("ParseTransformParams() = %v", err)
+	}
+
+	if parsed.ToQueryString() != params.ToQueryString() {
+		t.Errorf("round trip = %q, want %q", parsed.ToQueryString(), params.ToQueryString())
+	}
+
+	img := entities.ImageAsset{ImageMetadata: &dto.ImageMetadata{Checksum: "abc"}}
+	if *transform.CreateTransformEtag(img, parsed) != *transform.CreateTransformEtag(img, params) {
+		t.Error("parsed params have a different ETag")
+	}
+
+	for _, query := range []string{"fit=stretch", "gravity=up", "crop=1,2,3", "crop=0,0,0,10"} {
+		if _, err := ParseTransformParams("/images/abc/file?" + query); err == nil {
+			t.Errorf("ParseTransformParams(%q) succeeded", query)
+		}
+	}
+}
+
+// testLogo encodes a small half transparent PNG to watermark with
+func testLogo(t *testing.T) []byte {
+	logo := image.NewNRGBA(image.Rect(0, 0, 16, 8))
+	for x := range 16 {
+		for y := range 8 {
+			logo.Set(x, y, color.NRGBA{R: 200, A: uint8(x * 16)})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, logo); err != nil {
+		t.Fatalf("Failed to encode test logo: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
 func diff(a, b int64) int64 {
 	if a > b {
 		return a - b
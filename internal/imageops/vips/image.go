@@ -11056,6 +11056,19 @@ func (r *Image) RemoveExif() error {
 	return nil
 }
 
+// RemoveFields removes the named metadata fields from the image, names it doesn't have are ignored
+func (r *Image) RemoveFields(names ...string) error {
+	out, err := vipsgenCopy(r.image)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		vipsImageRemoveField(out, name)
+	}
+	r.setImage(out)
+	return nil
+}
+
 // Modulate the colors
 func (r *Image) Modulate(brightness, saturation, hue float64) error {
 	var err error
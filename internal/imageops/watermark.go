@@ -0,0 +1,145 @@
+package imageops
+
+import (
+	"fmt"
+	"html"
+	"math"
+
+	libvips "viz/internal/imageops/vips"
+	"viz/internal/transform"
+)
+
+// watermarkFont is the font watermark text is drawn in, libvips picks the
+// size that fits the watermark's width
+const watermarkFont = "sans bold"
+
+// WatermarkFormat returns the format a watermarked original is served in,
+// see transform.OriginalFormat
+func WatermarkFormat(fileType string) string {
+	return transform.OriginalFormat(fileType)
+}
+
+// drawWatermark composites a watermark over a finished transform, either
+// once at the profile's position or tiled over the whole image
+func drawWatermark(img *libvips.Image, wm *transform.Watermark) error {
+	if wm == nil || transform.WatermarkDrawsNothing(wm.Profile) {
+		return nil
+	}
+
+	mark, err := watermarkImage(wm, transform.WatermarkWidth(wm.Profile, img.Width()))
+	if err != nil {
+		return err
+	}
+	defer mark.Close()
+
+	// fade the mark through its alpha band only
+	scale := []float64{1, 1, 1, float64(wm.Profile.Opacity)}
+	if err := mark.Linear(scale, make([]float64, 4), nil); err != nil {
+		return fmt.Errorf("failed to fade watermark: %w", err)
+	}
+
+	var left, top int
+	if wm.Profile.Tile {
+		gapX, gapY := transform.WatermarkTileSpacing(mark.Width(), mark.Height())
+		tileW, tileH := mark.Width()+gapX, mark.Height()+gapY
+
+		// black with no alpha, so the gaps are transparent
+		if err := mark.Embed(0, 0, tileW, tileH, &libvips.EmbedOptions{Extend: libvips.ExtendBlack}); err != nil {
+			return fmt.Errorf("failed to space watermark tiles: %w", err)
+		}
+
+		across := int(math.Ceil(float64(img.Width()) / float64(tileW)))
+		down := int(math.Ceil(float64(img.Height()) / float64(tileH)))
+		if err := mark.Replicate(across, down); err != nil {
+			return fmt.Errorf("failed to tile watermark: %w", err)
+		}
+	} else {
+		left, top = transform.WatermarkOffset(img.Width(), img.Height(), mark.Width(), mark.Height(), wm.Profile.Position)
+	}
+
+	bands := img.Bands()
+	alpha := img.HasAlpha()
+
+	// 16-bit transforms stay 16-bit
+	format, space := libvips.BandFormatUchar, libvips.InterpretationSrgb
+	if img.BandFormat() == libvips.BandFormatUshort {
+		format, space = libvips.BandFormatUshort, libvips.InterpretationRgb16
+	}
+
+	// the output is the size of the image, the overflowing tiles are cut off
+	if err := img.Composite2(mark, libvips.BlendModeOver, &libvips.Composite2Options{
+		X:                left,
+		Y:                top,
+		CompositingSpace: space,
+	}); err != nil {
+		return fmt.Errorf("failed to draw watermark: %w", err)
+	}
+
+	// compositing adds an alpha band, drop it again for opaque images
+	if !alpha {
+		if err := img.ExtractBand(0, &libvips.ExtractBandOptions{N: bands}); err != nil {
+			return fmt.Errorf("failed to flatten watermarked image: %w", err)
+		}
+	}
+
+	return img.Cast(format, nil)
+}
+
+// watermarkImage renders a watermark's logo, or its text when there is no
+// logo, as an sRGB image with alpha width pixels wide
+func watermarkImage(wm *transform.Watermark, width int) (*libvips.Image, error) {
+	if wm.Profile.Logo != nil && *wm.Profile.Logo != "" {
+		return watermarkLogo(wm.Logo, width)
+	}
+
+	color := transform.WatermarkColor(wm.Profile)
+	markup := fmt.Sprintf(`<span foreground="#%02x%02x%02x">%s</span>`,
+		int(color[0]), int(color[1]), int(color[2]), html.EscapeString(*wm.Profile.Text))
+
+	// with a width and height and no dpi, libvips fits the text in the box
+	text, err := libvips.NewText(markup, &libvips.TextOptions{
+		Font:   watermarkFont,
+		Width:  width,
+		Height: width,
+		Align:  libvips.AlignCentre,
+		Rgba:   true,
+		Wrap:   libvips.TextWrapNone,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render watermark text: %w", err)
+	}
+
+	return text, nil
+}
+
+func watermarkLogo(data []byte, width int) (*libvips.Image, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("watermark logo is not loaded")
+	}
+
+	logo, err := libvips.NewImageFromBuffer(data, libvips.DefaultLoadOptions())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load watermark logo: %w", err)
+	}
+
+	// greyscale and palette PNGs come out as sRGB, keeping their alpha
+	if err := logo.Colourspace(libvips.InterpretationSrgb, nil); err != nil {
+		logo.Close()
+		return nil, fmt.Errorf("failed to convert watermark logo to sRGB: %w", err)
+	}
+
+	if !logo.HasAlpha() {
+		if err := logo.BandjoinConst([]float64{255}); err != nil {
+			logo.Close()
+			return nil, fmt.Errorf("failed to add alpha to watermark logo: %w", err)
+		}
+	}
+
+	scale := float64(width) / float64(logo.Width())
+	if err := logo.Resize(scale, &libvips.ResizeOptions{Kernel: libvips.KernelLanczos3, Vscale: scale}); err != nil {
+		logo.Close()
+		return nil, fmt.Errorf("failed to scale watermark logo: %w", err)
+	}
+
+	return logo, nil
+}
@@ -6,8 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"os"
-	"path/filepath"
+	"path"
 	"sort"
 	"strings"
 	"time"
@@ -17,6 +16,7 @@ import (
 	"viz/internal/config"
 	"viz/internal/dto"
 	"viz/internal/entities"
+	"viz/internal/storage"
 	"viz/internal/transform"
 )
 
@@ -36,163 +36,102 @@ func cacheFileName(key string, ext string) string {
 	return fmt.Sprintf("%x.%s", h, ext)
 }
 
-// CacheDirForUID returns the transforms dir for a given UID, creating it if necessary
-func CacheDirForUID(uid string) (string, error) {
-	if err := CreateImageDir(uid); err != nil {
-		return "", err
-	}
-
-	dir := filepath.Join(GetImageDir(uid), "transforms")
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		if err := os.MkdirAll(dir, 0o755); err != nil {
-			return "", err
-		}
-	}
-
-	return dir, nil
+// transformFolder returns the storage folder holding an image's cached transforms
+func transformFolder(uid string) string {
+	return storage.Join(ImageFolder(uid), "transforms")
 }
 
-// CacheFilePath returns the expected cache file path for the given uid/key/ext
-func CacheFilePath(uid string, key string, ext string) (string, error) {
-	dir, err := CacheDirForUID(uid)
-	if err != nil {
-		return "", err
-	}
-
-	return filepath.Join(dir, cacheFileName(key, ext)), nil
+// TransformKey returns the storage key of the cached transform for the given uid/key/ext
+func TransformKey(uid string, key string, ext string) string {
+	return storage.Join(transformFolder(uid), cacheFileName(key, ext))
 }
 
-// FindCachedTransform returns the path to the cached transform if it exists.
+// FindCachedTransform returns the storage key of the cached transform if it exists.
 // If not present, exists==false.
-func FindCachedTransform(uid string, key string, ext string) (path string, exists bool, err error) {
-	path, err = CacheFilePath(uid, key, ext)
+func FindCachedTransform(ctx context.Context, img entities.ImageAsset, key string, ext string) (storageKey string, exists bool, err error) {
+	store, err := imageStore(img)
 	if err != nil {
 		return "", false, err
 	}
 
-	_, err = os.Stat(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return "", false, nil
-		}
+	storageKey = TransformKey(img.Uid, key, ext)
 
+	exists, err = storage.Exists(ctx, store, storageKey)
+	if err != nil || !exists {
 		return "", false, err
 	}
 
-	return path, true, nil
+	return storageKey, true, nil
 }
 
-// ReadCachedTransform reads the cached transform bytes for the given uid/key/ext.
-func ReadCachedTransform(uid string, key string, ext string) (data []byte, err error) {
-	path, ok, err := FindCachedTransform(uid, key, ext)
+// ReadCachedTransform reads the cached transform bytes for the given image/key/ext.
+func ReadCachedTransform(ctx context.Context, img entities.ImageAsset, key string, ext string) (data []byte, err error) {
+	store, err := imageStore(img)
 	if err != nil {
 		return nil, err
 	}
 
-	if !ok {
-		return nil, errors.New(CacheErrTransformNotFound)
-	}
-
-	b, err := os.ReadFile(path)
+	b, err := storage.ReadAll(ctx, store, TransformKey(img.Uid, key, ext))
 	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			return nil, errors.New(CacheErrTransformNotFound)
+		}
+
 		return nil, err
 	}
 
 	return b, nil
 }
 
-// WriteCachedTransform writes bytes to a temp file then renames into place atomically.
-func WriteCachedTransform(uid string, key string, ext string, data []byte) error {
-	dir, err := CacheDirForUID(uid)
-	if err != nil {
-		return err
-	}
-
-	tmpFile, err := os.CreateTemp(dir, TempTransformPrefix)
+// WriteCachedTransform stores the transform bytes, the storage backend
+// replaces any existing transform atomically.
+func WriteCachedTransform(ctx context.Context, img entities.ImageAsset, key string, ext string, data []byte) error {
+	store, err := imageStore(img)
 	if err != nil {
 		return err
 	}
 
-	tmpPath := tmpFile.Name()
-
-	// write
-	if _, err := tmpFile.Write(data); err != nil {
-		tmpFile.Close()
-		os.Remove(tmpPath)
-		return err
-	}
-	tmpFile.Close()
-
-	finalPath := filepath.Join(dir, cacheFileName(key, ext))
-
-	// atomic rename
-	if err := os.Rename(tmpPath, finalPath); err != nil {
-		os.Remove(tmpPath)
-		return err
-	}
-
-	return nil
+	return storage.PutBytes(ctx, store, TransformKey(img.Uid, key, ext), data)
 }
 
-// PurgeTransformsForUID removes the transforms directory for a UID
-func PurgeTransformsForUID(uid string) error {
-	dir := filepath.Join(GetImageDir(uid), "transforms")
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		return nil
-	}
-	return os.RemoveAll(dir)
+// PurgeTransformsForUID removes the transforms directory for a UID in store
+func PurgeTransformsForUID(ctx context.Context, store storage.Storage, uid string) error {
+	return store.DeleteAll(ctx, transformFolder(uid))
 }
 
-// GetCacheStatus calculates and returns the current status of the image transform cache.
-func GetCacheStatus() (dto.CacheStatusResponse, error) {
-	var totalSize int64
-	var totalItems int64
-
-	entries, err := os.ReadDir(Directory)
-	if err != nil {
-		return dto.CacheStatusResponse{}, fmt.Errorf("failed to read images directory: %w", err)
-	}
-
-	for _, e := range entries {
-		if !e.IsDir() {
-			continue
+// listTransforms calls fn with every cached transform in the image
+// folders below root, skipping temporary files left by older versions
+func listTransforms(ctx context.Context, store storage.Storage, root string, fn func(uid string, obj storage.ObjectInfo) error) error {
+	return store.List(ctx, root, func(obj storage.ObjectInfo) error {
+		rel := obj.Key
+		if root != "" {
+			rel = strings.TrimPrefix(rel, root+"/")
 		}
 
-		transformsPath := filepath.Join(Directory, e.Name(), "transforms")
-		info, err := os.Stat(transformsPath)
-		if err != nil {
-			if os.IsNotExist(err) {
-				continue
-			}
-			return dto.CacheStatusResponse{}, fmt.Errorf("failed to stat transforms directory %s: %w", transformsPath, err)
-		}
-		if !info.IsDir() {
-			continue
-		}
-
-		tfiles, err := os.ReadDir(transformsPath)
-		if err != nil {
-			return dto.CacheStatusResponse{}, fmt.Errorf("failed to read transforms directory %s: %w", transformsPath, err)
+		// <uid>/transforms/<hash>.<ext>
+		parts := strings.Split(rel, "/")
+		if len(parts) != 3 || parts[1] != "transforms" || strings.HasPrefix(parts[2], TempTransformPrefix) {
+			return nil
 		}
 
-		for _, tf := range tfiles {
-			if tf.IsDir() {
-				continue
-			}
-
-			base := tf.Name()
-			if len(base) >= len(TempTransformPrefix) && base[:len(TempTransformPrefix)] == TempTransformPrefix {
-				continue
-			}
+		return fn(parts[0], obj)
+	})
+}
 
-			finfo, err := tf.Info()
-			if err != nil {
-				return dto.CacheStatusResponse{}, fmt.Errorf("failed to get file info for %s: %w", filepath.Join(transformsPath, base), err)
-			}
+// GetCacheStatus calculates and returns the current status of the image transform cache.
+func GetCacheStatus(ctx context.Context) (dto.CacheStatusResponse, error) {
+	var totalSize int64
+	var totalItems int64
 
-			totalSize += finfo.Size()
+	err := ForEachLocation(func(name string, store storage.Storage) error {
+		return listTransforms(ctx, store, LibraryPrefix, func(uid string, obj storage.ObjectInfo) error {
+			totalSize += obj.Size
 			totalItems++
-		}
+			return nil
+		})
+	})
+	if err != nil {
+		return dto.CacheStatusResponse{}, fmt.Errorf("failed to list cached transforms: %w", err)
 	}
 
 	// For now, hits and misses are not tracked.
@@ -211,25 +150,32 @@ func GetCacheStatus() (dto.CacheStatusResponse, error) {
 	}, nil
 }
 
-// ClearCache removes all cached transform files.
-func ClearCache(logger *slog.Logger) error {
-	entries, err := os.ReadDir(Directory)
-	if err != nil {
-		return fmt.Errorf("failed to read images directory: %w", err)
-	}
+// ClearCache removes all cached transform files in every storage location.
+func ClearCache(ctx context.Context, logger *slog.Logger) error {
+	return ForEachLocation(func(name string, store storage.Storage) error {
+		var uids []string
+		seen := make(map[string]bool)
 
-	for _, e := range entries {
-		if !e.IsDir() {
-			continue
-		}
-		uid := e.Name()
-		if err := PurgeTransformsForUID(uid); err != nil {
-			return fmt.Errorf("failed to purge transforms for UID %s: %w", uid, err)
+		err := listTransforms(ctx, store, LibraryPrefix, func(uid string, obj storage.ObjectInfo) error {
+			if !seen[uid] {
+				seen[uid] = true
+				uids = append(uids, uid)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list cached transforms in %s: %w", name, err)
 		}
 
-		logger.Debug("cleared transform cache for UID", slog.String("uid", uid))
-	}
-	return nil
+		for _, uid := range uids {
+			if err := PurgeTransformsForUID(ctx, store, uid); err != nil {
+				return fmt.Errorf("failed to purge transforms for UID %s: %w", uid, err)
+			}
+
+			logger.Debug("cleared transform cache for UID", slog.String("uid", uid), slog.String("location", name))
+		}
+		return nil
+	})
 }
 
 // PermanentHashGetter is a function that returns a set of hashes for permanent transforms that should be preserved.
@@ -248,10 +194,14 @@ func GetPermanentTransformHashes(db *gorm.DB) (map[string]bool, error) {
 			}
 			// Recalculate etag for each permanent transform and add its hash to the set
 			for _, params := range GetAllPermanentTransforms() {
+				// presets using uploaded profiles are keyed by their checksum
+				_ = LookupColourProfiles(&params)
+				// and rendered in the format GeneratePreset gives them
+				params.DefaultFormat(img.ImageMetadata.FileType)
 				etag := *transform.CreateTransformEtag(img, &params)
 				// The filename is the SHA1 hash of the etag
 				fname := cacheFileName(etag, params.Format)
-				hash := strings.TrimSuffix(fname, filepath.Ext(fname))
+				hash := strings.TrimSuffix(fname, path.Ext(fname))
 				permanentHashes[hash] = true
 			}
 		}
@@ -261,8 +211,10 @@ func GetPermanentTransformHashes(db *gorm.DB) (map[string]bool, error) {
 	return permanentHashes, err
 }
 
-// PerformTransformCacheCleanup executes the cache cleanup logic.
-func PerformTransformCacheCleanup(rootDir string, logger *slog.Logger, db *gorm.DB, cfg config.CacheConfig, hashGetter PermanentHashGetter) {
+// PerformTransformCacheCleanup executes the cache cleanup logic on the image
+// folders below root in store. Files that can't be removed are logged and
+// skipped, the error is for failing to list the cache at all.
+func PerformTransformCacheCleanup(ctx context.Context, store storage.Storage, root string, logger *slog.Logger, db *gorm.DB, cfg config.CacheConfig, hashGetter PermanentHashGetter) error {
 	var maxSizeBytes int64 = 10 * 1000 * 1000 * 1000 // 10 GB
 	var maxAgeDays int = 30
 	var cleanupIntervalMinutes int = 60 * 24 // daily
@@ -293,76 +245,36 @@ func PerformTransformCacheCleanup(rootDir string, logger *slog.Logger, db *gorm.
 		}
 	}
 
-	type fileInfo struct {
-		path string
-		size int64
-		mod  time.Time
-	}
-
-	var files []fileInfo
+	var files []storage.ObjectInfo
 	var total int64
 
-	entries, err := os.ReadDir(rootDir)
+	err := listTransforms(ctx, store, root, func(uid string, obj storage.ObjectInfo) error {
+		files = append(files, obj)
+		total += obj.Size
+		return nil
+	})
 	if err != nil {
-		logger.Warn("cache gc: failed to read images directory", slog.Any("error", err))
-		return
-	}
-
-	for _, e := range entries {
-		if !e.IsDir() {
-			continue
-		}
-
-		transformsPath := filepath.Join(rootDir, e.Name(), "transforms")
-		info, err := os.Stat(transformsPath)
-		if err != nil || !info.IsDir() {
-			continue
-		}
-
-		tfiles, err := os.ReadDir(transformsPath)
-		if err != nil {
-			continue
-		}
-
-		for _, tf := range tfiles {
-			if tf.IsDir() {
-				continue
-			}
-
-			base := tf.Name()
-			if strings.HasPrefix(base, TempTransformPrefix) {
-				continue
-			}
-
-			finfo, err := tf.Info()
-			if err != nil {
-				continue
-			}
-
-			p := filepath.Join(transformsPath, base)
-			files = append(files, fileInfo{path: p, size: finfo.Size(), mod: finfo.ModTime()})
-			total += finfo.Size()
-		}
+		return fmt.Errorf("failed to list cached transforms: %w", err)
 	}
 
 	cutoff := time.Now().AddDate(0, 0, -maxAgeDays)
-	var remaining []fileInfo
+	var remaining []storage.ObjectInfo
 	for _, f := range files {
 		if shouldPreservePermanent {
-			hash := strings.TrimSuffix(filepath.Base(f.path), filepath.Ext(f.path))
+			hash := strings.TrimSuffix(path.Base(f.Key), path.Ext(f.Key))
 			if permanentHashes[hash] {
 				continue // Skip to next file
 			}
 		}
 
-		if f.mod.Before(cutoff) {
-			if err := os.Remove(f.path); err == nil {
-				logger.Debug("transform cache gc: removed old file", slog.String("path", f.path), slog.Time("mod", f.mod))
+		if f.ModTime.Before(cutoff) {
+			if err := store.Delete(ctx, f.Key); err == nil {
+				logger.Debug("transform cache gc: removed old file", slog.String("key", f.Key), slog.Time("mod", f.ModTime))
 
-				total -= f.size
+				total -= f.Size
 				continue
 			} else {
-				logger.Warn("transform cache gc: failed to remove old file", slog.String("path", f.path), slog.Any("error", err))
+				logger.Warn("transform cache gc: failed to remove old file", slog.String("key", f.Key), slog.Any("error", err))
 			}
 		}
 
@@ -370,56 +282,20 @@ func PerformTransformCacheCleanup(rootDir string, logger *slog.Logger, db *gorm.
 	}
 
 	if total > maxSizeBytes {
-		sort.Slice(remaining, func(i, j int) bool { return remaining[i].mod.Before(remaining[j].mod) })
+		sort.Slice(remaining, func(i, j int) bool { return remaining[i].ModTime.Before(remaining[j].ModTime) })
 		for _, f := range remaining {
 			if total <= maxSizeBytes {
 				break
 			}
-			if err := os.Remove(f.path); err == nil {
-				total -= f.size
-				logger.Debug("transform cache gc: evicted file", slog.String("path", f.path), slog.Int64("size", f.size))
+			if err := store.Delete(ctx, f.Key); err == nil {
+				total -= f.Size
+				logger.Debug("transform cache gc: evicted file", slog.String("key", f.Key), slog.Int64("size", f.Size))
 			} else {
-				logger.Warn("transform cache gc: failed to evict file", slog.String("path", f.path), slog.Any("error", err))
+				logger.Warn("transform cache gc: failed to evict file", slog.String("key", f.Key), slog.Any("error", err))
 			}
 		}
 	}
 
 	logger.Debug("transform cache gc: finished", slog.Int64("remaining_total_bytes", total))
-}
-
-// StartTransformCacheGC starts a background goroutine that periodically
-// enforces the transform cache eviction policy based on config values.
-// The goroutine will stop when ctx is canceled.
-func StartTransformCacheGC(ctx context.Context, logger *slog.Logger, db *gorm.DB) {
-	go func() {
-		cfg := config.AppConfig
-		cleanupIntervalMinutes := cfg.Cache.CleanupIntervalMinutes
-		if cleanupIntervalMinutes <= 0 {
-			cleanupIntervalMinutes = 1440 // 24 hours
-			{
-				logger.Warn("transform cache gc: invalid cleanup interval, using default", slog.Int("interval_minutes", cleanupIntervalMinutes))
-			}
-		}
-
-		interval := time.Duration(cleanupIntervalMinutes) * time.Minute
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-
-		doCleanup := func() {
-			PerformTransformCacheCleanup(Directory, logger, db, config.AppConfig.Cache, GetPermanentTransformHashes)
-		}
-
-		// do startup run
-		doCleanup()
-
-		for {
-			select {
-			case <-ctx.Done():
-				logger.Debug("transform cache gc: stopping")
-				return
-			case <-ticker.C:
-				doCleanup()
-			}
-		}
-	}()
+	return nil
 }
@@ -1,6 +1,7 @@
 package images
 
 import (
+	"context"
 	"crypto/sha1"
 	"fmt"
 	"io"
@@ -15,6 +16,7 @@ import (
 	"viz/internal/config"
 	"viz/internal/dto"
 	"viz/internal/entities"
+	"viz/internal/storage"
 	"viz/internal/transform"
 	"viz/internal/uid"
 )
@@ -170,7 +172,7 @@ func TestPerformTransformCacheCleanup(t *testing.T) {
 
 			// Run Cleanup
 			// Pass a dummy DB since the mockHashGetter doesn't use it but PerformTransformCacheCleanup checks for it
-			PerformTransformCacheCleanup(rootDir, logger, &gorm.DB{}, cfg, mockHashGetter)
+			PerformTransformCacheCleanup(context.Background(), storage.NewLocal(rootDir), "", logger, &gorm.DB{}, cfg, mockHashGetter)
 
 			// Verify Exists
 			for _, name := range tt.expectExists {
@@ -3,6 +3,7 @@ package images
 import (
 	"crypto/sha1"
 	"encoding/hex"
+	"io"
 )
 
 func CalculateImageChecksum(data []byte) (string, error) {
@@ -14,3 +15,14 @@ func CalculateImageChecksum(data []byte) (string, error) {
 
 	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
+
+// CalculateReaderChecksum is CalculateImageChecksum for a file streamed
+// from r instead of held in memory
+func CalculateReaderChecksum(r io.Reader) (string, error) {
+	hasher := sha1.New()
+	if _, err := io.Copy(hasher, r); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
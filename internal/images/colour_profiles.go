@@ -0,0 +1,202 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"gorm.io/gorm"
+
+	"viz/internal/entities"
+	"viz/internal/icc"
+	"viz/internal/storage"
+	"viz/internal/transform"
+)
+
+// ColourProfilePrefix is the storage folder holding uploaded ICC profiles,
+// stored under their checksum like watermark logos
+const ColourProfilePrefix = "icc"
+
+// MaxColourProfileSize is the largest ICC profile that can be uploaded
+const MaxColourProfileSize = 4 << 20
+
+// ErrUnknownColourProfile is returned for transforms naming a profile that
+// was never uploaded
+var ErrUnknownColourProfile = errors.New("unknown colour profile")
+
+// ErrInvalidColourProfile is returned for uploads that can't be used as a
+// colour profile
+var ErrInvalidColourProfile = errors.New("invalid colour profile")
+
+var (
+	colourProfilesMu sync.RWMutex
+	// colourProfiles are the uploaded profiles by name
+	colourProfiles = map[string]entities.ColourProfile{}
+)
+
+// ColourProfileKey returns the storage key of the profile with a checksum
+func ColourProfileKey(checksum string) string {
+	return storage.Join(ColourProfilePrefix, checksum+".icc")
+}
+
+// LoadColourProfiles reads the uploaded profiles from the database
+func LoadColourProfiles(db *gorm.DB) error {
+	var saved []entities.ColourProfile
+	if err := db.Find(&saved).Error; err != nil {
+		return fmt.Errorf("failed to read colour profiles: %w", err)
+	}
+
+	loaded := make(map[string]entities.ColourProfile, len(saved))
+	for _, p := range saved {
+		loaded[p.Name] = p
+	}
+
+	colourProfilesMu.Lock()
+	colourProfiles = loaded
+	colourProfilesMu.Unlock()
+
+	return nil
+}
+
+// GetColourProfiles returns the uploaded profiles sorted by name
+func GetColourProfiles() []entities.ColourProfile {
+	colourProfilesMu.RLock()
+	defer colourProfilesMu.RUnlock()
+
+	list := make([]entities.ColourProfile, 0, len(colourProfiles))
+	for _, name := range slices.Sorted(maps.Keys(colourProfiles)) {
+		list = append(list, colourProfiles[name])
+	}
+
+	return list
+}
+
+// SaveColourProfile validates and stores an ICC profile under a name,
+// replacing the profile of that name. Transforms using it are rendered
+// again when next requested, their ETags include the profile's checksum.
+func SaveColourProfile(ctx context.Context, db *gorm.DB, name string, data []byte) (entities.ColourProfile, error) {
+	if !transform.ValidProfileName(name) {
+		return entities.ColourProfile{}, fmt.Errorf("%w: names are lowercase letters, digits and dashes, and not a built in profile", ErrInvalidColourProfile)
+	}
+
+	header, err := icc.ParseHeader(data)
+	if err != nil {
+		return entities.ColourProfile{}, fmt.Errorf("%w: %w", ErrInvalidColourProfile, err)
+	}
+
+	switch header.ColourSpace {
+	case icc.ColourSpaceRGB, icc.ColourSpaceCMYK, icc.ColourSpaceGray:
+	default:
+		return entities.ColourProfile{}, fmt.Errorf("%w: %s profiles aren't supported, upload an RGB, CMYK or gray profile", ErrInvalidColourProfile, header.ColourSpace)
+	}
+
+	checksum, err := CalculateImageChecksum(data)
+	if err != nil {
+		return entities.ColourProfile{}, err
+	}
+
+	if err := storage.PutBytes(ctx, Store, ColourProfileKey(checksum), data); err != nil {
+		return entities.ColourProfile{}, fmt.Errorf("failed to store colour profile: %w", err)
+	}
+
+	profile := entities.ColourProfile{Name: name}
+	err = db.Where(entities.ColourProfile{Name: name}).
+		Assign(entities.ColourProfile{Checksum: checksum, ColourSpace: header.ColourSpace, Class: header.Class, Size: int64(len(data))}).
+		FirstOrCreate(&profile).Error
+	if err != nil {
+		return entities.ColourProfile{}, fmt.Errorf("failed to save colour profile: %w", err)
+	}
+
+	return profile, LoadColourProfiles(db)
+}
+
+// DeleteColourProfile removes an uploaded profile, reporting whether there
+// was one. The file is left in storage, share links and cached transforms
+// may still refer to it.
+func DeleteColourProfile(db *gorm.DB, name string) (bool, error) {
+	result := db.Where("name = ?", name).Delete(&entities.ColourProfile{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, LoadColourProfiles(db)
+}
+
+// LookupColourProfiles sets the checksums of the uploaded profiles a
+// transform uses, which its ETag depends on
+func LookupColourProfiles(params *transform.TransformParams) error {
+	names := params.UploadedProfiles()
+	if len(names) == 0 {
+		return nil
+	}
+
+	colourProfilesMu.RLock()
+	defer colourProfilesMu.RUnlock()
+
+	resolved := make(map[string]transform.ColourProfile, len(names))
+	for _, name := range names {
+		profile, ok := colourProfiles[name]
+		if !ok {
+			return fmt.Errorf("%w %s", ErrUnknownColourProfile, name)
+		}
+
+		resolved[name] = transform.ColourProfile{Checksum: profile.Checksum}
+	}
+
+	params.ColourProfiles = resolved
+	return nil
+}
+
+// FetchColourProfiles makes the uploaded profiles of a transform, looked
+// up by LookupColourProfiles, available as the local files libvips reads
+// them from. Files are named by checksum so they're fetched once.
+func FetchColourProfiles(ctx context.Context, params *transform.TransformParams) error {
+	if len(params.ColourProfiles) == 0 {
+		return nil
+	}
+
+	dir := filepath.Join(os.TempDir(), "viz-icc")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create colour profile directory: %w", err)
+	}
+
+	for name, profile := range params.ColourProfiles {
+		profile.Path = filepath.Join(dir, profile.Checksum+".icc")
+		params.ColourProfiles[name] = profile
+
+		if _, err := os.Stat(profile.Path); err == nil {
+			continue
+		}
+
+		data, err := storage.ReadAll(ctx, Store, ColourProfileKey(profile.Checksum))
+		if err != nil {
+			return fmt.Errorf("failed to read colour profile %s: %w", name, err)
+		}
+
+		// written aside and renamed so concurrent transforms never read
+		// half a profile
+		tmp, err := os.CreateTemp(dir, profile.Checksum+"-*.tmp")
+		if err != nil {
+			return fmt.Errorf("failed to write colour profile %s: %w", name, err)
+		}
+
+		_, err = tmp.Write(data)
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), profile.Path)
+		}
+		if err != nil {
+			_ = os.Remove(tmp.Name())
+			return fmt.Errorf("failed to write colour profile %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"viz/internal/entities"
+	"viz/internal/icc"
+	"viz/internal/storage"
+	"viz/internal/transform"
+)
+
+func TestLookupAndFetchColourProfiles(t *testing.T) {
+	prev := Store
+	Store = storage.NewLocal(t.TempDir())
+	t.Cleanup(func() { Store = prev })
+	t.Setenv("TMPDIR", t.TempDir())
+
+	ctx := context.Background()
+	data := icc.AdobeRGB.Bytes()
+	checksum, _ := CalculateImageChecksum(data)
+	if err := storage.PutBytes(ctx, Store, ColourProfileKey(checksum), data); err != nil {
+		t.Fatal(err)
+	}
+
+	colourProfilesMu.Lock()
+	colourProfiles = map[string]entities.ColourProfile{"press": {Name: "press", Checksum: checksum}}
+	colourProfilesMu.Unlock()
+	t.Cleanup(func() { colourProfiles = map[string]entities.ColourProfile{} })
+
+	params := &transform.TransformParams{Format: "jpg", Profile: transform.ProfileDisplayP3, Proof: "press"}
+	if err := LookupColourProfiles(params); err != nil {
+		t.Fatalf("LookupColourProfiles() = %v", err)
+	}
+	if params.ColourProfiles["press"].Checksum != checksum {
+		t.Errorf("ColourProfiles = %+v", params.ColourProfiles)
+	}
+
+	if err := FetchColourProfiles(ctx, params); err != nil {
+		t.Fatalf("FetchColourProfiles() = %v", err)
+	}
+	got, err := os.ReadFile(params.ColourProfiles["press"].Path)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("fetched profile differs, err = %v", err)
+	}
+
+	unknown := &transform.TransformParams{Format: "jpg", Profile: "missing"}
+	if err := LookupColourProfiles(unknown); !errors.Is(err, ErrUnknownColourProfile) {
+		t.Errorf("LookupColourProfiles() of an unknown profile = %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package images
+
+import (
+	"slices"
+
+	"viz/internal/dto"
+	"viz/internal/entities"
+)
+
+// MaxEditHistory is how many earlier edit stacks are kept for undo
+const MaxEditHistory = 20
+
+// CurrentEdits returns an image's edit stack, empty when it has none
+func CurrentEdits(img entities.ImageAsset) []dto.ImageEdit {
+	if img.Edits == nil {
+		return []dto.ImageEdit{}
+	}
+
+	return *img.Edits
+}
+
+// EditStack describes an image's edit stack for the API
+func EditStack(img entities.ImageWithEditHistory) dto.ImageEditStack {
+	return dto.ImageEditStack{
+		Edits:   CurrentEdits(img.ImageAsset),
+		CanUndo: len(img.EditHistory) > 0,
+	}
+}
+
+// ReplaceEdits sets an image's edit stack, keeping the current one for
+// undo. It reports false when the stack didn't change.
+func ReplaceEdits(img *entities.ImageWithEditHistory, edits []dto.ImageEdit) bool {
+	current := CurrentEdits(img.ImageAsset)
+	if slices.EqualFunc(current, edits, editsEqual) {
+		return false
+	}
+
+	img.EditHistory = append(img.EditHistory, current)
+	if len(img.EditHistory) > MaxEditHistory {
+		img.EditHistory = img.EditHistory[len(img.EditHistory)-MaxEditHistory:]
+	}
+
+	if len(edits) == 0 {
+		img.Edits = nil
+	} else {
+		img.Edits = &edits
+	}
+
+	return true
+}
+
+// UndoEdits restores the edit stack from before the last change and
+// reports false when there is nothing to undo
+func UndoEdits(img *entities.ImageWithEditHistory) bool {
+	if len(img.EditHistory) == 0 {
+		return false
+	}
+
+	last := len(img.EditHistory) - 1
+	previous := img.EditHistory[last]
+	img.EditHistory = img.EditHistory[:last]
+
+	if len(previous) == 0 {
+		img.Edits = nil
+	} else {
+		img.Edits = &previous
+	}
+
+	return true
+}
+
+func editsEqual(a, b dto.ImageEdit) bool {
+	return a.Op == b.Op && equalPtr(a.Amount, b.Amount) && equalPtr(a.Angle, b.Angle) &&
+		equalPtr(a.Temperature, b.Temperature) && equalPtr(a.Tint, b.Tint) && equalPtr(a.Crop, b.Crop)
+}
+
+func equalPtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
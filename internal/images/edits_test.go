@@ -0,0 +1,58 @@
+package images
+
+import (
+	"testing"
+
+	"viz/internal/dto"
+	"viz/internal/entities"
+)
+
+func TestEditStackUndo(t *testing.T) {
+	amount := float32(25)
+	crop := []dto.ImageEdit{{Op: dto.ImageEditOpCrop, Crop: &dto.ImageEditCrop{Width: 10, Height: 10}}}
+	contrast := []dto.ImageEdit{{Op: dto.ImageEditOpContrast, Amount: &amount}}
+
+	var img entities.ImageWithEditHistory
+	if stack := EditStack(img); len(stack.Edits) != 0 || stack.CanUndo {
+		t.Fatalf("EditStack() of a new image = %+v", stack)
+	}
+
+	if !ReplaceEdits(&img, crop) || !ReplaceEdits(&img, contrast) {
+		t.Fatal("ReplaceEdits() reported no change")
+	}
+
+	same := []dto.ImageEdit{{Op: dto.ImageEditOpContrast, Amount: &amount}}
+	if ReplaceEdits(&img, same) {
+		t.Error("ReplaceEdits() with an equal stack reported a change")
+	}
+
+	if !ReplaceEdits(&img, nil) || img.Edits != nil {
+		t.Fatalf("reset left %+v", img.Edits)
+	}
+
+	for _, want := range [][]dto.ImageEdit{contrast, crop, {}} {
+		if !UndoEdits(&img) {
+			t.Fatal("UndoEdits() had nothing to undo")
+		}
+
+		if got := CurrentEdits(img.ImageAsset); len(got) != len(want) || (len(want) > 0 && got[0].Op != want[0].Op) {
+			t.Errorf("after undo edits = %+v, want %+v", got, want)
+		}
+	}
+
+	if UndoEdits(&img) {
+		t.Error("UndoEdits() past the first change succeeded")
+	}
+}
+
+func TestEditHistoryIsCapped(t *testing.T) {
+	var img entities.ImageWithEditHistory
+	for i := range MaxEditHistory + 5 {
+		amount := float32(i)
+		ReplaceEdits(&img, []dto.ImageEdit{{Op: dto.ImageEditOpExposure, Amount: &amount}})
+	}
+
+	if len(img.EditHistory) != MaxEditHistory {
+		t.Errorf("history holds %d stacks, want %d", len(img.EditHistory), MaxEditHistory)
+	}
+}
@@ -2,6 +2,7 @@ package images
 
 import (
 	"viz/internal/config"
+	"viz/internal/storage"
 	"os"
 	"path/filepath"
 	"strings"
@@ -60,4 +61,21 @@ var (
 		}
 		return trash
 	}()
+
+	// LibraryPrefix is the storage folder holding a folder per image
+	LibraryPrefix = func() string {
+		cfg, err := config.ReadConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		return storage.Join(filepath.ToSlash(cfg.GetString("upload.location")))
+	}()
+
+	// TrashPrefix is the storage folder deleted images are moved to
+	TrashPrefix = "trash"
+
+	// Store holds the files of the library. It's the base directory, the
+	// parent of the trash, until the API switches to the configured backend.
+	Store storage.Storage = storage.NewLocal(filepath.Dir(TrashDirectory))
 )
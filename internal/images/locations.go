@@ -0,0 +1,120 @@
+package images
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"gorm.io/gorm"
+
+	"viz/internal/entities"
+	"viz/internal/storage"
+)
+
+// DefaultLocation names the storage location of the configured backend,
+// Store. Images without a storage location keep their files there.
+const DefaultLocation = "default"
+
+// locations are the named storage locations next to the default one
+var locations = map[string]storage.Storage{}
+
+// SetLocations sets the named storage locations images can be stored in
+// next to the default one
+func SetLocations(stores map[string]storage.Storage) error {
+	if _, ok := stores[DefaultLocation]; ok {
+		return fmt.Errorf("storage location name %q is reserved", DefaultLocation)
+	}
+
+	locations = stores
+	return nil
+}
+
+// LocationName returns the name of a storage location as recorded on an
+// image, where nil and the empty string are the default location
+func LocationName(location *string) string {
+	if location == nil || *location == "" {
+		return DefaultLocation
+	}
+
+	return *location
+}
+
+// LocationValue is the inverse of LocationName, the value recorded on an
+// image stored in the named location
+func LocationValue(name string) *string {
+	if name == DefaultLocation {
+		return nil
+	}
+
+	return &name
+}
+
+// LocationNames returns the names of all storage locations, the default
+// one first
+func LocationNames() []string {
+	names := make([]string, 0, len(locations)+1)
+	for name := range locations {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+	return append([]string{DefaultLocation}, names...)
+}
+
+// StoreAt returns the storage of the named location
+func StoreAt(name string) (storage.Storage, error) {
+	if name == DefaultLocation {
+		return Store, nil
+	}
+
+	store, ok := locations[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown storage location %q", name)
+	}
+
+	return store, nil
+}
+
+// StoreFor returns the storage holding an image's files
+func StoreFor(img entities.ImageAsset) (storage.Storage, error) {
+	return StoreAt(LocationName(img.StorageLocation))
+}
+
+// ForEachLocation calls fn with every storage location in the order of
+// LocationNames, stopping at the first error
+func ForEachLocation(fn func(name string, store storage.Storage) error) error {
+	for _, name := range LocationNames() {
+		store, err := StoreAt(name)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(name, store); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// InLocation scopes a query on images to the ones stored in the named
+// location
+func InLocation(name string) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		if name == DefaultLocation {
+			return tx.Where("storage_location IS NULL OR storage_location = ''")
+		}
+
+		return tx.Where("storage_location = ?", name)
+	}
+}
+
+// imageStore is StoreFor for operations on an image's folder, which must
+// never run without a UID since the folder would be the whole library
+func imageStore(img entities.ImageAsset) (storage.Storage, error) {
+	if img.Uid == "" {
+		return nil, errors.New("image has no uid")
+	}
+
+	return StoreFor(img)
+}
@@ -1,10 +1,16 @@
 package images
 
 import (
+	"bytes"
+	"context"
 	"encoding/base64"
 	"image"
-	"os"
+	"io"
+	"path"
 	"path/filepath"
+
+	"viz/internal/entities"
+	"viz/internal/storage"
 )
 
 func EncodeThumbhashToString(data []byte) string {
@@ -15,51 +21,121 @@ func DecodeThumbhashString(encoded string) ([]byte, error) {
 	return base64.StdEncoding.DecodeString(encoded)
 }
 
-func CreateImageDir(uid string) error {
-	return os.MkdirAll(filepath.Join(Directory, uid), os.ModePerm)
+// ImageFolder returns the storage folder holding an image's files
+func ImageFolder(uid string) string {
+	return storage.Join(LibraryPrefix, uid)
 }
 
-func DeleteImageDir(uid string) error {
-	return os.RemoveAll(filepath.Join(Directory, uid))
+// ImageKey returns the storage key of a file in an image's folder
+func ImageKey(uid, fileName string) string {
+	return FileKey(ImageFolder(uid), fileName)
 }
 
-func GetImageDir(uid string) string {
-	return filepath.Join(Directory, uid)
+// FileKey returns the storage key of a file directly in folder
+func FileKey(folder, fileName string) string {
+	return storage.Join(folder, path.Base(filepath.ToSlash(fileName)))
 }
 
-func ReadImageDir(uid string) ([]os.DirEntry, error) {
-	return os.ReadDir(GetImageDir(uid))
+// TrashFolder returns the storage folder an image's files are moved to
+// when it's deleted
+func TrashFolder(uid string) string {
+	return storage.Join(TrashPrefix, uid)
 }
 
-func GetImagePath(uid, fileName string) string {
-	return filepath.Join(GetImageDir(uid), filepath.Base(fileName))
+func DeleteImageDir(ctx context.Context, img entities.ImageAsset) error {
+	store, err := imageStore(img)
+	if err != nil {
+		return err
+	}
+
+	return store.DeleteAll(ctx, ImageFolder(img.Uid))
 }
 
-func ReadImage(uid, fileName string) ([]byte, error) {
-	return os.ReadFile(GetImagePath(uid, fileName))
+// MoveToTrash moves an image's files to the trash of its storage location
+func MoveToTrash(ctx context.Context, img entities.ImageAsset) error {
+	store, err := imageStore(img)
+	if err != nil {
+		return err
+	}
+
+	return store.Move(ctx, ImageFolder(img.Uid), TrashFolder(img.Uid))
 }
 
-func ReadFileAsGoImage(uid, fileName string) (imageData image.Image, format string, err error) {
-	file, err := os.Open(GetImagePath(uid, fileName))
+// DeleteTrashedImage removes the files of an image from the trash
+func DeleteTrashedImage(ctx context.Context, img entities.ImageAsset) error {
+	store, err := imageStore(img)
 	if err != nil {
-		return nil, "", err
+		return err
+	}
+
+	return store.DeleteAll(ctx, TrashFolder(img.Uid))
+}
+
+// OpenImage opens a file in an image's folder for streaming, callers close it
+func OpenImage(ctx context.Context, img entities.ImageAsset, fileName string) (io.ReadCloser, error) {
+	store, err := imageStore(img)
+	if err != nil {
+		return nil, err
 	}
 
-	defer file.Close()
+	return store.Open(ctx, ImageKey(img.Uid, fileName))
+}
+
+// OpenImageSeekable opens a file in an image's folder for random access, so
+// large originals can be decoded without reading all of them into memory
+func OpenImageSeekable(ctx context.Context, img entities.ImageAsset, fileName string) (io.ReadSeekCloser, error) {
+	store, err := imageStore(img)
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.OpenSeekable(ctx, store, ImageKey(img.Uid, fileName))
+}
+
+// FileOpener returns a function opening the files in an image's folder by
+// name for random access, what imageops loads originals and RAW files with
+func FileOpener(ctx context.Context, img entities.ImageAsset) func(fileName string) (io.ReadSeekCloser, error) {
+	return func(fileName string) (io.ReadSeekCloser, error) {
+		return OpenImageSeekable(ctx, img, fileName)
+	}
+}
+
+func StatImage(ctx context.Context, img entities.ImageAsset, fileName string) (storage.ObjectInfo, error) {
+	store, err := imageStore(img)
+	if err != nil {
+		return storage.ObjectInfo{}, err
+	}
+
+	return store.Stat(ctx, ImageKey(img.Uid, fileName))
+}
+
+func ReadImage(ctx context.Context, img entities.ImageAsset, fileName string) ([]byte, error) {
+	store, err := imageStore(img)
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.ReadAll(ctx, store, ImageKey(img.Uid, fileName))
+}
+
+func ReadFileAsGoImage(ctx context.Context, img entities.ImageAsset, fileName string) (imageData image.Image, format string, err error) {
+	data, err := ReadImage(ctx, img, fileName)
+	if err != nil {
+		return nil, "", err
+	}
 
-	imageData, format, err = image.Decode(file)
+	imageData, format, err = image.Decode(bytes.NewReader(data))
 	if err != nil {
 		return nil, format, err
 	}
 	return imageData, format, nil
 }
 
-func SaveImage(data []byte, uid, fileName string) error {
-	err := CreateImageDir(uid)
+func SaveImage(ctx context.Context, data []byte, img entities.ImageAsset, fileName string) error {
+	store, err := imageStore(img)
 	if err != nil {
 		return err
 	}
 
-	imagesDir := GetImagePath(uid, fileName)
-	return os.WriteFile(imagesDir, data, 0644)
+	return storage.PutBytes(ctx, store, ImageKey(img.Uid, fileName), data)
 }
@@ -1,6 +1,19 @@
 package images
 
-import "viz/internal/transform"
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"sync"
+
+	"gorm.io/gorm"
+
+	"viz/internal/entities"
+	"viz/internal/transform"
+)
 
 // PermanentTransformName is a type for permanent transform names
 type PermanentTransformName string
@@ -28,13 +41,158 @@ var permanentTransforms = map[PermanentTransformName]transform.TransformParams{
 	},
 }
 
+// Where a preset is defined
+const (
+	PresetSourceBuiltin = "builtin"
+	PresetSourceConfig  = "config"
+	PresetSourceCustom  = "custom"
+)
+
+// Preset is a named permanent transform. The thumbnail and preview are
+// built in, admins add more in the config or through the API, and every
+// one of them is generated for every image when it's processed.
+type Preset struct {
+	Name   PermanentTransformName
+	Params transform.TransformParams
+	Source string
+}
+
+var (
+	presetsMu sync.RWMutex
+	// presets are the presets defined in the config and through the API
+	presets = map[PermanentTransformName]Preset{}
+)
+
+var presetNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+// ParsePreset validates a preset and parses its transform from a query
+// string. The built in names can't be redefined, and presets are generated
+// ahead of any request so their format can't depend on one.
+func ParsePreset(name, query string) (transform.TransformParams, error) {
+	if !presetNamePattern.MatchString(name) {
+		return transform.TransformParams{}, fmt.Errorf("preset names are lowercase letters, digits and dashes")
+	}
+
+	if _, ok := permanentTransforms[PermanentTransformName(name)]; ok {
+		return transform.TransformParams{}, fmt.Errorf("%s is a built in preset", name)
+	}
+
+	q, err := url.ParseQuery(query)
+	if err != nil {
+		return transform.TransformParams{}, fmt.Errorf("invalid transform: %w", err)
+	}
+
+	params, err := transform.ParseQuery(q)
+	if err != nil {
+		return transform.TransformParams{}, err
+	}
+
+	if params.Format == transform.FormatAuto {
+		return transform.TransformParams{}, fmt.Errorf("presets need a fixed format")
+	}
+
+	if params.Width < 0 || params.Height < 0 {
+		return transform.TransformParams{}, fmt.Errorf("invalid width/height")
+	}
+
+	if params.ToQueryString() == "" {
+		return transform.TransformParams{}, fmt.Errorf("a preset has to transform the image")
+	}
+
+	return *params, nil
+}
+
+// LoadPresets reads the presets defined in the config and saved in the
+// database, saved ones replacing those of the config with the same name.
+// Invalid presets are left out and returned joined in the error.
+func LoadPresets(db *gorm.DB, configured map[string]string) error {
+	var saved []entities.TransformPreset
+	if err := db.Find(&saved).Error; err != nil {
+		return fmt.Errorf("failed to read transform presets: %w", err)
+	}
+
+	return setPresets(configured, saved)
+}
+
+// setPresets replaces the presets with those of the config and database
+func setPresets(configured map[string]string, saved []entities.TransformPreset) error {
+	loaded := map[PermanentTransformName]Preset{}
+	var errs []error
+
+	add := func(name, query, source string) {
+		params, err := ParsePreset(name, query)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("preset %s: %w", name, err))
+			return
+		}
+
+		loaded[PermanentTransformName(name)] = Preset{Name: PermanentTransformName(name), Params: params, Source: source}
+	}
+
+	for name, query := range configured {
+		add(name, query, PresetSourceConfig)
+	}
+
+	for _, p := range saved {
+		add(p.Name, p.Params, PresetSourceCustom)
+	}
+
+	presetsMu.Lock()
+	presets = loaded
+	presetsMu.Unlock()
+
+	return errors.Join(errs...)
+}
+
+// GetPresets returns the presets defined in the config and through the
+// API, sorted by name
+func GetPresets() []Preset {
+	presetsMu.RLock()
+	defer presetsMu.RUnlock()
+
+	names := slices.Sorted(maps.Keys(presets))
+	list := make([]Preset, 0, len(names))
+	for _, name := range names {
+		list = append(list, presets[name])
+	}
+
+	return list
+}
+
 // GetPermanentTransformParams returns the transform parameters for a given permanent transform name.
 func GetPermanentTransformParams(name PermanentTransformName) (transform.TransformParams, bool) {
-	params, ok := permanentTransforms[name]
-	return params, ok
+	if params, ok := permanentTransforms[name]; ok {
+		return params, true
+	}
+
+	presetsMu.RLock()
+	defer presetsMu.RUnlock()
+
+	preset, ok := presets[name]
+	return preset.Params, ok
 }
 
-// GetAllPermanentTransforms returns all permanent transform definitions.
+// GetAllPermanentTransforms returns all permanent transform definitions,
+// the built in ones and the presets.
 func GetAllPermanentTransforms() map[PermanentTransformName]transform.TransformParams {
-	return permanentTransforms
+	all := maps.Clone(permanentTransforms)
+
+	presetsMu.RLock()
+	defer presetsMu.RUnlock()
+
+	for name, preset := range presets {
+		all[name] = preset.Params
+	}
+
+	return all
+}
+
+// GetAllPresets returns every preset, the built in ones first
+func GetAllPresets() []Preset {
+	all := []Preset{
+		{Name: TransformThumbnail, Params: permanentTransforms[TransformThumbnail], Source: PresetSourceBuiltin},
+		{Name: TransformPreview, Params: permanentTransforms[TransformPreview], Source: PresetSourceBuiltin},
+	}
+
+	return append(all, GetPresets()...)
 }
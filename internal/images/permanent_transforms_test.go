@@ -0,0 +1,67 @@
+package images
+
+import (
+	"testing"
+
+	"viz/internal/entities"
+	"viz/internal/transform"
+)
+
+func TestParsePreset(t *testing.T) {
+	params, err := ParsePreset("social-1080", "format=jpg&w=1080&h=1080&fit=cover&quality=88")
+	if err != nil {
+		t.Fatalf("ParsePreset() = %v", err)
+	}
+
+	want := transform.TransformParams{Format: "jpg", Width: 1080, Height: 1080, Quality: 88, Fit: transform.FitCover}
+	if params.ToQueryString() != want.ToQueryString() {
+		t.Errorf("ParsePreset() = %+v, want %+v", params, want)
+	}
+
+	invalid := map[string]string{
+		"Social":        "format=jpg&w=1080",
+		"thumbnail":     "format=jpg&w=100",
+		"auto":          "format=auto&w=100",
+		"bad-fit":       "w=100&fit=stretch",
+		"nothing":       "",
+		"has space":     "w=100",
+		"negative-size": "w=-1",
+	}
+	for name, query := range invalid {
+		if _, err := ParsePreset(name, query); err == nil {
+			t.Errorf("ParsePreset(%q, %q) accepted", name, query)
+		}
+	}
+}
+
+func TestSetPresets(t *testing.T) {
+	t.Cleanup(func() { _ = setPresets(nil, nil) })
+
+	configured := map[string]string{
+		"grid-square-crop": "format=webp&w=600&h=600&fit=cover",
+		"social-1080":      "format=jpg&w=1080",
+		"broken":           "fit=stretch",
+	}
+	saved := []entities.TransformPreset{{Name: "social-1080", Params: "format=jpg&w=1200"}}
+
+	if err := setPresets(configured, saved); err == nil {
+		t.Error("setPresets() didn't report the invalid preset")
+	}
+
+	presets := GetPresets()
+	if len(presets) != 2 || presets[0].Name != "grid-square-crop" || presets[1].Name != "social-1080" {
+		t.Fatalf("GetPresets() = %+v", presets)
+	}
+
+	if presets[1].Source != PresetSourceCustom || presets[1].Params.Width != 1200 {
+		t.Errorf("saved preset didn't replace the configured one: %+v", presets[1])
+	}
+
+	if _, ok := GetPermanentTransformParams("grid-square-crop"); !ok {
+		t.Error("GetPermanentTransformParams() doesn't find the preset")
+	}
+
+	if all := GetAllPermanentTransforms(); len(all) != 4 {
+		t.Errorf("GetAllPermanentTransforms() has %d transforms, want the 2 built in and 2 presets", len(all))
+	}
+}
@@ -0,0 +1,92 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"viz/internal/entities"
+	"viz/internal/storage"
+	"viz/internal/transform"
+)
+
+// pyramidFolder returns the storage folder holding an image's tile pyramid
+func pyramidFolder(uid string) string {
+	return storage.Join(ImageFolder(uid), "pyramid")
+}
+
+// PyramidKey returns the storage key of an image's tile pyramid. Pyramids
+// are rendered from the edited image, so the key changes with the checksum
+// of the original and the edits and an outdated pyramid is never read.
+func PyramidKey(img entities.ImageAsset) string {
+	checksum := "unknown"
+	if img.ImageMetadata != nil && img.ImageMetadata.Checksum != "" {
+		checksum = img.ImageMetadata.Checksum
+	}
+
+	version := "original"
+	if img.Edits != nil && len(*img.Edits) > 0 {
+		version = transform.EditsHash(*img.Edits)
+	}
+
+	return storage.Join(pyramidFolder(img.Uid), checksum+"-"+version+".tif")
+}
+
+// NeedsPyramid reports whether an image is large enough to get a tile
+// pyramid built when it's uploaded or edited, 0 megapixels turns that off
+func NeedsPyramid(img entities.ImageAsset, minMegapixels int) bool {
+	return minMegapixels > 0 && int64(img.Width)*int64(img.Height) >= int64(minMegapixels)*1_000_000
+}
+
+// HasPyramid reports whether the current tile pyramid of an image exists
+func HasPyramid(ctx context.Context, img entities.ImageAsset) (bool, error) {
+	store, err := imageStore(img)
+	if err != nil {
+		return false, err
+	}
+
+	return storage.Exists(ctx, store, PyramidKey(img))
+}
+
+// OpenPyramid opens the current tile pyramid of an image for random access
+func OpenPyramid(ctx context.Context, img entities.ImageAsset) (io.ReadSeekCloser, error) {
+	store, err := imageStore(img)
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.OpenSeekable(ctx, store, PyramidKey(img))
+}
+
+// SavePyramid stores the tile pyramid of an image read from r and removes
+// the pyramids of its earlier edits
+func SavePyramid(ctx context.Context, img entities.ImageAsset, r io.Reader, size int64) error {
+	store, err := imageStore(img)
+	if err != nil {
+		return err
+	}
+
+	key := PyramidKey(img)
+	if err := store.Put(ctx, key, r, size); err != nil {
+		return fmt.Errorf("failed to store tile pyramid: %w", err)
+	}
+
+	var outdated []string
+	err = store.List(ctx, pyramidFolder(img.Uid), func(obj storage.ObjectInfo) error {
+		if obj.Key != key {
+			outdated = append(outdated, obj.Key)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list tile pyramids: %w", err)
+	}
+
+	for _, old := range outdated {
+		if err := store.Delete(ctx, old); err != nil {
+			return fmt.Errorf("failed to remove outdated tile pyramid: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package images
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"viz/internal/dto"
+	"viz/internal/entities"
+	"viz/internal/storage"
+)
+
+func TestPyramidKeyFollowsEdits(t *testing.T) {
+	img := entities.ImageAsset{Uid: "abc", ImageMetadata: &dto.ImageMetadata{Checksum: "0123"}}
+	original := PyramidKey(img)
+	if original != ImageFolder("abc")+"/pyramid/0123-original.tif" {
+		t.Errorf("PyramidKey() = %q", original)
+	}
+
+	amount := float32(1)
+	img.Edits = &[]dto.ImageEdit{{Op: dto.ImageEditOpExposure, Amount: &amount}}
+	if edited := PyramidKey(img); edited == original || !strings.HasPrefix(edited, ImageFolder("abc")+"/pyramid/0123-") {
+		t.Errorf("PyramidKey() of the edited image = %q", edited)
+	}
+}
+
+func TestSavePyramidRemovesOutdated(t *testing.T) {
+	prev := Store
+	Store = storage.NewLocal(t.TempDir())
+	t.Cleanup(func() { Store = prev })
+
+	ctx := context.Background()
+	img := entities.ImageAsset{Uid: "abc", ImageMetadata: &dto.ImageMetadata{Checksum: "0123"}}
+	if err := SavePyramid(ctx, img, strings.NewReader("first"), 5); err != nil {
+		t.Fatalf("SavePyramid() = %v", err)
+	}
+
+	edited := img
+	amount := float32(1)
+	edited.Edits = &[]dto.ImageEdit{{Op: dto.ImageEditOpExposure, Amount: &amount}}
+	if err := SavePyramid(ctx, edited, strings.NewReader("second"), 6); err != nil {
+		t.Fatalf("SavePyramid() = %v", err)
+	}
+
+	if ok, err := HasPyramid(ctx, img); err != nil || ok {
+		t.Errorf("HasPyramid() of the unedited image = %v, %v, want false", ok, err)
+	}
+
+	r, err := OpenPyramid(ctx, edited)
+	if err != nil {
+		t.Fatalf("OpenPyramid() = %v", err)
+	}
+	defer r.Close()
+
+	buf := make([]byte, 6)
+	if _, err := r.Read(buf); err != nil || string(buf) != "second" {
+		t.Errorf("OpenPyramid() read %q, %v", buf, err)
+	}
+
+	if NeedsPyramid(entities.ImageAsset{Width: 10000, Height: 9000}, 100) || !NeedsPyramid(entities.ImageAsset{Width: 12000, Height: 9000}, 100) {
+		t.Error("NeedsPyramid() doesn't compare against 100 megapixels")
+	}
+	if NeedsPyramid(entities.ImageAsset{Width: 100000, Height: 100000}, 0) {
+		t.Error("NeedsPyramid() with the threshold at 0 = true")
+	}
+}
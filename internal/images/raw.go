@@ -0,0 +1,51 @@
+package images
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"viz/internal/entities"
+)
+
+// PairName returns the name a camera RAW file and the JPEG shot with it
+// share, the file name without its extension and case
+func PairName(fileName string) string {
+	base := path.Base(filepath.ToSlash(fileName))
+	return strings.ToLower(strings.TrimSuffix(base, path.Ext(base)))
+}
+
+// PairPattern returns a LIKE pattern matching, case folded, the file names
+// that could pair with fileName, callers compare PairName of the results
+func PairPattern(fileName string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(PairName(fileName))
+	return escaped + ".%"
+}
+
+// RawPath returns the API path of an image's camera RAW file
+func RawPath(uid string) string {
+	return fmt.Sprintf("/images/%s/raw", uid)
+}
+
+// SetRAWFile records the camera RAW file stored in an image's folder, the
+// original itself for RAW only images
+func SetRAWFile(img *entities.ImageAsset, fileName, checksum string) {
+	img.ImageMetadata.RawFileName = &fileName
+	img.ImageMetadata.RawChecksum = &checksum
+
+	rawPath := RawPath(img.Uid)
+	img.ImagePaths.Raw = &rawPath
+}
+
+// HasPairedRAW reports whether an image is a JPEG, or another developed
+// format, with a camera RAW file stored alongside it
+func HasPairedRAW(img entities.ImageAsset) bool {
+	meta := img.ImageMetadata
+	return meta != nil && meta.RawFileName != nil && *meta.RawFileName != meta.FileName
+}
+
+// IsRAWOnly reports whether an image's original is a camera RAW file
+func IsRAWOnly(img entities.ImageAsset) bool {
+	return img.ImageMetadata != nil && entities.IsRAWFile(img.ImageMetadata.FileName)
+}
@@ -0,0 +1,52 @@
+package images
+
+import (
+	"testing"
+
+	"viz/internal/dto"
+	"viz/internal/entities"
+)
+
+func TestPairName(t *testing.T) {
+	tests := []struct {
+		fileName string
+		want     string
+	}{
+		{"IMG_0042.CR3", "img_0042"},
+		{"IMG_0042.jpg", "img_0042"},
+		{"shoot/DSC01234.ARW", "dsc01234"},
+		{"DSC_0001.NEF", "dsc_0001"},
+		{"holiday.2024.jpeg", "holiday.2024"},
+		{"noext", "noext"},
+	}
+	for _, tt := range tests {
+		if got := PairName(tt.fileName); got != tt.want {
+			t.Errorf("PairName(%q) = %q, want %q", tt.fileName, got, tt.want)
+		}
+	}
+
+	if got := PairPattern("DSC_0001.NEF"); got != `dsc\_0001.%` {
+		t.Errorf("PairPattern() = %q", got)
+	}
+}
+
+func TestSetRAWFile(t *testing.T) {
+	img := entities.ImageAsset{Uid: "abc", ImageMetadata: &dto.ImageMetadata{FileName: "IMG_0042.jpg"}}
+	if HasPairedRAW(img) || IsRAWOnly(img) {
+		t.Fatal("JPEG without a RAW reported as paired or RAW only")
+	}
+
+	SetRAWFile(&img, "IMG_0042.CR3", "0123")
+	if !HasPairedRAW(img) || IsRAWOnly(img) {
+		t.Error("JPEG with a RAW not reported as paired")
+	}
+	if img.ImagePaths.Raw == nil || *img.ImagePaths.Raw != "/images/abc/raw" {
+		t.Errorf("ImagePaths.Raw = %v", img.ImagePaths.Raw)
+	}
+
+	rawOnly := entities.ImageAsset{Uid: "def", ImageMetadata: &dto.ImageMetadata{FileName: "DSC01234.ARW"}}
+	SetRAWFile(&rawOnly, "DSC01234.ARW", "4567")
+	if HasPairedRAW(rawOnly) || !IsRAWOnly(rawOnly) {
+		t.Error("RAW only image reported as paired")
+	}
+}
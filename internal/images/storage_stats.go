@@ -3,12 +3,11 @@ package images
 import (
 	"context"
 	"fmt"
-	"io/fs"
 	"log/slog"
-	"os"
-	"path/filepath"
 	"sync/atomic"
 	"time"
+
+	"viz/internal/storage"
 )
 
 // StorageStatsHolder holds the current calculated storage size.
@@ -16,11 +15,16 @@ import (
 type StorageStatsHolder struct {
 	totalSizeBytes int64
 	path           string
+	store          storage.Storage
 }
 
-func NewStorageStatsHolder(path string) *StorageStatsHolder {
+// NewStorageStatsHolder sums the size of everything in store and the named
+// storage locations, path is the local directory whose disk space is
+// reported next to it
+func NewStorageStatsHolder(path string, store storage.Storage) *StorageStatsHolder {
 	return &StorageStatsHolder{
-		path: path,
+		path:  path,
+		store: store,
 	}
 }
 
@@ -32,50 +36,31 @@ func (s *StorageStatsHolder) GetPath() string {
 	return s.path
 }
 
-// StartStorageStatsWorker starts a background goroutine to calculate storage size.
-func (s *StorageStatsHolder) StartStorageStatsWorker(ctx context.Context, logger *slog.Logger, interval time.Duration) {
-	logger.Info("starting storage stats worker", slog.String("path", s.path), slog.Duration("interval", interval))
-
-	s.calculate(logger)
-
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			logger.Info("stopping storage stats worker")
-			return
-		case <-ticker.C:
-			s.calculate(logger)
-		}
-	}
+func (s *StorageStatsHolder) GetBackend() string {
+	return s.store.Name()
 }
 
-func (s *StorageStatsHolder) calculate(logger *slog.Logger) {
+// Refresh lists everything in the storage backends and updates the total size
+func (s *StorageStatsHolder) Refresh(ctx context.Context, logger *slog.Logger) error {
 	start := time.Now()
 	var size int64
 
-	err := filepath.WalkDir(s.path, func(path string, d fs.DirEntry, err error) error {
+	count := func(obj storage.ObjectInfo) error {
+		size += obj.Size
+		return nil
+	}
+
+	err := s.store.List(ctx, "", count)
+	for _, store := range locations {
 		if err != nil {
-			if os.IsPermission(err) {
-				return nil
-			}
-			return err
+			break
 		}
 
-		if !d.IsDir() {
-			info, err := d.Info()
-			if err == nil {
-				size += info.Size()
-			}
-		}
-		return nil
-	})
+		err = store.List(ctx, "", count)
+	}
 
 	if err != nil {
-		logger.Error("failed to calculate storage size", slog.Any("error", err))
-		return
+		return fmt.Errorf("failed to calculate storage size: %w", err)
 	}
 
 	atomic.StoreInt64(&s.totalSizeBytes, size)
@@ -84,4 +69,6 @@ func (s *StorageStatsHolder) calculate(logger *slog.Logger) {
 		slog.Duration("time_taken", time.Since(start)),
 		slog.String("time_taken_seconds", fmt.Sprintf("%.2fs", time.Since(start).Seconds())),
 	)
+
+	return nil
 }
@@ -9,6 +9,13 @@ const (
 	JPG  SupportedImageTypes = "jpg"
 	PNG  SupportedImageTypes = "png"
 	TIFF SupportedImageTypes = "tiff"
+	GIF  SupportedImageTypes = "gif"
+	WEBP SupportedImageTypes = "webp"
+	HEIC SupportedImageTypes = "heic"
+	HEIF SupportedImageTypes = "heif"
+	AVIF SupportedImageTypes = "avif"
+	JXL  SupportedImageTypes = "jxl"
+	PSD  SupportedImageTypes = "psd"
 )
 
 var SUPPORTED_IMAGE_TYPES = []SupportedImageTypes{
@@ -16,6 +23,13 @@ var SUPPORTED_IMAGE_TYPES = []SupportedImageTypes{
 	JPG,
 	PNG,
 	TIFF,
+	GIF,
+	WEBP,
+	HEIC,
+	HEIF,
+	AVIF,
+	JXL,
+	PSD,
 }
 
 /*
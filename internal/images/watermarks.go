@@ -0,0 +1,55 @@
+package images
+
+import (
+	"context"
+	"fmt"
+
+	"viz/internal/dto"
+	"viz/internal/storage"
+	"viz/internal/transform"
+)
+
+// WatermarkPrefix is the storage folder holding watermark logos. Logos are
+// stored under their checksum, so share links keep the logo they were
+// signed with after the profile gets a new one.
+const WatermarkPrefix = "watermarks"
+
+// MaxWatermarkLogoSize is the largest logo that can be uploaded
+const MaxWatermarkLogoSize = 5 << 20
+
+// WatermarkLogoKey returns the storage key of the logo with a checksum
+func WatermarkLogoKey(checksum string) string {
+	return storage.Join(WatermarkPrefix, checksum+".png")
+}
+
+// SaveWatermarkLogo stores a PNG logo and returns its checksum, which is
+// what a watermark profile refers to it by
+func SaveWatermarkLogo(ctx context.Context, data []byte) (string, error) {
+	checksum, err := CalculateImageChecksum(data)
+	if err != nil {
+		return "", err
+	}
+
+	if err := storage.PutBytes(ctx, Store, WatermarkLogoKey(checksum), data); err != nil {
+		return "", fmt.Errorf("failed to store watermark logo: %w", err)
+	}
+
+	return checksum, nil
+}
+
+// LoadWatermark returns a watermark profile ready to draw, with its logo
+// read from storage when it has one
+func LoadWatermark(ctx context.Context, profile dto.WatermarkProfile) (*transform.Watermark, error) {
+	wm := &transform.Watermark{Profile: profile}
+	if profile.Logo == nil || *profile.Logo == "" {
+		return wm, nil
+	}
+
+	logo, err := storage.ReadAll(ctx, Store, WatermarkLogoKey(*profile.Logo))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watermark logo: %w", err)
+	}
+
+	wm.Logo = logo
+	return wm, nil
+}
@@ -0,0 +1,112 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	"viz/internal/entities"
+)
+
+// ErrJobCancelled is the cause of a job context cancelled through CancelJob.
+// Handlers return it (see Cancelled) to stop between steps.
+var ErrJobCancelled = errors.New("job cancelled")
+
+// Cancelled returns ErrJobCancelled once the job's context was cancelled
+// through CancelJob, or ctx.Err() if the queue itself is shutting down.
+// It returns nil while the job should keep going.
+func Cancelled(ctx context.Context) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+
+	return context.Cause(ctx)
+}
+
+// CancelJob marks a worker job as cancelled and, if it's running in this
+// process, cancels its context so the handler stops at its next check.
+// Queued jobs are dropped when their message is delivered, pending
+// pipeline steps never start.
+// It returns gorm.ErrRecordNotFound for unknown jobs.
+func CancelJob(db *gorm.DB, uid string) error {
+	result := db.Model(&entities.WorkerJob{}).
+		Where("uid = ? AND status IN ?", uid, []JobStatus{WorkerJobStatusPending, WorkerJobStatusQueued, WorkerJobStatusRunning}).
+		Update("status", WorkerJobStatusCancelled)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	allJobsMu.RLock()
+	job, running := allJobs[uid]
+	allJobsMu.RUnlock()
+
+	// the pipeline of a running job is settled once its handler returns
+	if running {
+		job.Cancel()
+		return nil
+	}
+
+	if result.RowsAffected > 0 {
+		var wj entities.WorkerJob
+		if err := db.Select("pipeline_uid").Where("uid = ?", uid).First(&wj).Error; err != nil {
+			return err
+		}
+
+		if wj.PipelineUid != nil {
+			return settlePipeline(db, *wj.PipelineUid)
+		}
+	}
+
+	if result.RowsAffected == 0 {
+		var count int64
+		if err := db.Model(&entities.WorkerJob{}).Where("uid = ?", uid).Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count == 0 {
+			return gorm.ErrRecordNotFound
+		}
+	}
+
+	return nil
+}
+
+// CancelTopic cancels every queued and running job of a topic and returns
+// how many were cancelled
+func CancelTopic(db *gorm.DB, topic string) (int, error) {
+	var uids []string
+	err := db.Model(&entities.WorkerJob{}).
+		Where("topic = ? AND status IN ?", topic, []JobStatus{WorkerJobStatusQueued, WorkerJobStatusRunning}).
+		Pluck("uid", &uids).Error
+	if err != nil {
+		return 0, err
+	}
+
+	// jobs running here are cancelled even if their row is missing
+	seen := make(map[string]bool, len(uids))
+	for _, uid := range uids {
+		seen[uid] = true
+	}
+
+	for uid, j := range GetAllJobs() {
+		if j.Topic() == topic && !seen[uid] {
+			uids = append(uids, uid)
+		}
+	}
+
+	cancelled := 0
+	for _, uid := range uids {
+		if err := CancelJob(db, uid); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+
+			return cancelled, err
+		}
+
+		cancelled++
+	}
+
+	return cancelled, nil
+}
@@ -0,0 +1,69 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestJobCancel(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	job := &Job{ctx: ctx, cancel: cancel, ID: "job", status: WorkerJobStatusRunning}
+	if err := Cancelled(job.Context()); err != nil {
+		t.Fatalf("Cancelled() before Cancel = %v, want nil", err)
+	}
+
+	job.Cancel()
+
+	if got := job.GetStatus(); got != WorkerJobStatusCancelled {
+		t.Errorf("GetStatus() = %q, want %q", got, WorkerJobStatusCancelled)
+	}
+
+	if err := Cancelled(job.Context()); !errors.Is(err, ErrJobCancelled) {
+		t.Errorf("Cancelled() = %v, want ErrJobCancelled", err)
+	}
+}
+
+func TestJobCancelWhileFinishing(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	// the handler cancelling and the worker finishing the job race, run
+	// with -race to catch unguarded status writes
+	job := &Job{ctx: ctx, cancel: cancel, ID: "job", status: WorkerJobStatusRunning}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		job.Cancel()
+	}()
+	go func() {
+		defer wg.Done()
+		job.SetStatus(WorkerJobStatusSuccess)
+	}()
+	wg.Wait()
+
+	if got := job.GetStatus(); got != WorkerJobStatusCancelled && got != WorkerJobStatusSuccess {
+		t.Errorf("GetStatus() = %q", got)
+	}
+}
+
+func TestCancelledShutdown(t *testing.T) {
+	parent, stop := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancelCause(parent)
+	defer cancel(nil)
+
+	stop()
+
+	err := Cancelled(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Cancelled() = %v, want context.Canceled", err)
+	}
+
+	if errors.Is(err, ErrJobCancelled) {
+		t.Error("queue shutdown reported as a cancelled job")
+	}
+}
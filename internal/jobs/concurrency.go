@@ -1,6 +1,7 @@
 package jobs
 
 import (
+	"context"
 	"runtime"
 	"sync"
 
@@ -9,11 +10,25 @@ import (
 
 // ConcurrencyManager restricts the number of concurrent running jobs.
 // This implementation supports dynamic updates to the max concurrency at runtime.
+// Free slots go to the highest priority waiter first; between waiters of the
+// same priority they rotate between owners, the one with the fewest running
+// jobs first, so one user's large import can't starve everyone else.
 type ConcurrencyManager struct {
-	mu            sync.Mutex
-	cond          *sync.Cond
-	maxConcurrent int
-	current       int
+	mu             sync.Mutex
+	maxConcurrent  int
+	current        int
+	waiters        []*waiter
+	runningByOwner map[string]int
+	lastServed     map[string]uint64
+	seq            uint64
+	served         uint64
+}
+
+type waiter struct {
+	owner    string
+	priority Priority
+	seq      uint64
+	ready    chan struct{}
 }
 
 // NewConcurrencyManager creates a new manager with the default max concurrency.
@@ -22,29 +37,162 @@ func NewConcurrencyManager() *ConcurrencyManager {
 	if max < 1 {
 		max = 1
 	}
-	cm := &ConcurrencyManager{maxConcurrent: max}
-	cm.cond = sync.NewCond(&cm.mu)
-	return cm
+	return &ConcurrencyManager{
+		maxConcurrent:  max,
+		runningByOwner: map[string]int{},
+		lastServed:     map[string]uint64{},
+	}
 }
 
 // Acquire blocks until a slot is available.
 func (l *ConcurrencyManager) Acquire() {
+	_ = l.AcquireFor(context.Background(), "", PriorityNormal)
+}
+
+// AcquireFor blocks until the owner's job is given a slot, or ctx is done
+// while it's still waiting.
+func (l *ConcurrencyManager) AcquireFor(ctx context.Context, owner string, priority Priority) error {
 	l.mu.Lock()
-	for l.current >= l.maxConcurrent {
-		l.cond.Wait()
-	}
-	l.current++
+	l.seq++
+	w := &waiter{owner: owner, priority: priority, seq: l.seq, ready: make(chan struct{})}
+	l.waiters = append(l.waiters, w)
+	l.dispatch()
 	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// the slot may have been handed over while ctx was being cancelled
+	select {
+	case <-w.ready:
+		l.release(owner)
+		return ctx.Err()
+	default:
+	}
+
+	for i, other := range l.waiters {
+		if other == w {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			break
+		}
+	}
+
+	return ctx.Err()
 }
 
 // Release frees up a slot.
 func (l *ConcurrencyManager) Release() {
+	l.ReleaseFor("")
+}
+
+// ReleaseFor frees up a slot acquired with AcquireFor.
+func (l *ConcurrencyManager) ReleaseFor(owner string) {
 	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.release(owner)
+}
+
+func (l *ConcurrencyManager) release(owner string) {
 	if l.current > 0 {
 		l.current--
 	}
-	l.cond.Signal()
-	l.mu.Unlock()
+
+	if l.runningByOwner[owner] > 1 {
+		l.runningByOwner[owner]--
+	} else {
+		delete(l.runningByOwner, owner)
+	}
+
+	l.dispatch()
+}
+
+// dispatch hands free slots to waiters. Callers must hold l.mu.
+func (l *ConcurrencyManager) dispatch() {
+	for l.current < l.maxConcurrent && len(l.waiters) > 0 {
+		next := 0
+		for i := 1; i < len(l.waiters); i++ {
+			if l.before(l.waiters[i], l.waiters[next]) {
+				next = i
+			}
+		}
+
+		w := l.waiters[next]
+		l.waiters = append(l.waiters[:next], l.waiters[next+1:]...)
+
+		l.current++
+		l.runningByOwner[w.owner]++
+		l.served++
+		l.lastServed[w.owner] = l.served
+		close(w.ready)
+	}
+
+	// owners without running or waiting jobs start from scratch next time
+	if len(l.waiters) == 0 {
+		for owner := range l.lastServed {
+			if l.runningByOwner[owner] == 0 {
+				delete(l.lastServed, owner)
+			}
+		}
+	}
+}
+
+// before reports whether a should get a slot ahead of b: higher priority
+// first, then the owner with fewer running jobs, then the owner served
+// longest ago, then the one waiting longest.
+func (l *ConcurrencyManager) before(a, b *waiter) bool {
+	if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+
+	if a.owner != b.owner {
+		if ra, rb := l.runningByOwner[a.owner], l.runningByOwner[b.owner]; ra != rb {
+			return ra < rb
+		}
+
+		if sa, sb := l.lastServed[a.owner], l.lastServed[b.owner]; sa != sb {
+			return sa < sb
+		}
+	}
+
+	return a.seq < b.seq
+}
+
+// SchedulingStats describes the jobs holding or waiting for a slot
+type SchedulingStats struct {
+	Waiting           int
+	RunningByOwner    map[string]int
+	WaitingByOwner    map[string]int
+	WaitingByPriority map[Priority]int
+}
+
+// Stats returns a snapshot of who holds and waits for slots.
+func (l *ConcurrencyManager) Stats() SchedulingStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := SchedulingStats{
+		Waiting:           len(l.waiters),
+		RunningByOwner:    make(map[string]int, len(l.runningByOwner)),
+		WaitingByOwner:    map[string]int{},
+		WaitingByPriority: map[Priority]int{},
+	}
+
+	for owner, n := range l.runningByOwner {
+		stats.RunningByOwner[owner] = n
+	}
+
+	for _, w := range l.waiters {
+		stats.WaitingByOwner[w.owner]++
+		stats.WaitingByPriority[w.priority]++
+	}
+
+	return stats
 }
 
 // SetMaxConcurrent sets the maximum number of concurrent jobs and wakes any waiters.
@@ -54,8 +202,8 @@ func (l *ConcurrencyManager) SetMaxConcurrent(max int) {
 	}
 	l.mu.Lock()
 	l.maxConcurrent = max
-	// Wake up any waiters to re-check the condition with the new max.
-	l.cond.Broadcast()
+	// Hand out any slots the new max frees up.
+	l.dispatch()
 	l.mu.Unlock()
 }
 
@@ -0,0 +1,113 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitForWaiters polls until n jobs are waiting for a slot
+func waitForWaiters(t *testing.T, cm *ConcurrencyManager, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for cm.Stats().Waiting != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d waiters, have %d", n, cm.Stats().Waiting)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConcurrencyManagerOrder(t *testing.T) {
+	cm := NewConcurrencyManager()
+	cm.SetMaxConcurrent(1)
+	cm.Acquire()
+
+	order := make(chan string, 5)
+	waiters := []struct {
+		name     string
+		owner    string
+		priority Priority
+	}{
+		{"a1", "alice", PriorityNormal},
+		{"a2", "alice", PriorityNormal},
+		{"a3", "alice", PriorityNormal},
+		{"b1", "bob", PriorityNormal},
+		{"c1", "carol", PriorityInteractive},
+	}
+
+	for i, w := range waiters {
+		go func() {
+			if err := cm.AcquireFor(context.Background(), w.owner, w.priority); err != nil {
+				t.Errorf("AcquireFor(%s) = %v", w.name, err)
+				return
+			}
+			order <- w.name
+			cm.ReleaseFor(w.owner)
+		}()
+		waitForWaiters(t, cm, i+1)
+	}
+
+	stats := cm.Stats()
+	if stats.WaitingByOwner["alice"] != 3 || stats.WaitingByPriority[PriorityInteractive] != 1 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+
+	cm.Release()
+
+	// interactive first, then bob gets a turn before alice's backlog
+	want := []string{"c1", "a1", "b1", "a2", "a3"}
+	for i, name := range want {
+		select {
+		case got := <-order:
+			if got != name {
+				t.Fatalf("slot %d went to %s, want %s", i, got, name)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for slot %d", i)
+		}
+	}
+}
+
+func TestConcurrencyManagerCancelWait(t *testing.T) {
+	cm := NewConcurrencyManager()
+	cm.SetMaxConcurrent(1)
+	cm.Acquire()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- cm.AcquireFor(ctx, "alice", PriorityBackfill)
+	}()
+	waitForWaiters(t, cm, 1)
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("AcquireFor() = %v, want context.Canceled", err)
+	}
+
+	if stats := cm.Stats(); stats.Waiting != 0 {
+		t.Errorf("cancelled waiter still queued: %+v", stats)
+	}
+
+	cm.Release()
+	cm.Acquire()
+	cm.Release()
+}
+
+func TestPriorityString(t *testing.T) {
+	tests := map[Priority]string{
+		PriorityBackfill:    "backfill",
+		PriorityNormal:      "normal",
+		PriorityInteractive: "interactive",
+		Priority(3):         "3",
+	}
+
+	for p, want := range tests {
+		if got := p.String(); got != want {
+			t.Errorf("Priority(%d).String() = %q, want %q", int(p), got, want)
+		}
+	}
+}
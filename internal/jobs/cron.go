@@ -0,0 +1,383 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+
+	"viz/internal/entities"
+)
+
+var (
+	ErrCronJobNotFound = errors.New("cron job not found")
+	ErrCronJobRunning  = errors.New("cron job is already running")
+	ErrInvalidSchedule = errors.New("invalid cron schedule")
+)
+
+// CronJob is a named maintenance task run on a cron schedule. The schedule
+// here is only the default, once registered the schedule, whether the job
+// is paused and the outcome of its last run live in the cron_jobs table.
+type CronJob struct {
+	Name        string
+	Description string
+	// Schedule is a crontab like "0 3 * * *" or a descriptor like "@every 1h"
+	Schedule string
+	// Paused is whether the job starts out paused the first time it's registered
+	Paused bool
+	// RunOnStart runs the job once at startup when it isn't paused, for
+	// jobs whose results are kept in memory
+	RunOnStart bool
+	Run        func(ctx context.Context) error
+}
+
+// cronEntry is a registered CronJob and the scheduler job running it,
+// which is nil while it's paused
+type cronEntry struct {
+	CronJob
+	job     gocron.Job
+	running atomic.Bool
+}
+
+var (
+	cronJobs   = make(map[string]*cronEntry)
+	cronJobsMu sync.Mutex
+	cronCtx    = context.Background()
+	cronLogger = slog.Default()
+)
+
+// ParseSchedule checks a cron schedule, wrapping ErrInvalidSchedule if it
+// can't be used
+func ParseSchedule(schedule string) (cron.Schedule, error) {
+	sched, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSchedule, err)
+	}
+
+	if sched.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("%w: %s never runs", ErrInvalidSchedule, schedule)
+	}
+
+	return sched, nil
+}
+
+// StartCronJobs registers the cron jobs with the scheduler and starts it.
+// Jobs seen for the first time are stored with their default schedule,
+// others keep the schedule and paused state they were left with. Manual
+// and scheduled runs stop when ctx is cancelled.
+func StartCronJobs(ctx context.Context, db *gorm.DB, logger *slog.Logger, defs ...CronJob) error {
+	if Scheduler == nil {
+		if err := Start(); err != nil {
+			return err
+		}
+	}
+
+	cronCtx = ctx
+	cronLogger = logger
+
+	for _, def := range defs {
+		if _, err := ParseSchedule(def.Schedule); err != nil {
+			return fmt.Errorf("cron job %s: %w", def.Name, err)
+		}
+
+		row := entities.CronJob{Name: def.Name, Schedule: def.Schedule, Paused: def.Paused}
+		if err := db.Where("name = ?", def.Name).FirstOrCreate(&row).Error; err != nil {
+			return fmt.Errorf("failed to load cron job %s: %w", def.Name, err)
+		}
+
+		if _, err := ParseSchedule(row.Schedule); err != nil {
+			logger.Warn("stored cron schedule is invalid, using the default",
+				slog.String("name", def.Name),
+				slog.String("schedule", row.Schedule),
+				slog.Any("error", err),
+			)
+			row.Schedule = def.Schedule
+		}
+
+		entry := &cronEntry{CronJob: def}
+		entry.Schedule = row.Schedule
+		entry.Paused = row.Paused
+
+		if !entry.Paused {
+			if err := scheduleCronJob(db, entry); err != nil {
+				return fmt.Errorf("failed to schedule cron job %s: %w", def.Name, err)
+			}
+		}
+
+		if err := saveCronState(db, entry, nil); err != nil {
+			return err
+		}
+
+		cronJobsMu.Lock()
+		cronJobs[def.Name] = entry
+		cronJobsMu.Unlock()
+
+		if !entry.Paused && entry.RunOnStart {
+			go runCronJob(cronCtx, db, entry)
+		}
+	}
+
+	Scheduler.Start()
+
+	return nil
+}
+
+// scheduleCronJob adds an entry to the scheduler, or updates its schedule
+// if it's already there. Callers hold cronJobsMu once the entry is registered.
+func scheduleCronJob(db *gorm.DB, entry *cronEntry) error {
+	definition := gocron.CronJob(entry.Schedule, false)
+	task := gocron.NewTask(func(ctx context.Context) {
+		_ = runCronJob(ctx, db, entry)
+	})
+
+	var (
+		job gocron.Job
+		err error
+	)
+
+	if entry.job != nil {
+		job, err = Scheduler.Update(entry.job.ID(), definition, task, gocron.WithName(entry.Name))
+	} else {
+		job, err = Scheduler.NewJob(definition, task, gocron.WithName(entry.Name))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	entry.job = job
+	Jobs[entry.Name] = job
+
+	return nil
+}
+
+// runCronJob runs an entry once and records the outcome. Runs don't
+// overlap, scheduled ones are skipped while a manual one is going and
+// vice versa.
+func runCronJob(ctx context.Context, db *gorm.DB, entry *cronEntry) (err error) {
+	if !entry.running.CompareAndSwap(false, true) {
+		return ErrCronJobRunning
+	}
+	defer entry.running.Store(false)
+
+	started := time.Now().UTC()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic in cron job %s: %v", entry.Name, r)
+		}
+
+		duration := time.Since(started).Milliseconds()
+		updates := map[string]any{
+			"last_run_at":      started,
+			"last_duration_ms": duration,
+			"last_error":       nil,
+		}
+
+		if err != nil {
+			cronLogger.Error("cron job failed", slog.String("name", entry.Name), slog.Any("error", err))
+			updates["last_error"] = Truncate(err.Error(), 1024)
+		}
+
+		cronJobsMu.Lock()
+		updates["next_run_at"] = nextCronRun(entry)
+		cronJobsMu.Unlock()
+
+		if dbErr := db.Model(&entities.CronJob{}).Where("name = ?", entry.Name).Updates(updates).Error; dbErr != nil {
+			cronLogger.Error("failed to record cron job run", slog.String("name", entry.Name), slog.Any("error", dbErr))
+		}
+	}()
+
+	return entry.Run(ctx)
+}
+
+// nextCronRun returns when an entry runs next, nil while it's paused
+func nextCronRun(entry *cronEntry) *time.Time {
+	if entry.Paused {
+		return nil
+	}
+
+	sched, err := ParseSchedule(entry.Schedule)
+	if err != nil {
+		return nil
+	}
+
+	next := sched.Next(time.Now()).UTC()
+	return &next
+}
+
+// saveCronState persists an entry's schedule, paused state and next run,
+// loading the stored row into row if it isn't nil. Callers hold cronJobsMu
+// once the entry is registered.
+func saveCronState(db *gorm.DB, entry *cronEntry, row *entities.CronJob) error {
+	updates := map[string]any{
+		"schedule":    entry.Schedule,
+		"paused":      entry.Paused,
+		"next_run_at": nextCronRun(entry),
+	}
+
+	if err := db.Model(&entities.CronJob{}).Where("name = ?", entry.Name).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to save cron job %s: %w", entry.Name, err)
+	}
+
+	if row == nil {
+		return nil
+	}
+
+	return db.Where("name = ?", entry.Name).First(row).Error
+}
+
+func getCronEntry(name string) (*cronEntry, error) {
+	cronJobsMu.Lock()
+	defer cronJobsMu.Unlock()
+
+	entry, ok := cronJobs[name]
+	if !ok {
+		return nil, ErrCronJobNotFound
+	}
+
+	return entry, nil
+}
+
+// CronJobInfo is a registered cron job with its persisted state
+type CronJobInfo struct {
+	entities.CronJob
+	Description string
+	Running     bool
+}
+
+// ListCronJobs returns the registered cron jobs sorted by name
+func ListCronJobs(db *gorm.DB) ([]CronJobInfo, error) {
+	cronJobsMu.Lock()
+	names := make([]string, 0, len(cronJobs))
+	for name := range cronJobs {
+		names = append(names, name)
+	}
+	cronJobsMu.Unlock()
+
+	slices.Sort(names)
+
+	var rows []entities.CronJob
+	if err := db.Where("name IN ?", names).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]entities.CronJob, len(rows))
+	for _, row := range rows {
+		byName[row.Name] = row
+	}
+
+	infos := make([]CronJobInfo, 0, len(names))
+	for _, name := range names {
+		entry, err := getCronEntry(name)
+		if err != nil {
+			continue
+		}
+
+		infos = append(infos, cronJobInfo(entry, byName[name]))
+	}
+
+	return infos, nil
+}
+
+func cronJobInfo(entry *cronEntry, row entities.CronJob) CronJobInfo {
+	return CronJobInfo{
+		CronJob:     row,
+		Description: entry.Description,
+		Running:     entry.running.Load(),
+	}
+}
+
+// TriggerCronJob starts a run of a cron job now, in the background,
+// whether or not it's paused. It returns ErrCronJobRunning if it's
+// already running.
+func TriggerCronJob(db *gorm.DB, name string) error {
+	entry, err := getCronEntry(name)
+	if err != nil {
+		return err
+	}
+
+	if entry.running.Load() {
+		return ErrCronJobRunning
+	}
+
+	go runCronJob(cronCtx, db, entry)
+
+	return nil
+}
+
+// PauseCronJob stops or resumes the scheduled runs of a cron job
+func PauseCronJob(db *gorm.DB, name string, paused bool) (*CronJobInfo, error) {
+	entry, err := getCronEntry(name)
+	if err != nil {
+		return nil, err
+	}
+
+	cronJobsMu.Lock()
+	defer cronJobsMu.Unlock()
+
+	if paused && entry.job != nil {
+		if err := Scheduler.RemoveJob(entry.job.ID()); err != nil && !errors.Is(err, gocron.ErrJobNotFound) {
+			return nil, err
+		}
+
+		entry.job = nil
+		delete(Jobs, name)
+	}
+
+	if !paused && entry.job == nil {
+		if err := scheduleCronJob(db, entry); err != nil {
+			return nil, err
+		}
+	}
+
+	entry.Paused = paused
+
+	var row entities.CronJob
+	if err := saveCronState(db, entry, &row); err != nil {
+		return nil, err
+	}
+
+	info := cronJobInfo(entry, row)
+	return &info, nil
+}
+
+// RescheduleCronJob changes the schedule of a cron job, it returns an error
+// wrapping ErrInvalidSchedule if the schedule can't be parsed
+func RescheduleCronJob(db *gorm.DB, name string, schedule string) (*CronJobInfo, error) {
+	if _, err := ParseSchedule(schedule); err != nil {
+		return nil, err
+	}
+
+	entry, err := getCronEntry(name)
+	if err != nil {
+		return nil, err
+	}
+
+	cronJobsMu.Lock()
+	defer cronJobsMu.Unlock()
+
+	previous := entry.Schedule
+	entry.Schedule = schedule
+	if entry.job != nil {
+		if err := scheduleCronJob(db, entry); err != nil {
+			entry.Schedule = previous
+			return nil, err
+		}
+	}
+
+	var row entities.CronJob
+	if err := saveCronState(db, entry, &row); err != nil {
+		return nil, err
+	}
+
+	info := cronJobInfo(entry, row)
+	return &info, nil
+}
@@ -0,0 +1,34 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		schedule string
+		valid    bool
+	}{
+		{"0 3 * * *", true},
+		{"*/15 * * * *", true},
+		{"@every 1h30m", true},
+		{"@daily", true},
+		{"", false},
+		{"0 3 * *", false},
+		{"61 * * * *", false},
+		{"@every soon", false},
+		{"0 0 30 2 *", false},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseSchedule(tt.schedule)
+		if tt.valid && err != nil {
+			t.Errorf("ParseSchedule(%q) = %v, want nil", tt.schedule, err)
+		}
+
+		if !tt.valid && !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("ParseSchedule(%q) = %v, want ErrInvalidSchedule", tt.schedule, err)
+		}
+	}
+}
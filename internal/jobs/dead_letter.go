@@ -0,0 +1,154 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"gorm.io/gorm"
+
+	"viz/internal/entities"
+	"viz/internal/utils"
+)
+
+// DeadLetterTopic receives every job that ran out of attempts or failed
+// permanently. The original topic and last error are kept in the metadata.
+const DeadLetterTopic = "dead_letter"
+
+var (
+	ErrJobNotReplayable   = errors.New("only failed, cancelled or dead-lettered jobs can be replayed")
+	ErrPayloadUnavailable = errors.New("job payload was not stored in full and can't be replayed")
+)
+
+// recordAttempt increments the attempts column of a worker job and returns
+// the new count. Attempts are counted in the database so redeliveries after
+// a restart carry on where they left off.
+func recordAttempt(db *gorm.DB, uid string) (int, error) {
+	err := db.Model(&entities.WorkerJob{}).
+		Where("uid = ?", uid).
+		UpdateColumn("attempts", gorm.Expr("COALESCE(attempts, 0) + 1")).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var attempts int
+	if err := db.Model(&entities.WorkerJob{}).Where("uid = ?", uid).Pluck("attempts", &attempts).Error; err != nil {
+		return 0, err
+	}
+
+	return attempts, nil
+}
+
+// deadLetter marks the worker job as dead-lettered and publishes the message
+// to DeadLetterTopic. The job can be replayed from the payload stored when
+// it was enqueued.
+func deadLetter(db *gorm.DB, uid string, topic string, msg *message.Message, attempts int, cause error) error {
+	errorCode := "max_attempts"
+	if IsPermanent(cause) {
+		errorCode = "permanent_failure"
+	}
+
+	completedAt := time.Now().UTC()
+	if err := UpdateWorkerJobStatus(db, uid, WorkerJobStatusDeadLetter, &errorCode, utils.StringPtr(cause.Error()), nil, &completedAt); err != nil {
+		return err
+	}
+
+	dead := message.NewMessage(msg.UUID, msg.Payload)
+	for k, v := range msg.Metadata {
+		dead.Metadata.Set(k, v)
+	}
+
+	dead.Metadata.Set("X-Original-Topic", topic)
+	dead.Metadata.Set("X-Attempts", strconv.Itoa(attempts))
+	dead.Metadata.Set("X-Error", Truncate(cause.Error(), 1024))
+
+	if Publisher == nil {
+		return nil
+	}
+
+	return Publisher.Publish(DeadLetterTopic, dead)
+}
+
+// Replay re-publishes a failed, cancelled or dead-lettered job to its
+// original topic with a fresh set of attempts, keeping its UID.
+func Replay(db *gorm.DB, uid string) (*entities.WorkerJob, error) {
+	var wj entities.WorkerJob
+	if err := db.Where("uid = ?", uid).First(&wj).Error; err != nil {
+		return nil, err
+	}
+
+	switch JobStatus(wj.Status) {
+	case WorkerJobStatusDeadLetter, WorkerJobStatusFailed, WorkerJobStatusCancelled:
+	default:
+		return nil, ErrJobNotReplayable
+	}
+
+	// jobs enqueued before payloads were stored in full may have lost the end of theirs
+	if wj.Payload == nil || !json.Valid([]byte(*wj.Payload)) {
+		return nil, ErrPayloadUnavailable
+	}
+
+	updates := map[string]any{
+		"status":       WorkerJobStatusQueued,
+		"attempts":     0,
+		"error_code":   nil,
+		"error_msg":    nil,
+		"started_at":   nil,
+		"completed_at": nil,
+		"enqueued_at":  time.Now().UTC(),
+	}
+
+	if err := db.Model(&wj).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to reset worker job: %w", err)
+	}
+
+	// steps skipped because of this one get another chance
+	if wj.PipelineUid != nil {
+		if err := reopenPipeline(db, *wj.PipelineUid); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := Publish(wj.Topic, newJobMessage(wj, []byte(*wj.Payload))); err != nil {
+		_ = UpdateWorkerJobStatus(db, wj.Uid, WorkerJobStatusDeadLetter, utils.StringPtr("publish_failed"), utils.StringPtr("failed to publish message"), nil, nil)
+		return nil, fmt.Errorf("publish: %w", err)
+	}
+
+	if err := db.Where("uid = ?", uid).First(&wj).Error; err != nil {
+		return nil, err
+	}
+
+	return &wj, nil
+}
+
+// ReplayDeadLetters replays every dead-lettered job, optionally only those
+// from one topic, and returns how many were re-published
+func ReplayDeadLetters(db *gorm.DB, topic string) (int, error) {
+	query := db.Model(&entities.WorkerJob{}).Where("status = ?", WorkerJobStatusDeadLetter)
+	if topic != "" {
+		query = query.Where("topic = ?", topic)
+	}
+
+	var uids []string
+	if err := query.Order("enqueued_at asc").Pluck("uid", &uids).Error; err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for _, uid := range uids {
+		if _, err := Replay(db, uid); err != nil {
+			if errors.Is(err, ErrPayloadUnavailable) {
+				continue
+			}
+
+			return replayed, err
+		}
+
+		replayed++
+	}
+
+	return replayed, nil
+}
@@ -13,7 +13,6 @@ import (
 	"viz/internal/utils"
 )
 
-
 type JobStatus string
 
 const (
@@ -22,12 +21,18 @@ const (
 	WorkerJobStatusFailed JobStatus  = "failed"
 	WorkerJobStatusSuccess JobStatus = "completed"
 	WorkerJobStatusCancelled JobStatus = "cancelled"
+	WorkerJobStatusDeadLetter JobStatus = "dead_letter"
+	// WorkerJobStatusPending is a pipeline step waiting for the steps it depends on
+	WorkerJobStatusPending JobStatus = "pending"
+	// WorkerJobStatusSkipped is a pipeline step that won't run because a step it depends on didn't complete
+	WorkerJobStatusSkipped JobStatus = "skipped"
 )
 
 // Enqueue creates a persisted WorkerJob and publishes the message to the
 // router. It returns the created WorkerJob UID which is also set as the
-// Watermill message UUID.
-func Enqueue(db *gorm.DB, topic string, payload any, cmd *JobCommand, imageUid *string) (string, error) {
+// Watermill message UUID. Jobs waiting for a slot run by priority, and
+// rotate between owners (nil for system jobs) within the same priority.
+func Enqueue(db *gorm.DB, topic string, payload any, cmd *JobCommand, imageUid *string, priority Priority, ownerUid *string) (string, error) {
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
 		return "", fmt.Errorf("marshal payload: %w", err)
@@ -41,11 +46,8 @@ func Enqueue(db *gorm.DB, topic string, payload any, cmd *JobCommand, imageUid *
 		cmdStr = &s
 	}
 
+	// The payload is stored in full, recovery and replay publish it again
 	payloadStr := string(payloadBytes)
-	// Truncate payload for DB storage if too large
-	if len(payloadStr) > 10_000 {
-		payloadStr = payloadStr[:10_000]
-	}
 
 	wj := entities.WorkerJob{
 		Uid:        uid,
@@ -53,6 +55,8 @@ func Enqueue(db *gorm.DB, topic string, payload any, cmd *JobCommand, imageUid *
 		Topic:      topic,
 		Command:    cmdStr,
 		ImageUid:   imageUid,
+		OwnerUid:   ownerUid,
+		Priority:   int(priority),
 		Status:     string(WorkerJobStatusQueued),
 		Payload:    &payloadStr,
 		EnqueuedAt: time.Now().UTC(),
@@ -62,18 +66,52 @@ func Enqueue(db *gorm.DB, topic string, payload any, cmd *JobCommand, imageUid *
 		return "", fmt.Errorf("failed to persist worker job: %w", err)
 	}
 
-	msg := message.NewMessage(uid, payloadBytes)
-	msg.Metadata.Set("X-Worker-Job-Uid", uid)
-	if imageUid != nil {
-		msg.Metadata.Set("X-Image-Uid", *imageUid)
+	if err := publishJob(db, wj, payloadBytes); err != nil {
+		return uid, err
 	}
 
-	if err := Publish(topic, msg); err != nil {
-		_ = UpdateWorkerJobStatus(db, uid, WorkerJobStatusFailed, utils.StringPtr("publish_failed"), utils.StringPtr("failed to publish message"), nil, nil)
-		return uid, fmt.Errorf("publish: %w", err)
+	return uid, nil
+}
+
+// publishJob publishes the message for a persisted worker job, marking the
+// job as failed if that doesn't work
+func publishJob(db *gorm.DB, wj entities.WorkerJob, payload []byte) error {
+	if err := Publish(wj.Topic, newJobMessage(wj, payload)); err != nil {
+		_ = UpdateWorkerJobStatus(db, wj.Uid, WorkerJobStatusFailed, utils.StringPtr("publish_failed"), utils.StringPtr("failed to publish message"), nil, nil)
+		return fmt.Errorf("publish: %w", err)
 	}
 
-	return uid, nil
+	return nil
+}
+
+// newJobMessage builds the message delivering a worker job to its handler
+func newJobMessage(wj entities.WorkerJob, payload []byte) *message.Message {
+	msg := message.NewMessage(wj.Uid, payload)
+	msg.Metadata.Set("X-Worker-Job-Uid", wj.Uid)
+	if wj.ImageUid != nil {
+		msg.Metadata.Set("X-Image-Uid", *wj.ImageUid)
+	}
+
+	if wj.PipelineUid != nil {
+		msg.Metadata.Set("X-Pipeline-Uid", *wj.PipelineUid)
+	}
+
+	return msg
+}
+
+// UpdateWorkerJobPayload replaces the stored payload of a worker job, so a
+// long running job can record a checkpoint that RecoverJobs resumes from
+func UpdateWorkerJobPayload(db *gorm.DB, uid string, payload any) error {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal payload: %w", err)
+	}
+
+	if err := db.Model(&entities.WorkerJob{}).Where("uid = ?", uid).Update("payload", string(payloadBytes)).Error; err != nil {
+		return fmt.Errorf("failed to update worker job payload: %w", err)
+	}
+
+	return nil
 }
 
 // UpdateWorkerJobStatus updates WorkerJob status and optional timestamps and error info.
@@ -1,20 +1,38 @@
 package jobs
 
-import "context"
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
 
 type Job struct {
 	ctx      context.Context
+	cancel   context.CancelCauseFunc
 	ID       string
 	topic    string
+	statusMu sync.Mutex
 	status   JobStatus
 	ImageUid string
+	OwnerUid string
+	priority Priority
+	progress atomic.Int32
+	// pipeline the job is a step of, with how many of its steps had
+	// finished and how many it has when this one started
+	pipelineUid   string
+	pipelineDone  int
+	pipelineTotal int
 }
 
 func (j *Job) SetStatus(status JobStatus) {
+	j.statusMu.Lock()
+	defer j.statusMu.Unlock()
 	j.status = status
 }
 
 func (j *Job) GetStatus() JobStatus {
+	j.statusMu.Lock()
+	defer j.statusMu.Unlock()
 	return j.status
 }
 
@@ -30,6 +48,15 @@ func (j *Job) Context() context.Context {
 	return j.ctx
 }
 
+// Cancel marks the job as cancelled and cancels its context with
+// ErrJobCancelled as the cause
+func (j *Job) Cancel() {
+	j.SetStatus(WorkerJobStatusCancelled)
+	if j.cancel != nil {
+		j.cancel(ErrJobCancelled)
+	}
+}
+
 func (j *Job) SetID(id string) {
 	j.ID = id
 }
@@ -45,3 +72,34 @@ func (j *Job) SetImageUid(uid string) {
 func (j *Job) GetImageUid() string {
 	return j.ImageUid
 }
+
+func (j *Job) GetOwnerUid() string {
+	return j.OwnerUid
+}
+
+func (j *Job) Priority() Priority {
+	return j.priority
+}
+
+// SetProgress records how far along the job is, from 0 to 100
+func (j *Job) SetProgress(progress int) {
+	j.progress.Store(int32(progress))
+}
+
+func (j *Job) Progress() int {
+	return int(j.progress.Load())
+}
+
+func (j *Job) PipelineUid() string {
+	return j.pipelineUid
+}
+
+// PipelineProgress returns the progress of the job's pipeline as a whole,
+// counting the steps finished before this one started in full
+func (j *Job) PipelineProgress() int {
+	if j.pipelineTotal == 0 {
+		return j.Progress()
+	}
+
+	return (j.pipelineDone*100 + j.Progress()) / j.pipelineTotal
+}
@@ -0,0 +1,463 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"gorm.io/gorm"
+
+	"viz/internal/entities"
+	libhttp "viz/internal/http"
+)
+
+const (
+	PipelineStatusRunning   = "running"
+	PipelineStatusCompleted = "completed"
+	PipelineStatusFailed    = "failed"
+	PipelineStatusCancelled = "cancelled"
+)
+
+var ErrInvalidPipeline = errors.New("invalid pipeline")
+
+// Broker receives pipeline events. Job events are sent by the workers
+// themselves, pipelines are settled here so they need a broker of their own.
+var Broker *libhttp.WSBroker
+
+// PipelineStep is one job of a pipeline. A step starts once every step
+// whose topic is in DependsOn has completed, steps without dependencies
+// start straight away.
+type PipelineStep struct {
+	Topic     string
+	Payload   any
+	DependsOn []string
+}
+
+// validatePipeline checks steps run each topic once and only depend on
+// earlier steps, which keeps the graph acyclic
+func validatePipeline(steps []PipelineStep) error {
+	if len(steps) == 0 {
+		return fmt.Errorf("%w: no steps", ErrInvalidPipeline)
+	}
+
+	seen := make(map[string]bool, len(steps))
+	for _, step := range steps {
+		if step.Topic == "" {
+			return fmt.Errorf("%w: step without a topic", ErrInvalidPipeline)
+		}
+
+		if seen[step.Topic] {
+			return fmt.Errorf("%w: topic %s appears twice", ErrInvalidPipeline, step.Topic)
+		}
+
+		for _, dep := range step.DependsOn {
+			if !seen[dep] {
+				return fmt.Errorf("%w: %s depends on %s which isn't an earlier step", ErrInvalidPipeline, step.Topic, dep)
+			}
+		}
+
+		seen[step.Topic] = true
+	}
+
+	return nil
+}
+
+// EnqueuePipeline persists a pipeline with a worker job per step and
+// publishes the steps without dependencies. The others wait as "pending"
+// and are published as their parents complete, or "skipped" if one of
+// them is dead-lettered or cancelled.
+func EnqueuePipeline(db *gorm.DB, name string, steps []PipelineStep, imageUid *string, priority Priority, ownerUid *string) (*entities.Pipeline, error) {
+	if err := validatePipeline(steps); err != nil {
+		return nil, err
+	}
+
+	pipeline := entities.Pipeline{
+		Uid:      watermill.NewUUID(),
+		Name:     name,
+		ImageUid: imageUid,
+		OwnerUid: ownerUid,
+		Status:   PipelineStatusRunning,
+	}
+
+	now := time.Now().UTC()
+	workerJobs := make([]entities.WorkerJob, 0, len(steps))
+	payloads := make([][]byte, 0, len(steps))
+	uidByTopic := make(map[string]string, len(steps))
+	var deps []entities.WorkerJobDependency
+
+	for _, step := range steps {
+		payloadBytes, err := json.Marshal(step.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("marshal %s payload: %w", step.Topic, err)
+		}
+
+		status := WorkerJobStatusQueued
+		if len(step.DependsOn) > 0 {
+			status = WorkerJobStatusPending
+		}
+
+		// pending steps are published from the row, so it's kept in full
+		payloadStr := string(payloadBytes)
+		wj := entities.WorkerJob{
+			Uid:         watermill.NewUUID(),
+			Type:        step.Topic,
+			Topic:       step.Topic,
+			ImageUid:    imageUid,
+			OwnerUid:    ownerUid,
+			PipelineUid: &pipeline.Uid,
+			Priority:    int(priority),
+			Status:      string(status),
+			Payload:     &payloadStr,
+			EnqueuedAt:  now,
+		}
+
+		uidByTopic[step.Topic] = wj.Uid
+		for _, dep := range step.DependsOn {
+			deps = append(deps, entities.WorkerJobDependency{
+				PipelineUid: pipeline.Uid,
+				ParentUid:   uidByTopic[dep],
+				ChildUid:    wj.Uid,
+			})
+		}
+
+		workerJobs = append(workerJobs, wj)
+		payloads = append(payloads, payloadBytes)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&pipeline).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Create(&workerJobs).Error; err != nil {
+			return err
+		}
+
+		if len(deps) > 0 {
+			return tx.Create(&deps).Error
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to persist pipeline: %w", err)
+	}
+
+	for i, wj := range workerJobs {
+		if JobStatus(wj.Status) != WorkerJobStatusQueued {
+			continue
+		}
+
+		if err := publishJob(db, wj, payloads[i]); err != nil {
+			return &pipeline, err
+		}
+	}
+
+	return &pipeline, nil
+}
+
+// GetPipelineSteps returns the worker jobs of a pipeline in the order they
+// were enqueued, and the UIDs of the steps each one depends on
+func GetPipelineSteps(db *gorm.DB, pipelineUid string) ([]entities.WorkerJob, map[string][]string, error) {
+	var steps []entities.WorkerJob
+	if err := db.Where("pipeline_uid = ?", pipelineUid).Order("id asc").Find(&steps).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var deps []entities.WorkerJobDependency
+	if err := db.Where("pipeline_uid = ?", pipelineUid).Find(&deps).Error; err != nil {
+		return nil, nil, err
+	}
+
+	parents := make(map[string][]string, len(steps))
+	for _, dep := range deps {
+		parents[dep.ChildUid] = append(parents[dep.ChildUid], dep.ParentUid)
+	}
+
+	return steps, parents, nil
+}
+
+// PipelineStepUid returns the UID of the worker job running a pipeline's
+// step for topic
+func PipelineStepUid(db *gorm.DB, pipelineUid string, topic string) (string, error) {
+	var uid string
+	err := db.Model(&entities.WorkerJob{}).
+		Where("pipeline_uid = ? AND topic = ?", pipelineUid, topic).
+		Limit(1).
+		Pluck("uid", &uid).Error
+
+	return uid, err
+}
+
+// nextStepStatus decides what a pending step does given its parents'
+// statuses: start once all completed, be skipped once one of them won't,
+// otherwise keep waiting. Failed parents may still be retried.
+func nextStepStatus(parents []JobStatus) JobStatus {
+	ready := true
+	for _, status := range parents {
+		if status == WorkerJobStatusSuccess {
+			continue
+		}
+
+		if isFinal(status) {
+			return WorkerJobStatusSkipped
+		}
+
+		ready = false
+	}
+
+	if ready {
+		return WorkerJobStatusQueued
+	}
+
+	return WorkerJobStatusPending
+}
+
+// pipelineStatus derives a pipeline's status from its steps'
+func pipelineStatus(steps []JobStatus) string {
+	completed := true
+	cancelled := false
+	for _, status := range steps {
+		if !isFinal(status) {
+			return PipelineStatusRunning
+		}
+
+		if status == WorkerJobStatusCancelled {
+			cancelled = true
+		}
+
+		if status != WorkerJobStatusSuccess {
+			completed = false
+		}
+	}
+
+	switch {
+	case completed:
+		return PipelineStatusCompleted
+	case cancelled:
+		return PipelineStatusCancelled
+	default:
+		return PipelineStatusFailed
+	}
+}
+
+// StepProgress returns how far along a pipeline step is from 0 to 100,
+// finished steps count in full and running ones report their own progress
+func StepProgress(step entities.WorkerJob) int {
+	if isFinal(JobStatus(step.Status)) {
+		return 100
+	}
+
+	allJobsMu.RLock()
+	job, running := allJobs[step.Uid]
+	allJobsMu.RUnlock()
+
+	if running {
+		return job.Progress()
+	}
+
+	return 0
+}
+
+// PipelineProgress returns the overall progress of a pipeline's steps from
+// 0 to 100
+func PipelineProgress(steps []entities.WorkerJob) int {
+	if len(steps) == 0 {
+		return 0
+	}
+
+	total := 0
+	for _, step := range steps {
+		total += StepProgress(step)
+	}
+
+	return total / len(steps)
+}
+
+// pipelineCounts returns how many of a pipeline's steps are finished and
+// how many there are, for running steps to report overall progress
+func pipelineCounts(db *gorm.DB, pipelineUid string) (int, int) {
+	var statuses []string
+	if err := db.Model(&entities.WorkerJob{}).Where("pipeline_uid = ?", pipelineUid).Pluck("status", &statuses).Error; err != nil {
+		return 0, 0
+	}
+
+	done := 0
+	for _, status := range statuses {
+		if isFinal(JobStatus(status)) {
+			done++
+		}
+	}
+
+	return done, len(statuses)
+}
+
+// settlePipeline publishes the pending steps whose parents all completed,
+// skips those whose parents won't, and updates the pipeline's status. It's
+// called whenever one of its steps stops running and is safe to call twice,
+// steps only leave "pending" once.
+func settlePipeline(db *gorm.DB, pipelineUid string) error {
+	var pipeline entities.Pipeline
+	if err := db.Where("uid = ?", pipelineUid).First(&pipeline).Error; err != nil {
+		return err
+	}
+
+	var steps []entities.WorkerJob
+	for {
+		var parents map[string][]string
+		var err error
+		steps, parents, err = GetPipelineSteps(db, pipelineUid)
+		if err != nil {
+			return err
+		}
+
+		statusByUid := make(map[string]JobStatus, len(steps))
+		for _, step := range steps {
+			statusByUid[step.Uid] = JobStatus(step.Status)
+		}
+
+		// skipping a step can skip its own children, go again until nothing moves
+		changed := false
+		for _, step := range steps {
+			if JobStatus(step.Status) != WorkerJobStatusPending {
+				continue
+			}
+
+			parentStatuses := make([]JobStatus, 0, len(parents[step.Uid]))
+			for _, parent := range parents[step.Uid] {
+				parentStatuses = append(parentStatuses, statusByUid[parent])
+			}
+
+			next := nextStepStatus(parentStatuses)
+			if next == WorkerJobStatusPending {
+				continue
+			}
+
+			updates := map[string]any{"status": next}
+			if next == WorkerJobStatusQueued {
+				updates["enqueued_at"] = time.Now().UTC()
+			} else {
+				updates["completed_at"] = time.Now().UTC()
+			}
+
+			result := db.Model(&entities.WorkerJob{}).
+				Where("uid = ? AND status = ?", step.Uid, WorkerJobStatusPending).
+				Updates(updates)
+			if result.Error != nil {
+				return result.Error
+			}
+
+			// settled at the same time by another step
+			if result.RowsAffected == 0 {
+				continue
+			}
+
+			changed = true
+			if next == WorkerJobStatusQueued && step.Payload != nil {
+				if err := publishJob(db, step, []byte(*step.Payload)); err != nil {
+					return err
+				}
+			}
+		}
+
+		if !changed {
+			break
+		}
+	}
+
+	statuses := make([]JobStatus, 0, len(steps))
+	for _, step := range steps {
+		statuses = append(statuses, JobStatus(step.Status))
+	}
+
+	status := pipelineStatus(statuses)
+	progress := PipelineProgress(steps)
+
+	if status != pipeline.Status {
+		updates := map[string]any{"status": status, "completed_at": nil}
+		if status != PipelineStatusRunning {
+			updates["completed_at"] = time.Now().UTC()
+		}
+
+		if err := db.Model(&pipeline).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to update pipeline: %w", err)
+		}
+	}
+
+	if Broker != nil {
+		event := pipelineEvent(pipeline, status, progress)
+		Broker.Broadcast("pipeline-progress", event)
+
+		if status != pipeline.Status && status != PipelineStatusRunning {
+			Broker.Broadcast("pipeline-"+status, event)
+		}
+	}
+
+	return nil
+}
+
+func pipelineEvent(pipeline entities.Pipeline, status string, progress int) map[string]any {
+	imageUid := ""
+	if pipeline.ImageUid != nil {
+		imageUid = *pipeline.ImageUid
+	}
+
+	return map[string]any{
+		"uid":        pipeline.Uid,
+		"pipelineId": pipeline.Uid,
+		"name":       pipeline.Name,
+		"image_uid":  imageUid,
+		"imageId":    imageUid,
+		"status":     status,
+		"progress":   progress,
+	}
+}
+
+// reopenPipeline puts the steps skipped in a pipeline back to pending, for
+// when a step they were skipped for is replayed
+func reopenPipeline(db *gorm.DB, pipelineUid string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&entities.WorkerJob{}).
+			Where("pipeline_uid = ? AND status = ?", pipelineUid, WorkerJobStatusSkipped).
+			Updates(map[string]any{"status": WorkerJobStatusPending, "completed_at": nil}).Error
+		if err != nil {
+			return fmt.Errorf("failed to reopen pipeline steps: %w", err)
+		}
+
+		err = tx.Model(&entities.Pipeline{}).
+			Where("uid = ?", pipelineUid).
+			Updates(map[string]any{"status": PipelineStatusRunning, "completed_at": nil}).Error
+		if err != nil {
+			return fmt.Errorf("failed to reopen pipeline: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// CancelPipeline cancels every step of a pipeline that hasn't finished.
+// It returns gorm.ErrRecordNotFound for unknown pipelines.
+func CancelPipeline(db *gorm.DB, uid string) error {
+	var pipeline entities.Pipeline
+	if err := db.Where("uid = ?", uid).First(&pipeline).Error; err != nil {
+		return err
+	}
+
+	var uids []string
+	err := db.Model(&entities.WorkerJob{}).
+		Where("pipeline_uid = ? AND status IN ?", uid, []JobStatus{WorkerJobStatusPending, WorkerJobStatusQueued, WorkerJobStatusRunning}).
+		Pluck("uid", &uids).Error
+	if err != nil {
+		return err
+	}
+
+	for _, jobUid := range uids {
+		if err := CancelJob(db, jobUid); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+	}
+
+	return nil
+}
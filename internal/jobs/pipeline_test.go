@@ -0,0 +1,91 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidatePipeline(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []PipelineStep
+		valid bool
+	}{
+		{"empty", nil, false},
+		{"chain", []PipelineStep{
+			{Topic: "exif"},
+			{Topic: "image", DependsOn: []string{"exif"}},
+			{Topic: "xmp", DependsOn: []string{"image"}},
+		}, true},
+		{"fan in", []PipelineStep{
+			{Topic: "exif"},
+			{Topic: "image"},
+			{Topic: "xmp", DependsOn: []string{"exif", "image"}},
+		}, true},
+		{"duplicate topic", []PipelineStep{
+			{Topic: "exif"},
+			{Topic: "exif"},
+		}, false},
+		{"later dependency", []PipelineStep{
+			{Topic: "image", DependsOn: []string{"exif"}},
+			{Topic: "exif"},
+		}, false},
+		{"self dependency", []PipelineStep{
+			{Topic: "exif", DependsOn: []string{"exif"}},
+		}, false},
+		{"missing topic", []PipelineStep{
+			{Topic: ""},
+		}, false},
+	}
+
+	for _, tt := range tests {
+		err := validatePipeline(tt.steps)
+		if tt.valid && err != nil {
+			t.Errorf("%s: validatePipeline() = %v, want nil", tt.name, err)
+		}
+
+		if !tt.valid && !errors.Is(err, ErrInvalidPipeline) {
+			t.Errorf("%s: validatePipeline() = %v, want ErrInvalidPipeline", tt.name, err)
+		}
+	}
+}
+
+func TestNextStepStatus(t *testing.T) {
+	tests := []struct {
+		parents []JobStatus
+		want    JobStatus
+	}{
+		{[]JobStatus{WorkerJobStatusSuccess}, WorkerJobStatusQueued},
+		{[]JobStatus{WorkerJobStatusSuccess, WorkerJobStatusRunning}, WorkerJobStatusPending},
+		// failed jobs are retried, only dead-lettered ones are final
+		{[]JobStatus{WorkerJobStatusFailed}, WorkerJobStatusPending},
+		{[]JobStatus{WorkerJobStatusSuccess, WorkerJobStatusDeadLetter}, WorkerJobStatusSkipped},
+		{[]JobStatus{WorkerJobStatusRunning, WorkerJobStatusCancelled}, WorkerJobStatusSkipped},
+		{[]JobStatus{WorkerJobStatusSkipped}, WorkerJobStatusSkipped},
+	}
+
+	for _, tt := range tests {
+		if got := nextStepStatus(tt.parents); got != tt.want {
+			t.Errorf("nextStepStatus(%v) = %q, want %q", tt.parents, got, tt.want)
+		}
+	}
+}
+
+func TestPipelineStatus(t *testing.T) {
+	tests := []struct {
+		steps []JobStatus
+		want  string
+	}{
+		{[]JobStatus{WorkerJobStatusSuccess, WorkerJobStatusPending}, PipelineStatusRunning},
+		{[]JobStatus{WorkerJobStatusSuccess, WorkerJobStatusSuccess}, PipelineStatusCompleted},
+		{[]JobStatus{WorkerJobStatusDeadLetter, WorkerJobStatusSkipped}, PipelineStatusFailed},
+		{[]JobStatus{WorkerJobStatusCancelled, WorkerJobStatusSkipped}, PipelineStatusCancelled},
+		{[]JobStatus{WorkerJobStatusFailed, WorkerJobStatusSkipped}, PipelineStatusRunning},
+	}
+
+	for _, tt := range tests {
+		if got := pipelineStatus(tt.steps); got != tt.want {
+			t.Errorf("pipelineStatus(%v) = %q, want %q", tt.steps, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package jobs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	wmsql "github.com/ThreeDotsLabs/watermill-sql/v3/pkg/sql"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"gorm.io/gorm"
+)
+
+// newPostgresPubSub uses the application's Postgres database as the message
+// broker. Each topic gets its own watermill_<topic> table, so jobs queued
+// before a restart are still delivered afterwards.
+func newPostgresPubSub(db *gorm.DB, logger watermill.LoggerAdapter) (message.Publisher, message.Subscriber, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get sql.DB: %w", err)
+	}
+
+	publisher, err := wmsql.NewPublisher(
+		sqlDB,
+		wmsql.PublisherConfig{
+			SchemaAdapter:        wmsql.DefaultPostgreSQLSchema{},
+			AutoInitializeSchema: true,
+		},
+		logger,
+	)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create postgres publisher: %w", err)
+	}
+
+	subscriber, err := wmsql.NewSubscriber(
+		sqlDB,
+		wmsql.SubscriberConfig{
+			ConsumerGroup:    "imagine_workers",
+			SchemaAdapter:    wmsql.DefaultPostgreSQLSchema{},
+			OffsetsAdapter:   wmsql.DefaultPostgreSQLOffsetsAdapter{},
+			InitializeSchema: true,
+			PollInterval:     time.Second,
+		},
+		logger,
+	)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create postgres subscriber: %w", err)
+	}
+
+	return publisher, subscriber, nil
+}
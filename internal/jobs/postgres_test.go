@@ -0,0 +1,52 @@
+package jobs
+
+import (
+	"testing"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"viz/internal/config"
+)
+
+func TestQueueBackend(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.QueueConfig
+		want string
+	}{
+		{"default", config.QueueConfig{}, QueueBackendMemory},
+		{"redis enabled", config.QueueConfig{RedisConfig: config.RedisConfig{Enabled: true}}, QueueBackendRedis},
+		{"postgres", config.QueueConfig{Backend: QueueBackendPostgres}, QueueBackendPostgres},
+		{"postgres over redis", config.QueueConfig{RedisConfig: config.RedisConfig{Enabled: true}, Backend: QueueBackendPostgres}, QueueBackendPostgres},
+	}
+
+	for _, tt := range tests {
+		if got := queueBackend(tt.cfg); got != tt.want {
+			t.Errorf("queueBackend(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewPostgresPubSub(t *testing.T) {
+	// neither the publisher nor the subscriber connect until they're used,
+	// so this only needs a handle to a database
+	db, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1 dbname=viz sslmode=disable"), &gorm.Config{DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("gorm.Open() = %v", err)
+	}
+
+	publisher, subscriber, err := newPostgresPubSub(db, watermill.NopLogger{})
+	if err != nil {
+		t.Fatalf("newPostgresPubSub() = %v", err)
+	}
+
+	if err := subscriber.Close(); err != nil {
+		t.Errorf("subscriber.Close() = %v", err)
+	}
+
+	if err := publisher.Close(); err != nil {
+		t.Errorf("publisher.Close() = %v", err)
+	}
+}
@@ -6,7 +6,9 @@ import (
 
 // NewProgressCallback creates a reusable progress reporter closure that broadcasts
 // generic job-progress WebSocket events with a consistent payload shape.
-// If wsBroker is nil, the returned function is a no-op.
+// Steps of a pipeline also broadcast pipeline-progress with the progress of
+// the whole pipeline. If wsBroker is nil, progress is only recorded on the
+// running job.
 func NewProgressCallback(
 	wsBroker *libhttp.WSBroker,
 	jobId string,
@@ -14,11 +16,19 @@ func NewProgressCallback(
 	imageId string,
 	filename string,
 ) func(step string, progress int) {
-	if wsBroker == nil {
-		return func(step string, progress int) {}
-	}
-
 	return func(step string, progress int) {
+		allJobsMu.RLock()
+		job := allJobs[jobId]
+		allJobsMu.RUnlock()
+
+		if job != nil {
+			job.SetProgress(progress)
+		}
+
+		if wsBroker == nil {
+			return
+		}
+
 		wsBroker.Broadcast("job-progress", map[string]interface{}{
 			"uid":       jobId,
 			"jobId":     jobId,
@@ -31,5 +41,17 @@ func NewProgressCallback(
 			"status":    step,
 			"step":      step,
 		})
+
+		if job != nil && job.PipelineUid() != "" {
+			wsBroker.Broadcast("pipeline-progress", map[string]interface{}{
+				"uid":        job.PipelineUid(),
+				"pipelineId": job.PipelineUid(),
+				"image_uid":  imageId,
+				"imageId":    imageId,
+				"status":     PipelineStatusRunning,
+				"progress":   job.PipelineProgress(),
+				"step":       jobType,
+			})
+		}
 	}
 }
@@ -3,10 +3,14 @@ package jobs
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"log/slog"
 	"maps"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/ThreeDotsLabs/watermill"
@@ -16,8 +20,10 @@ import (
 	"github.com/ThreeDotsLabs/watermill/message/router/plugin"
 	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
 	goredis "github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
 
 	"viz/internal/config"
+	"viz/internal/entities"
 )
 
 var (
@@ -25,8 +31,14 @@ var (
 	allJobsMu      sync.RWMutex
 	queuedCounts   = make(map[string]int)
 	queuedCountsMu sync.RWMutex
+	// acceptedJobs holds the UIDs of acked jobs waiting for or holding a slot
+	acceptedJobs sync.Map
 )
 
+// queueCtx ends when the process is asked to stop, cancelling jobs that
+// are still waiting for a slot or running
+var queueCtx = context.Background()
+
 var (
 	Publisher  message.Publisher
 	Subscriber message.Subscriber
@@ -64,24 +76,39 @@ func Publish(topic string, msg *message.Message) error {
 	return Publisher.Publish(topic, msg)
 }
 
-// JobCounts describes running and queued counts by topic.
+// SystemOwner is the owner jobs enqueued without one are counted under
+const SystemOwner = "system"
+
+// JobCounts describes running and queued counts by topic. The by-owner
+// and by-priority counts are keyed by topic, then owner UID or priority.
 type JobCounts struct {
-	Running        int64          `json:"running"`
-	RunningByTopic map[string]int `json:"running_by_topic"`
-	QueuedByTopic  map[string]int `json:"queued_by_topic"`
+	Running          int64                     `json:"running"`
+	RunningByTopic   map[string]int            `json:"running_by_topic"`
+	QueuedByTopic    map[string]int            `json:"queued_by_topic"`
+	RunningByOwner   map[string]map[string]int `json:"running_by_owner"`
+	QueuedByOwner    map[string]map[string]int `json:"queued_by_owner"`
+	QueuedByPriority map[string]map[string]int `json:"queued_by_priority"`
 }
 
 // GetCounts returns a snapshot of running and queued counts.
 func GetCounts() JobCounts {
 	jc := JobCounts{
-		RunningByTopic: make(map[string]int),
-		QueuedByTopic:  make(map[string]int),
+		RunningByTopic:   make(map[string]int),
+		QueuedByTopic:    make(map[string]int),
+		RunningByOwner:   make(map[string]map[string]int),
+		QueuedByOwner:    make(map[string]map[string]int),
+		QueuedByPriority: make(map[string]map[string]int),
 	}
 
 	// running
 	allJobsMu.RLock()
 	for _, j := range allJobs {
 		jc.RunningByTopic[j.Topic()]++
+
+		if jc.RunningByOwner[j.Topic()] == nil {
+			jc.RunningByOwner[j.Topic()] = make(map[string]int)
+		}
+		jc.RunningByOwner[j.Topic()][ownerKey(j.GetOwnerUid())]++
 	}
 	jc.Running = int64(len(allJobs))
 	allJobsMu.RUnlock()
@@ -93,12 +120,41 @@ func GetCounts() JobCounts {
 	}
 	queuedCountsMu.RUnlock()
 
+	// accepted and waiting for a slot
+	managersMu.Lock()
+	managers := maps.Clone(managersByTopic)
+	managersMu.Unlock()
+
+	for topic, cm := range managers {
+		stats := cm.Stats()
+		if stats.Waiting == 0 {
+			continue
+		}
+
+		jc.QueuedByTopic[topic] += stats.Waiting
+
+		jc.QueuedByOwner[topic] = make(map[string]int, len(stats.WaitingByOwner))
+		for owner, n := range stats.WaitingByOwner {
+			jc.QueuedByOwner[topic][ownerKey(owner)] = n
+		}
+
+		jc.QueuedByPriority[topic] = make(map[string]int, len(stats.WaitingByPriority))
+		for priority, n := range stats.WaitingByPriority {
+			jc.QueuedByPriority[topic][priority.String()] = n
+		}
+	}
+
 	return jc
 }
 
 // RegisterWorkers registers all JobWorkers with the router.
 // Call this after initializing Router and PubSub, but before Router.Run().
-func RegisterWorkers(workers ...*Worker) {
+// Messages are acked as soon as their job is accepted, the subscribers hand
+// out one message per topic at a time, so jobs wait for a slot here where
+// they can be ordered by priority and owner rather than in the pub/sub.
+// Failed jobs are retried following their topic's RetryPolicy; once out of
+// attempts they're moved to DeadLetterTopic.
+func RegisterWorkers(db *gorm.DB, workers ...*Worker) {
 
 	for _, worker := range workers {
 		handler := worker.Handler
@@ -110,54 +166,270 @@ func RegisterWorkers(workers ...*Worker) {
 			topic,
 			Subscriber,
 			func(msg *message.Message) error {
+				jobUid := msg.Metadata.Get("X-Worker-Job-Uid")
+				if jobUid == "" {
+					jobUid = msg.UUID
+				}
 
-				cm.Acquire()
-				defer cm.Release()
+				// Transition from queued -> waiting for a slot
+				decrementQueued(topic)
 
-				worker.Start()
-				job := &Job{
-					ctx:      msg.Context(),
-					ID:       msg.UUID,
-					topic:    topic,
-					status:   JobStatusRunning,
-					ImageUid: msg.Metadata.Get("X-Image-Uid"),
+				var wj entities.WorkerJob
+				if err := db.Select("status", "priority", "owner_uid", "pipeline_uid").Where("uid = ?", jobUid).First(&wj).Error; err != nil {
+					return fmt.Errorf("load job %s: %w", jobUid, err)
 				}
 
-				if job.ID == "" {
-					job.ID = watermill.NewUUID()
+				// Redelivered or recovered copies of jobs that already finished
+				if isFinal(JobStatus(wj.Status)) {
+					return nil
 				}
 
-				// Transition from queued -> running: decrement queued count for topic.
-				queuedCountsMu.Lock()
-				if v, ok := queuedCounts[topic]; ok && v > 0 {
-					queuedCounts[topic] = v - 1
+				// Another copy of the job is already waiting here
+				if _, waiting := acceptedJobs.LoadOrStore(jobUid, struct{}{}); waiting {
+					return nil
+				}
+				defer acceptedJobs.Delete(jobUid)
+
+				// Start the next steps once this one stops, whichever way it does
+				if wj.PipelineUid != nil {
+					defer func() {
+						if err := settlePipeline(db, *wj.PipelineUid); err != nil {
+							Logger.Error("failed to settle pipeline", err, watermill.LogFields{
+								"uid":      *wj.PipelineUid,
+								"step_uid": jobUid,
+							})
+						}
+					}()
 				}
-				queuedCountsMu.Unlock()
 
-				// Register running job in a thread-safe way.
-				allJobsMu.Lock()
-				allJobs[job.ID] = job
-				allJobsMu.Unlock()
+				msg.Ack()
 
+				// The subscriber may cancel the message's context once it's
+				// acked, jobs keep going until the queue shuts down
+				ctx, cancel := context.WithCancel(context.WithoutCancel(msg.Context()))
+				stop := context.AfterFunc(queueCtx, cancel)
+				msg.SetContext(ctx)
 				defer func() {
-					worker.Stop()
-					job.SetStatus(JobStatusSuccess)
-					allJobsMu.Lock()
-					delete(allJobs, job.ID)
-					allJobsMu.Unlock()
+					stop()
+					cancel()
 				}()
 
-				return handler(msg)
+				owner := ""
+				if wj.OwnerUid != nil {
+					owner = *wj.OwnerUid
+				}
+				priority := Priority(wj.Priority)
+
+				policy := GetRetryPolicy(topic)
+				attempt := 0
+				for {
+					// Jobs cancelled while waiting for their next attempt
+					if isFinished(db, jobUid) {
+						return nil
+					}
+
+					if err := cm.AcquireFor(msg.Context(), owner, priority); err != nil {
+						// Shutting down, the job is still queued and recovered on restart
+						return err
+					}
+
+					// Another copy of the message, or another instance, got to it first
+					if claimed, err := claimJob(db, jobUid); err != nil || !claimed {
+						cm.ReleaseFor(owner)
+						return err
+					}
+
+					attempt++
+					if recorded, err := recordAttempt(db, jobUid); err == nil && recorded > 0 {
+						attempt = recorded
+					}
+
+					var position pipelinePosition
+					if wj.PipelineUid != nil {
+						position.uid = *wj.PipelineUid
+						position.done, position.total = pipelineCounts(db, position.uid)
+					}
+
+					err := runAttempt(worker, handler, msg, topic, owner, priority, position)
+					cm.ReleaseFor(owner)
+					if err == nil {
+						return nil
+					}
+
+					// Cancelled jobs aren't retried or dead-lettered
+					if errors.Is(err, ErrJobCancelled) {
+						return nil
+					}
+
+					var status string
+					if db.Model(&entities.WorkerJob{}).Where("uid = ?", jobUid).Pluck("status", &status); JobStatus(status) == WorkerJobStatusCancelled {
+						return nil
+					}
+
+					if IsPermanent(err) || attempt >= policy.MaxAttempts {
+						Logger.Error("job moved to dead-letter topic", err, watermill.LogFields{
+							"uid":      jobUid,
+							"topic":    topic,
+							"attempts": attempt,
+						})
+
+						if dlErr := deadLetter(db, jobUid, topic, msg, attempt, err); dlErr != nil {
+							return fmt.Errorf("dead-letter job %s: %w", jobUid, dlErr)
+						}
+
+						return nil
+					}
+
+					// Waiting jobs count as queued again until the next attempt
+					_ = UpdateWorkerJobStatus(db, jobUid, WorkerJobStatusQueued, nil, nil, nil, nil)
+					incrementQueued(topic)
+
+					wait := time.NewTimer(policy.Backoff(attempt))
+					select {
+					case <-wait.C:
+						decrementQueued(topic)
+					case <-msg.Context().Done():
+						wait.Stop()
+						decrementQueued(topic)
+						return msg.Context().Err()
+					}
+				}
 			},
 		)
 	}
 }
 
-func RunJobQueue(cfg config.QueueConfig, logger *slog.Logger, workers ...*Worker) {
+// claimJob moves a queued job to running, reporting false if it wasn't
+// queued anymore, so each job runs once however many copies are delivered
+func claimJob(db *gorm.DB, uid string) (bool, error) {
+	result := db.Model(&entities.WorkerJob{}).
+		Where("uid = ? AND status = ?", uid, WorkerJobStatusQueued).
+		Update("status", WorkerJobStatusRunning)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// pipelinePosition is how far along its pipeline a step is when it starts
+type pipelinePosition struct {
+	uid   string
+	done  int
+	total int
+}
+
+// runAttempt runs the handler once, tracking the job as running while it
+// does. Panics are turned into errors so they're retried like any failure.
+func runAttempt(worker *Worker, handler JobHandler, msg *message.Message, topic string, owner string, priority Priority, position pipelinePosition) (err error) {
+	worker.Start()
+
+	// The handler sees a context of its own through msg.Context(), which
+	// CancelJob cancels. Retries get a fresh one.
+	parent := msg.Context()
+	ctx, cancel := context.WithCancelCause(parent)
+	msg.SetContext(ctx)
+	defer func() {
+		cancel(nil)
+		msg.SetContext(parent)
+	}()
+
+	job := &Job{
+		ctx:      ctx,
+		cancel:   cancel,
+		ID:       msg.UUID,
+		topic:    topic,
+		status:   WorkerJobStatusRunning,
+		ImageUid: msg.Metadata.Get("X-Image-Uid"),
+		OwnerUid: owner,
+		priority: priority,
+
+		pipelineUid:   position.uid,
+		pipelineDone:  position.done,
+		pipelineTotal: position.total,
+	}
+
+	if job.ID == "" {
+		job.ID = watermill.NewUUID()
+	}
+
+	// Register running job in a thread-safe way.
+	allJobsMu.Lock()
+	allJobs[job.ID] = job
+	allJobsMu.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic in %s handler: %v", topic, r)
+		}
+
+		worker.Stop()
+		if errors.Is(context.Cause(ctx), ErrJobCancelled) {
+			job.SetStatus(WorkerJobStatusCancelled)
+		} else if err != nil {
+			job.SetStatus(WorkerJobStatusFailed)
+		} else {
+			job.SetStatus(WorkerJobStatusSuccess)
+		}
+
+		allJobsMu.Lock()
+		delete(allJobs, job.ID)
+		allJobsMu.Unlock()
+	}()
+
+	return handler(msg)
+}
+
+func ownerKey(owner string) string {
+	if owner == "" {
+		return SystemOwner
+	}
+
+	return owner
+}
+
+func incrementQueued(topic string) {
+	queuedCountsMu.Lock()
+	queuedCounts[topic]++
+	queuedCountsMu.Unlock()
+}
+
+func decrementQueued(topic string) {
+	queuedCountsMu.Lock()
+	if v, ok := queuedCounts[topic]; ok && v > 0 {
+		queuedCounts[topic] = v - 1
+	}
+	queuedCountsMu.Unlock()
+}
+
+const (
+	QueueBackendRedis    = "redis"
+	QueueBackendPostgres = "postgres"
+	QueueBackendMemory   = "memory"
+)
+
+// queueBackend resolves which pub/sub to use when none is configured:
+// Redis if it's enabled, otherwise the in-memory GoChannel. Postgres is
+// only used when configured.
+func queueBackend(cfg config.QueueConfig) string {
+	if cfg.Backend != "" {
+		return cfg.Backend
+	}
+
+	if cfg.Enabled {
+		return QueueBackendRedis
+	}
+
+	return QueueBackendMemory
+}
+
+func RunJobQueue(cfg config.QueueConfig, db *gorm.DB, logger *slog.Logger, workers ...*Worker) {
 	var err error
 	Logger = watermill.NewSlogLogger(logger)
 
-	if cfg.Enabled {
+	backend := queueBackend(cfg)
+	switch backend {
+	case QueueBackendRedis:
 		address := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
 		Logger.Info("Using Redis Streams for jobs", watermill.LogFields{
 			"address": address,
@@ -204,12 +476,19 @@ func RunJobQueue(cfg config.QueueConfig, logger *slog.Logger, workers ...*Worker
 		if err != nil {
 			panic(err)
 		}
-	} else {
-		Logger.Info("Using in-memory GoChannel for jobs", nil)
-		// Fallback to gochannel
+	case QueueBackendPostgres:
+		Logger.Info("Using Postgres for jobs", nil)
+		Publisher, Subscriber, err = newPostgresPubSub(db, Logger)
+		if err != nil {
+			panic(err)
+		}
+	case QueueBackendMemory:
+		Logger.Info("Using in-memory GoChannel for jobs, queued jobs are re-published from the database on restart", nil)
 		gc := gochannel.NewGoChannel(gochannel.Config{}, Logger)
 		Publisher = gc
 		Subscriber = gc
+	default:
+		panic(fmt.Sprintf("unknown job queue backend %q, expected redis, postgres or memory", backend))
 	}
 
 	Router, err = message.NewRouter(message.RouterConfig{}, Logger)
@@ -221,11 +500,6 @@ func RunJobQueue(cfg config.QueueConfig, logger *slog.Logger, workers ...*Worker
 	// You can also close the router by just calling `r.Close()`.
 	Router.AddPlugin(plugin.SignalsHandler)
 
-	poisonQueue, err := middleware.PoisonQueue(Publisher, "poison_queue")
-	if err != nil {
-		panic(err)
-	}
-	
 	// Router level middleware are executed for every message sent to the router
 	Router.AddMiddleware(
 		// CorrelationID will copy the correlation id from the incoming message's metadata to the produced messages
@@ -233,30 +507,30 @@ func RunJobQueue(cfg config.QueueConfig, logger *slog.Logger, workers ...*Worker
 
 		middleware.Recoverer,
 
-		// The handler function is retried if it returns an error.
-		// After MaxRetries, the message is Nacked and it's up to the PubSub to resend it.
-
-		// This can be done better honestly,
-		// These sort of just fail and hope and fail and hope
-		// Like after the second fail you should kind of just let it go
-		// and the pubsub should decide based on the error if it's
-		// worth retrying or not
-		middleware.Retry{
-			MaxRetries:      3,
-			InitialInterval: time.Second * 2,
-			Logger:          Logger,
-		}.Middleware,
-
-		poisonQueue,
+		// Retries and dead-lettering are handled per topic in RegisterWorkers,
+		// where the attempts can be recorded against the WorkerJob
 
 		middleware.NewThrottle(10, time.Second).Middleware,
 	)
 
-	RegisterWorkers(workers...)
+	RegisterWorkers(db, workers...)
+
+	// Publish interrupted jobs once the handlers are subscribed
+	go func() {
+		<-Router.Running()
+		if _, err := RecoverJobs(db, logger); err != nil {
+			logger.Error("failed to recover interrupted jobs", slog.Any("error", err))
+		}
+	}()
+
+	// Same signals as plugin.SignalsHandler, so jobs waiting for a slot give
+	// up instead of holding the router open until its close timeout
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	queueCtx = ctx
 
 	// Now that all handlers are registered, we're running the Router.
 	// Run is blocking while the router is running.
-	ctx := context.Background()
 	if err := Router.Run(ctx); err != nil {
 		panic(err)
 	}
@@ -0,0 +1,91 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+
+	"viz/internal/entities"
+)
+
+// StaleJobTimeout is how long a job can sit in "running" without any update
+// before it's assumed the process running it died
+const StaleJobTimeout = 10 * time.Minute
+
+// RecoverJobs re-publishes worker jobs that were interrupted by a restart:
+// "running" jobs not updated within StaleJobTimeout, and every "queued" job
+// since messages are acked before their job runs. Copies of jobs that are
+// still in the pub/sub are dropped when claimed. Running pipelines are then
+// settled, in case a step finished without its children being published.
+// It returns how many jobs were re-published.
+func RecoverJobs(db *gorm.DB, logger *slog.Logger) (int, error) {
+	query := db.Where("status = ? AND updated_at < ?", WorkerJobStatusRunning, time.Now().Add(-StaleJobTimeout)).
+		Or("status = ?", WorkerJobStatusQueued)
+
+	var pending []entities.WorkerJob
+	if err := query.Order("enqueued_at asc").Find(&pending).Error; err != nil {
+		return 0, fmt.Errorf("failed to find interrupted jobs: %w", err)
+	}
+
+	recovered := 0
+	for _, wj := range pending {
+		// jobs enqueued before payloads were stored in full may have lost the end of theirs
+		if wj.Payload == nil || !json.Valid([]byte(*wj.Payload)) {
+			logger.Warn("can't recover interrupted job without its payload", slog.String("uid", wj.Uid), slog.String("topic", wj.Topic))
+			continue
+		}
+
+		if err := UpdateWorkerJobStatus(db, wj.Uid, WorkerJobStatusQueued, nil, nil, nil, nil); err != nil {
+			return recovered, err
+		}
+
+		if err := Publish(wj.Topic, newJobMessage(wj, []byte(*wj.Payload))); err != nil {
+			logger.Error("failed to re-publish interrupted job", slog.String("uid", wj.Uid), slog.Any("error", err))
+			continue
+		}
+
+		recovered++
+	}
+
+	if recovered > 0 {
+		logger.Info("re-published interrupted jobs", slog.Int("count", recovered))
+	}
+
+	// Steps whose parents finished just before the restart
+	var pipelines []string
+	if err := db.Model(&entities.Pipeline{}).Where("status = ?", PipelineStatusRunning).Pluck("uid", &pipelines).Error; err != nil {
+		return recovered, fmt.Errorf("failed to find running pipelines: %w", err)
+	}
+
+	for _, uid := range pipelines {
+		if err := settlePipeline(db, uid); err != nil {
+			logger.Error("failed to settle pipeline", slog.String("uid", uid), slog.Any("error", err))
+		}
+	}
+
+	return recovered, nil
+}
+
+// isFinished reports whether a worker job already reached a final state,
+// so a redelivered copy of its message can be dropped
+func isFinished(db *gorm.DB, uid string) bool {
+	var status string
+	if err := db.Model(&entities.WorkerJob{}).Where("uid = ?", uid).Pluck("status", &status).Error; err != nil {
+		return false
+	}
+
+	return isFinal(JobStatus(status))
+}
+
+// isFinal reports whether a job in this status won't run (again) on its own
+func isFinal(status JobStatus) bool {
+	switch status {
+	case WorkerJobStatusSuccess, WorkerJobStatusDeadLetter, WorkerJobStatusCancelled, WorkerJobStatusSkipped:
+		return true
+	}
+
+	return false
+}
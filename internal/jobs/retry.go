@@ -0,0 +1,104 @@
+package jobs
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// RetryPolicy decides how often a failing job is retried, and how long to
+// wait in between, before it's moved to the dead-letter topic.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of times the handler runs, including the first
+	MaxAttempts int
+	// InitialInterval is the wait after the first failed attempt
+	InitialInterval time.Duration
+	// MaxInterval caps the wait between attempts
+	MaxInterval time.Duration
+	// Multiplier grows the wait after every further failure
+	Multiplier float64
+}
+
+// DefaultRetryPolicy is used for topics without their own policy
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:     3,
+	InitialInterval: 2 * time.Second,
+	MaxInterval:     time.Minute,
+	Multiplier:      2,
+}
+
+// Backoff returns how long to wait after the given failed attempt (1-based)
+// before trying again
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	wait := float64(p.InitialInterval) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxInterval > 0 && wait > float64(p.MaxInterval) {
+		return p.MaxInterval
+	}
+
+	return time.Duration(wait)
+}
+
+var (
+	retryPoliciesMu sync.RWMutex
+	retryPolicies   = map[string]RetryPolicy{}
+)
+
+// SetRetryPolicy sets the retry policy for a topic
+func SetRetryPolicy(topic string, policy RetryPolicy) {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+
+	retryPoliciesMu.Lock()
+	defer retryPoliciesMu.Unlock()
+	retryPolicies[topic] = policy
+}
+
+// GetRetryPolicy returns the retry policy for a topic, or DefaultRetryPolicy
+func GetRetryPolicy(topic string) RetryPolicy {
+	retryPoliciesMu.RLock()
+	defer retryPoliciesMu.RUnlock()
+	if policy, ok := retryPolicies[topic]; ok {
+		return policy
+	}
+
+	return DefaultRetryPolicy
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks a handler error as not worth retrying, e.g. a corrupt
+// file or a malformed payload. The job goes straight to the dead-letter topic.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
@@ -0,0 +1,72 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts:     5,
+		InitialInterval: 2 * time.Second,
+		MaxInterval:     10 * time.Second,
+		Multiplier:      2,
+	}
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 0, want: 2 * time.Second},
+		{attempt: 1, want: 2 * time.Second},
+		{attempt: 2, want: 4 * time.Second},
+		{attempt: 3, want: 8 * time.Second},
+		{attempt: 4, want: 10 * time.Second},
+		{attempt: 20, want: 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := policy.Backoff(tt.attempt); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+
+	constant := RetryPolicy{InitialInterval: time.Second}
+	if got := constant.Backoff(3); got != time.Second {
+		t.Errorf("Backoff(3) without a multiplier = %v, want %v", got, time.Second)
+	}
+}
+
+func TestRetryPolicyRegistry(t *testing.T) {
+	if got := GetRetryPolicy("unregistered_topic"); got != DefaultRetryPolicy {
+		t.Errorf("GetRetryPolicy() for an unknown topic = %+v, want the default", got)
+	}
+
+	SetRetryPolicy("test_topic", RetryPolicy{MaxAttempts: 0, InitialInterval: time.Second})
+	if got := GetRetryPolicy("test_topic").MaxAttempts; got != 1 {
+		t.Errorf("MaxAttempts = %d, want it raised to 1", got)
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	base := errors.New("corrupt file")
+
+	if IsPermanent(base) {
+		t.Error("IsPermanent() = true for a plain error")
+	}
+
+	wrapped := fmt.Errorf("image_process: %w", Permanent(base))
+	if !IsPermanent(wrapped) {
+		t.Error("IsPermanent() = false for a wrapped permanent error")
+	}
+
+	if !errors.Is(wrapped, base) {
+		t.Error("Permanent() should keep the original error in the chain")
+	}
+
+	if Permanent(nil) != nil {
+		t.Error("Permanent(nil) should be nil")
+	}
+}
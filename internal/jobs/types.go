@@ -1,5 +1,7 @@
 package jobs
 
+import "strconv"
+
 // JobCommand represents a command for a worker job. Commands may be shared
 // (e.g. "all", "missing", "single") or worker-specific strings.
 type JobCommand string
@@ -9,3 +11,30 @@ const (
 	JobCommandMissing JobCommand = "missing"
 	JobCommandSingle  JobCommand = "single"
 )
+
+// Priority orders the jobs waiting for a slot on a topic, higher first.
+// Any int works, these are the levels the API uses.
+type Priority int
+
+const (
+	// PriorityBackfill is for bulk jobs over the whole library
+	PriorityBackfill Priority = -10
+	// PriorityNormal is for jobs an admin or a background task starts for a single image
+	PriorityNormal Priority = 0
+	// PriorityInteractive is for jobs a user is waiting on, like processing an upload
+	PriorityInteractive Priority = 10
+)
+
+// String returns the level's name, or the number for other priorities
+func (p Priority) String() string {
+	switch p {
+	case PriorityBackfill:
+		return "backfill"
+	case PriorityNormal:
+		return "normal"
+	case PriorityInteractive:
+		return "interactive"
+	}
+
+	return strconv.Itoa(int(p))
+}
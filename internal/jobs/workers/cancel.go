@@ -0,0 +1,52 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"gorm.io/gorm"
+
+	"viz/internal/entities"
+	libhttp "viz/internal/http"
+	"viz/internal/images"
+	"viz/internal/jobs"
+)
+
+// finishCancelled records a job stopped through jobs.CancelJob and tells
+// clients it won't complete
+func finishCancelled(db *gorm.DB, wsBroker *libhttp.WSBroker, jobUid string, jobType string, imageUid string) {
+	if wsBroker != nil {
+		wsBroker.Broadcast("job-cancelled", map[string]any{
+			"uid":       jobUid,
+			"jobId":     jobUid,
+			"type":      jobType,
+			"topic":     jobType,
+			"image_uid": imageUid,
+			"imageId":   imageUid,
+		})
+	}
+
+	completedAt := time.Now().UTC()
+	_ = jobs.UpdateWorkerJobStatus(db, jobUid, jobs.WorkerJobStatusCancelled, nil, nil, nil, &completedAt)
+}
+
+// removePartialFiles deletes files a cancelled run already wrote so a
+// half-processed image doesn't leave stale thumbnails or transforms behind.
+// The job's context is already cancelled, so this runs without it.
+func removePartialFiles(img entities.ImageAsset, keys []string) {
+	store, err := images.StoreFor(img)
+	if err != nil {
+		jobs.Logger.Error("failed to remove files left by cancelled job", err, watermill.LogFields{"uid": img.Uid})
+		return
+	}
+
+	for _, key := range keys {
+		if err := store.Delete(context.Background(), key); err != nil {
+			jobs.Logger.Error("failed to remove file left by cancelled job", err, watermill.LogFields{
+				"uid": img.Uid,
+				"key": key,
+			})
+		}
+	}
+}
@@ -1,9 +1,9 @@
 package workers
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"viz/internal/dto"
 	"strings"
@@ -16,9 +16,9 @@ import (
 	"viz/internal/entities"
 	libhttp "viz/internal/http"
 	"viz/internal/imageops"
-	libvips "viz/internal/imageops/vips"
 	"viz/internal/images"
 	"viz/internal/jobs"
+	"viz/internal/search"
 	"viz/internal/utils"
 	customxmp "viz/internal/xmp"
 
@@ -41,13 +41,18 @@ func NewExifWorker(db *gorm.DB, wsBroker *libhttp.WSBroker) *jobs.Worker {
 		var job ExifProcessJob
 		err := json.Unmarshal(msg.Payload, &job)
 		if err != nil {
-			return fmt.Errorf("%s: %w", JobTypeExifProcess, err)
+			return jobs.Permanent(fmt.Errorf("%s: %w", JobTypeExifProcess, err))
+		}
+
+		job.Image, err = latestImage(db, msg, job.Image)
+		if err != nil {
+			return err
 		}
 
 		if job.Image.ImageMetadata == nil {
 			err = fmt.Errorf("job %s failed: image metadata is nil for image %s", JobTypeExifProcess, job.Image.Uid)
 			_ = jobs.UpdateWorkerJobStatus(db, msg.UUID, jobs.WorkerJobStatusFailed, utils.StringPtr("worker_error"), utils.StringPtr(jobs.Truncate(err.Error(), 1024)), nil, nil)
-			return nil // Return nil to avoid retry loop
+			return jobs.Permanent(err)
 		}
 
 		if wsBroker != nil {
@@ -76,6 +81,11 @@ func NewExifWorker(db *gorm.DB, wsBroker *libhttp.WSBroker) *jobs.Worker {
 
 		err = ExifProcess(msg.Context(), db, job.Image, onProgress)
 
+		if errors.Is(err, jobs.ErrJobCancelled) {
+			finishCancelled(db, wsBroker, msg.UUID, JobTypeExifProcess, job.Image.Uid)
+			return err
+		}
+
 		if err != nil {
 			if wsBroker != nil {
 				wsBroker.Broadcast("job-failed", map[string]any{
@@ -113,21 +123,21 @@ func NewExifWorker(db *gorm.DB, wsBroker *libhttp.WSBroker) *jobs.Worker {
 
 // ExifProcess extracts EXIF and updates the DB (exif + taken_at + optional metadata)
 func ExifProcess(ctx context.Context, db *gorm.DB, imgEnt entities.ImageAsset, onProgress func(step string, progress int)) error {
-	originalData, err := images.ReadImage(imgEnt.Uid, imgEnt.ImageMetadata.FileName)
-	if err != nil {
-		return fmt.Errorf("failed to read image for exif: %w", err)
-	}
-
 	if onProgress != nil {
 		onProgress("Processing EXIF data", 30)
 	}
 
-	libvipsImg, err := libvips.NewImageFromBuffer(originalData, libvips.DefaultLoadOptions())
+	// only the header is decoded, the pixels of the original are never read
+	libvipsImg, err := imageops.LoadOriginal(images.FileOpener(ctx, imgEnt), imgEnt, nil)
 	if err != nil {
-		return fmt.Errorf("failed to create vips image from buffer: %w", err)
+		return fmt.Errorf("failed to read image for exif: %w", err)
 	}
 	defer libvipsImg.Close()
 
+	if err := jobs.Cancelled(ctx); err != nil {
+		return err
+	}
+
 	exifData, fileCreatedAt, fileModifiedAt := imageops.BuildImageEXIF(libvipsImg.Exif())
 	imgEnt.Exif = &exifData
 
@@ -144,16 +154,26 @@ func ExifProcess(ctx context.Context, db *gorm.DB, imgEnt entities.ImageAsset, o
 	}
 
 	hasIcc := libvipsImg.HasICCProfile()
-	imgEnt.ImageMetadata.ColorSpace = imageops.GetColourSpaceString(libvipsImg)
+	imgEnt.ImageMetadata.ColorSpace = imageops.GetColourSpaceString(libvipsImg.Image)
 	imgEnt.ImageMetadata.HasIccProfile = &hasIcc
 	takenAt := imageops.GetTakenAt(imgEnt)
 
+	if err := jobs.Cancelled(ctx); err != nil {
+		return err
+	}
+
 	// Extract XMP Metadata (ACR, Capture One, Standard)
 	if onProgress != nil {
 		onProgress("Processing XMP data", 60)
 	}
 
-	if doc, err := xmp.Scan(bytes.NewReader(originalData)); err == nil {
+	xmpFile, err := images.OpenImage(ctx, imgEnt, imgEnt.ImageMetadata.FileName)
+	if err != nil {
+		return fmt.Errorf("failed to read image for xmp: %w", err)
+	}
+	defer xmpFile.Close()
+
+	if doc, err := xmp.Scan(xmpFile); err == nil {
 		defer doc.Close()
 
 		xmpBase := &xmpbase.XmpBase{}
@@ -233,6 +253,10 @@ func ExifProcess(ctx context.Context, db *gorm.DB, imgEnt entities.ImageAsset, o
 		}
 	}
 
+	if err := jobs.Cancelled(ctx); err != nil {
+		return err
+	}
+
 	if onProgress != nil {
 		onProgress("Updating database", 90)
 	}
@@ -274,5 +298,13 @@ func ExifProcess(ctx context.Context, db *gorm.DB, imgEnt entities.ImageAsset, o
 		return fmt.Errorf("failed to update db image exif: %w", err)
 	}
 
+	// Numeric copies of the EXIF values used for search filters
+	if err := db.Model(&entities.ImageAsset{}).
+		Where("uid = ?", imgEnt.Uid).
+		Updates(search.ExifColumnValues(imgEnt.Exif)).
+		Error; err != nil {
+		return fmt.Errorf("failed to update db image exif values: %w", err)
+	}
+
 	return nil
 }
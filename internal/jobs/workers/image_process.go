@@ -3,6 +3,7 @@ package workers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"viz/internal/transform"
 	"time"
@@ -15,6 +16,7 @@ import (
 	"viz/internal/imageops"
 	"viz/internal/images"
 	"viz/internal/jobs"
+	"viz/internal/storage"
 	"viz/internal/utils"
 
 	"gorm.io/gorm"
@@ -31,17 +33,30 @@ type ImageProcessJob struct {
 
 // NewImageWorker creates a worker that processes images and sends WebSocket updates
 func NewImageWorker(db *gorm.DB, wsBroker *libhttp.WSBroker) *jobs.Worker {
+	// Large RAW batches mostly fail from memory pressure, so give them longer to recover
+	jobs.SetRetryPolicy(TopicImageProcess, jobs.RetryPolicy{
+		MaxAttempts:     5,
+		InitialInterval: 5 * time.Second,
+		MaxInterval:     2 * time.Minute,
+		Multiplier:      2,
+	})
+
 	return jobs.NewWorker(JobTypeImageProcess, TopicImageProcess, "Image Processing", 5, func(msg *message.Message) error {
 		var job ImageProcessJob
 		err := json.Unmarshal(msg.Payload, &job)
 		if err != nil {
-			return fmt.Errorf("%s: %w", JobTypeImageProcess, err)
+			return jobs.Permanent(fmt.Errorf("%s: %w", JobTypeImageProcess, err))
+		}
+
+		job.Image, err = latestImage(db, msg, job.Image)
+		if err != nil {
+			return err
 		}
 
 		if job.Image.ImageMetadata == nil {
 			err = fmt.Errorf("job %s failed: image metadata is nil for image %s", JobTypeImageProcess, job.Image.Uid)
 			_ = jobs.UpdateWorkerJobStatus(db, msg.UUID, jobs.WorkerJobStatusFailed, utils.StringPtr("worker_error"), utils.StringPtr(jobs.Truncate(err.Error(), 1024)), nil, nil)
-			return nil // Return nil to avoid retry loop for unrecoverable error
+			return jobs.Permanent(err)
 		}
 
 		if wsBroker != nil {
@@ -71,6 +86,11 @@ func NewImageWorker(db *gorm.DB, wsBroker *libhttp.WSBroker) *jobs.Worker {
 
 		err = ImageProcess(msg.Context(), db, job.Image, onProgress)
 
+		if errors.Is(err, jobs.ErrJobCancelled) {
+			finishCancelled(db, wsBroker, msg.UUID, JobTypeImageProcess, job.Image.Uid)
+			return err
+		}
+
 		if err != nil {
 			if wsBroker != nil {
 				wsBroker.Broadcast("job-failed", map[string]any{
@@ -108,18 +128,27 @@ func NewImageWorker(db *gorm.DB, wsBroker *libhttp.WSBroker) *jobs.Worker {
 	)
 }
 
-func ImageProcess(ctx context.Context, db *gorm.DB, imgEnt entities.ImageAsset, onProgress func(step string, progress int)) error {
-	originalData, err := images.ReadImage(imgEnt.Uid, imgEnt.ImageMetadata.FileName)
-	if err != nil {
-		return fmt.Errorf("failed to read image: %w", err)
-	}
+// ImageProcess creates the thumbnail, thumbhash and permanent transforms of
+// an image. It stops between steps once ctx is cancelled and removes the
+// files it already wrote when the job itself was cancelled.
+func ImageProcess(ctx context.Context, db *gorm.DB, imgEnt entities.ImageAsset, onProgress func(step string, progress int)) (err error) {
+	var written []string
+	defer func() {
+		if errors.Is(err, jobs.ErrJobCancelled) {
+			removePartialFiles(imgEnt, written)
+		}
+	}()
+
+	// the original is streamed from storage by every step instead of read
+	// into memory, large scans and panoramas don't fit
+	files := images.FileOpener(ctx, imgEnt)
 
 	if imgEnt.ImageMetadata.Checksum == "" {
 		if onProgress != nil {
 			onProgress("Calculating image checksum", 10)
 		}
 
-		checksum, err := images.CalculateImageChecksum(originalData)
+		checksum, err := originalChecksum(ctx, imgEnt)
 		if err != nil {
 			return fmt.Errorf("failed to calculate image checksum: %w", err)
 		}
@@ -129,17 +158,21 @@ func ImageProcess(ctx context.Context, db *gorm.DB, imgEnt entities.ImageAsset,
 
 	// Create a display thumbnail from the image
 	// Update - 28/12/2025: this is redundant if we have transforms, but this can be used for something else maybe
-	thumbData, err := imageops.CreateThumbnailWithSize(originalData, 200, 0)
+	thumbData, err := imageops.CreateThumbnailFromSource(files, imgEnt, 200, 0)
 	if err != nil {
 		return fmt.Errorf("failed to create thumbnail: %w", err)
 	}
 
+	if err := jobs.Cancelled(ctx); err != nil {
+		return err
+	}
+
 	if onProgress != nil {
 		onProgress("Creating thumbnail for thumbhash", 40)
 	}
 
 	// Create a very small thumbnail for thumbhash (e.g., 32x32)
-	smallThumbData, err := imageops.CreateThumbnailWithSize(originalData, 32, 32)
+	smallThumbData, err := imageops.CreateThumbnailFromSource(files, imgEnt, 32, 32)
 	if err != nil {
 		return fmt.Errorf("failed to create small thumbnail for thumbhash: %w", err)
 	}
@@ -155,12 +188,28 @@ func ImageProcess(ctx context.Context, db *gorm.DB, imgEnt entities.ImageAsset,
 		onProgress("Saving thumbnail to disk", 55)
 	}
 
+	if err := jobs.Cancelled(ctx); err != nil {
+		return err
+	}
+
 	// Save the thumbnail to disk
-	err = images.SaveImage(thumbData, imgEnt.Uid, fmt.Sprintf("%s-thumbnail", imgEnt.Uid)+".jpeg")
+	thumbName := fmt.Sprintf("%s-thumbnail", imgEnt.Uid) + ".jpeg"
+	thumbKey := images.ImageKey(imgEnt.Uid, thumbName)
+	_, statErr := images.StatImage(ctx, imgEnt, thumbName)
+	if statErr != nil && !errors.Is(statErr, storage.ErrNotExist) {
+		return fmt.Errorf("failed to check for thumbnail: %w", statErr)
+	}
+
+	err = images.SaveImage(ctx, thumbData, imgEnt, thumbName)
 	if err != nil {
 		return fmt.Errorf("failed to save thumbnail: %w", err)
 	}
 
+	// a thumbnail from an earlier run is still valid, only remove new ones
+	if statErr != nil {
+		written = append(written, thumbKey)
+	}
+
 	// Decode the thumbnail bytes to an image and generate the thumbhash from it
 	jobs.Logger.Info("generating thumbhash", loggerFields)
 
@@ -192,6 +241,10 @@ func ImageProcess(ctx context.Context, db *gorm.DB, imgEnt entities.ImageAsset,
 	var transformParams *transform.TransformParams
 	var terr error
 
+	if err := jobs.Cancelled(ctx); err != nil {
+		return err
+	}
+
 	if onProgress != nil {
 		onProgress("Generating transforms", 80)
 	}
@@ -208,7 +261,7 @@ func ImageProcess(ctx context.Context, db *gorm.DB, imgEnt entities.ImageAsset,
 			return terr
 		}
 
-		result, terr := imageops.GenerateTransform(transformParams, imgEnt, originalData)
+		result, terr := imageops.GenerateTransformFromSource(transformParams, imgEnt, files)
 		if terr != nil {
 			if terr.Error() == images.CacheErrTransformExists {
 				jobs.Logger.Debug("GenerateTransformFromPath: transform already exists", loggerFields.Add(watermill.LogFields{
@@ -223,10 +276,12 @@ func ImageProcess(ctx context.Context, db *gorm.DB, imgEnt entities.ImageAsset,
 				ext = result.Ext
 			}
 
-			if terr := images.WriteCachedTransform(imgEnt.Uid, *result.TransformHash, ext, result.ImageData); terr != nil {
+			if terr := images.WriteCachedTransform(ctx, imgEnt, *result.TransformHash, ext, result.ImageData); terr != nil {
 				return fmt.Errorf("failed to write cached transform: %w", terr)
 			}
 
+			written = append(written, images.TransformKey(imgEnt.Uid, *result.TransformHash, ext))
+
 			jobs.Logger.Debug("GenerateTransformFromPath: finished generating transform", watermill.LogFields{
 				"uid":         imgEnt.Uid,
 				"path":        imgEnt.ImagePaths.Thumbnail,
@@ -237,6 +292,10 @@ func ImageProcess(ctx context.Context, db *gorm.DB, imgEnt entities.ImageAsset,
 		}
 	}
 
+	if err := jobs.Cancelled(ctx); err != nil {
+		return err
+	}
+
 	// Generate preview transform
 	tstart = time.Now()
 	if imgEnt.ImagePaths.Preview != "" {
@@ -249,7 +308,7 @@ func ImageProcess(ctx context.Context, db *gorm.DB, imgEnt entities.ImageAsset,
 			return terr
 		}
 
-		result, terr := imageops.GenerateTransform(transformParams, imgEnt, originalData)
+		result, terr := imageops.GenerateTransformFromSource(transformParams, imgEnt, files)
 		if terr != nil {
 			if terr.Error() == images.CacheErrTransformExists {
 				jobs.Logger.Debug("GenerateTransformFromPath: transform already exists", loggerFields.Add(watermill.LogFields{
@@ -264,10 +323,12 @@ func ImageProcess(ctx context.Context, db *gorm.DB, imgEnt entities.ImageAsset,
 				ext = result.Ext
 			}
 
-			if terr := images.WriteCachedTransform(imgEnt.Uid, *result.TransformHash, ext, result.ImageData); terr != nil {
+			if terr := images.WriteCachedTransform(ctx, imgEnt, *result.TransformHash, ext, result.ImageData); terr != nil {
 				return fmt.Errorf("failed to write cached transform: %w", terr)
 			}
 
+			written = append(written, images.TransformKey(imgEnt.Uid, *result.TransformHash, ext))
+
 			jobs.Logger.Debug("GenerateTransformFromPath: finished generating transform", watermill.LogFields{
 				"uid":         imgEnt.Uid,
 				"path":        imgEnt.ImagePaths.Preview,
@@ -278,6 +339,34 @@ func ImageProcess(ctx context.Context, db *gorm.DB, imgEnt entities.ImageAsset,
 		}
 	}
 
+	// The presets admins define are generated like the thumbnail and
+	// preview, one that can't be rendered for this image, like a crop
+	// outside it, doesn't fail the rest
+	for _, preset := range images.GetPresets() {
+		if err := jobs.Cancelled(ctx); err != nil {
+			return err
+		}
+
+		key, perr := GeneratePreset(ctx, imgEnt, preset.Params)
+		if perr != nil {
+			if errors.Is(perr, jobs.ErrJobCancelled) {
+				return perr
+			}
+
+			jobs.Logger.Error("failed to generate transform preset", perr, loggerFields.Add(watermill.LogFields{
+				"preset": string(preset.Name),
+			}))
+			continue
+		}
+
+		written = append(written, key)
+	}
+
+	// Last chance to stop before the results are saved
+	if err := jobs.Cancelled(ctx); err != nil {
+		return err
+	}
+
 	if onProgress != nil {
 		onProgress("Updating database", 90)
 	}
@@ -301,3 +390,15 @@ func ImageProcess(ctx context.Context, db *gorm.DB, imgEnt entities.ImageAsset,
 
 	return nil
 }
+
+// originalChecksum hashes the original of an image as it's read from
+// storage
+func originalChecksum(ctx context.Context, imgEnt entities.ImageAsset) (string, error) {
+	r, err := images.OpenImage(ctx, imgEnt, imgEnt.ImageMetadata.FileName)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	return images.CalculateReaderChecksum(r)
+}
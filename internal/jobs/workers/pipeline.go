@@ -0,0 +1,65 @@
+package workers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"gorm.io/gorm"
+
+	"viz/internal/config"
+	"viz/internal/entities"
+	"viz/internal/images"
+	"viz/internal/jobs"
+)
+
+// PipelineUpload is the name of the pipeline run for each uploaded image
+const PipelineUpload = "upload"
+
+// UploadPipeline returns the steps run for a new image: EXIF first so the
+// transforms and the XMP sidecar are built from the extracted metadata.
+// There's no checksum step, the upload routes need the checksum to find
+// duplicates before the image exists and have already stored it.
+// Images large enough to be viewed through IIIF tiles get their tile
+// pyramid built once the checksum it's keyed by is known.
+func UploadPipeline(img entities.ImageAsset) []jobs.PipelineStep {
+	steps := []jobs.PipelineStep{
+		{Topic: TopicExifProcess, Payload: &ExifProcessJob{Image: img}},
+		{Topic: TopicImageProcess, Payload: &ImageProcessJob{Image: img}, DependsOn: []string{TopicExifProcess}},
+		{Topic: TopicXMPGeneration, Payload: &XMPGenerationJob{Image: img}, DependsOn: []string{TopicImageProcess}},
+	}
+
+	if images.NeedsPyramid(img, config.AppConfig.IIIF.PyramidMinMegapixels) {
+		steps = append(steps, jobs.PipelineStep{Topic: TopicTilePyramid, Payload: &TilePyramidJob{Image: img}, DependsOn: []string{TopicImageProcess}})
+	}
+
+	return steps
+}
+
+// latestImage returns the image as saved by the earlier steps of the
+// message's pipeline, payloads are a snapshot from when it was enqueued.
+// Jobs outside a pipeline get img back with just its storage location and
+// edits refreshed, since a storage migration may have moved its files and
+// the edits decide what its transforms look like.
+func latestImage(db *gorm.DB, msg *message.Message, img entities.ImageAsset) (entities.ImageAsset, error) {
+	if msg.Metadata.Get("X-Pipeline-Uid") == "" {
+		var current entities.ImageAsset
+		err := db.Unscoped().Select("storage_location", "edits").Where("uid = ?", img.Uid).First(&current).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return img, fmt.Errorf("failed to load image %s: %w", img.Uid, err)
+		}
+
+		if err == nil {
+			img.StorageLocation = current.StorageLocation
+			img.Edits = current.Edits
+		}
+		return img, nil
+	}
+
+	var latest entities.ImageAsset
+	if err := db.Preload("Owner").Preload("UploadedBy").Where("uid = ?", img.Uid).First(&latest).Error; err != nil {
+		return img, fmt.Errorf("failed to load image %s: %w", img.Uid, err)
+	}
+
+	return latest, nil
+}
@@ -0,0 +1,152 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"gorm.io/gorm"
+
+	"viz/internal/entities"
+	libhttp "viz/internal/http"
+	"viz/internal/imageops"
+	"viz/internal/images"
+	"viz/internal/jobs"
+	"viz/internal/transform"
+	"viz/internal/utils"
+)
+
+const (
+	JobTypePresetTransform = "preset_transform"
+	TopicPresetTransform   = JobTypePresetTransform
+)
+
+// PresetTransformJob regenerates one transform preset of an image after
+// the preset was added or changed
+type PresetTransformJob struct {
+	Image  entities.ImageAsset
+	Preset string
+}
+
+// NewPresetTransformWorker creates the worker that regenerates transform
+// presets. A changed preset queues a job for every image, so they run at
+// backfill priority next to uploads.
+func NewPresetTransformWorker(db *gorm.DB, wsBroker *libhttp.WSBroker) *jobs.Worker {
+	return jobs.NewWorker(JobTypePresetTransform, TopicPresetTransform, "Transform Presets", 2, func(msg *message.Message) error {
+		var job PresetTransformJob
+		err := json.Unmarshal(msg.Payload, &job)
+		if err != nil {
+			return jobs.Permanent(fmt.Errorf("%s: %w", JobTypePresetTransform, err))
+		}
+
+		job.Image, err = latestImage(db, msg, job.Image)
+		if err != nil {
+			return err
+		}
+
+		if job.Image.ImageMetadata == nil {
+			err = fmt.Errorf("job %s failed: image metadata is nil for image %s", JobTypePresetTransform, job.Image.Uid)
+			_ = jobs.UpdateWorkerJobStatus(db, msg.UUID, jobs.WorkerJobStatusFailed, utils.StringPtr("worker_error"), utils.StringPtr(jobs.Truncate(err.Error(), 1024)), nil, nil)
+			return jobs.Permanent(err)
+		}
+
+		if wsBroker != nil {
+			wsBroker.Broadcast("job-started", map[string]any{
+				"uid":       msg.UUID,
+				"jobId":     msg.UUID,
+				"type":      JobTypePresetTransform,
+				"topic":     JobTypePresetTransform,
+				"image_uid": job.Image.Uid,
+				"imageId":   job.Image.Uid,
+				"filename":  job.Image.ImageMetadata.FileName,
+			})
+		}
+
+		startedAt := time.Now().UTC()
+		_ = jobs.UpdateWorkerJobStatus(db, msg.UUID, jobs.WorkerJobStatusRunning, nil, nil, &startedAt, nil)
+
+		// the preset is read when the job runs, a preset changed again while
+		// the job waited is generated as it is now, a removed one not at all
+		if params, ok := images.GetPermanentTransformParams(images.PermanentTransformName(job.Preset)); ok {
+			_, err = GeneratePreset(msg.Context(), job.Image, params)
+		}
+
+		if errors.Is(err, jobs.ErrJobCancelled) {
+			finishCancelled(db, wsBroker, msg.UUID, JobTypePresetTransform, job.Image.Uid)
+			return err
+		}
+
+		if err != nil {
+			if wsBroker != nil {
+				wsBroker.Broadcast("job-failed", map[string]any{
+					"uid":       msg.UUID,
+					"jobId":     msg.UUID,
+					"type":      JobTypePresetTransform,
+					"topic":     JobTypePresetTransform,
+					"image_uid": job.Image.Uid,
+					"imageId":   job.Image.Uid,
+					"error":     err.Error(),
+				})
+			}
+			_ = jobs.UpdateWorkerJobStatus(db, msg.UUID, jobs.WorkerJobStatusFailed, utils.StringPtr("worker_error"), utils.StringPtr(jobs.Truncate(err.Error(), 1024)), nil, nil)
+			return err
+		}
+
+		if wsBroker != nil {
+			wsBroker.Broadcast("job-completed", map[string]any{
+				"uid":       msg.UUID,
+				"jobId":     msg.UUID,
+				"type":      JobTypePresetTransform,
+				"topic":     JobTypePresetTransform,
+				"image_uid": job.Image.Uid,
+				"imageId":   job.Image.Uid,
+			})
+		}
+
+		completedAt := time.Now().UTC()
+		_ = jobs.UpdateWorkerJobStatus(db, msg.UUID, jobs.WorkerJobStatusSuccess, nil, nil, nil, &completedAt)
+
+		return nil
+	},
+	)
+}
+
+// GeneratePreset renders a transform preset of an image, streaming the
+// original from storage, and stores it with the image's cached transforms.
+// It returns the storage key it was written to.
+func GeneratePreset(ctx context.Context, imgEnt entities.ImageAsset, params transform.TransformParams) (string, error) {
+	if err := jobs.Cancelled(ctx); err != nil {
+		return "", err
+	}
+
+	// defaulted like the file route and the cache GC do, so both find it
+	// under the same ETag
+	params.DefaultFormat(imgEnt.ImageMetadata.FileType)
+
+	if err := images.LookupColourProfiles(&params); err != nil {
+		return "", err
+	}
+
+	if err := images.FetchColourProfiles(ctx, &params); err != nil {
+		return "", err
+	}
+
+	result, err := imageops.GenerateTransformFromSource(&params, imgEnt, images.FileOpener(ctx, imgEnt))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate preset: %w", err)
+	}
+
+	ext := imgEnt.ImageMetadata.FileType
+	if result.Ext != "" {
+		ext = result.Ext
+	}
+
+	if err := images.WriteCachedTransform(ctx, imgEnt, *result.TransformHash, ext, result.ImageData); err != nil {
+		return "", fmt.Errorf("failed to write cached transform: %w", err)
+	}
+
+	return images.TransformKey(imgEnt.Uid, *result.TransformHash, ext), nil
+}
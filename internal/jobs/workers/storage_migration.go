@@ -0,0 +1,385 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"gorm.io/gorm"
+
+	"viz/internal/entities"
+	libhttp "viz/internal/http"
+	"viz/internal/images"
+	"viz/internal/jobs"
+	"viz/internal/storage"
+	"viz/internal/utils"
+)
+
+const (
+	JobTypeStorageMigration = "storage_migration"
+	TopicStorageMigration   = JobTypeStorageMigration
+)
+
+const (
+	// DefaultMigrationConcurrency is how many images a storage migration
+	// copies at the same time unless the job sets it
+	DefaultMigrationConcurrency = 4
+	// migrationBatchSize is how many images are migrated between checkpoints
+	migrationBatchSize = 50
+)
+
+// ErrChecksumMismatch is returned when a copied original doesn't match the
+// checksum stored for its image
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// StorageMigrationJob moves the files of every image in the Source storage
+// location to Target. Images are migrated in ID order and the stored
+// payload is updated with a checkpoint after every batch, so a job
+// recovered after a restart continues where it stopped.
+type StorageMigrationJob struct {
+	Source       string `json:"source"`
+	Target       string `json:"target"`
+	DeleteSource bool   `json:"delete_source"`
+	Concurrency  int    `json:"concurrency"`
+	// Checkpoint is the ID of the last image handled
+	Checkpoint uint `json:"checkpoint"`
+	Migrated   int  `json:"migrated"`
+	Failed     int  `json:"failed"`
+}
+
+// Validate checks that both locations exist and don't keep their files in
+// the same place, where deleting the source would delete the migrated files
+func (j StorageMigrationJob) Validate() error {
+	if j.Source == j.Target {
+		return errors.New("source and target are the same storage location")
+	}
+
+	source, err := images.StoreAt(j.Source)
+	if err != nil {
+		return err
+	}
+
+	target, err := images.StoreAt(j.Target)
+	if err != nil {
+		return err
+	}
+
+	if storage.SameLocation(source, target) {
+		return fmt.Errorf("storage locations %q and %q keep their files in the same place", j.Source, j.Target)
+	}
+
+	return nil
+}
+
+// NewStorageMigrationWorker creates the worker that moves images between
+// storage locations. Only one migration runs at a time.
+func NewStorageMigrationWorker(db *gorm.DB, wsBroker *libhttp.WSBroker) *jobs.Worker {
+	return jobs.NewWorker(JobTypeStorageMigration, TopicStorageMigration, "Storage Migration", 1, func(msg *message.Message) error {
+		var job StorageMigrationJob
+		err := json.Unmarshal(msg.Payload, &job)
+		if err != nil {
+			return jobs.Permanent(fmt.Errorf("%s: %w", JobTypeStorageMigration, err))
+		}
+
+		if err := job.Validate(); err != nil {
+			err = fmt.Errorf("job %s failed: %w", JobTypeStorageMigration, err)
+			_ = jobs.UpdateWorkerJobStatus(db, msg.UUID, jobs.WorkerJobStatusFailed, utils.StringPtr("worker_error"), utils.StringPtr(jobs.Truncate(err.Error(), 1024)), nil, nil)
+			return jobs.Permanent(err)
+		}
+
+		if wsBroker != nil {
+			wsBroker.Broadcast("job-started", map[string]any{
+				"uid":    msg.UUID,
+				"jobId":  msg.UUID,
+				"type":   JobTypeStorageMigration,
+				"topic":  JobTypeStorageMigration,
+				"source": job.Source,
+				"target": job.Target,
+			})
+		}
+
+		startedAt := time.Now().UTC()
+		_ = jobs.UpdateWorkerJobStatus(db, msg.UUID, jobs.WorkerJobStatusRunning, nil, nil, &startedAt, nil)
+
+		onProgress := jobs.NewProgressCallback(wsBroker, msg.UUID, JobTypeStorageMigration, "", "")
+
+		err = MigrateStorage(msg.Context(), db, msg.UUID, &job, onProgress)
+
+		if errors.Is(err, jobs.ErrJobCancelled) {
+			finishCancelled(db, wsBroker, msg.UUID, JobTypeStorageMigration, "")
+			return err
+		}
+
+		if err != nil {
+			if wsBroker != nil {
+				wsBroker.Broadcast("job-failed", map[string]any{
+					"uid":   msg.UUID,
+					"jobId": msg.UUID,
+					"type":  JobTypeStorageMigration,
+					"topic": JobTypeStorageMigration,
+					"error": err.Error(),
+				})
+			}
+			_ = jobs.UpdateWorkerJobStatus(db, msg.UUID, jobs.WorkerJobStatusFailed, utils.StringPtr("worker_error"), utils.StringPtr(jobs.Truncate(err.Error(), 1024)), nil, nil)
+			return err
+		}
+
+		if wsBroker != nil {
+			wsBroker.Broadcast("job-completed", map[string]any{
+				"uid":      msg.UUID,
+				"jobId":    msg.UUID,
+				"type":     JobTypeStorageMigration,
+				"topic":    JobTypeStorageMigration,
+				"migrated": job.Migrated,
+			})
+		}
+
+		completedAt := time.Now().UTC()
+		_ = jobs.UpdateWorkerJobStatus(db, msg.UUID, jobs.WorkerJobStatusSuccess, nil, nil, nil, &completedAt)
+
+		return nil
+	},
+	)
+}
+
+// MigrateStorage migrates the images still in the job's source location,
+// continuing after its checkpoint. Images that fail are left in the source
+// and counted; once the rest are done the job fails, with its checkpoint
+// reset so replaying it retries just the failed images.
+func MigrateStorage(ctx context.Context, db *gorm.DB, jobUid string, job *StorageMigrationJob, onProgress func(step string, progress int)) error {
+	src, err := images.StoreAt(job.Source)
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+
+	dst, err := images.StoreAt(job.Target)
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+
+	concurrency := job.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultMigrationConcurrency
+	}
+
+	var remaining int64
+	err = db.Unscoped().Model(&entities.ImageAsset{}).Scopes(images.InLocation(job.Source)).
+		Where("id > ?", job.Checkpoint).
+		Count(&remaining).Error
+	if err != nil {
+		return fmt.Errorf("failed to count images to migrate: %w", err)
+	}
+
+	total := job.Migrated + job.Failed + int(remaining)
+
+	for {
+		if err := jobs.Cancelled(ctx); err != nil {
+			return err
+		}
+
+		var batch []entities.ImageAsset
+		err := db.Unscoped().Scopes(images.InLocation(job.Source)).
+			Where("id > ?", job.Checkpoint).
+			Order("id asc").
+			Limit(migrationBatchSize).
+			Find(&batch).Error
+		if err != nil {
+			return fmt.Errorf("failed to load images to migrate: %w", err)
+		}
+
+		if len(batch) == 0 {
+			break
+		}
+
+		errs := make([]error, len(batch))
+		sem := make(chan struct{}, concurrency)
+		var wg sync.WaitGroup
+		for i, img := range batch {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				errs[i] = migrateImage(ctx, db, src, dst, img, job)
+			}()
+		}
+		wg.Wait()
+
+		// images migrated before the cancellation left the source, the
+		// rest are picked up again without moving the checkpoint
+		if err := jobs.Cancelled(ctx); err != nil {
+			return err
+		}
+
+		for i, err := range errs {
+			if err == nil {
+				job.Migrated++
+				continue
+			}
+
+			job.Failed++
+			jobs.Logger.Error("failed to migrate image", err, watermill.LogFields{
+				"image_uid": batch[i].Uid,
+				"source":    job.Source,
+				"target":    job.Target,
+			})
+		}
+
+		job.Checkpoint = batch[len(batch)-1].ID
+		if err := jobs.UpdateWorkerJobPayload(db, jobUid, job); err != nil {
+			return err
+		}
+
+		if onProgress != nil && total > 0 {
+			onProgress(fmt.Sprintf("Migrated %d of %d images", job.Migrated, total), (job.Migrated+job.Failed)*100/total)
+		}
+	}
+
+	jobs.Logger.Info("storage migration finished", watermill.LogFields{
+		"source":   job.Source,
+		"target":   job.Target,
+		"migrated": job.Migrated,
+		"failed":   job.Failed,
+	})
+
+	if job.Failed > 0 {
+		failed := job.Failed
+		retry := *job
+		retry.Checkpoint, retry.Migrated, retry.Failed = 0, 0, 0
+		if err := jobs.UpdateWorkerJobPayload(db, jobUid, retry); err != nil {
+			return err
+		}
+
+		return jobs.Permanent(fmt.Errorf("%d of %d images failed to migrate and are still in %s", failed, total, job.Source))
+	}
+
+	if onProgress != nil {
+		onProgress("Complete", 100)
+	}
+
+	return nil
+}
+
+// migrateImage copies an image's folder, its trash folder once deleted,
+// from src to dst, checks the copy and then switches the image's location.
+// The switch only applies if the image is still where and as it was, so a
+// concurrent delete or migration isn't undone.
+func migrateImage(ctx context.Context, db *gorm.DB, src, dst storage.Storage, img entities.ImageAsset, job *StorageMigrationJob) (err error) {
+	trashed := img.DeletedAt.Valid
+	folder := images.ImageFolder(img.Uid)
+	if trashed {
+		folder = images.TrashFolder(img.Uid)
+	}
+
+	var objects []storage.ObjectInfo
+	err = src.List(ctx, folder, func(obj storage.ObjectInfo) error {
+		objects = append(objects, obj)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %w", folder, err)
+	}
+
+	if len(objects) == 0 {
+		return fmt.Errorf("no files found in %s", folder)
+	}
+
+	defer func() {
+		if err != nil {
+			// the image stays in the source, drop the partial copy
+			if derr := dst.DeleteAll(context.Background(), folder); derr != nil {
+				jobs.Logger.Error("failed to remove partial copy", derr, watermill.LogFields{"image_uid": img.Uid, "folder": folder})
+			}
+		}
+	}()
+
+	for _, obj := range objects {
+		if err := copyObject(ctx, src, dst, obj); err != nil {
+			return err
+		}
+	}
+
+	if img.ImageMetadata != nil && img.ImageMetadata.Checksum != "" {
+		if err := verifyChecksum(ctx, dst, images.FileKey(folder, img.ImageMetadata.FileName), img.ImageMetadata.Checksum); err != nil {
+			return err
+		}
+	}
+
+	tx := db.Unscoped().Model(&entities.ImageAsset{}).
+		Scopes(images.InLocation(job.Source)).
+		Where("uid = ?", img.Uid)
+	if trashed {
+		tx = tx.Where("deleted_at IS NOT NULL")
+	} else {
+		tx = tx.Where("deleted_at IS NULL")
+	}
+
+	result := tx.Update("storage_location", images.LocationValue(job.Target))
+	if result.Error != nil {
+		return fmt.Errorf("failed to switch storage location: %w", result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return errors.New("image was changed during the migration")
+	}
+
+	if job.DeleteSource {
+		if err := src.DeleteAll(ctx, folder); err != nil {
+			// the image already uses the copy, the source is just left over
+			jobs.Logger.Error("failed to delete migrated source files", err, watermill.LogFields{"image_uid": img.Uid, "folder": folder})
+		}
+	}
+
+	return nil
+}
+
+// copyObject copies one object and checks the copy has the same size
+func copyObject(ctx context.Context, src, dst storage.Storage, obj storage.ObjectInfo) error {
+	r, err := src.Open(ctx, obj.Key)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", obj.Key, err)
+	}
+	defer r.Close()
+
+	if err := dst.Put(ctx, obj.Key, r, obj.Size); err != nil {
+		return fmt.Errorf("failed to copy %s: %w", obj.Key, err)
+	}
+
+	info, err := dst.Stat(ctx, obj.Key)
+	if err != nil {
+		return fmt.Errorf("failed to check copy of %s: %w", obj.Key, err)
+	}
+
+	if info.Size != obj.Size {
+		return fmt.Errorf("copy of %s has %d bytes, expected %d", obj.Key, info.Size, obj.Size)
+	}
+
+	return nil
+}
+
+// verifyChecksum streams an original back from storage and compares it
+// with the checksum recorded at upload, without holding it in memory
+func verifyChecksum(ctx context.Context, store storage.Storage, key, checksum string) error {
+	r, err := store.Open(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to read back %s: %w", key, err)
+	}
+	defer r.Close()
+
+	sum, err := images.CalculateReaderChecksum(r)
+	if err != nil {
+		return fmt.Errorf("failed to read back %s: %w", key, err)
+	}
+
+	if sum != checksum {
+		return fmt.Errorf("%w: %s", ErrChecksumMismatch, key)
+	}
+
+	return nil
+}
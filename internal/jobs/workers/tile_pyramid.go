@@ -0,0 +1,165 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"gorm.io/gorm"
+
+	"viz/internal/entities"
+	libhttp "viz/internal/http"
+	"viz/internal/imageops"
+	"viz/internal/images"
+	"viz/internal/jobs"
+	"viz/internal/utils"
+)
+
+const (
+	JobTypeTilePyramid = "tile_pyramid"
+	TopicTilePyramid   = JobTypeTilePyramid
+)
+
+// TilePyramidJob builds the tile pyramid IIIF requests for a large image
+// are rendered from
+type TilePyramidJob struct {
+	Image entities.ImageAsset
+}
+
+// NewTilePyramidWorker creates the worker that builds tile pyramids. Their
+// originals are the largest images in the library, so one is built at a
+// time.
+func NewTilePyramidWorker(db *gorm.DB, wsBroker *libhttp.WSBroker) *jobs.Worker {
+	return jobs.NewWorker(JobTypeTilePyramid, TopicTilePyramid, "Tile Pyramid", 1, func(msg *message.Message) error {
+		var job TilePyramidJob
+		err := json.Unmarshal(msg.Payload, &job)
+		if err != nil {
+			return jobs.Permanent(fmt.Errorf("%s: %w", JobTypeTilePyramid, err))
+		}
+
+		job.Image, err = latestImage(db, msg, job.Image)
+		if err != nil {
+			return err
+		}
+
+		if job.Image.ImageMetadata == nil {
+			err = fmt.Errorf("job %s failed: image metadata is nil for image %s", JobTypeTilePyramid, job.Image.Uid)
+			_ = jobs.UpdateWorkerJobStatus(db, msg.UUID, jobs.WorkerJobStatusFailed, utils.StringPtr("worker_error"), utils.StringPtr(jobs.Truncate(err.Error(), 1024)), nil, nil)
+			return jobs.Permanent(err)
+		}
+
+		if wsBroker != nil {
+			wsBroker.Broadcast("job-started", map[string]any{
+				"uid":       msg.UUID,
+				"jobId":     msg.UUID,
+				"type":      JobTypeTilePyramid,
+				"topic":     JobTypeTilePyramid,
+				"image_uid": job.Image.Uid,
+				"imageId":   job.Image.Uid,
+				"filename":  job.Image.ImageMetadata.FileName,
+			})
+		}
+
+		startedAt := time.Now().UTC()
+		_ = jobs.UpdateWorkerJobStatus(db, msg.UUID, jobs.WorkerJobStatusRunning, nil, nil, &startedAt, nil)
+
+		onProgress := jobs.NewProgressCallback(
+			wsBroker,
+			msg.UUID,
+			JobTypeTilePyramid,
+			job.Image.Uid,
+			job.Image.ImageMetadata.FileName,
+		)
+
+		err = BuildTilePyramid(msg.Context(), job.Image, onProgress)
+
+		if errors.Is(err, jobs.ErrJobCancelled) {
+			finishCancelled(db, wsBroker, msg.UUID, JobTypeTilePyramid, job.Image.Uid)
+			return err
+		}
+
+		if err != nil {
+			if wsBroker != nil {
+				wsBroker.Broadcast("job-failed", map[string]any{
+					"uid":       msg.UUID,
+					"jobId":     msg.UUID,
+					"type":      JobTypeTilePyramid,
+					"topic":     JobTypeTilePyramid,
+					"image_uid": job.Image.Uid,
+					"imageId":   job.Image.Uid,
+					"error":     err.Error(),
+				})
+			}
+			_ = jobs.UpdateWorkerJobStatus(db, msg.UUID, jobs.WorkerJobStatusFailed, utils.StringPtr("worker_error"), utils.StringPtr(jobs.Truncate(err.Error(), 1024)), nil, nil)
+			return err
+		}
+
+		if wsBroker != nil {
+			wsBroker.Broadcast("job-completed", map[string]any{
+				"uid":       msg.UUID,
+				"jobId":     msg.UUID,
+				"type":      JobTypeTilePyramid,
+				"topic":     JobTypeTilePyramid,
+				"image_uid": job.Image.Uid,
+				"imageId":   job.Image.Uid,
+			})
+		}
+
+		completedAt := time.Now().UTC()
+		_ = jobs.UpdateWorkerJobStatus(db, msg.UUID, jobs.WorkerJobStatusSuccess, nil, nil, nil, &completedAt)
+
+		return nil
+	},
+	)
+}
+
+// BuildTilePyramid renders the tile pyramid of an image to a temporary
+// file, streaming the original from storage, and stores it in place of the
+// pyramid of its earlier edits
+func BuildTilePyramid(ctx context.Context, imgEnt entities.ImageAsset, onProgress func(step string, progress int)) error {
+	if err := jobs.Cancelled(ctx); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp("", "viz-pyramid-*.tif")
+	if err != nil {
+		return fmt.Errorf("failed to create tile pyramid file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpPath)
+
+	if onProgress != nil {
+		onProgress("Building tile pyramid", 10)
+	}
+
+	err = imageops.BuildPyramid(images.FileOpener(ctx, imgEnt), imgEnt, tmpPath)
+	if err != nil {
+		return err
+	}
+
+	if err := jobs.Cancelled(ctx); err != nil {
+		return err
+	}
+
+	if onProgress != nil {
+		onProgress("Saving tile pyramid", 80)
+	}
+
+	f, err := os.Open(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to open tile pyramid file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat tile pyramid file: %w", err)
+	}
+
+	return images.SavePyramid(ctx, imgEnt, f, info.Size())
+}
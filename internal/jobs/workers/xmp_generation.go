@@ -1,10 +1,11 @@
 package workers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"path"
 	"strconv"
 	"strings"
 	"time"
@@ -23,6 +24,7 @@ import (
 	"viz/internal/imageops"
 	"viz/internal/images"
 	"viz/internal/jobs"
+	"viz/internal/storage"
 	"viz/internal/utils"
 	customxmp "viz/internal/xmp"
 )
@@ -42,13 +44,18 @@ func NewXMPWorker(db *gorm.DB, wsBroker *libhttp.WSBroker) *jobs.Worker {
 		var job XMPGenerationJob
 		err := json.Unmarshal(msg.Payload, &job)
 		if err != nil {
-			return fmt.Errorf("%s: %w", JobTypeXMPGeneration, err)
+			return jobs.Permanent(fmt.Errorf("%s: %w", JobTypeXMPGeneration, err))
+		}
+
+		job.Image, err = latestImage(db, msg, job.Image)
+		if err != nil {
+			return err
 		}
 
 		if job.Image.ImageMetadata == nil {
 			err = fmt.Errorf("job %s failed: image metadata is nil for image %s", JobTypeXMPGeneration, job.Image.Uid)
 			_ = jobs.UpdateWorkerJobStatus(db, msg.UUID, jobs.WorkerJobStatusFailed, utils.StringPtr("worker_error"), utils.StringPtr(jobs.Truncate(err.Error(), 1024)), nil, nil)
-			return nil // Return nil to avoid retry loop
+			return jobs.Permanent(err)
 		}
 
 		if wsBroker != nil {
@@ -75,7 +82,12 @@ func NewXMPWorker(db *gorm.DB, wsBroker *libhttp.WSBroker) *jobs.Worker {
 			job.Image.ImageMetadata.FileName,
 		)
 
-		err = generateXMPSidecar(job.Image, onProgress)
+		err = generateXMPSidecar(msg.Context(), job.Image, onProgress)
+
+		if errors.Is(err, jobs.ErrJobCancelled) {
+			finishCancelled(db, wsBroker, msg.UUID, JobTypeXMPGeneration, job.Image.Uid)
+			return err
+		}
 
 		if err != nil {
 			if wsBroker != nil {
@@ -112,20 +124,29 @@ func NewXMPWorker(db *gorm.DB, wsBroker *libhttp.WSBroker) *jobs.Worker {
 	)
 }
 
-func generateXMPSidecar(img entities.ImageAsset, onProgress func(step string, progress int)) error {
-	originalPath := images.GetImagePath(img.Uid, img.ImageMetadata.FileName)
+// generateXMPSidecar writes an XMP sidecar next to the original image.
+// Storage never exposes a partially written object, so a cancelled job
+// never leaves a partial sidecar behind.
+func generateXMPSidecar(ctx context.Context, img entities.ImageAsset, onProgress func(step string, progress int)) error {
+	fileName := img.ImageMetadata.FileName
 	logger := jobs.Logger
 
-	if _, err := os.Stat(originalPath); os.IsNotExist(err) {
-		return fmt.Errorf("original image file not found: %s", originalPath)
+	if _, err := images.StatImage(ctx, img, fileName); err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			return fmt.Errorf("original image file not found: %s", images.ImageKey(img.Uid, fileName))
+		}
+
+		return fmt.Errorf("failed to check original image file: %w", err)
 	}
 
 	if onProgress != nil {
 		onProgress("Validating input", 5)
 	}
 
-	xmpPath := strings.TrimSuffix(originalPath, filepath.Ext(originalPath)) + ".xmp"
+	xmpName := strings.TrimSuffix(fileName, path.Ext(fileName)) + ".xmp"
 	doc := xmp.NewDocument()
+	defer doc.Close()
+
 	xmpBase := &xmpbase.XmpBase{
 		CreatorTool: "Viz Image Management System",
 	}
@@ -136,11 +157,15 @@ func generateXMPSidecar(img entities.ImageAsset, onProgress func(step string, pr
 	psModel := &customxmp.PhotoshopInfo{}
 
 	// Set SidecarForExtension to match original file extension (without dot)
-	ext := strings.TrimPrefix(filepath.Ext(originalPath), ".")
+	ext := strings.TrimPrefix(path.Ext(fileName), ".")
 	if ext != "" {
 		psModel.SidecarForExtension = ext
 	}
 
+	if err := jobs.Cancelled(ctx); err != nil {
+		return err
+	}
+
 	if onProgress != nil {
 		onProgress("Building XMP models", 20)
 	}
@@ -368,6 +393,10 @@ func generateXMPSidecar(img entities.ImageAsset, onProgress func(step string, pr
 	doc.AddModel(tiffModel)
 	doc.AddModel(psModel)
 
+	if err := jobs.Cancelled(ctx); err != nil {
+		return err
+	}
+
 	if onProgress != nil {
 		onProgress("Marshalling XMP", 80)
 	}
@@ -381,15 +410,17 @@ func generateXMPSidecar(img entities.ImageAsset, onProgress func(step string, pr
 		onProgress("Writing XMP file", 90)
 	}
 
-	if err := os.WriteFile(xmpPath, xmpData, 0644); err != nil {
-		return fmt.Errorf("failed to write XMP file: %w", err)
+	if err := jobs.Cancelled(ctx); err != nil {
+		return err
 	}
 
-	doc.Close()
+	if err := images.SaveImage(ctx, xmpData, img, xmpName); err != nil {
+		return fmt.Errorf("failed to write XMP file: %w", err)
+	}
 
 	logger.Info("generated XMP sidecar", watermill.LogFields{
 		"image_uid": img.Uid,
-		"xmp_path":  xmpPath,
+		"xmp_key":   images.ImageKey(img.Uid, xmpName),
 	})
 
 	if onProgress != nil {
@@ -0,0 +1,105 @@
+// Package maintenance holds the cron jobs that keep the library tidy: cache
+// and trash cleanup, storage stats and expired credentials.
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+
+	"viz/internal/config"
+	"viz/internal/images"
+	"viz/internal/jobs"
+	"viz/internal/storage"
+)
+
+const (
+	JobTransformCacheGC = "transform_cache_gc"
+	JobStorageStats     = "storage_stats"
+	JobTrashPurge       = "trash_purge"
+	JobOrphanSweep      = "orphan_sweep"
+	JobExpiredTokens    = "expired_token_cleanup"
+)
+
+// CronJobs returns the maintenance jobs with their default schedules. The
+// cache GC and storage stats intervals come from the config, and start out
+// paused if they're disabled there.
+func CronJobs(db *gorm.DB, logger *slog.Logger, cfg config.VizConfig, storageStats *images.StorageStatsHolder) []jobs.CronJob {
+	cacheInterval := time.Duration(cfg.Cache.CleanupIntervalMinutes) * time.Minute
+	if cacheInterval <= 0 {
+		cacheInterval = 24 * time.Hour
+	}
+
+	statsInterval := time.Duration(cfg.StorageMetrics.IntervalSeconds) * time.Second
+	if statsInterval <= 0 {
+		statsInterval = 5 * time.Minute
+	}
+
+	retention := time.Duration(cfg.Trash.RetentionDays) * 24 * time.Hour
+
+	return []jobs.CronJob{
+		{
+			Name:        JobTransformCacheGC,
+			Description: "Evicts old transform cache files and keeps the cache under its size limit",
+			Schedule:    every(cacheInterval),
+			Paused:      !cfg.Cache.GCEnabled,
+			RunOnStart:  true,
+			Run: func(ctx context.Context) error {
+				return images.ForEachLocation(func(name string, store storage.Storage) error {
+					return images.PerformTransformCacheCleanup(ctx, store, images.LibraryPrefix, logger, db, config.AppConfig.Cache, images.GetPermanentTransformHashes)
+				})
+			},
+		},
+		{
+			Name:        JobStorageStats,
+			Description: "Recalculates the storage used by the library",
+			Schedule:    every(statsInterval),
+			Paused:      !cfg.StorageMetrics.Enabled,
+			RunOnStart:  true,
+			Run: func(ctx context.Context) error {
+				return storageStats.Refresh(ctx, logger)
+			},
+		},
+		{
+			Name:        JobTrashPurge,
+			Description: fmt.Sprintf("Permanently deletes images that have been in the trash for more than %d days", cfg.Trash.RetentionDays),
+			Schedule:    "30 3 * * *",
+			Paused:      retention <= 0,
+			Run: func(ctx context.Context) error {
+				// a retention of 0 keeps the trash forever, even on manual runs
+				if retention <= 0 {
+					return nil
+				}
+
+				_, err := PurgeTrash(ctx, db, logger, time.Now().Add(-retention))
+				return err
+			},
+		},
+		{
+			Name:        JobOrphanSweep,
+			Description: "Removes image and trash folders that no longer belong to an image",
+			Schedule:    "0 4 * * 0",
+			Run: func(ctx context.Context) error {
+				_, err := SweepOrphans(ctx, db, logger, time.Now().Add(-OrphanGracePeriod))
+				return err
+			},
+		},
+		{
+			Name:        JobExpiredTokens,
+			Description: "Deletes expired sessions and download links",
+			Schedule:    "0 * * * *",
+			Run: func(ctx context.Context) error {
+				_, err := DeleteExpiredTokens(ctx, db, time.Now())
+				return err
+			},
+		},
+	}
+}
+
+// every returns an "@every" schedule for an interval, rounded to seconds
+func every(interval time.Duration) string {
+	return "@every " + interval.Round(time.Second).String()
+}
@@ -0,0 +1,117 @@
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+
+	"viz/internal/entities"
+	"viz/internal/images"
+	"viz/internal/storage"
+)
+
+// OrphanGracePeriod is how old a folder without an image has to be before
+// it's removed, so uploads that are still being saved aren't swept up
+const OrphanGracePeriod = 24 * time.Hour
+
+// SweepOrphans removes folders in the library and the trash of every
+// storage location that don't belong to any image, live or soft-deleted,
+// and weren't modified after cutoff. Copies left in the source of a storage
+// migration still belong to their image and are kept. It returns how many
+// folders were removed.
+func SweepOrphans(ctx context.Context, db *gorm.DB, logger *slog.Logger, cutoff time.Time) (int, error) {
+	removed := 0
+	err := images.ForEachLocation(func(name string, store storage.Storage) error {
+		for _, prefix := range []string{images.LibraryPrefix, images.TrashPrefix} {
+			n, err := sweepFolder(ctx, db, logger, store, prefix, cutoff)
+			removed += n
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return removed, err
+}
+
+func sweepFolder(ctx context.Context, db *gorm.DB, logger *slog.Logger, store storage.Storage, prefix string, cutoff time.Time) (int, error) {
+	candidates, err := staleFolders(ctx, store, prefix, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for start := 0; start < len(candidates); start += batchSize {
+		if err := ctx.Err(); err != nil {
+			return removed, err
+		}
+
+		batch := candidates[start:min(start+batchSize, len(candidates))]
+
+		var known []string
+		if err := db.Unscoped().Model(&entities.ImageAsset{}).Where("uid IN ?", batch).Pluck("uid", &known).Error; err != nil {
+			return removed, fmt.Errorf("failed to look up images: %w", err)
+		}
+
+		exists := make(map[string]bool, len(known))
+		for _, uid := range known {
+			exists[uid] = true
+		}
+
+		for _, name := range batch {
+			if exists[name] {
+				continue
+			}
+
+			folder := storage.Join(prefix, name)
+			if err := store.DeleteAll(ctx, folder); err != nil {
+				logger.Warn("failed to remove orphaned folder", slog.String("folder", folder), slog.Any("error", err))
+				continue
+			}
+
+			logger.Debug("removed orphaned folder", slog.String("folder", folder))
+			removed++
+		}
+	}
+
+	if removed > 0 {
+		logger.Info("removed orphaned folders", slog.String("prefix", prefix), slog.Int("count", removed))
+	}
+
+	return removed, nil
+}
+
+// staleFolders returns the names of the folders directly in prefix whose
+// newest object wasn't modified after cutoff
+func staleFolders(ctx context.Context, store storage.Storage, prefix string, cutoff time.Time) ([]string, error) {
+	newest := make(map[string]time.Time)
+	err := store.List(ctx, prefix, func(obj storage.ObjectInfo) error {
+		folder, _, ok := storage.SplitFolder(prefix, obj.Key)
+		if !ok {
+			return nil
+		}
+
+		if obj.ModTime.After(newest[folder]) {
+			newest[folder] = obj.ModTime
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+	}
+
+	var names []string
+	for name, modTime := range newest {
+		if !modTime.After(cutoff) {
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
@@ -0,0 +1,55 @@
+package maintenance
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"viz/internal/storage"
+)
+
+func TestStaleFolders(t *testing.T) {
+	root := t.TempDir()
+	store := storage.NewLocal(root)
+	ctx := context.Background()
+	now := time.Now()
+
+	files := map[string]time.Time{
+		"images/old/photo.jpg":              now.Add(-48 * time.Hour),
+		"images/older/photo.jpg":            now.Add(-72 * time.Hour),
+		"images/fresh/photo.jpg":            now,
+		"images/mixed/photo.jpg":            now.Add(-48 * time.Hour),
+		"images/mixed/transforms/thumb.jpg": now,
+		"images/stray.txt":                  now.Add(-48 * time.Hour),
+	}
+
+	for key, modTime := range files {
+		if err := storage.PutBytes(ctx, store, key, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.Chtimes(filepath.Join(root, filepath.FromSlash(key)), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names, err := staleFolders(ctx, store, "images", now.Add(-OrphanGracePeriod))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"old", "older"}) {
+		t.Errorf("staleFolders() = %v, want [old older]", names)
+	}
+}
+
+func TestStaleFoldersMissing(t *testing.T) {
+	names, err := staleFolders(context.Background(), storage.NewLocal(t.TempDir()), "missing", time.Now())
+	if err != nil || names != nil {
+		t.Errorf("staleFolders() = %v, %v, want nil, nil", names, err)
+	}
+}
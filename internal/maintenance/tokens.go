@@ -0,0 +1,30 @@
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"viz/internal/entities"
+)
+
+// DeleteExpiredTokens deletes the sessions and download links that expired
+// before now and returns how many rows were removed. API keys are left for
+// their owners to revoke, so they keep seeing why a key stopped working.
+func DeleteExpiredTokens(ctx context.Context, db *gorm.DB, now time.Time) (int64, error) {
+	db = db.WithContext(ctx)
+
+	sessions := db.Unscoped().Where("expires_at IS NOT NULL AND expires_at < ?", now).Delete(&entities.Session{})
+	if sessions.Error != nil {
+		return 0, fmt.Errorf("failed to delete expired sessions: %w", sessions.Error)
+	}
+
+	tokens := db.Unscoped().Where("expires_at IS NOT NULL AND expires_at < ?", now).Delete(&entities.DownloadToken{})
+	if tokens.Error != nil {
+		return sessions.RowsAffected, fmt.Errorf("failed to delete expired download links: %w", tokens.Error)
+	}
+
+	return sessions.RowsAffected + tokens.RowsAffected, nil
+}
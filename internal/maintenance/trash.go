@@ -0,0 +1,60 @@
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+
+	"viz/internal/entities"
+	"viz/internal/images"
+)
+
+// batchSize is how many images are loaded at a time while cleaning up
+const batchSize = 100
+
+// PurgeTrash permanently deletes images soft-deleted before cutoff along
+// with their folder in the trash, and returns how many were deleted.
+func PurgeTrash(ctx context.Context, db *gorm.DB, logger *slog.Logger, cutoff time.Time) (int, error) {
+	purged := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return purged, err
+		}
+
+		var trashed []entities.ImageAsset
+		err := db.Unscoped().Select("uid", "storage_location").
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+			Order("deleted_at asc").
+			Limit(batchSize).
+			Find(&trashed).Error
+		if err != nil {
+			return purged, fmt.Errorf("failed to find trashed images: %w", err)
+		}
+
+		if len(trashed) == 0 {
+			break
+		}
+
+		for _, img := range trashed {
+			// the row goes last so a failed removal is retried next time
+			if err := images.DeleteTrashedImage(ctx, img); err != nil {
+				return purged, fmt.Errorf("failed to remove trashed files of %s: %w", img.Uid, err)
+			}
+
+			if err := db.Unscoped().Where("uid = ?", img.Uid).Delete(&entities.ImageAsset{}).Error; err != nil {
+				return purged, fmt.Errorf("failed to delete trashed image %s: %w", img.Uid, err)
+			}
+
+			purged++
+		}
+	}
+
+	if purged > 0 {
+		logger.Info("purged images from the trash", slog.Int("count", purged), slog.Time("deleted_before", cutoff))
+	}
+
+	return purged, nil
+}
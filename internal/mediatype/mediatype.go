@@ -0,0 +1,151 @@
+// Package mediatype identifies image files by their magic bytes, whatever
+// their file name says.
+package mediatype
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Formats Detect tells apart, named like the file types of images
+const (
+	JPEG = "jpeg"
+	PNG  = "png"
+	GIF  = "gif"
+	WebP = "webp"
+	TIFF = "tiff"
+	HEIC = "heic"
+	HEIF = "heif"
+	AVIF = "avif"
+	JXL  = "jxl"
+	PSD  = "psd"
+)
+
+// Camera RAW containers that aren't plain TIFF, most RAW formats are
+const (
+	CR3 = "cr3"
+	CRW = "crw"
+	ORF = "orf"
+	RW2 = "rw2"
+	RAF = "raf"
+	MRW = "mrw"
+	X3F = "x3f"
+)
+
+// HeaderSize is how much of the start of a file Detect reads
+const HeaderSize = 256
+
+var mimeTypes = map[string]string{
+	JPEG: "image/jpeg",
+	PNG:  "image/png",
+	GIF:  "image/gif",
+	WebP: "image/webp",
+	TIFF: "image/tiff",
+	HEIC: "image/heic",
+	HEIF: "image/heif",
+	AVIF: "image/avif",
+	JXL:  "image/jxl",
+	PSD:  "image/vnd.adobe.photoshop",
+}
+
+// Detect returns the format of the file starting with head, empty when
+// it's none it knows
+func Detect(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xFF, 0xD8, 0xFF}):
+		return JPEG
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return PNG
+	case bytes.HasPrefix(head, []byte("GIF87a")), bytes.HasPrefix(head, []byte("GIF89a")):
+		return GIF
+	case len(head) >= 12 && string(head[0:4]) == "RIFF" && string(head[8:12]) == "WEBP":
+		return WebP
+	case bytes.HasPrefix(head, []byte("II*\x00")), bytes.HasPrefix(head, []byte("MM\x00*")),
+		bytes.HasPrefix(head, []byte("II+\x00")), bytes.HasPrefix(head, []byte("MM\x00+")):
+		return TIFF
+	case bytes.HasPrefix(head, []byte{0xFF, 0x0A}),
+		bytes.HasPrefix(head, []byte("\x00\x00\x00\x0cJXL \r\n\x87\n")):
+		return JXL
+	case bytes.HasPrefix(head, []byte("8BPS")):
+		return PSD
+	case bytes.HasPrefix(head, []byte("IIRO")), bytes.HasPrefix(head, []byte("IIRS")), bytes.HasPrefix(head, []byte("MMOR")):
+		return ORF
+	case bytes.HasPrefix(head, []byte("IIU\x00")):
+		return RW2
+	case bytes.HasPrefix(head, []byte("FUJIFILMCCD-RAW")):
+		return RAF
+	case bytes.HasPrefix(head, []byte("\x00MRM")):
+		return MRW
+	case bytes.HasPrefix(head, []byte("FOVb")):
+		return X3F
+	case len(head) >= 14 && string(head[6:14]) == "HEAPCCDR":
+		return CRW
+	case len(head) >= 12 && string(head[4:8]) == "ftyp":
+		return isoFormat(head)
+	default:
+		return ""
+	}
+}
+
+// isoFormat tells the ISO base media formats apart by the brands of their
+// ftyp box: the major brand first, then the compatible ones
+func isoFormat(head []byte) string {
+	size := int(binary.BigEndian.Uint32(head[0:4]))
+	if size < 16 || size > len(head) {
+		size = len(head)
+	}
+
+	brands := []string{string(head[8:12])}
+	for at := 16; at+4 <= size; at += 4 {
+		brands = append(brands, string(head[at:at+4]))
+	}
+
+	var format string
+	for _, brand := range brands {
+		switch brand {
+		case "avif", "avis":
+			return AVIF
+		case "heic", "heix", "heim", "heis", "hevc", "hevx":
+			return HEIC
+		case "crx ":
+			return CR3
+		case "mif1", "msf1":
+			format = HEIF
+		}
+	}
+
+	return format
+}
+
+// MIMEType returns the media type of a format, image/ and the format for
+// ones without a registered type
+func MIMEType(format string) string {
+	switch format {
+	case "jpg":
+		return mimeTypes[JPEG]
+	case "tif":
+		return mimeTypes[TIFF]
+	}
+
+	if mime, ok := mimeTypes[format]; ok {
+		return mime
+	}
+	return "image/" + format
+}
+
+// IsRAWContainer reports whether a camera RAW file can be in format, which
+// for most cameras is TIFF
+func IsRAWContainer(format string) bool {
+	switch format {
+	case TIFF, CR3, CRW, ORF, RW2, RAF, MRW, X3F:
+		return true
+	default:
+		return false
+	}
+}
+
+// CanAnimate reports whether a format holds animations libvips loads and
+// saves
+func CanAnimate(format string) bool {
+	return format == GIF || format == WebP
+}
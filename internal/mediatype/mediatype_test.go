@@ -0,0 +1,87 @@
+package mediatype
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// ftyp returns the start of an ISO base media file with the given brands
+func ftyp(major string, compatible ...string) []byte {
+	box := make([]byte, 16, 16+4*len(compatible))
+	copy(box[4:], "ftyp")
+	copy(box[8:], major)
+	for _, brand := range compatible {
+		box = append(box, brand...)
+	}
+	binary.BigEndian.PutUint32(box, uint32(len(box)))
+	return append(box, "\x00\x00\x00\x08meta"...)
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{"jpeg", []byte("\xff\xd8\xff\xe1\x00\x10Exif"), JPEG},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), PNG},
+		{"gif", []byte("GIF89a\x10\x00\x10\x00"), GIF},
+		{"webp", []byte("RIFF\x24\x00\x00\x00WEBPVP8X"), WebP},
+		{"tiff little endian", []byte("II*\x00\x08\x00\x00\x00"), TIFF},
+		{"tiff big endian", []byte("MM\x00*\x00\x00\x00\x08"), TIFF},
+		{"bigtiff", []byte("II+\x00\x08\x00\x00\x00"), TIFF},
+		{"jxl codestream", []byte("\xff\x0a\xfa\x1f"), JXL},
+		{"jxl container", []byte("\x00\x00\x00\x0cJXL \r\n\x87\n\x00\x00\x00\x14ftypjxl "), JXL},
+		{"psd", []byte("8BPS\x00\x01"), PSD},
+		{"iphone heic", ftyp("heic", "mif1", "heic"), HEIC},
+		{"heic by compatible brand", ftyp("mif1", "mif1", "heic"), HEIC},
+		{"avif", ftyp("avif", "avif", "mif1", "miaf"), AVIF},
+		{"avif by compatible brand", ftyp("mif1", "mif1", "avif"), AVIF},
+		{"avif sequence", ftyp("avis", "avis", "msf1"), AVIF},
+		{"plain heif", ftyp("mif1", "mif1", "miaf"), HEIF},
+		{"cr3", ftyp("crx ", "crx ", "isom"), CR3},
+		{"mp4", ftyp("isom", "isom", "mp41"), ""},
+		{"orf", []byte("IIRO\x08\x00\x00\x00"), ORF},
+		{"rw2", []byte("IIU\x00\x18\x00\x00\x00"), RW2},
+		{"raf", []byte("FUJIFILMCCD-RAW 0201"), RAF},
+		{"crw", []byte("II\x1a\x00\x00\x00HEAPCCDR"), CRW},
+		{"svg", []byte("<svg xmlns="), ""},
+		{"empty", nil, ""},
+		{"truncated riff", []byte("RIFF\x24\x00"), ""},
+	}
+	for _, tt := range tests {
+		if got := Detect(tt.head); got != tt.want {
+			t.Errorf("Detect(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMIMEType(t *testing.T) {
+	tests := map[string]string{
+		JPEG:  "image/jpeg",
+		"jpg": "image/jpeg",
+		HEIC:  "image/heic",
+		JXL:   "image/jxl",
+		PSD:   "image/vnd.adobe.photoshop",
+		"bmp": "image/bmp",
+	}
+	for format, want := range tests {
+		if got := MIMEType(format); got != want {
+			t.Errorf("MIMEType(%q) = %q, want %q", format, got, want)
+		}
+	}
+}
+
+func TestIsRAWContainer(t *testing.T) {
+	for _, format := range []string{TIFF, CR3, RAF, ORF} {
+		if !IsRAWContainer(format) {
+			t.Errorf("IsRAWContainer(%q) = false", format)
+		}
+	}
+
+	for _, format := range []string{JPEG, HEIC, PSD, ""} {
+		if IsRAWContainer(format) {
+			t.Errorf("IsRAWContainer(%q) = true", format)
+		}
+	}
+}
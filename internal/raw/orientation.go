@@ -0,0 +1,116 @@
+package raw
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// cr3MetadataSize is how much of a CR3 file is searched for its EXIF, the
+// CMT1 box is near the start of the moov box that opens the file
+const cr3MetadataSize = 1 << 20
+
+// tagOrientation is the TIFF tag of the EXIF orientation
+const tagOrientation = 0x0112
+
+// Orientation returns the EXIF orientation, 1 to 8, the camera recorded
+// for a RAW file. Embedded previews are stored as the sensor saw them and
+// don't carry it. Files without one, or in a format whose metadata isn't
+// TIFF, are 1.
+func Orientation(r io.ReadSeeker) int {
+	var header [16]byte
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return 1
+	}
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 1
+	}
+
+	switch {
+	case bytes.Equal(header[4:12], []byte("ftypcrx ")):
+		return cr3Orientation(r)
+	case bytes.HasPrefix(header[:], []byte("II")) || bytes.HasPrefix(header[:], []byte("MM")):
+		// TIFF and the variants of it ORF (IIRO) and RW2 (IIU) are
+		return tiffOrientation(r, 0)
+	}
+
+	return 1
+}
+
+// cr3Orientation reads the orientation from the TIFF header in the CMT1
+// box of a CR3 file
+func cr3Orientation(r io.ReadSeeker) int {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return 1
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(r, cr3MetadataSize))
+	if err != nil {
+		return 1
+	}
+
+	i := bytes.Index(buf, []byte("CMT1"))
+	if i < 0 {
+		return 1
+	}
+
+	return tiffOrientation(bytes.NewReader(buf), int64(i+4))
+}
+
+// tiffOrientation reads the orientation tag from the first IFD of the TIFF
+// structure starting at base, whose offsets are relative to it
+func tiffOrientation(r io.ReadSeeker, base int64) int {
+	var header [8]byte
+	if _, err := r.Seek(base, io.SeekStart); err != nil {
+		return 1
+	}
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(header[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int64(order.Uint32(header[4:8]))
+	if _, err := r.Seek(base+ifd, io.SeekStart); err != nil {
+		return 1
+	}
+
+	var count [2]byte
+	if _, err := io.ReadFull(r, count[:]); err != nil {
+		return 1
+	}
+
+	n := int(order.Uint16(count[:]))
+	if n > 1000 {
+		return 1
+	}
+
+	entries := make([]byte, 12*n)
+	if _, err := io.ReadFull(r, entries); err != nil {
+		return 1
+	}
+
+	for i := range n {
+		entry := entries[12*i : 12*i+12]
+		if order.Uint16(entry[0:2]) != tagOrientation {
+			continue
+		}
+
+		// a SHORT, stored in the first bytes of the value field
+		orientation := int(order.Uint16(entry[8:10]))
+		if orientation < 1 || orientation > 8 {
+			return 1
+		}
+		return orientation
+	}
+
+	return 1
+}
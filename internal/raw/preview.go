@@ -0,0 +1,232 @@
+// Package raw reads what's needed from camera RAW files without decoding
+// their sensor data: the JPEG previews cameras embed in them and the
+// orientation the camera recorded.
+package raw
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// ErrNoPreview is returned for RAW files without a JPEG preview that can
+// be decoded
+var ErrNoPreview = errors.New("raw file has no embedded preview")
+
+// maxPreviewSize bounds the JPEGs read from a RAW file, the largest
+// embedded previews are full size camera JPEGs of a few tens of megabytes
+const maxPreviewSize = 64 << 20
+
+// errNotPreview marks a JPEG stream that isn't usable as a preview, such
+// as the lossless JPEG some cameras store their sensor data as
+var errNotPreview = errors.New("not a preview")
+
+// Preview is a JPEG embedded in a RAW file
+type Preview struct {
+	Data          []byte
+	Width, Height int
+}
+
+// ExtractPreview returns the largest JPEG embedded in a RAW file. The file
+// is read once from start to end, so it works on a stream of any of the
+// formats cameras write: TIFF based ones like NEF, ARW, CR2 and DNG keep
+// their previews in IFDs, CR3 in its own boxes, and all of them store the
+// JPEGs whole.
+func ExtractPreview(r io.Reader) (*Preview, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+
+	var best *Preview
+	for {
+		if err := findSOI(br); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+
+		p, err := readJPEG(br)
+		if errors.Is(err, errNotPreview) {
+			continue
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if best == nil || p.Width*p.Height > best.Width*best.Height {
+			best = p
+		}
+	}
+
+	if best == nil {
+		return nil, ErrNoPreview
+	}
+
+	return best, nil
+}
+
+// findSOI reads up to and including the next JPEG start of image marker
+func findSOI(br *bufio.Reader) error {
+	for {
+		if _, err := br.ReadSlice(0xFF); err != nil {
+			if errors.Is(err, bufio.ErrBufferFull) {
+				continue
+			}
+			return err
+		}
+
+		next, err := br.Peek(2)
+		if err != nil {
+			return err
+		}
+
+		// the marker after SOI starts with 0xFF too, which keeps random
+		// 0xFF 0xD8 pairs in sensor data from being taken for a JPEG
+		if next[0] == 0xD8 && next[1] == 0xFF {
+			_, err := br.Discard(1)
+			return err
+		}
+	}
+}
+
+// readJPEG reads the segments of a JPEG whose start of image marker has
+// just been read, up to its end of image marker. The entropy coded data
+// after a start of scan is copied up to the next marker, 0xFF bytes in it
+// are always followed by 0x00 or a restart marker.
+func readJPEG(br *bufio.Reader) (*Preview, error) {
+	p := &Preview{Data: []byte{0xFF, 0xD8}}
+
+	// a scan ends having read the 0xFF of the marker after it
+	afterScan := false
+	for {
+		if len(p.Data) > maxPreviewSize {
+			return nil, errNotPreview
+		}
+
+		if !afterScan {
+			b, err := br.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if b != 0xFF {
+				return nil, errNotPreview
+			}
+		}
+		afterScan = false
+
+		marker, err := br.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		// fill bytes before a marker
+		for marker == 0xFF {
+			if marker, err = br.ReadByte(); err != nil {
+				return nil, err
+			}
+		}
+
+		switch {
+		case marker == 0xD9:
+			p.Data = append(p.Data, 0xFF, 0xD9)
+			if p.Width == 0 || p.Height == 0 {
+				return nil, errNotPreview
+			}
+			return p, nil
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			// markers without a segment
+			p.Data = append(p.Data, 0xFF, marker)
+			continue
+		case marker == 0x00 || marker == 0xD8:
+			return nil, errNotPreview
+		}
+
+		segment, err := readSegment(br)
+		if err != nil {
+			return nil, err
+		}
+
+		switch marker {
+		case 0xC0, 0xC1, 0xC2:
+			// baseline, extended and progressive frames, the rest are
+			// lossless or hierarchical and not previews
+			if len(segment) < 7 {
+				return nil, errNotPreview
+			}
+			p.Height = int(binary.BigEndian.Uint16(segment[3:5]))
+			p.Width = int(binary.BigEndian.Uint16(segment[5:7]))
+		case 0xC3, 0xC5, 0xC6, 0xC7, 0xC9, 0xCA, 0xCB, 0xCD, 0xCE, 0xCF:
+			return nil, errNotPreview
+		}
+
+		p.Data = append(p.Data, 0xFF, marker)
+		p.Data = append(p.Data, segment...)
+
+		if marker == 0xDA {
+			if err := readScan(br, p); err != nil {
+				return nil, err
+			}
+			afterScan = true
+		}
+	}
+}
+
+// readSegment reads the length prefixed body of a marker segment,
+// returned with its length
+func readSegment(br *bufio.Reader) ([]byte, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(br, length[:]); err != nil {
+		return nil, err
+	}
+
+	n := int(binary.BigEndian.Uint16(length[:]))
+	if n < 2 {
+		return nil, errNotPreview
+	}
+
+	segment := make([]byte, n)
+	copy(segment, length[:])
+	if _, err := io.ReadFull(br, segment[2:]); err != nil {
+		return nil, err
+	}
+
+	return segment, nil
+}
+
+// readScan copies entropy coded data up to the marker after it, leaving
+// all but the marker's 0xFF to be read
+func readScan(br *bufio.Reader, p *Preview) error {
+	for {
+		chunk, err := br.ReadSlice(0xFF)
+		p.Data = append(p.Data, chunk...)
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(p.Data) > maxPreviewSize {
+			return errNotPreview
+		}
+
+		next, err := br.Peek(1)
+		if err != nil {
+			return err
+		}
+
+		if next[0] == 0x00 || (next[0] >= 0xD0 && next[0] <= 0xD7) {
+			p.Data = append(p.Data, next[0])
+			if _, err := br.Discard(1); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// a marker, its 0xFF is added back by readJPEG
+		p.Data = p.Data[:len(p.Data)-1]
+		return nil
+	}
+}
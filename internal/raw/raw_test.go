@@ -0,0 +1,125 @@
+package raw
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// testJPEG encodes a w x h JPEG
+func testJPEG(t *testing.T, w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := range w {
+		for y := range h {
+			img.Set(x, y, color.RGBA{R: uint8(x * 7), G: uint8(y * 13), B: uint8(x ^ y), A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatalf("Failed to encode test JPEG: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+// testTIFFHeader returns a little endian TIFF header with a first IFD
+// holding just an orientation
+func testTIFFHeader(orientation int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("II*\x00")
+	binary.Write(&buf, binary.LittleEndian, uint32(8))
+	binary.Write(&buf, binary.LittleEndian, uint16(1))
+	binary.Write(&buf, binary.LittleEndian, uint16(tagOrientation))
+	binary.Write(&buf, binary.LittleEndian, uint16(3))
+	binary.Write(&buf, binary.LittleEndian, uint32(1))
+	binary.Write(&buf, binary.LittleEndian, uint16(orientation))
+	binary.Write(&buf, binary.LittleEndian, uint16(0))
+	binary.Write(&buf, binary.LittleEndian, uint32(0))
+	return buf.Bytes()
+}
+
+// testLosslessJPEG is the start of a lossless JPEG like the sensor data of
+// CR2 and DNG files, followed by data that isn't a JPEG
+func testLosslessJPEG() []byte {
+	data := []byte{0xFF, 0xD8, 0xFF, 0xC3, 0x00, 0x0B, 0x08, 0x10, 0x00, 0x10, 0x00, 0x01, 0x01, 0x11, 0x00}
+	return append(data, bytes.Repeat([]byte{0xFF, 0xD8, 0x12, 0x34}, 64)...)
+}
+
+func TestExtractPreview(t *testing.T) {
+	thumb := testJPEG(t, 32, 24)
+	preview := testJPEG(t, 320, 240)
+
+	var file bytes.Buffer
+	file.Write(testTIFFHeader(6))
+	file.Write(bytes.Repeat([]byte{0x00, 0xFF, 0x12}, 100))
+	file.Write(thumb)
+	file.Write(testLosslessJPEG())
+	file.Write(preview)
+	file.Write(bytes.Repeat([]byte{0xAB}, 100))
+
+	p, err := ExtractPreview(bytes.NewReader(file.Bytes()))
+	if err != nil {
+		t.Fatalf("ExtractPreview() = %v", err)
+	}
+
+	if p.Width != 320 || p.Height != 240 {
+		t.Errorf("ExtractPreview() is %dx%d, want the 320x240 preview", p.Width, p.Height)
+	}
+
+	if !bytes.Equal(p.Data, preview) {
+		t.Errorf("ExtractPreview() returned %d bytes, want the %d of the preview", len(p.Data), len(preview))
+	}
+
+	if _, err := jpeg.Decode(bytes.NewReader(p.Data)); err != nil {
+		t.Errorf("preview doesn't decode: %v", err)
+	}
+}
+
+func TestExtractPreviewNone(t *testing.T) {
+	var file bytes.Buffer
+	file.Write(testTIFFHeader(1))
+	file.Write(testLosslessJPEG())
+
+	if _, err := ExtractPreview(bytes.NewReader(file.Bytes())); !errors.Is(err, ErrNoPreview) {
+		t.Errorf("ExtractPreview() = %v, want ErrNoPreview", err)
+	}
+
+	// cut off before its end
+	truncated := testJPEG(t, 64, 64)
+	truncated = truncated[:len(truncated)/2]
+	if _, err := ExtractPreview(bytes.NewReader(truncated)); !errors.Is(err, ErrNoPreview) {
+		t.Errorf("ExtractPreview() of a truncated JPEG = %v, want ErrNoPreview", err)
+	}
+}
+
+func TestOrientation(t *testing.T) {
+	var cr3 bytes.Buffer
+	cr3.Write([]byte{0x00, 0x00, 0x00, 0x18})
+	cr3.WriteString("ftypcrx ")
+	cr3.Write(bytes.Repeat([]byte{0x00}, 200))
+	cr3.Write([]byte{0x00, 0x00, 0x00, 0x30})
+	cr3.WriteString("CMT1")
+	cr3.Write(testTIFFHeader(8))
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"tiff", append(testTIFFHeader(6), testJPEG(t, 8, 8)...), 6},
+		{"cr3", cr3.Bytes(), 8},
+		{"unknown", []byte("FUJIFILMCCD-RAW 0201FF383501"), 1},
+		{"invalid", testTIFFHeader(12), 1},
+		{"short", []byte("II*"), 1},
+	}
+	for _, tt := range tests {
+		if got := Orientation(bytes.NewReader(tt.data)); got != tt.want {
+			t.Errorf("Orientation(%s) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package search
+
+import (
+	"strings"
+)
+
+// Node is a node in a parsed search query. Queries are made of terms
+// (free text or key:value filters) combined with AND, OR and NOT.
+type Node interface {
+	// String renders the node back into query syntax. The output is
+	// normalised (explicit operators, parentheses around groups) so it can
+	// be used for comparisons and logging.
+	String() string
+}
+
+// AndNode matches when all of its children match. Adjacent terms without
+// an operator between them are implicitly AND-ed.
+type AndNode struct {
+	Children []Node
+}
+
+// OrNode matches when any of its children match
+type OrNode struct {
+	Children []Node
+}
+
+// NotNode matches when its child does not
+type NotNode struct {
+	Child Node
+}
+
+// TermNode is a single free text term (Key is empty) or a key:value filter
+type TermNode struct {
+	Key    string
+	Value  string
+	Quoted bool
+}
+
+func (n *AndNode) String() string {
+	return joinNodes(n.Children, " AND ")
+}
+
+func (n *OrNode) String() string {
+	return joinNodes(n.Children, " OR ")
+}
+
+func (n *NotNode) String() string {
+	return "-" + n.Child.String()
+}
+
+func (n *TermNode) String() string {
+	value := n.Value
+	if n.Quoted || strings.ContainsAny(value, " \t()") {
+		value = `"` + value + `"`
+	}
+
+	if n.Key == "" {
+		return value
+	}
+
+	return n.Key + ":" + value
+}
+
+// IsText reports whether the term is free text rather than a key:value filter
+func (n *TermNode) IsText() bool {
+	return n.Key == ""
+}
+
+func joinNodes(nodes []Node, sep string) string {
+	parts := make([]string, 0, len(nodes))
+	for _, child := range nodes {
+		parts = append(parts, child.String())
+	}
+
+	return "(" + strings.Join(parts, sep) + ")"
+}
+
+// Walk calls fn for every node in the tree, parents before children.
+// Returning false from fn skips that node's children.
+func Walk(node Node, fn func(Node) bool) {
+	if node == nil || !fn(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *AndNode:
+		for _, child := range n.Children {
+			Walk(child, fn)
+		}
+	case *OrNode:
+		for _, child := range n.Children {
+			Walk(child, fn)
+		}
+	case *NotNode:
+		Walk(n.Child, fn)
+	}
+}
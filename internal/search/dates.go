@@ -0,0 +1,147 @@
+package search
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateSpan is the half-open interval [Start, End) a date expression covers.
+// A zero Start or End means the span is unbounded on that side.
+type dateSpan struct {
+	Start time.Time
+	End   time.Time
+}
+
+// relativeDate matches expressions like "7 days ago", "2 weeks ago" or "3d ago"
+var relativeDate = regexp.MustCompile(`^(\d+)\s*(minute|min|m|hour|hr|h|day|d|week|wk|w|month|mo|year|yr|y)s?\s+ago$`)
+
+// absoluteLayouts are tried in order. Each layout's precision decides how
+// long the resulting span is, e.g. "2024-06" covers the whole of June.
+var absoluteLayouts = []struct {
+	layout string
+	next   func(time.Time) time.Time
+}{
+	{time.RFC3339, func(t time.Time) time.Time { return t.Add(time.Second) }},
+	{"2006-01-02T15:04:05", func(t time.Time) time.Time { return t.Add(time.Second) }},
+	{"2006-01-02T15:04", func(t time.Time) time.Time { return t.Add(time.Minute) }},
+	{"2006-01-02", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }},
+	{"2006/01/02", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }},
+	{"02-01-2006", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }},
+	{"2006-01", func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }},
+	{"2006", func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }},
+}
+
+// resolveDate turns a date expression into the span of time it covers,
+// resolved in loc relative to now. It understands:
+//
+//   - absolute dates: 2024, 2024-06, 2024-06-15, 15-06-2024, 2024-06-15T10:30
+//   - keywords: today, yesterday, this/last week, this/last month, this/last year
+//   - relative dates: "7 days ago", "2 weeks ago", "3h ago"
+//   - ranges of any of the above: 2024-01..2024-03, 2024.., ..yesterday
+func resolveDate(value string, now time.Time, loc *time.Location) (dateSpan, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return dateSpan{}, false
+	}
+
+	if low, high, ok := strings.Cut(value, ".."); ok {
+		var span dateSpan
+
+		if strings.TrimSpace(low) != "" {
+			from, ok := resolveDate(low, now, loc)
+			if !ok {
+				return dateSpan{}, false
+			}
+
+			span.Start = from.Start
+		}
+
+		if strings.TrimSpace(high) != "" {
+			to, ok := resolveDate(high, now, loc)
+			if !ok {
+				return dateSpan{}, false
+			}
+
+			span.End = to.End
+		}
+
+		if span.Start.IsZero() && span.End.IsZero() {
+			return dateSpan{}, false
+		}
+
+		return span, true
+	}
+
+	keyword := strings.ToLower(value)
+	now = now.In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	switch keyword {
+	case "now":
+		return dateSpan{Start: now, End: now.Add(time.Second)}, true
+	case "today":
+		return dateSpan{Start: today, End: today.AddDate(0, 0, 1)}, true
+	case "yesterday":
+		return dateSpan{Start: today.AddDate(0, 0, -1), End: today}, true
+	case "this week", "last week":
+		// weeks start on Monday
+		start := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		if keyword == "last week" {
+			start = start.AddDate(0, 0, -7)
+		}
+
+		return dateSpan{Start: start, End: start.AddDate(0, 0, 7)}, true
+	case "this month", "last month":
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+		if keyword == "last month" {
+			start = start.AddDate(0, -1, 0)
+		}
+
+		return dateSpan{Start: start, End: start.AddDate(0, 1, 0)}, true
+	case "this year", "last year":
+		start := time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, loc)
+		if keyword == "last year" {
+			start = start.AddDate(-1, 0, 0)
+		}
+
+		return dateSpan{Start: start, End: start.AddDate(1, 0, 0)}, true
+	}
+
+	if m := relativeDate.FindStringSubmatch(keyword); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return dateSpan{}, false
+		}
+
+		var t time.Time
+		switch m[2] {
+		case "minute", "min", "m":
+			t = now.Add(-time.Duration(n) * time.Minute).Truncate(time.Minute)
+			return dateSpan{Start: t, End: t.Add(time.Minute)}, true
+		case "hour", "hr", "h":
+			t = now.Add(-time.Duration(n) * time.Hour).Truncate(time.Hour)
+			return dateSpan{Start: t, End: t.Add(time.Hour)}, true
+		case "day", "d":
+			t = today.AddDate(0, 0, -n)
+		case "week", "wk", "w":
+			t = today.AddDate(0, 0, -7*n)
+		case "month", "mo":
+			t = today.AddDate(0, -n, 0)
+		case "year", "yr", "y":
+			t = today.AddDate(-n, 0, 0)
+		}
+
+		// anything a day or longer ago covers that whole calendar day
+		return dateSpan{Start: t, End: t.AddDate(0, 0, 1)}, true
+	}
+
+	for _, l := range absoluteLayouts {
+		if t, err := time.ParseInLocation(l.layout, value, loc); err == nil {
+			return dateSpan{Start: t, End: l.next(t)}, true
+		}
+	}
+
+	return dateSpan{}, false
+}
@@ -1,164 +1,468 @@
 package search
 
 import (
+	"encoding/json"
 	"fmt"
-	"viz/internal/entities"
 	"regexp"
 	"strings"
+	"time"
+	"viz/internal/entities"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-type Engine struct{}
+// filterFunc compiles the value of a key:value filter into a SQL condition.
+// Returning nil means the value isn't valid for that filter and the term
+// is ignored.
+type filterFunc func(value string) *clause.Expr
+
+// dateFilter compiles a date expression, already resolved to the span of
+// time it covers, into a SQL condition. op is the comparison operator the
+// value was prefixed with, or "=" for none.
+type dateFilter func(op string, span dateSpan) *clause.Expr
+
+// fieldSet describes how the terms of a query map onto one table
+type fieldSet struct {
+	text    func(term *TermNode) *clause.Expr
+	filters map[string]filterFunc
+	dates   map[string]dateFilter
+}
+
+type Engine struct {
+	// Location is the timezone date expressions are resolved in
+	Location *time.Location
+	// Now is the reference time for relative dates such as "yesterday"
+	Now func() time.Time
+}
 
 func NewEngine() *Engine {
-	return &Engine{}
+	return &Engine{Location: time.UTC, Now: time.Now}
 }
 
-// Apply applies the search criteria to the Image query
-func (e *Engine) Apply(db *gorm.DB, criteria SearchCriteria) *gorm.DB {
-	query := db.Model(&entities.ImageAsset{})
+// WithLocation returns a copy of the engine that resolves dates in loc,
+// usually the user's timezone setting. A nil loc keeps the current one.
+func (e *Engine) WithLocation(loc *time.Location) *Engine {
+	copied := *e
+	if loc != nil {
+		copied.Location = loc
+	}
+
+	return &copied
+}
+
+// Apply applies the search query to the Image query
+func (e *Engine) Apply(db *gorm.DB, query *Query) *gorm.DB {
+	return e.compiler(imageFields).apply(db.Model(&entities.ImageAsset{}), query)
+}
+
+// ApplyCollections applies the search query to the Collection query
+func (e *Engine) ApplyCollections(db *gorm.DB, query *Query) *gorm.DB {
+	return e.compiler(collectionFields).apply(db.Model(&entities.Collection{}), query)
+}
 
-	// 1. Text Search (Name OR Description OR Keywords OR EXIF Make/Model)
-	if len(criteria.Text) > 0 {
-		term := "%" + strings.Join(criteria.Text, " ") + "%"
-		// Postgres ILIKE for case-insensitive search
-		// Note: accessing JSONB array elements as text for searching might require different syntax depending on exact requirement
-		// For keywords (JSON array), check if the array contains the value or convert to text.
-		// Here we attempt a broad text match.
-		query = query.Where(
-			"name ILIKE ? OR description ILIKE ? OR image_metadata::text ILIKE ? OR exif::text ILIKE ?",
-			term, term, term, term,
-		)
+func (e *Engine) compiler(fields fieldSet) *compiler {
+	loc := e.Location
+	if loc == nil {
+		loc = time.UTC
 	}
 
-	// 2. Metadata Filters
-	if val, ok := criteria.Filters["rating"]; ok {
-		op, num := parseOperator(val)
-		// Cast JSONB string to numeric for comparison
-		query = query.Where(fmt.Sprintf("(image_metadata->>'rating')::numeric %s ?", op), num)
+	now := time.Now
+	if e.Now != nil {
+		now = e.Now
 	}
 
-	// 3. EXIF Filters
-	exifKeys := []string{"iso", "f_number", "make", "model", "f", "aperture"} // Added 'f' and 'aperture' to be explicitly handled
-	for _, key := range exifKeys {
-		if val, ok := criteria.Filters[key]; ok {
-			dbKey := key
-			if key == "f" || key == "aperture" { // Map 'f' and 'aperture' to 'f_number' in DB
-				dbKey = "f_number"
+	return &compiler{fields: fields, loc: loc, now: now()}
+}
+
+// compiler turns a query tree into SQL for one fieldSet. Relative dates
+// are all resolved against the same instant.
+type compiler struct {
+	fields fieldSet
+	loc    *time.Location
+	now    time.Time
+	// strict makes filters another entity type understands, but this one
+	// doesn't, match nothing instead of being ignored. Used when several
+	// types are searched at once so iso:>=1600 doesn't match every user.
+	strict bool
+}
+
+var imageFields = fieldSet{
+	// Text Search (Name, Description, Keywords, Camera and Lens) against the
+	// maintained tsvector column, see EnsureSearchIndex
+	text: func(term *TermNode) *clause.Expr {
+		tsq := tsQuery(term)
+		return &clause.Expr{SQL: "search_vector @@ " + tsq.SQL, Vars: tsq.Vars}
+	},
+	filters: map[string]filterFunc{
+		// Cast JSONB string to numeric for comparison
+		"rating":   numericFilter("(image_metadata->>'rating')::numeric"),
+		"iso":      numericFilter("exif_iso"),
+		"f_number": numericFilter("exif_f_number"),
+		"f":        numericFilter("exif_f_number"),
+		"aperture": numericFilter("exif_f_number"),
+		"focal":    numericFilter("exif_focal_length"),
+		"focal35":  numericFilter("exif_focal_length_35mm"),
+		"shutter":  numericFilter("exif_exposure_time"),
+		"exposure": numericFilter("exif_exposure_time"),
+		"width":    numericFilter("width"),
+		"height":   numericFilter("height"),
+		"make":     exifContains("make"),
+		"model":    exifContains("model"),
+		"lens":     exifContains("lens_make", "lens_model"),
+		"orientation": func(value string) *clause.Expr {
+			switch strings.ToLower(value) {
+			case "landscape":
+				return &clause.Expr{SQL: "width > height"}
+			case "portrait":
+				return &clause.Expr{SQL: "height > width"}
+			case "square":
+				return &clause.Expr{SQL: "width = height"}
 			}
-			query = query.Where(fmt.Sprintf("exif->>'%s' = ?", dbKey), val)
-		}
-	}
 
-	// Aspect Ratio / Orientation
-	if val, ok := criteria.Filters["orientation"]; ok {
-		switch strings.ToLower(val) {
-		case "landscape":
-			query = query.Where("width > height")
-		case "portrait":
-			query = query.Where("height > width")
-		case "square":
-			query = query.Where("width = height")
-		}
+			return nil
+		},
+		"ext":        fileTypeEquals,
+		"type":       fileTypeEquals,
+		"owner":      ownerEquals,
+		"is":         visibilityEquals,
+		"favourited": favouritedEquals,
+		"favorite":   favouritedEquals,
+	},
+	dates: map[string]dateFilter{
+		"after":    dateCompare("taken_at", ">="),
+		"before":   dateCompare("taken_at", "<"),
+		"date":     dateCompare("taken_at", "="),
+		"taken":    dateCompare("taken_at", "="),
+		"year":     dateCompare("taken_at", "="),
+		"month":    dateCompare("taken_at", "="),
+		"uploaded": dateCompare("created_at", "="),
+	},
+}
+
+var collectionFields = fieldSet{
+	text: func(term *TermNode) *clause.Expr {
+		like := "%" + term.Value + "%"
+		return &clause.Expr{SQL: "(collections.name ILIKE ? OR collections.description ILIKE ?)", Vars: []any{like, like}}
+	},
+	filters: map[string]filterFunc{
+		"has":        collectionHas,
+		"contains":   collectionContains,
+		"count":      numericFilter("collections.image_count"),
+		"owner":      ownerEquals,
+		"is":         collectionVisibilityEquals,
+		"favourited": nullableBoolEquals("collections.favourited"),
+		"favorite":   nullableBoolEquals("collections.favourited"),
+	},
+	dates: map[string]dateFilter{
+		"after":   dateCompare("collections.created_at", ">="),
+		"before":  dateCompare("collections.created_at", "<"),
+		"date":    dateCompare("collections.created_at", "="),
+		"created": dateCompare("collections.created_at", "="),
+		"updated": dateCompare("collections.updated_at", "="),
+	},
+}
+
+var userFields = fieldSet{
+	text: func(term *TermNode) *clause.Expr {
+		like := "%" + term.Value + "%"
+		return &clause.Expr{SQL: "(users.username ILIKE ? OR users.first_name ILIKE ? OR users.last_name ILIKE ?)", Vars: []any{like, like, like}}
+	},
+	dates: map[string]dateFilter{
+		"joined": dateCompare("users.created_at", "="),
+	},
+}
+
+// collectionMembers expands a collection's images JSONB into one row per
+// image. Anything other than an array (e.g. a JSON null) has no members.
+const collectionMembers = "jsonb_array_elements(CASE WHEN jsonb_typeof(collections.images) = 'array' THEN collections.images ELSE '[]'::jsonb END)"
+
+// collectionHas matches collections that include an image, has:image:<uid>.
+// Smart collections only match images added to them directly.
+func collectionHas(value string) *clause.Expr {
+	kind, uid, ok := strings.Cut(value, ":")
+	if !ok || strings.ToLower(kind) != "image" || uid == "" {
+		return nil
 	}
 
-	// File type
-	if val, ok := criteria.Filters["ext"]; ok {
-		query = query.Where("image_metadata->>'file_type' = ?", val)
+	member, err := json.Marshal([]map[string]string{{"uid": uid}})
+	if err != nil {
+		return nil
 	}
-	if val, ok := criteria.Filters["type"]; ok {
-		query = query.Where("image_metadata->>'file_type' = ?", val)
+
+	return &clause.Expr{SQL: "collections.images @> ?::jsonb", Vars: []any{string(member)}}
+}
+
+// collectionContains matches collections holding at least one image tagged
+// with the keyword, compared case-insensitively
+func collectionContains(value string) *clause.Expr {
+	return &clause.Expr{
+		SQL: "EXISTS (SELECT 1 FROM " + collectionMembers + " AS member " +
+			"JOIN images ON images.uid = member->>'uid' AND images.deleted_at IS NULL " +
+			"WHERE jsonb_typeof(images.image_metadata->'keywords') = 'array' " +
+			"AND EXISTS (SELECT 1 FROM jsonb_array_elements_text(images.image_metadata->'keywords') AS keyword WHERE lower(keyword) = lower(?)))",
+		Vars: []any{value},
 	}
+}
 
-	// 4. User Filters (e.g. owner:john)
-	if user, ok := criteria.Filters["owner"]; ok {
-		query = query.Joins("JOIN users ON users.uid = images.owner_id").
-			Where("users.username = ?", user)
+// exifContains matches EXIF string values case-insensitively, so make:canon
+// finds "Canon" and lens:"RF 50mm" finds "RF 50mm F1.8 STM"
+func exifContains(dbKeys ...string) filterFunc {
+	return func(value string) *clause.Expr {
+		like := "%" + value + "%"
+
+		parts := make([]string, 0, len(dbKeys))
+		vars := make([]any, 0, len(dbKeys))
+		for _, key := range dbKeys {
+			parts = append(parts, fmt.Sprintf("exif->>'%s' ILIKE ?", key))
+			vars = append(vars, like)
+		}
+
+		if len(parts) == 1 {
+			return &clause.Expr{SQL: parts[0], Vars: vars}
+		}
+
+		return &clause.Expr{SQL: "(" + strings.Join(parts, " OR ") + ")", Vars: vars}
 	}
+}
 
-	// Status
-	if val, ok := criteria.Filters["is"]; ok {
-		switch val {
-		case "private":
-			query = query.Where("private = ?", true)
-		case "public":
-			query = query.Where("private = ?", false)
+// numericFilter compares a numeric column against a value like "1600",
+// ">=1600", "<1/60" or "f/2.8", or a range like "24..70", "..2.8" or "1600..".
+// Ranges are inclusive. Values are normalised with ParseExifNumber.
+func numericFilter(column string) filterFunc {
+	return func(value string) *clause.Expr {
+		if low, high, ok := strings.Cut(value, ".."); ok {
+			lowNum, hasLow := ParseExifNumber(low)
+			highNum, hasHigh := ParseExifNumber(high)
+
+			switch {
+			case hasLow && hasHigh:
+				return &clause.Expr{SQL: fmt.Sprintf("(%[1]s >= ? AND %[1]s <= ?)", column), Vars: []any{lowNum, highNum}}
+			case hasLow && strings.TrimSpace(high) == "":
+				return &clause.Expr{SQL: column + " >= ?", Vars: []any{lowNum}}
+			case hasHigh && strings.TrimSpace(low) == "":
+				return &clause.Expr{SQL: column + " <= ?", Vars: []any{highNum}}
+			}
+
+			return nil
 		}
+
+		op, raw := parseOperator(value)
+		num, ok := ParseExifNumber(raw)
+		if !ok {
+			return nil
+		}
+
+		return &clause.Expr{SQL: fmt.Sprintf("%s %s ?", column, op), Vars: []any{num}}
 	}
+}
 
-	// Favourited
-	if val, ok := criteria.Filters["favourited"]; ok {
-		switch val {
-		case "true":
-			query = query.Where("favourited = ?", true)
-		case "false":
-			query = query.Where("favourited = ? OR favourited IS NULL", false)
+func fileTypeEquals(value string) *clause.Expr {
+	return &clause.Expr{SQL: "image_metadata->>'file_type' = ?", Vars: []any{value}}
+}
+
+// dateCompare compares a timestamp column against a resolved date span.
+// With "=" the column must fall inside the span, otherwise the operator
+// is applied to the span as a whole: >2024 means from 2025 onwards and
+// <=2024-06 means up to the end of June. fixedOp, when not "=", is used
+// in place of any operator written in the query.
+func dateCompare(column string, fixedOp string) dateFilter {
+	return func(op string, span dateSpan) *clause.Expr {
+		if fixedOp != "=" {
+			op = fixedOp
+		}
+
+		var bound time.Time
+		switch op {
+		case ">=", "<":
+			bound = span.Start
+		case ">", "<=":
+			bound = span.End
+			if op == ">" {
+				op = ">="
+			} else {
+				op = "<"
+			}
+		default:
+			switch {
+			case span.Start.IsZero():
+				return &clause.Expr{SQL: column + " < ?", Vars: []any{span.End}}
+			case span.End.IsZero():
+				return &clause.Expr{SQL: column + " >= ?", Vars: []any{span.Start}}
+			}
+
+			return &clause.Expr{SQL: fmt.Sprintf("(%[1]s >= ? AND %[1]s < ?)", column), Vars: []any{span.Start, span.End}}
 		}
-	} else if val, ok := criteria.Filters["favorite"]; ok {
-		switch val {
+
+		if bound.IsZero() {
+			return nil
+		}
+
+		return &clause.Expr{SQL: fmt.Sprintf("%s %s ?", column, op), Vars: []any{bound}}
+	}
+}
+
+// ownerEquals uses a subquery rather than a JOIN so that it can be negated
+// and OR-ed with other terms
+func ownerEquals(value string) *clause.Expr {
+	return &clause.Expr{SQL: "owner_id IN (SELECT uid FROM users WHERE users.username = ?)", Vars: []any{value}}
+}
+
+func visibilityEquals(value string) *clause.Expr {
+	switch value {
+	case "private":
+		return &clause.Expr{SQL: "private = ?", Vars: []any{true}}
+	case "public":
+		return &clause.Expr{SQL: "private = ?", Vars: []any{false}}
+	}
+
+	return nil
+}
+
+// collectionVisibilityEquals treats collections with no private flag as
+// public, the same way favourited:false includes ones never favourited
+func collectionVisibilityEquals(value string) *clause.Expr {
+	switch value {
+	case "private":
+		return &clause.Expr{SQL: "collections.private = ?", Vars: []any{true}}
+	case "public":
+		return &clause.Expr{SQL: "(collections.private = ? OR collections.private IS NULL)", Vars: []any{false}}
+	}
+
+	return nil
+}
+
+func favouritedEquals(value string) *clause.Expr {
+	return nullableBoolEquals("favourited")(value)
+}
+
+// nullableBoolEquals compares a nullable boolean column, where NULL
+// counts as false
+func nullableBoolEquals(column string) filterFunc {
+	return func(value string) *clause.Expr {
+		switch value {
 		case "true":
-			query = query.Where("favourited = ?", true)
+			return &clause.Expr{SQL: column + " = ?", Vars: []any{true}}
 		case "false":
-			query = query.Where("favourited = ? OR favourited IS NULL", false)
+			return &clause.Expr{SQL: fmt.Sprintf("(%[1]s = ? OR %[1]s IS NULL)", column), Vars: []any{false}}
 		}
+
+		return nil
+	}
+}
+
+// apply compiles the query tree into WHERE conditions. A top-level AND
+// is added as separate conditions so GORM chains them as usual.
+func (c *compiler) apply(db *gorm.DB, query *Query) *gorm.DB {
+	if query == nil || query.Root == nil {
+		return db
 	}
 
-	// 5. Date Filters
-	if !criteria.DateRange.Min.IsZero() {
-		query = query.Where("taken_at >= ?", criteria.DateRange.Min)
+	nodes := []Node{query.Root}
+	if and, ok := query.Root.(*AndNode); ok {
+		nodes = and.Children
 	}
-	if !criteria.DateRange.Max.IsZero() {
-		query = query.Where("taken_at <= ?", criteria.DateRange.Max)
+
+	for _, node := range nodes {
+		if expr := c.compile(node); expr != nil {
+			db = db.Where(*expr)
+		}
 	}
 
-	return query
+	return db
 }
 
-// ApplyCollections applies the search criteria to the Collection query
-func (e *Engine) ApplyCollections(db *gorm.DB, criteria SearchCriteria) *gorm.DB {
-	query := db.Model(&entities.Collection{})
+// compile turns a node into a single SQL expression. Groups are always
+// parenthesised so precedence never depends on GORM's clause building.
+func (c *compiler) compile(node Node) *clause.Expr {
+	switch n := node.(type) {
+	case *TermNode:
+		return c.compileTerm(n)
+	case *NotNode:
+		child := c.compile(n.Child)
+		if child == nil {
+			return nil
+		}
+
+		return &clause.Expr{SQL: "NOT (" + child.SQL + ")", Vars: child.Vars}
+	case *AndNode:
+		return c.join(n.Children, " AND ")
+	case *OrNode:
+		return c.join(n.Children, " OR ")
+	}
+
+	return nil
+}
+
+func (c *compiler) compileTerm(term *TermNode) *clause.Expr {
+	if term.Value == "" {
+		return nil
+	}
 
-	// 1. Text Search (Name OR Description)
-	if len(criteria.Text) > 0 {
-		term := "%" + strings.Join(criteria.Text, " ") + "%"
-		query = query.Where("name ILIKE ? OR description ILIKE ?", term, term)
+	if term.IsText() {
+		return c.fields.text(term)
 	}
 
-	// 2. User Filters
-	if user, ok := criteria.Filters["owner"]; ok {
-		query = query.Joins("JOIN users ON users.uid = collections.owner_id").
-			Where("users.username = ?", user)
+	if filter, ok := c.fields.filters[term.Key]; ok {
+		return filter(term.Value)
 	}
 
-	// Status
-	if val, ok := criteria.Filters["is"]; ok {
-		switch val {
-		case "private":
-			query = query.Where("private = ?", true)
-		case "public":
-			query = query.Where("private = ?", false)
+	if filter, ok := c.fields.dates[term.Key]; ok {
+		op, value := parseOperator(term.Value)
+		span, ok := resolveDate(value, c.now, c.loc)
+		if !ok {
+			return nil
 		}
+
+		return filter(op, span)
 	}
 
-	// Favourited
-	if val, ok := criteria.Filters["favourited"]; ok {
-		switch val {
-		case "true":
-			query = query.Where("favourited = ?", true)
-		case "false":
-			query = query.Where("favourited = ? OR favourited IS NULL", false)
+	if c.strict && isKnownFilter(term.Key) {
+		return &clause.Expr{SQL: "FALSE"}
+	}
+
+	return nil
+}
+
+// isKnownFilter reports whether any entity type understands the filter key
+func isKnownFilter(key string) bool {
+	for _, fields := range []fieldSet{imageFields, collectionFields, userFields} {
+		if _, ok := fields.filters[key]; ok {
+			return true
 		}
-	} else if val, ok := criteria.Filters["favorite"]; ok {
-		switch val {
-		case "true":
-			query = query.Where("favourited = ?", true)
-		case "false":
-			query = query.Where("favourited = ? OR favourited IS NULL", false)
+
+		if _, ok := fields.dates[key]; ok {
+			return true
 		}
 	}
 
-	return query
+	return false
+}
+
+// join compiles each child and joins them with sep. Children that
+// compile to nothing (unknown filters, invalid values) are dropped.
+func (c *compiler) join(children []Node, sep string) *clause.Expr {
+	parts := make([]string, 0, len(children))
+	vars := make([]any, 0)
+
+	for _, child := range children {
+		expr := c.compile(child)
+		if expr == nil {
+			continue
+		}
+
+		parts = append(parts, expr.SQL)
+		vars = append(vars, expr.Vars...)
+	}
+
+	switch len(parts) {
+	case 0:
+		return nil
+	case 1:
+		return &clause.Expr{SQL: parts[0], Vars: vars}
+	}
+
+	return &clause.Expr{SQL: "(" + strings.Join(parts, sep) + ")", Vars: vars}
 }
 
 // parseOperator extracts operator and value from string like ">=5"
@@ -3,6 +3,7 @@ package search
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -104,51 +105,93 @@ func TestEngineApply(t *testing.T) {
 
 	tests := []struct {
 		name             string
-		criteria         SearchCriteria
+		query            string
 		wantWhereContain []string
 	}{
 		{
-			name: "Basic Filters",
-			criteria: SearchCriteria{
-				Filters: map[string]string{
-					"rating": ">=4",
-				},
-			},
+			name:             "Basic Filters",
+			query:            "rating:>=4",
 			wantWhereContain: []string{"(image_metadata->>'rating')::numeric >= ?"},
 		},
 		{
-			name: "Favourited True",
-			criteria: SearchCriteria{
-				Filters: map[string]string{
-					"favourited": "true",
-				},
-			},
+			name:             "Favourited True",
+			query:            "favourited:true",
 			wantWhereContain: []string{"favourited = ?"},
 		},
 		{
-			name: "Favourited False",
-			criteria: SearchCriteria{
-				Filters: map[string]string{
-					"favourited": "false",
-				},
-			},
+			name:             "Favourited False",
+			query:            "favourited:false",
 			wantWhereContain: []string{"favourited = ? OR favourited IS NULL"},
 		},
 		{
-			name: "Favorite Alias",
-			criteria: SearchCriteria{
-				Filters: map[string]string{
-					"favorite": "true",
-				},
-			},
+			name:             "Favorite Alias",
+			query:            "favorite:true",
 			wantWhereContain: []string{"favourited = ?"},
 		},
 		{
-			name: "Text Search with EXIF",
-			criteria: SearchCriteria{
-				Text: []string{"fujifilm"},
-			},
-			wantWhereContain: []string{"image_metadata::text ILIKE ?", "exif::text ILIKE ?"},
+			name:             "Text Search Uses Full-Text Index",
+			query:            "fujifilm",
+			wantWhereContain: []string{"search_vector @@ websearch_to_tsquery('english', ?)"},
+		},
+		{
+			name:             "OR Across Same Key",
+			query:            "make:canon OR make:nikon",
+			wantWhereContain: []string{"(exif->>'make' ILIKE ? OR exif->>'make' ILIKE ?)"},
+		},
+		{
+			name:             "Negated Filter",
+			query:            "-is:private",
+			wantWhereContain: []string{"NOT (private = ?)"},
+		},
+		{
+			name:             "Grouped Alternatives",
+			query:            "make:canon OR make:nikon -is:private (rating:>=4 OR favourited:true)",
+			wantWhereContain: []string{"((image_metadata->>'rating')::numeric >= ? OR favourited = ?)", "NOT (private = ?)"},
+		},
+		{
+			name:             "Owner Uses Subquery",
+			query:            "-owner:jane",
+			wantWhereContain: []string{"NOT (owner_id IN (SELECT uid FROM users WHERE users.username = ?))"},
+		},
+		{
+			name:             "Unknown Filter Dropped From OR",
+			query:            "camera:fuji OR rating:5",
+			wantWhereContain: []string{"(image_metadata->>'rating')::numeric = ?"},
+		},
+		{
+			name:             "Numeric EXIF Comparison",
+			query:            "iso:>=1600 f:<2.8 shutter:<1/60",
+			wantWhereContain: []string{"exif_iso >= ?", "exif_f_number < ?", "exif_exposure_time < ?"},
+		},
+		{
+			name:             "Numeric Range",
+			query:            "focal:24..70 OR width:>4000",
+			wantWhereContain: []string{"((exif_focal_length >= ? AND exif_focal_length <= ?) OR width > ?)"},
+		},
+		{
+			name:             "Open Range",
+			query:            "iso:..400",
+			wantWhereContain: []string{"exif_iso <= ?"},
+		},
+		{
+			name:             "Lens Matches Make Or Model",
+			query:            `lens:"RF 50mm"`,
+			wantWhereContain: []string{"(exif->>'lens_make' ILIKE ? OR exif->>'lens_model' ILIKE ?)"},
+		},
+		{
+			name:             "Date Covers Whole Day",
+			query:            "date:yesterday",
+			wantWhereContain: []string{"(taken_at >= ? AND taken_at < ?)"},
+		},
+		{
+			name:             "Relative After",
+			query:            `after:"7 days ago"`,
+			wantWhereContain: []string{"taken_at >= ?"},
+		},
+		{
+			name:             "Uploaded Uses Created At",
+			query:            "uploaded:>=2024-06 taken:2024-01..2024-03",
+			wantWhereContain: []string{"created_at >= ?", "(taken_at >= ? AND taken_at < ?)"},
 		},
 	}
 
@@ -156,7 +199,12 @@ func TestEngineApply(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Reset clauses for each run
 			db.Statement.Clauses = make(map[string]clause.Clause)
-			resultDB := engine.Apply(db, tt.criteria)
+			query, err := ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("ParseQuery(%q) returned error: %v", tt.query, err)
+			}
+
+			resultDB := engine.Apply(db, query)
 
 			for _, want := range tt.wantWhereContain {
 				if !hasWhereClause(resultDB, want) {
@@ -184,52 +232,71 @@ func TestEngineApplyCollections(t *testing.T) {
 
 	tests := []struct {
 		name             string
-		criteria         SearchCriteria
+		query            string
 		wantWhereContain []string
 	}{
 		{
-			name: "Basic Filters",
-			criteria: SearchCriteria{
-				Filters: map[string]string{
-					"owner": "jane",
-				},
-			},
+			name:             "Basic Filters",
+			query:            "owner:jane",
 			wantWhereContain: []string{"users.username = ?"},
 		},
 		{
-			name: "Favourited True",
-			criteria: SearchCriteria{
-				Filters: map[string]string{
-					"favourited": "true",
-				},
-			},
+			name:             "Favourited True",
+			query:            "favourited:true",
 			wantWhereContain: []string{"favourited = ?"},
 		},
 		{
-			name: "Favourited False",
-			criteria: SearchCriteria{
-				Filters: map[string]string{
-					"favourited": "false",
-				},
-			},
-			wantWhereContain: []string{"favourited = ? OR favourited IS NULL"},
+			name:             "Favourited False",
+			query:            "favourited:false",
+			wantWhereContain: []string{"collections.favourited = ? OR collections.favourited IS NULL"},
 		},
 		{
-			name: "Favorite Alias",
-			criteria: SearchCriteria{
-				Filters: map[string]string{
-					"favorite": "true",
-				},
-			},
+			name:             "Favorite Alias",
+			query:            "favorite:true",
 			wantWhereContain: []string{"favourited = ?"},
 		},
+		{
+			name:             "Public Includes Unset",
+			query:            "is:public",
+			wantWhereContain: []string{"collections.private = ? OR collections.private IS NULL"},
+		},
+		{
+			name:             "Has Image",
+			query:            "has:image:abc123",
+			wantWhereContain: []string{"collections.images @> ?::jsonb"},
+		},
+		{
+			name:             "Contains Keyword",
+			query:            `contains:"table mountain"`,
+			wantWhereContain: []string{"jsonb_array_elements_text(images.image_metadata->'keywords')", "lower(keyword) = lower(?)"},
+		},
+		{
+			name:             "Image Count",
+			query:            "count:>50",
+			wantWhereContain: []string{"collections.image_count > ?"},
+		},
+		{
+			name:             "Created Date",
+			query:            "created:2024",
+			wantWhereContain: []string{"(collections.created_at >= ? AND collections.created_at < ?)"},
+		},
+		{
+			name:             "Image Only Filter Ignored",
+			query:            "name iso:>=1600",
+			wantWhereContain: []string{"collections.name ILIKE ?"},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Reset clauses for each run
 			db.Statement.Clauses = make(map[string]clause.Clause)
-			resultDB := engine.ApplyCollections(db, tt.criteria)
+			query, err := ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("ParseQuery(%q) returned error: %v", tt.query, err)
+			}
+
+			resultDB := engine.ApplyCollections(db, query)
 
 			for _, want := range tt.wantWhereContain {
 				if !hasWhereClause(resultDB, want) {
@@ -239,3 +306,261 @@ func TestEngineApplyCollections(t *testing.T) {
 		})
 	}
 }
+
+func TestEngineRank(t *testing.T) {
+	engine := NewEngine()
+
+	newDB := func() *gorm.DB {
+		return &gorm.DB{
+			Statement: &gorm.Statement{
+				Clauses: make(map[string]clause.Clause),
+				Table:   "images",
+				Vars:    make([]interface{}, 0),
+			},
+			Config: &gorm.Config{
+				DryRun: true,
+			},
+		}
+	}
+
+	t.Run("Ranks by positive text terms", func(t *testing.T) {
+		query, err := ParseQuery("sunset OR beach -city rating:5")
+		if err != nil {
+			t.Fatalf("ParseQuery() returned error: %v", err)
+		}
+
+		resultDB := engine.Rank(newDB(), query)
+
+		expr, ok := resultDB.Statement.Clauses["SELECT"].Expression.(clause.Expr)
+		if !ok {
+			t.Fatalf("Rank() expected a SELECT expression, got %T", resultDB.Statement.Clauses["SELECT"].Expression)
+		}
+
+		if !strings.Contains(expr.SQL, "ts_rank(search_vector, (websearch_to_tsquery('english', ?) || websearch_to_tsquery('english', ?)))") {
+			t.Errorf("Rank() SELECT = %q, want ts_rank over both terms", expr.SQL)
+		}
+
+		if !strings.Contains(expr.SQL, "ts_headline(") {
+			t.Errorf("Rank() SELECT = %q, want a ts_headline snippet", expr.SQL)
+		}
+
+		// negated terms are not used for ranking, and each term appears once for rank and once for the headline
+		want := []any{"sunset", "beach", "sunset", "beach"}
+		if len(expr.Vars) != len(want) {
+			t.Fatalf("Rank() vars = %v, want %v", expr.Vars, want)
+		}
+
+		for i := range want {
+			if expr.Vars[i] != want[i] {
+				t.Errorf("Rank() vars[%d] = %v, want %v", i, expr.Vars[i], want[i])
+			}
+		}
+
+		if !strings.Contains(expr.SQL, "replace(replace(replace(replace(replace(concat_ws(") || !strings.Contains(expr.SQL, "'<', '&lt;'") {
+			t.Errorf("Rank() SELECT = %q, want the headline document HTML escaped", expr.SQL)
+		}
+
+		orderBy, ok := resultDB.Statement.Clauses["ORDER BY"].Expression.(clause.OrderBy)
+		if !ok {
+			t.Fatalf("Rank() expected an ORDER BY clause")
+		}
+
+		if len(orderBy.Columns) != 1 || orderBy.Columns[0].Column.Name != "search_rank DESC, images.id DESC" {
+			t.Errorf("Rank() ORDER BY = %v, want search_rank with an ID tie-breaker", orderBy.Columns)
+		}
+	})
+
+	t.Run("Quoted terms are phrases", func(t *testing.T) {
+		query, err := ParseQuery(`"golden hour"`)
+		if err != nil {
+			t.Fatalf("ParseQuery() returned error: %v", err)
+		}
+
+		expr := rankQuery(query)
+		if expr == nil || len(expr.Vars) != 1 || expr.Vars[0] != `"golden hour"` {
+			t.Errorf("rankQuery() = %v, want a single quoted phrase", expr)
+		}
+	})
+
+	t.Run("No text terms leaves order alone", func(t *testing.T) {
+		query, err := ParseQuery("rating:5")
+		if err != nil {
+			t.Fatalf("ParseQuery() returned error: %v", err)
+		}
+
+		resultDB := engine.Rank(newDB(), query)
+		if _, ok := resultDB.Statement.Clauses["ORDER BY"]; ok {
+			t.Errorf("Rank() should not order results without text terms")
+		}
+	})
+}
+
+func TestParseExifNumber(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+		ok    bool
+	}{
+		{"1600", 1600, true},
+		{"ISO 800", 800, true},
+		{"100, 100", 100, true},
+		{"f/2.8", 2.8, true},
+		{"f4", 4, true},
+		{"50.0 mm", 50, true},
+		{"1/250", 0.004, true},
+		{"1/1250 sec.", 0.0008, true},
+		{"2s", 2, true},
+		{"1/0", 0, false},
+		{"fast", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ParseExifNumber(tt.input)
+		if ok != tt.ok {
+			t.Errorf("ParseExifNumber(%q) ok = %v, want %v", tt.input, ok, tt.ok)
+			continue
+		}
+
+		if ok && (got-tt.want > 1e-9 || tt.want-got > 1e-9) {
+			t.Errorf("ParseExifNumber(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseFacets(t *testing.T) {
+	names, err := ParseFacets(" Make, year,make ")
+	if err != nil {
+		t.Fatalf("ParseFacets() returned error: %v", err)
+	}
+
+	if strings.Join(names, ",") != "make,year" {
+		t.Errorf("ParseFacets() = %v, want [make year]", names)
+	}
+
+	all, err := ParseFacets("all")
+	if err != nil || len(all) != len(imageFacets) {
+		t.Errorf("ParseFacets(all) = %v, %v, want every facet", all, err)
+	}
+
+	if _, err := ParseFacets("make,camera"); err == nil {
+		t.Errorf("ParseFacets() expected an error for an unknown facet")
+	}
+
+	if names, err := ParseFacets(""); err != nil || len(names) != 0 {
+		t.Errorf("ParseFacets(\"\") = %v, %v, want no facets", names, err)
+	}
+}
+
+func TestFacetsSQL(t *testing.T) {
+	sql, locations := facetsSQL([]string{"make", "year", "month"})
+
+	if locations != 2 {
+		t.Errorf("facetsSQL() locations = %d, want 2", locations)
+	}
+
+	if got := strings.Count(sql, " UNION ALL "); got != 2 {
+		t.Errorf("facetsSQL() has %d UNION ALL, want 2: %s", got, sql)
+	}
+
+	for _, want := range []string{
+		"SELECT 'make' AS facet, value, count(*) AS count FROM (SELECT exif->>'make' AS value FROM results)",
+		"to_char(taken_at AT TIME ZONE ?, 'YYYY')",
+		"ORDER BY value DESC",
+		"ORDER BY count DESC, value ASC",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("facetsSQL() = %q, want it to contain %q", sql, want)
+		}
+	}
+}
+
+func TestSQLTimeZone(t *testing.T) {
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip("no tz database")
+	}
+
+	tests := []struct {
+		name string
+		loc  *time.Location
+		want string
+	}{
+		{"utc", time.UTC, "UTC"},
+		{"iana", paris, "Europe/Paris"},
+		{"east of utc", time.FixedZone("", 5*3600+30*60), "UTC-05:30"},
+		{"west of utc", time.FixedZone("EST-ish", -5*3600), "UTC+05:00"},
+	}
+
+	for _, tt := range tests {
+		if got := sqlTimeZone(tt.loc, now); got != tt.want {
+			t.Errorf("sqlTimeZone(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if got := sqlTimeZone(time.Local, now); got == "Local" {
+		t.Errorf("sqlTimeZone(time.Local) = %q", got)
+	}
+}
+
+func TestStrictCompiler(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  fieldSet
+		query   string
+		wantSQL string
+	}{
+		{name: "Image Filter On Users", fields: userFields, query: "iso:>=1600", wantSQL: "FALSE"},
+		{name: "Collection Filter On Images", fields: imageFields, query: "count:>50", wantSQL: "FALSE"},
+		{name: "Negated", fields: userFields, query: "-rating:5", wantSQL: "NOT (FALSE)"},
+		{name: "Supported Filter", fields: collectionFields, query: "count:>50", wantSQL: "collections.image_count > ?"},
+		{name: "Unknown Filter", fields: userFields, query: "camera:x", wantSQL: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("ParseQuery(%q) returned error: %v", tt.query, err)
+			}
+
+			c := NewEngine().compiler(tt.fields)
+			c.strict = true
+
+			expr := c.compile(query.Root)
+			got := ""
+			if expr != nil {
+				got = expr.SQL
+			}
+
+			if got != tt.wantSQL {
+				t.Errorf("compile(%q) = %q, want %q", tt.query, got, tt.wantSQL)
+			}
+		})
+	}
+}
+
+func TestParseCursor(t *testing.T) {
+	hit := Hit{
+		Kind:      ResultCollection,
+		Uid:       "abc123",
+		Rank:      0.0607927,
+		CreatedAt: time.Date(2024, 6, 11, 10, 30, 0, 123456000, time.UTC),
+	}
+
+	parsed, err := ParseCursor(hit.Cursor())
+	if err != nil {
+		t.Fatalf("ParseCursor() returned error: %v", err)
+	}
+
+	if parsed.Kind != hit.Kind || parsed.Uid != hit.Uid || parsed.Rank != hit.Rank || !parsed.CreatedAt.Equal(hit.CreatedAt) {
+		t.Errorf("ParseCursor() = %+v, want %+v", *parsed, hit)
+	}
+
+	for _, invalid := range []string{"", "not base64!", "e30", Hit{Kind: "album", Uid: "x"}.Cursor()} {
+		if _, err := ParseCursor(invalid); err == nil {
+			t.Errorf("ParseCursor(%q) expected an error", invalid)
+		}
+	}
+}
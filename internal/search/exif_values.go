@@ -0,0 +1,128 @@
+package search
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"viz/internal/dto"
+	"viz/internal/entities"
+)
+
+// ParseExifNumber normalises the string forms EXIF values come in, such as
+// "1/250", "1/1250 sec.", "f/2.8", "50.0 mm" or "ISO 1600", into a number.
+func ParseExifNumber(s string) (float64, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return 0, false
+	}
+
+	// multi-valued tags (e.g. "100, 100") use the first value
+	if idx := strings.Index(s, ","); idx > 0 {
+		s = strings.TrimSpace(s[:idx])
+	}
+
+	s = strings.TrimPrefix(s, "iso")
+	s = strings.TrimPrefix(s, "f/")
+	if len(s) > 1 && s[0] == 'f' && (s[1] >= '0' && s[1] <= '9' || s[1] == '.') {
+		s = s[1:]
+	}
+
+	for _, suffix := range []string{"sec.", "sec", "mm", "s", "\""} {
+		s = strings.TrimSuffix(s, suffix)
+	}
+
+	s = strings.TrimSpace(s)
+	if num, den, ok := strings.Cut(s, "/"); ok {
+		n, err1 := strconv.ParseFloat(strings.TrimSpace(num), 64)
+		d, err2 := strconv.ParseFloat(strings.TrimSpace(den), 64)
+		if err1 != nil || err2 != nil || d == 0 {
+			return 0, false
+		}
+
+		return n / d, true
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return v, true
+}
+
+// ExifColumnValues returns the numeric EXIF columns (see entities.ImageWithExifValues)
+// for an image, keyed by column name, ready to pass to Updates.
+// Values that are missing or can't be parsed are set to NULL, and the
+// image is marked as parsed either way.
+func ExifColumnValues(exif *dto.ImageEXIF) map[string]any {
+	values := map[string]any{
+		"exif_iso":               nil,
+		"exif_f_number":          nil,
+		"exif_focal_length":      nil,
+		"exif_focal_length_35mm": nil,
+		"exif_exposure_time":     nil,
+		"exif_values_parsed_at":  time.Now().UTC(),
+	}
+
+	if exif == nil {
+		return values
+	}
+
+	set := func(column string, candidates ...*string) {
+		for _, c := range candidates {
+			if c == nil {
+				continue
+			}
+
+			if v, ok := ParseExifNumber(*c); ok {
+				values[column] = v
+				return
+			}
+		}
+	}
+
+	set("exif_iso", exif.Iso)
+	set("exif_f_number", exif.FNumber, exif.Aperture)
+	set("exif_focal_length", exif.FocalLength)
+	set("exif_focal_length_35mm", exif.FocalLengthIn35mmFormat)
+	set("exif_exposure_time", exif.ExposureTime)
+
+	return values
+}
+
+// BackfillExifValues fills the numeric EXIF columns for images that were
+// processed before those columns existed. Every image it goes through is
+// marked as parsed, including those with no usable values, so each is only
+// looked at once.
+func BackfillExifValues(client *gorm.DB, logger *slog.Logger) {
+	var pending []entities.ImageAsset
+	updated := 0
+
+	result := client.Model(&entities.ImageAsset{}).
+		Select("id", "uid", "exif").
+		Where("exif IS NOT NULL AND exif_values_parsed_at IS NULL").
+		FindInBatches(&pending, 500, func(tx *gorm.DB, batch int) error {
+			for _, img := range pending {
+				if err := client.Model(&entities.ImageAsset{}).Where("uid = ?", img.Uid).Updates(ExifColumnValues(img.Exif)).Error; err != nil {
+					return err
+				}
+
+				updated++
+			}
+
+			return nil
+		})
+
+	if result.Error != nil {
+		logger.Error("failed to backfill numeric exif values", slog.Any("error", result.Error))
+		return
+	}
+
+	if updated > 0 {
+		logger.Info("backfilled numeric exif values", slog.Int("images", updated))
+	}
+}
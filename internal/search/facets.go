@@ -0,0 +1,174 @@
+package search
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MaxFacetBuckets is the most values returned for a single facet
+const MaxFacetBuckets = 50
+
+// FacetBucket is one value of a facet and how many results have it
+type FacetBucket struct {
+	Facet string
+	Value string
+	Count int64
+}
+
+// facet describes how results are grouped for one facet. expr is evaluated
+// against the filtered result set; chronological facets are listed newest
+// first rather than by count.
+type facet struct {
+	expr          string
+	usesLocation  bool
+	chronological bool
+}
+
+var imageFacets = map[string]facet{
+	"make":        {expr: "exif->>'make'"},
+	"model":       {expr: "exif->>'model'"},
+	"lens":        {expr: "exif->>'lens_model'"},
+	"year":        {expr: "to_char(taken_at AT TIME ZONE ?, 'YYYY')", usesLocation: true, chronological: true},
+	"month":       {expr: "to_char(taken_at AT TIME ZONE ?, 'YYYY-MM')", usesLocation: true, chronological: true},
+	"rating":      {expr: "image_metadata->>'rating'"},
+	"label":       {expr: "image_metadata->>'label'"},
+	"type":        {expr: "image_metadata->>'file_type'"},
+	"orientation": {expr: "CASE WHEN width > height THEN 'landscape' WHEN height > width THEN 'portrait' WHEN width > 0 THEN 'square' END"},
+	"owner":       {expr: "(SELECT username FROM users WHERE users.uid = results.owner_id)"},
+}
+
+// facetColumns are the only columns the facet expressions read
+var facetColumns = []string{"images.exif", "images.taken_at", "images.image_metadata", "images.width", "images.height", "images.owner_id"}
+
+// FacetNames returns every facet that can be requested, sorted
+func FacetNames() []string {
+	names := make([]string, 0, len(imageFacets))
+	for name := range imageFacets {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+	return names
+}
+
+// ParseFacets parses a comma separated list of facet names, e.g. "make,year".
+// "all" requests every facet. Duplicates are removed and unknown names are an error.
+func ParseFacets(value string) ([]string, error) {
+	names := make([]string, 0)
+	for _, part := range strings.Split(value, ",") {
+		name := strings.ToLower(strings.TrimSpace(part))
+		if name == "" {
+			continue
+		}
+
+		if name == "all" {
+			return FacetNames(), nil
+		}
+
+		if _, ok := imageFacets[name]; !ok {
+			return nil, fmt.Errorf("unknown facet %q, expected one of %s", name, strings.Join(FacetNames(), ", "))
+		}
+
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
+
+// facetsSQL builds a single statement that counts every requested facet over
+// the rows of a "results" CTE: one GROUP BY per facet, UNION-ed together.
+// It returns the SQL and the number of location placeholders it contains.
+func facetsSQL(names []string) (string, int) {
+	parts := make([]string, 0, len(names))
+	locations := 0
+
+	for _, name := range names {
+		f := imageFacets[name]
+
+		order := "count DESC, value ASC"
+		if f.chronological {
+			order = "value DESC"
+		}
+
+		if f.usesLocation {
+			locations++
+		}
+
+		parts = append(parts, fmt.Sprintf(
+			"(SELECT '%[1]s' AS facet, value, count(*) AS count FROM (SELECT %[2]s AS value FROM results) AS f_%[1]s WHERE value IS NOT NULL AND value <> '' GROUP BY value ORDER BY %[3]s LIMIT %[4]d)",
+			name, f.expr, order, MaxFacetBuckets,
+		))
+	}
+
+	return strings.Join(parts, " UNION ALL "), locations
+}
+
+// Facets counts the requested facets over every row the filtered query matches,
+// ignoring its ordering and pagination. Pass the query produced by Apply,
+// with any visibility scopes already added. Buckets are returned per facet.
+func (e *Engine) Facets(db *gorm.DB, filtered *gorm.DB, names []string) (map[string][]FacetBucket, error) {
+	result := make(map[string][]FacetBucket, len(names))
+	if len(names) == 0 {
+		return result, nil
+	}
+
+	for _, name := range names {
+		result[name] = make([]FacetBucket, 0)
+	}
+
+	loc := e.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	now := time.Now
+	if e.Now != nil {
+		now = e.Now
+	}
+
+	zone := sqlTimeZone(loc, now())
+	sql, locations := facetsSQL(names)
+	vars := []any{filtered.Session(&gorm.Session{}).Select(facetColumns)}
+	for range locations {
+		vars = append(vars, zone)
+	}
+
+	var buckets []FacetBucket
+	if err := db.Raw("WITH results AS (?) "+sql, vars...).Scan(&buckets).Error; err != nil {
+		return nil, err
+	}
+
+	for _, b := range buckets {
+		result[b.Facet] = append(result[b.Facet], b)
+	}
+
+	return result, nil
+}
+
+// sqlTimeZone returns a time zone name Postgres accepts for loc. Locations
+// loaded from the tz database keep their name, time.Local and fixed zones
+// are named after the UTC offset they have at now, as a POSIX zone whose
+// offsets count west of Greenwich.
+func sqlTimeZone(loc *time.Location, now time.Time) string {
+	name := loc.String()
+	if name != "" && name != "Local" {
+		if _, err := time.LoadLocation(name); err == nil {
+			return name
+		}
+	}
+
+	_, offset := now.In(loc).Zone()
+	sign := "-"
+	if offset <= 0 {
+		sign = "+"
+		offset = -offset
+	}
+
+	return fmt.Sprintf("UTC%s%02d:%02d", sign, offset/3600, offset%3600/60)
+}
@@ -0,0 +1,170 @@
+package search
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"viz/internal/entities"
+)
+
+const (
+	// TextSearchConfig is the Postgres text search configuration used for
+	// both the stored vectors and the queries run against them
+	TextSearchConfig = "english"
+
+	// searchVectorVersion is stored as a comment on the search_vector column.
+	// Bump it whenever searchVectorSQL changes so existing databases rebuild the column.
+	searchVectorVersion = "viz:search_vector:v1"
+)
+
+// searchVectorSQL builds the weighted document for an image:
+// A = name and keywords, B = description, C = camera and lens.
+// It must stay IMMUTABLE because it backs a generated column.
+var searchVectorSQL = fmt.Sprintf(`setweight(to_tsvector('%[1]s', coalesce(name, '')), 'A') ||
+	setweight(jsonb_to_tsvector('%[1]s', coalesce(image_metadata->'keywords', '[]'::jsonb), '["string"]'), 'A') ||
+	setweight(to_tsvector('%[1]s', coalesce(description, '')), 'B') ||
+	setweight(to_tsvector('%[1]s',
+		coalesce(exif->>'make', '') || ' ' || coalesce(exif->>'model', '') || ' ' ||
+		coalesce(exif->>'lens_make', '') || ' ' || coalesce(exif->>'lens_model', '')
+	), 'C')`, TextSearchConfig)
+
+// headlineDocumentSQL is the plain text that snippets are cut from. It covers
+// the same fields as searchVectorSQL so every match can be highlighted.
+const headlineDocumentSQL = `concat_ws(' ', name, description,
+	array_to_string(ARRAY(SELECT jsonb_array_elements_text(coalesce(image_metadata->'keywords', '[]'::jsonb))), ' '),
+	exif->>'make', exif->>'model', exif->>'lens_make', exif->>'lens_model')`
+
+// headlineOptions wraps matches in <mark> tags. The document is HTML escaped
+// before it's cut, so the tags are the only markup in a snippet.
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2"
+
+// htmlEscapes are the replacements escapeHTMLSQL makes, ampersands first so
+// the entities it adds aren't escaped again
+var htmlEscapes = [][2]string{{"&", "&amp;"}, {"<", "&lt;"}, {">", "&gt;"}, {`"`, "&quot;"}, {"'", "&#39;"}}
+
+// escapeHTMLSQL wraps a SQL text expression so the characters HTML treats
+// specially come out as entities. Postgres' parser reads entities as single
+// tokens, so ts_headline still finds and highlights the words around them.
+func escapeHTMLSQL(expr string) string {
+	for _, r := range htmlEscapes {
+		expr = fmt.Sprintf("replace(%s, '%s', '%s')", expr, strings.ReplaceAll(r[0], "'", "''"), r[1])
+	}
+
+	return expr
+}
+
+// ImageHit is an image returned by a ranked search along with its
+// relevance score and a highlighted snippet of the text that matched
+type ImageHit struct {
+	entities.ImageAsset
+	Rank    float64 `gorm:"column:search_rank"`
+	Snippet *string `gorm:"column:search_snippet"`
+}
+
+// EnsureSearchIndex creates the maintained tsvector column and its GIN index
+// on the images table. AutoMigrate can't express generated columns, so this
+// runs as raw SQL after migration and is safe to call on every start.
+func EnsureSearchIndex(client *gorm.DB, logger *slog.Logger) {
+	var comment *string
+	err := client.Raw(`SELECT col_description(a.attrelid, a.attnum)
+		FROM pg_attribute a
+		WHERE a.attrelid = 'images'::regclass AND a.attname = 'search_vector' AND NOT a.attisdropped`).
+		Scan(&comment).Error
+	if err != nil {
+		logger.Error("failed to inspect images search vector", slog.Any("error", err))
+		return
+	}
+
+	if comment != nil && *comment == searchVectorVersion {
+		return
+	}
+
+	logger.Info("building full-text search index for images...")
+
+	err = client.Transaction(func(tx *gorm.DB) error {
+		statements := []string{
+			"DROP INDEX IF EXISTS idx_images_search_vector",
+			"ALTER TABLE images DROP COLUMN IF EXISTS search_vector",
+			fmt.Sprintf("ALTER TABLE images ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (%s) STORED", searchVectorSQL),
+			"CREATE INDEX idx_images_search_vector ON images USING GIN (search_vector)",
+			fmt.Sprintf("COMMENT ON COLUMN images.search_vector IS '%s'", searchVectorVersion),
+		}
+
+		for _, stmt := range statements {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		logger.Error("failed to build images search index", slog.Any("error", err))
+	}
+}
+
+// tsQuery returns the tsquery expression for a single free text term.
+// Quoted terms are searched as a phrase.
+func tsQuery(term *TermNode) *clause.Expr {
+	value := term.Value
+	if term.Quoted {
+		value = `"` + strings.ReplaceAll(value, `"`, "") + `"`
+	}
+
+	return &clause.Expr{SQL: fmt.Sprintf("websearch_to_tsquery('%s', ?)", TextSearchConfig), Vars: []any{value}}
+}
+
+// rankQuery combines every free text term that isn't negated into one
+// tsquery so results can be ranked by how well they match any of them.
+func rankQuery(query *Query) *clause.Expr {
+	if query == nil {
+		return nil
+	}
+
+	parts := make([]string, 0)
+	vars := make([]any, 0)
+	Walk(query.Root, func(n Node) bool {
+		switch node := n.(type) {
+		case *NotNode:
+			return false
+		case *TermNode:
+			if node.IsText() && node.Value != "" {
+				expr := tsQuery(node)
+				parts = append(parts, expr.SQL)
+				vars = append(vars, expr.Vars...)
+			}
+		}
+
+		return true
+	})
+
+	if len(parts) == 0 {
+		return nil
+	}
+
+	return &clause.Expr{SQL: "(" + strings.Join(parts, " || ") + ")", Vars: vars}
+}
+
+// Rank orders an image query by full-text relevance, breaking ties by ID so
+// pages don't overlap, and selects a highlighted snippet for each row. Scan the results into []ImageHit. Queries without
+// any free text terms are returned unchanged apart from the extra columns.
+func (e *Engine) Rank(db *gorm.DB, query *Query) *gorm.DB {
+	tsq := rankQuery(query)
+	if tsq == nil {
+		return db.Select("images.*, 0::float8 AS search_rank, NULL::text AS search_snippet")
+	}
+
+	vars := make([]any, 0, len(tsq.Vars)*2)
+	vars = append(vars, tsq.Vars...)
+	vars = append(vars, tsq.Vars...)
+
+	return db.
+		Select(fmt.Sprintf("images.*, ts_rank(search_vector, %[1]s) AS search_rank, ts_headline('%[2]s', %[3]s, %[1]s, '%[4]s') AS search_snippet",
+			tsq.SQL, TextSearchConfig, escapeHTMLSQL(headlineDocumentSQL), headlineOptions), vars...).
+		Order("search_rank DESC, images.id DESC")
+}
@@ -0,0 +1,180 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenTerm tokenKind = iota
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenLParen
+	tokenRParen
+	tokenEOF
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokenTerm:
+		return "term"
+	case tokenAnd:
+		return "AND"
+	case tokenOr:
+		return "OR"
+	case tokenNot:
+		return "NOT"
+	case tokenLParen:
+		return "("
+	case tokenRParen:
+		return ")"
+	default:
+		return "end of query"
+	}
+}
+
+// token is a single lexical unit of a search query. Only tokenTerm
+// carries a Key/Value; operator tokens just record where they were found.
+type token struct {
+	Kind   tokenKind
+	Key    string
+	Value  string
+	Quoted bool
+	Pos    int
+}
+
+// lexer splits a raw query into tokens. It understands:
+//   - bare words and quoted phrases ("..." or '...')
+//   - key:value filters, where the value may itself be quoted
+//   - the keywords AND, OR and NOT (upper case only, so that "or" can still be searched for)
+//   - a leading '-' as shorthand for NOT
+//   - parentheses for grouping
+type lexer struct {
+	input []rune
+	pos   int
+}
+
+func tokenize(input string) []token {
+	l := &lexer{input: []rune(input)}
+	tokens := make([]token, 0)
+
+	for {
+		tok := l.next()
+		tokens = append(tokens, tok)
+		if tok.Kind == tokenEOF {
+			return tokens
+		}
+	}
+}
+
+func (l *lexer) next() token {
+	l.skipSpace()
+	if l.pos >= len(l.input) {
+		return token{Kind: tokenEOF, Pos: l.pos}
+	}
+
+	start := l.pos
+	switch r := l.input[l.pos]; {
+	case r == '(':
+		l.pos++
+		return token{Kind: tokenLParen, Pos: start}
+	case r == ')':
+		l.pos++
+		return token{Kind: tokenRParen, Pos: start}
+	case r == '-' && l.pos+1 < len(l.input) && !unicode.IsSpace(l.input[l.pos+1]) && l.input[l.pos+1] != ')':
+		l.pos++
+		return token{Kind: tokenNot, Pos: start}
+	case r == '"' || r == '\'':
+		return token{Kind: tokenTerm, Value: l.readQuoted(), Quoted: true, Pos: start}
+	}
+
+	word := l.readWord()
+	switch word {
+	case "AND":
+		return token{Kind: tokenAnd, Pos: start}
+	case "OR":
+		return token{Kind: tokenOr, Pos: start}
+	case "NOT":
+		return token{Kind: tokenNot, Pos: start}
+	}
+
+	// key:value filter. The key must be a plain identifier, anything else
+	// (e.g. a URL or a time like 12:30) is treated as free text.
+	if idx := strings.IndexRune(word, ':'); idx > 0 && isIdentifier(word[:idx]) {
+		key := strings.ToLower(word[:idx])
+		value := word[idx+1:]
+
+		// The value is quoted, e.g. lens:"RF 50mm", so keep reading past the space
+		if value == "" && l.pos < len(l.input) && (l.input[l.pos] == '"' || l.input[l.pos] == '\'') {
+			return token{Kind: tokenTerm, Key: key, Value: l.readQuoted(), Quoted: true, Pos: start}
+		}
+
+		return token{Kind: tokenTerm, Key: key, Value: value, Pos: start}
+	}
+
+	return token{Kind: tokenTerm, Value: word, Pos: start}
+}
+
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.input) && unicode.IsSpace(l.input[l.pos]) {
+		l.pos++
+	}
+}
+
+// readWord reads until whitespace or a parenthesis. Quotes inside a word
+// (key:"value") stop the word so the caller can read the quoted part.
+func (l *lexer) readWord() string {
+	start := l.pos
+	for l.pos < len(l.input) {
+		r := l.input[l.pos]
+		if isBoundary(r) {
+			break
+		}
+
+		if (r == '"' || r == '\'') && l.pos > start && l.input[l.pos-1] == ':' {
+			break
+		}
+
+		l.pos++
+	}
+
+	return string(l.input[start:l.pos])
+}
+
+// readQuoted reads a quoted phrase. An unterminated quote runs to the end
+// of the input rather than failing, since that's almost always a typo.
+func (l *lexer) readQuoted() string {
+	quote := l.input[l.pos]
+	l.pos++
+
+	var sb strings.Builder
+	for l.pos < len(l.input) {
+		r := l.input[l.pos]
+		l.pos++
+
+		if r == quote {
+			return sb.String()
+		}
+
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}
+
+func isBoundary(r rune) bool {
+	return unicode.IsSpace(r) || r == '(' || r == ')'
+}
+
+func isIdentifier(s string) bool {
+	for _, r := range s {
+		if !(r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return false
+		}
+	}
+
+	return s != ""
+}
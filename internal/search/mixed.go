@@ -0,0 +1,117 @@
+package search
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"viz/internal/entities"
+)
+
+// ResultKind is the type of entity a mixed search result refers to
+type ResultKind string
+
+const (
+	ResultImage      ResultKind = "image"
+	ResultCollection ResultKind = "collection"
+	ResultUser       ResultKind = "user"
+)
+
+var ErrInvalidCursor = errors.New("invalid search cursor")
+
+// Hit is one row of a mixed search: the entity it refers to and the values
+// results are ordered by. Load the entities themselves by UID.
+type Hit struct {
+	Kind      ResultKind `gorm:"column:kind" json:"k"`
+	Uid       string     `gorm:"column:uid" json:"u"`
+	Rank      float64    `gorm:"column:search_rank" json:"r"`
+	CreatedAt time.Time  `gorm:"column:created_at" json:"c"`
+}
+
+// Cursor encodes the hit as an opaque cursor. Passing it back to Mixed
+// continues with the results after this one.
+func (h Hit) Cursor() string {
+	data, _ := json.Marshal(h)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ParseCursor decodes a cursor returned by Hit.Cursor
+func ParseCursor(cursor string) (*Hit, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var hit Hit
+	if err := json.Unmarshal(data, &hit); err != nil || hit.Uid == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	switch hit.Kind {
+	case ResultImage, ResultCollection, ResultUser:
+		return &hit, nil
+	}
+
+	return nil, ErrInvalidCursor
+}
+
+// MixedScopes limit each entity type to the rows the user may see.
+// A nil scope leaves that type unrestricted.
+type MixedScopes struct {
+	Images      func(*gorm.DB) *gorm.DB
+	Collections func(*gorm.DB) *gorm.DB
+	Users       func(*gorm.DB) *gorm.DB
+}
+
+// Mixed searches images, collections and users at once and returns up to
+// limit hits after the cursor (nil for the first page), best matches first.
+// Ties, and queries without free text, fall back to newest first. Filters
+// only some types understand, such as iso:, exclude the other types.
+func (e *Engine) Mixed(db *gorm.DB, query *Query, scopes MixedScopes, after *Hit, limit int) ([]Hit, error) {
+	c := e.compiler(imageFields)
+	c.strict = true
+
+	branch := func(model any, fields fieldSet, scope func(*gorm.DB) *gorm.DB, kind ResultKind, table, document string) *gorm.DB {
+		c.fields = fields
+		q := c.apply(db.Session(&gorm.Session{NewDB: true}).Model(model), query)
+		if scope != nil {
+			q = q.Scopes(scope)
+		}
+
+		rank := clause.Expr{SQL: "0::float8"}
+		if tsq := rankQuery(query); tsq != nil {
+			rank = clause.Expr{SQL: fmt.Sprintf("ts_rank(%s, %s)::float8", document, tsq.SQL), Vars: tsq.Vars}
+		}
+
+		return q.Select(fmt.Sprintf("'%s' AS kind, %[2]s.uid, %[3]s AS search_rank, %[2]s.created_at", kind, table, rank.SQL), rank.Vars...)
+	}
+
+	images := branch(&entities.ImageAsset{}, imageFields, scopes.Images, ResultImage, "images", "images.search_vector")
+	collections := branch(&entities.Collection{}, collectionFields, scopes.Collections, ResultCollection, "collections",
+		fmt.Sprintf("to_tsvector('%s', coalesce(collections.name, '') || ' ' || coalesce(collections.description, ''))", TextSearchConfig))
+	users := branch(&entities.User{}, userFields, scopes.Users, ResultUser, "users",
+		"to_tsvector('simple', users.username || ' ' || users.first_name || ' ' || users.last_name)")
+
+	sql := "SELECT kind, uid, search_rank, created_at FROM ((?) UNION ALL (?) UNION ALL (?)) AS results"
+	vars := []any{images, collections, users}
+	if after != nil {
+		// every column is ordered descending, so a row comparison finds the next page
+		sql += " WHERE (search_rank, created_at, kind, uid) < (?::float8, ?, ?, ?)"
+		vars = append(vars, after.Rank, after.CreatedAt, string(after.Kind), after.Uid)
+	}
+
+	sql += " ORDER BY search_rank DESC, created_at DESC, kind DESC, uid DESC LIMIT ?"
+	vars = append(vars, limit)
+
+	var hits []Hit
+	if err := db.Raw(sql, vars...).Scan(&hits).Error; err != nil {
+		return nil, err
+	}
+
+	return hits, nil
+}
@@ -1,93 +1,205 @@
 package search
 
 import (
-	"regexp"
-	"strings"
-	"time"
+	"fmt"
 )
 
-// DateRange holds the parsed date range
-type DateRange struct {
-	Min time.Time
-	Max time.Time
+// Query is a parsed search query
+type Query struct {
+	// Raw is the original query string as typed by the user
+	Raw string
+	// Root is the top of the query tree. It is nil for an empty query,
+	// which matches everything.
+	Root Node
 }
 
-// SearchCriteria holds the parsed search parameters
-type SearchCriteria struct {
-	Text      []string
-	DateRange DateRange
-	Filters   map[string]string
+// String renders the normalised form of the query
+func (q *Query) String() string {
+	if q == nil || q.Root == nil {
+		return ""
+	}
+
+	return q.Root.String()
 }
 
-// Regex to capture key:value pairs.
-// Group 1: Key ([a-zA-Z0-9_]+)
-// Group 2: Value ("..." or '...' or unquoted)
-var filterRegex = regexp.MustCompile(`([a-zA-Z0-9_]+):(".*?"|'.*?'|[^"\s]+)`)
+// Terms returns every term in the query, in the order they were written,
+// regardless of how they are grouped or negated.
+func (q *Query) Terms() []*TermNode {
+	terms := make([]*TermNode, 0)
+	if q == nil {
+		return terms
+	}
+
+	Walk(q.Root, func(n Node) bool {
+		if term, ok := n.(*TermNode); ok {
+			terms = append(terms, term)
+		}
+
+		return true
+	})
+
+	return terms
+}
 
-// ParseQuery parses a raw query string into a structured SearchCriteria
-func ParseQuery(input string) SearchCriteria {
-	criteria := SearchCriteria{
-		Text:    make([]string, 0),
-		Filters: make(map[string]string),
+// ParseError describes a syntax error in a search query
+type ParseError struct {
+	// Pos is the character offset of the error in the raw query
+	Pos int
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid search query at position %d: %s", e.Pos, e.Msg)
+}
+
+// ParseQuery parses a raw query string into a query tree.
+//
+// The grammar, from lowest to highest precedence:
+//
+//	query   = or
+//	or      = and { "OR" and }
+//	and     = unary { [ "AND" ] unary }
+//	unary   = ( "NOT" | "-" ) unary | primary
+//	primary = "(" or ")" | term
+//	term    = word | "phrase" | key:value | key:"phrase"
+//
+// e.g. `make:canon OR make:nikon -is:private (rating:>=4 OR favourited:true)`
+func ParseQuery(input string) (*Query, error) {
+	p := &parser{tokens: tokenize(input)}
+
+	query := &Query{Raw: input}
+	if p.peek().Kind == tokenEOF {
+		return query, nil
 	}
 
-	// 1. Find all filter matches
-	matches := filterRegex.FindAllStringSubmatch(input, -1)
-	for _, match := range matches {
-		// match[0] is the full match (e.g. "rating:5")
-		// match[1] is the key (e.g. "rating")
-		// match[2] is the value (e.g. "5", "'foo'", "\"bar\"")
-
-		key := strings.ToLower(match[1])
-		value := match[2]
-
-		// Remove quotes if present
-		if len(value) >= 2 {
-			if (strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"")) ||
-				(strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'")) {
-				value = value[1 : len(value)-1]
-			}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.Kind != tokenEOF {
+		return nil, &ParseError{Pos: tok.Pos, Msg: fmt.Sprintf("unexpected %s", tok.Kind)}
+	}
+
+	query.Root = root
+	return query, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) advance() token {
+	tok := p.tokens[p.pos]
+	if tok.Kind != tokenEOF {
+		p.pos++
+	}
+
+	return tok
+}
+
+func (p *parser) parseOr() (Node, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	children := []Node{first}
+	for p.peek().Kind == tokenOr {
+		p.advance()
+
+		next, err := p.parseAnd()
+		if err != nil {
+			return nil, err
 		}
 
-		// Handle Date Filters
-		switch key {
-		case "after":
-			if t, err := parseDate(value); err == nil {
-				criteria.DateRange.Min = t
-			}
-		case "before":
-			if t, err := parseDate(value); err == nil {
-				criteria.DateRange.Max = t
-			}
-		case "date":
-			if t, err := parseDate(value); err == nil {
-				criteria.DateRange.Min = t
-				criteria.DateRange.Max = t.Add(24 * time.Hour)
-			}
+		children = append(children, next)
+	}
+
+	if len(children) == 1 {
+		return first, nil
+	}
+
+	return &OrNode{Children: children}, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	first, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	children := []Node{first}
+	for {
+		switch p.peek().Kind {
+		case tokenAnd:
+			p.advance()
+		case tokenTerm, tokenNot, tokenLParen:
+			// implicit AND
 		default:
-			criteria.Filters[key] = value
+			if len(children) == 1 {
+				return first, nil
+			}
+
+			return &AndNode{Children: children}, nil
+		}
+
+		next, err := p.parseUnary()
+		if err != nil {
+			return nil, err
 		}
+
+		children = append(children, next)
 	}
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	if p.peek().Kind == tokenNot {
+		p.advance()
 
-	// 2. Remove filters from input to get remaining text
-	// We replace each match with a space to prevent accidentally merging surrounding words
-	cleanedInput := filterRegex.ReplaceAllString(input, " ")
-
-	// 3. Extract remaining text (tokens)
-	tokens := strings.FieldsSeq(cleanedInput)
-	for t := range tokens {
-		t = strings.TrimSpace(t)
-		// Trim quotes from tokens if they were part of a phrase that got split
-		t = strings.Trim(t, "\"'")
-		if t != "" {
-			criteria.Text = append(criteria.Text, t)
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
 		}
+
+		// --foo is just foo
+		if not, ok := child.(*NotNode); ok {
+			return not.Child, nil
+		}
+
+		return &NotNode{Child: child}, nil
 	}
 
-	return criteria
+	return p.parsePrimary()
 }
 
-func parseDate(value string) (time.Time, error) {
-	// DD-MM-YYYY
-	return time.Parse("02-01-2006", value)
+func (p *parser) parsePrimary() (Node, error) {
+	tok := p.advance()
+
+	switch tok.Kind {
+	case tokenTerm:
+		return &TermNode{Key: tok.Key, Value: tok.Value, Quoted: tok.Quoted}, nil
+	case tokenLParen:
+		if p.peek().Kind == tokenRParen {
+			return nil, &ParseError{Pos: tok.Pos, Msg: "empty group"}
+		}
+
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if closing := p.advance(); closing.Kind != tokenRParen {
+			return nil, &ParseError{Pos: tok.Pos, Msg: "missing closing parenthesis"}
+		}
+
+		return node, nil
+	default:
+		return nil, &ParseError{Pos: tok.Pos, Msg: fmt.Sprintf("expected a search term but found %s", tok.Kind)}
+	}
 }
@@ -1,7 +1,7 @@
 package search
 
 import (
-	"reflect"
+	"errors"
 	"testing"
 	"time"
 )
@@ -10,116 +10,239 @@ func TestParseQuery(t *testing.T) {
 	tests := []struct {
 		name     string
 		input    string
-		expected SearchCriteria
+		expected string
 	}{
 		{
-			name:  "Basic text only",
-			input: "hello world",
-			expected: SearchCriteria{
-				Text:    []string{"hello", "world"},
-				Filters: map[string]string{},
-			},
-		},
-		{
-			name:  "Basic filter only",
-			input: "rating:5",
-			expected: SearchCriteria{
-				Text: []string{},
-				Filters: map[string]string{
-					"rating": "5",
-				},
-			},
-		},
-		{
-			name:  "Mixed text and filter",
-			input: "sunset rating:5 beach",
-			expected: SearchCriteria{
-				Text: []string{"sunset", "beach"},
-				Filters: map[string]string{
-					"rating": "5",
-				},
-			},
-		},
-		{
-			name:  "Quoted value",
-			input: "title:\"auckland park\" park",
-			expected: SearchCriteria{
-				Text: []string{"park"},
-				Filters: map[string]string{
-					"title": "auckland park",
-				},
-			},
-		},
-		{
-			name:  "Single quoted value",
-			input: "tag:'maboneng precinct'",
-			expected: SearchCriteria{
-				Text: []string{},
-				Filters: map[string]string{
-					"tag": "maboneng precinct",
-				},
-			},
-		},
-		{
-			name:  "Comparison operator",
-			input: "rating:>=4",
-			expected: SearchCriteria{
-				Text: []string{},
-				Filters: map[string]string{
-					"rating": ">=4",
-				},
-			},
-		},
-		{
-			name:  "Complex mix",
-			input: "  party narowbi   is:public  rating:5   \"johannesburg\" ",
-			expected: SearchCriteria{
-				Text: []string{"party", "narowbi", "johannesburg"}, // Quotes in text are split by Fields currently
-				Filters: map[string]string{
-					"is":     "public",
-					"rating": "5",
-				},
-			},
-		},
-		{
-			name:  "Date filters",
-			input: "after:01-01-2023 before:31-12-2023",
-			expected: SearchCriteria{
-				Text: []string{},
-				DateRange: DateRange{
-					Min: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
-					Max: time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC),
-				},
-				Filters: map[string]string{},
-			},
+			name:     "Empty query",
+			input:    "   ",
+			expected: "",
+		},
+		{
+			name:     "Basic text only",
+			input:    "hello world",
+			expected: "(hello AND world)",
+		},
+		{
+			name:     "Basic filter only",
+			input:    "rating:5",
+			expected: "rating:5",
+		},
+		{
+			name:     "Mixed text and filter",
+			input:    "sunset rating:5 beach",
+			expected: "(sunset AND rating:5 AND beach)",
+		},
+		{
+			name:     "Quoted value",
+			input:    "title:\"auckland park\" park",
+			expected: "(title:\"auckland park\" AND park)",
+		},
+		{
+			name:     "Single quoted value",
+			input:    "tag:'maboneng precinct'",
+			expected: "tag:\"maboneng precinct\"",
+		},
+		{
+			name:     "Comparison operator",
+			input:    "rating:>=4",
+			expected: "rating:>=4",
+		},
+		{
+			name:     "Complex mix",
+			input:    "  party narowbi   is:public  rating:5   \"johannesburg\" ",
+			expected: "(party AND narowbi AND is:public AND rating:5 AND \"johannesburg\")",
+		},
+		{
+			name:     "Date filters",
+			input:    "after:01-01-2023 before:31-12-2023",
+			expected: "(after:01-01-2023 AND before:31-12-2023)",
+		},
+		{
+			name:     "Keys are lower cased",
+			input:    "Rating:5",
+			expected: "rating:5",
+		},
+		{
+			name:     "Same key twice with OR",
+			input:    "make:canon OR make:nikon",
+			expected: "(make:canon OR make:nikon)",
+		},
+		{
+			name:     "OR binds looser than AND",
+			input:    "a b OR c",
+			expected: "((a AND b) OR c)",
+		},
+		{
+			name:     "Explicit AND",
+			input:    "a AND b",
+			expected: "(a AND b)",
+		},
+		{
+			name:     "Dash negation",
+			input:    "-is:private",
+			expected: "-is:private",
+		},
+		{
+			name:     "NOT keyword",
+			input:    "NOT is:private",
+			expected: "-is:private",
+		},
+		{
+			name:     "Double negation cancels out",
+			input:    "--sunset",
+			expected: "sunset",
+		},
+		{
+			name:     "Lower case operators are text",
+			input:    "black or white",
+			expected: "(black AND or AND white)",
+		},
+		{
+			name:     "Grouping",
+			input:    "make:canon OR make:nikon -is:private (rating:>=4 OR favourited:true)",
+			expected: "(make:canon OR (make:nikon AND -is:private AND (rating:>=4 OR favourited:true)))",
+		},
+		{
+			name:     "Negated group",
+			input:    "-(make:canon OR make:nikon)",
+			expected: "-(make:canon OR make:nikon)",
+		},
+		{
+			name:     "Nested groups",
+			input:    "((a OR b) c)",
+			expected: "((a OR b) AND c)",
+		},
+		{
+			name:     "Hyphen inside word is not negation",
+			input:    "black-and-white",
+			expected: "black-and-white",
+		},
+		{
+			name:     "Non identifier key is text",
+			input:    "https://example.com",
+			expected: "https://example.com",
+		},
+		{
+			name:     "Unterminated quote runs to end",
+			input:    "lens:\"RF 50mm",
+			expected: "lens:\"RF 50mm\"",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := ParseQuery(tt.input)
-			
-			if len(got.Filters) == 0 && len(tt.expected.Filters) == 0 {
-				got.Filters = map[string]string{}
-				tt.expected.Filters = map[string]string{}
+			got, err := ParseQuery(tt.input)
+			if err != nil {
+				t.Fatalf("ParseQuery() returned error: %v", err)
+			}
+
+			if got.Raw != tt.input {
+				t.Errorf("ParseQuery() Raw = %q, want %q", got.Raw, tt.input)
 			}
 
-			if !reflect.DeepEqual(got.Filters, tt.expected.Filters) {
-				t.Errorf("ParseQuery() Filters = %v, want %v", got.Filters, tt.expected.Filters)
+			if got.String() != tt.expected {
+				t.Errorf("ParseQuery() = %q, want %q", got.String(), tt.expected)
 			}
+		})
+	}
+}
 
-			// sort text slices for comparison as order might vary slightly depending on split implementation details
-			// although fields preserves order
-			if !reflect.DeepEqual(got.Text, tt.expected.Text) {
-				t.Errorf("ParseQuery() Text = %v, want %v", got.Text, tt.expected.Text)
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantPos int
+	}{
+		{name: "Missing closing parenthesis", input: "(a OR b", wantPos: 0},
+		{name: "Unexpected closing parenthesis", input: "a)", wantPos: 1},
+		{name: "Empty group", input: "a ()", wantPos: 2},
+		{name: "Dangling OR", input: "a OR", wantPos: 4},
+		{name: "Leading OR", input: "OR a", wantPos: 0},
+		{name: "Dangling NOT", input: "a NOT", wantPos: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseQuery(tt.input)
+			if err == nil {
+				t.Fatalf("ParseQuery(%q) expected an error", tt.input)
 			}
 
-			if !got.DateRange.Min.Equal(tt.expected.DateRange.Min) {
-				t.Errorf("ParseQuery() DateRange.Min = %v, want %v", got.DateRange.Min, tt.expected.DateRange.Min)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("ParseQuery() error = %T, want *ParseError", err)
 			}
-			if !got.DateRange.Max.Equal(tt.expected.DateRange.Max) {
-				t.Errorf("ParseQuery() DateRange.Max = %v, want %v", got.DateRange.Max, tt.expected.DateRange.Max)
+
+			if parseErr.Pos != tt.wantPos {
+				t.Errorf("ParseQuery() error position = %d, want %d", parseErr.Pos, tt.wantPos)
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestQueryTerms(t *testing.T) {
+	query, err := ParseQuery("sunset -(make:canon OR rating:5)")
+	if err != nil {
+		t.Fatalf("ParseQuery() returned error: %v", err)
+	}
+
+	terms := query.Terms()
+	want := []string{"sunset", "make:canon", "rating:5"}
+	if len(terms) != len(want) {
+		t.Fatalf("Terms() returned %d terms, want %d", len(terms), len(want))
+	}
+
+	for i, term := range terms {
+		if term.String() != want[i] {
+			t.Errorf("Terms()[%d] = %q, want %q", i, term.String(), want[i])
+		}
+	}
+}
+
+func TestResolveDate(t *testing.T) {
+	loc, err := time.LoadLocation("Africa/Johannesburg")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+
+	// Wednesday 2024-06-12 01:30 in Johannesburg, still the 11th in UTC
+	now := time.Date(2024, 6, 11, 23, 30, 0, 0, time.UTC)
+	day := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 0, 0, 0, 0, loc)
+	}
+
+	tests := []struct {
+		input string
+		want  dateSpan
+		ok    bool
+	}{
+		{"2024-06-15", dateSpan{day(2024, 6, 15), day(2024, 6, 16)}, true},
+		{"15-06-2024", dateSpan{day(2024, 6, 15), day(2024, 6, 16)}, true},
+		{"2024-06", dateSpan{day(2024, 6, 1), day(2024, 7, 1)}, true},
+		{"2024", dateSpan{day(2024, 1, 1), day(2025, 1, 1)}, true},
+		{"today", dateSpan{day(2024, 6, 12), day(2024, 6, 13)}, true},
+		{"Yesterday", dateSpan{day(2024, 6, 11), day(2024, 6, 12)}, true},
+		{"this week", dateSpan{day(2024, 6, 10), day(2024, 6, 17)}, true},
+		{"last month", dateSpan{day(2024, 5, 1), day(2024, 6, 1)}, true},
+		{"7 days ago", dateSpan{day(2024, 6, 5), day(2024, 6, 6)}, true},
+		{"2 weeks ago", dateSpan{day(2024, 5, 29), day(2024, 5, 30)}, true},
+		{"2024-01..2024-03", dateSpan{day(2024, 1, 1), day(2024, 4, 1)}, true},
+		{"2024..", dateSpan{Start: day(2024, 1, 1)}, true},
+		{"..yesterday", dateSpan{End: day(2024, 6, 12)}, true},
+		{"..", dateSpan{}, false},
+		{"someday", dateSpan{}, false},
+		{"2024-13-01", dateSpan{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := resolveDate(tt.input, now, loc)
+		if ok != tt.ok {
+			t.Errorf("resolveDate(%q) ok = %v, want %v", tt.input, ok, tt.ok)
+			continue
+		}
+
+		if !got.Start.Equal(tt.want.Start) || !got.End.Equal(tt.want.End) {
+			t.Errorf("resolveDate(%q) = [%v, %v), want [%v, %v)", tt.input, got.Start, got.End, tt.want.Start, tt.want.End)
+		}
+	}
+}
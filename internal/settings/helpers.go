@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"strconv"
+	"time"
 
 	"viz/internal/dto"
 	"viz/internal/entities"
@@ -186,6 +187,39 @@ func GetSetting(db *gorm.DB, name string, userID *string) (string, error) {
 	return def.Value, nil
 }
 
+// GetLocation returns the timezone from the timezone setting, falling back
+// to UTC when it isn't set or isn't a valid IANA zone.
+func GetLocation(db *gorm.DB, userID *string) *time.Location {
+	name, err := GetSetting(db, SettingNameTimezone, userID)
+	if err != nil || name == "" {
+		return time.UTC
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
+// GetWatermarkProfile returns the watermark profile from the
+// image_watermark setting, the user's own when they have one
+func GetWatermarkProfile(db *gorm.DB, userID *string) (dto.WatermarkProfile, error) {
+	var profile dto.WatermarkProfile
+
+	value, err := GetSetting(db, SettingNameImageWatermark, userID)
+	if err != nil {
+		return profile, err
+	}
+
+	if err := json.Unmarshal([]byte(value), &profile); err != nil {
+		return profile, fmt.Errorf("invalid watermark profile: %w", err)
+	}
+
+	return profile, nil
+}
+
 // Helper function for boolean settings
 func BoolSetting(name string, displayName string, value bool, isUserEditable bool, group, description string) entities.SettingDefault {
 	return entities.SettingDefault{
@@ -12,9 +12,15 @@ const (
 	SettingNameViewMode             = "ui_default_view_mode"
 	SettingNameImageDownloadQuality = "image_download_quality"
 	SettingNameImageDownloadFormat  = "image_download_format"
+	SettingNameImageDownloadProfile = "image_download_profile"
+	SettingNameImageDownloadIntent  = "image_download_intent"
+	SettingNameImageDownloadICC     = "image_download_icc"
+	SettingNameImageDownloadDepth   = "image_download_bit_depth"
 	SettingNameImagePreviewFormat   = "image_preview_format"
 	SettingNameImageResizeKernel    = "image_resize_kernel"
 	SettingNameImageVisibleMetadata = "image_visible_metadata"
+	SettingNameImageWatermark       = "image_watermark"
 	SettingNameStripMetadata        = "privacy_download_strip_metadata"
+	SettingNameMetadataPolicy       = "privacy_metadata_policy"
 	SettingNameOnboardingComplete   = "onboarding_complete"
 )
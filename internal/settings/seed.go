@@ -14,6 +14,7 @@ import (
 
 	"gorm.io/gorm"
 
+	"viz/internal/dto"
 	"viz/internal/entities"
 	imaTime "viz/internal/time"
 	"viz/internal/utils"
@@ -111,11 +112,47 @@ var defaultSettings = []entities.SettingDefault{
 		"image_download_format",
 		"",
 		"original",
-		[]string{"original", "jpg", "png", "webp", "avif"},
+		[]string{"original", "jpg", "png", "webp", "avif", "jxl", "tiff"},
 		true,
 		"Images",
 		"Default file format for downloaded images.",
 	),
+	EnumSetting(
+		"image_download_profile",
+		"",
+		"srgb",
+		[]string{"srgb", "p3", "adobe-rgb", "original"},
+		true,
+		"Images",
+		"Colour profile downloaded images are converted to when format conversion occurs, 'original' keeps the original's.",
+	),
+	EnumSetting(
+		"image_download_intent",
+		"",
+		"perceptual",
+		[]string{"perceptual", "relative", "saturation", "absolute"},
+		true,
+		"Images",
+		"Rendering intent used to convert downloaded images to their colour profile.",
+	),
+	EnumSetting(
+		"image_download_icc",
+		"",
+		"embed",
+		[]string{"embed", "strip"},
+		true,
+		"Images",
+		"Whether downloaded images embed their colour profile or leave it out.",
+	),
+	IntSetting(
+		"image_download_bit_depth",
+		"",
+		8,
+		[]int{8, 16},
+		true,
+		"Images",
+		"Bits per channel of downloaded PNG and TIFF images.",
+	),
 	EnumSetting(
 		"image_preview_format",
 		"",
@@ -140,7 +177,16 @@ var defaultSettings = []entities.SettingDefault{
 		false,
 		true,
 		"Privacy",
-		"Automatically remove EXIF/GPS metadata when creating download links.",
+		"Remove all EXIF, XMP and IPTC metadata, GPS included, from images downloaded through share links.",
+	),
+	EnumSetting(
+		"privacy_metadata_policy",
+		"",
+		"no-gps",
+		[]string{"keep", "no-gps", "copyright", "none"},
+		true,
+		"Privacy",
+		"Metadata kept on your images when others view them: all of it, all but the GPS location, only the creator and copyright, or none. You always see all of it.",
 	),
 	JsonSetting(
 		"image_visible_metadata",
@@ -150,6 +196,18 @@ var defaultSettings = []entities.SettingDefault{
 		"Images",
 		"A JSON array of EXIF/image metadata fields to display in image detail views.",
 	),
+	JsonSetting(
+		"image_watermark",
+		"Watermark",
+		dto.WatermarkProfile{
+			Position: dto.WatermarkProfilePositionSoutheast,
+			Opacity:  0.5,
+			Scale:    0.2,
+		},
+		true,
+		"Images",
+		"Watermark drawn on images requested with watermark=1 and on share links signed with a watermark. Upload a logo to draw it instead of the text.",
+	),
 	BoolSetting(
 		"first_run_complete",
 		"",
@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	libos "viz/internal/os"
+)
+
+// tempPrefix marks files being written by Local.Put, they're renamed into
+// place once complete and never listed
+const tempPrefix = ".tmp-"
+
+// Local stores objects as files below a root directory, keys are paths
+// relative to it
+type Local struct {
+	root string
+}
+
+func NewLocal(root string) *Local {
+	return &Local{root: root}
+}
+
+func (l *Local) Name() string {
+	return BackendLocal
+}
+
+// Root returns the directory the objects are stored in
+func (l *Local) Root() string {
+	return l.root
+}
+
+func (l *Local) path(key string) (string, error) {
+	clean, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(l.root, filepath.FromSlash(clean)), nil
+}
+
+func (l *Local) objectPath(key string) (string, error) {
+	clean, err := objectKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(l.root, filepath.FromSlash(clean)), nil
+}
+
+func (l *Local) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := l.objectPath(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(p)
+}
+
+func (l *Local) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	clean, err := objectKey(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	p := filepath.Join(l.root, filepath.FromSlash(clean))
+
+	info, err := os.Stat(p)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	if info.IsDir() {
+		return ObjectInfo{}, &fs.PathError{Op: "stat", Path: p, Err: ErrNotExist}
+	}
+
+	return ObjectInfo{Key: clean, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// Put writes to a temporary file next to the destination and renames it
+// into place, so the object is replaced atomically
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	p, err := l.objectPath(key)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(p)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, tempPrefix)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := tmp.Name()
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Chmod(tmpPath, 0o644); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, p); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	p, err := l.objectPath(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (l *Local) DeleteAll(ctx context.Context, prefix string) error {
+	p, err := l.objectPath(prefix)
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(p)
+}
+
+func (l *Local) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	root, err := l.path(prefix)
+	if err != nil {
+		return err
+	}
+
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// the folder not existing is the same as it being empty
+			if p == root && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if d.IsDir() || strings.HasPrefix(d.Name(), tempPrefix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			// removed while listing
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+
+			return err
+		}
+
+		rel, err := filepath.Rel(l.root, p)
+		if err != nil {
+			return err
+		}
+
+		return fn(ObjectInfo{Key: filepath.ToSlash(rel), Size: info.Size(), ModTime: info.ModTime()})
+	})
+
+	return err
+}
+
+func (l *Local) Move(ctx context.Context, src, dst string) error {
+	srcPath, err := l.objectPath(src)
+	if err != nil {
+		return err
+	}
+
+	dstPath, err := l.objectPath(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(srcPath); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dstPath), os.ModePerm); err != nil {
+		return err
+	}
+
+	if err := libos.MoveDirWithFallback(srcPath, dstPath); err != nil {
+		return fmt.Errorf("failed to move %s to %s: %w", src, dst, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"viz/internal/config"
+)
+
+func TestLocal(t *testing.T) {
+	testStorage(t, NewLocal(t.TempDir()))
+}
+
+func TestLocalSkipsPartialWrites(t *testing.T) {
+	root := t.TempDir()
+	s := NewLocal(root)
+
+	if err := os.MkdirAll(filepath.Join(root, "images", "a"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "images", "a", tempPrefix+"123"), []byte("partial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := listKeys(t, s, "images"); len(got) != 0 {
+		t.Errorf("List() = %v, want partial writes skipped", got)
+	}
+
+	if got := listKeys(t, s, "missing"); len(got) != 0 {
+		t.Errorf("List() of a missing folder = %v", got)
+	}
+}
+
+func TestLocalDeleteAllNeedsPrefix(t *testing.T) {
+	s := NewLocal(t.TempDir())
+	if err := s.DeleteAll(context.Background(), ""); err == nil {
+		t.Error("DeleteAll() of the root succeeded")
+	}
+}
+
+func TestNewLocationLocalDirectory(t *testing.T) {
+	base := t.TempDir()
+	abs := t.TempDir()
+
+	tests := []struct {
+		dir  string
+		want string
+	}{
+		{dir: "", want: base},
+		{dir: "archive", want: filepath.Join(base, "archive")},
+		{dir: abs, want: abs},
+	}
+
+	for _, tt := range tests {
+		s, err := NewLocation(config.StorageLocation{Backend: BackendLocal, Directory: tt.dir}, base)
+		if err != nil {
+			t.Fatalf("NewLocation(%q) = %v", tt.dir, err)
+		}
+
+		if err := PutBytes(context.Background(), s, "images/a/photo.jpg", []byte("original")); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := os.Stat(filepath.Join(tt.want, "images", "a", "photo.jpg")); err != nil {
+			t.Errorf("NewLocation(%q) didn't store in %s: %v", tt.dir, tt.want, err)
+		}
+	}
+}
+
+func TestSameLocation(t *testing.T) {
+	base := t.TempDir()
+	if err := os.Mkdir(filepath.Join(base, "archive"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(filepath.Join(base, "archive"), link); err != nil {
+		t.Fatal(err)
+	}
+
+	newS3 := func(endpoint, bucket, prefix string) Storage {
+		s, err := NewS3(config.S3Config{Endpoint: endpoint, Bucket: bucket, Prefix: prefix, AccessKeyID: "key", SecretAccessKey: "secret"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return s
+	}
+
+	tests := []struct {
+		name string
+		a, b Storage
+		want bool
+	}{
+		{"same directory", NewLocal(filepath.Join(base, "archive")), NewLocal(filepath.Join(base, "archive") + "/"), true},
+		{"unclean path", NewLocal(filepath.Join(base, "archive")), NewLocal(filepath.Join(base, "x", "..", "archive")), true},
+		{"symlink", NewLocal(filepath.Join(base, "archive")), NewLocal(link), true},
+		{"different directories", NewLocal(base), NewLocal(filepath.Join(base, "archive")), false},
+		{"same bucket and prefix", newS3("minio:9000", "photos", "viz"), newS3("http://MINIO:9000", "photos", "/viz/"), true},
+		{"different prefix", newS3("minio:9000", "photos", "viz"), newS3("minio:9000", "photos", "archive"), false},
+		{"different bucket", newS3("minio:9000", "photos", "viz"), newS3("minio:9000", "archive", "viz"), false},
+		{"different endpoint", newS3("minio:9000", "photos", "viz"), newS3("backup:9000", "photos", "viz"), false},
+		{"different backends", NewLocal(base), newS3("minio:9000", "photos", ""), false},
+	}
+
+	for _, tt := range tests {
+		if got := SameLocation(tt.a, tt.b); got != tt.want {
+			t.Errorf("%s: SameLocation() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,408 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"viz/internal/config"
+)
+
+// S3 stores objects in a bucket of an S3-compatible service such as AWS,
+// MinIO, Garage or Google Cloud Storage with HMAC keys. Keys are stored
+// below the configured prefix.
+type S3 struct {
+	client    *http.Client
+	endpoint  *url.URL
+	bucket    string
+	prefix    string
+	pathStyle bool
+	signer    signer
+}
+
+// NewS3 creates an S3 backend. Without an endpoint it talks to AWS in the
+// configured region.
+func NewS3(cfg config.S3Config) (*S3, error) {
+	if strings.TrimSpace(cfg.Bucket) == "" {
+		return nil, errors.New("storage: s3 bucket is not set")
+	}
+
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("storage: s3 access key id and secret access key are required")
+	}
+
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("storage: invalid s3 endpoint %q: %w", cfg.Endpoint, err)
+	}
+
+	prefix, err := cleanKey(cfg.Prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3{
+		client:    &http.Client{},
+		endpoint:  u,
+		bucket:    cfg.Bucket,
+		prefix:    prefix,
+		pathStyle: cfg.UsePathStyle,
+		signer: signer{
+			accessKeyID:     cfg.AccessKeyID,
+			secretAccessKey: cfg.SecretAccessKey,
+			region:          region,
+			service:         "s3",
+		},
+	}, nil
+}
+
+func (s *S3) Name() string {
+	return BackendS3
+}
+
+// fullKey maps a storage key to the object key in the bucket
+func (s *S3) fullKey(key string) string {
+	return Join(s.prefix, key)
+}
+
+// storageKey maps an object key in the bucket back to a storage key
+func (s *S3) storageKey(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+
+	return strings.TrimPrefix(key, s.prefix+"/")
+}
+
+// folderPrefix is the bucket prefix matching the objects in a folder
+func (s *S3) folderPrefix(prefix string) (string, error) {
+	clean, err := cleanKey(prefix)
+	if err != nil {
+		return "", err
+	}
+
+	full := s.fullKey(clean)
+	if full == "" {
+		return "", nil
+	}
+
+	return full + "/", nil
+}
+
+// objectURL returns the URL of an object, or of the bucket for an empty key
+func (s *S3) objectURL(key string, query url.Values) *url.URL {
+	u := *s.endpoint
+	p := "/" + key
+	if s.pathStyle {
+		p = "/" + s.bucket + p
+	} else {
+		u.Host = s.bucket + "." + u.Host
+	}
+
+	u.Path = strings.TrimSuffix(s.endpoint.Path, "/") + p
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	return &u
+}
+
+// do signs and sends a request, turning error responses into errors
+func (s *S3) do(ctx context.Context, method string, key string, query url.Values, header http.Header, body io.Reader, size int64) (*http.Response, error) {
+	// net/http takes a length of 0 as unknown unless the body is NoBody,
+	// and sends bodies of unknown length chunked, which S3 refuses
+	if body != nil && size == 0 {
+		body = http.NoBody
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key, query).String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	if body != nil {
+		req.ContentLength = size
+	}
+
+	s.signer.sign(req, unsignedPayload, time.Now())
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= 300 {
+		defer res.Body.Close()
+		return nil, s3Error(method, key, res)
+	}
+
+	return res, nil
+}
+
+// s3ErrorBody is the XML body S3 sends with an error response
+type s3ErrorBody struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func s3Error(method, key string, res *http.Response) error {
+	var body s3ErrorBody
+	data, _ := io.ReadAll(io.LimitReader(res.Body, 64*1024))
+	_ = xml.Unmarshal(data, &body)
+
+	if res.StatusCode == http.StatusNotFound && (body.Code == "" || body.Code == "NoSuchKey") {
+		return &fs.PathError{Op: strings.ToLower(method), Path: key, Err: ErrNotExist}
+	}
+
+	if body.Code == "" {
+		body.Code = res.Status
+	}
+
+	return fmt.Errorf("s3 %s %s: %s: %s", method, key, body.Code, body.Message)
+}
+
+func (s *S3) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	clean, err := objectKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.do(ctx, http.MethodGet, s.fullKey(clean), nil, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Body, nil
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	clean, err := objectKey(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	res, err := s.do(ctx, http.MethodHead, s.fullKey(clean), nil, nil, nil, 0)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	res.Body.Close()
+
+	modTime, _ := http.ParseTime(res.Header.Get("Last-Modified"))
+	return ObjectInfo{Key: clean, Size: res.ContentLength, ModTime: modTime}, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	clean, err := objectKey(key)
+	if err != nil {
+		return err
+	}
+
+	if size < 0 {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+
+		r = bytes.NewReader(data)
+		size = int64(len(data))
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/octet-stream")
+
+	res, err := s.do(ctx, http.MethodPut, s.fullKey(clean), nil, header, r, size)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	return nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	clean, err := objectKey(key)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.do(ctx, http.MethodDelete, s.fullKey(clean), nil, nil, nil, 0)
+	if err != nil {
+		if errors.Is(err, ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+	res.Body.Close()
+
+	return nil
+}
+
+func (s *S3) DeleteAll(ctx context.Context, prefix string) error {
+	if _, err := objectKey(prefix); err != nil {
+		return err
+	}
+
+	var keys []string
+	err := s.List(ctx, prefix, func(obj ObjectInfo) error {
+		keys = append(keys, obj.Key)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := s.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// listBucketResult is the body of a ListObjectsV2 response
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	full, err := s.folderPrefix(prefix)
+	if err != nil {
+		return err
+	}
+
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		if full != "" {
+			query.Set("prefix", full)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		res, err := s.do(ctx, http.MethodGet, "", query, nil, nil, 0)
+		if err != nil {
+			return err
+		}
+
+		var page listBucketResult
+		err = xml.NewDecoder(res.Body).Decode(&page)
+		res.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to decode s3 listing: %w", err)
+		}
+
+		for _, obj := range page.Contents {
+			// folder markers created by some tools aren't objects
+			if strings.HasSuffix(obj.Key, "/") {
+				continue
+			}
+
+			if err := fn(ObjectInfo{Key: s.storageKey(obj.Key), Size: obj.Size, ModTime: obj.LastModified}); err != nil {
+				return err
+			}
+		}
+
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return nil
+		}
+
+		token = page.NextContinuationToken
+	}
+}
+
+// Move copies every object to its new key and then deletes the original,
+// S3 has no rename
+func (s *S3) Move(ctx context.Context, src, dst string) error {
+	srcClean, err := objectKey(src)
+	if err != nil {
+		return err
+	}
+
+	dstClean, err := objectKey(dst)
+	if err != nil {
+		return err
+	}
+
+	var objects []ObjectInfo
+	err = s.List(ctx, srcClean, func(obj ObjectInfo) error {
+		objects = append(objects, obj)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objects {
+		target := dstClean + strings.TrimPrefix(obj.Key, srcClean)
+
+		header := http.Header{}
+		header.Set("X-Amz-Copy-Source", uriEncode("/"+s.bucket+"/"+s.fullKey(obj.Key), false))
+
+		res, err := s.do(ctx, http.MethodPut, s.fullKey(target), nil, header, nil, 0)
+		if err != nil {
+			return fmt.Errorf("failed to copy %s to %s: %w", obj.Key, target, err)
+		}
+		res.Body.Close()
+
+		if err := s.Delete(ctx, obj.Key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// uriEncode percent-encodes s the way SigV4 expects, leaving slashes alone
+// unless encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			b.WriteString("%" + strings.ToUpper(strconv.FormatUint(uint64(c)|0x100, 16)[1:]))
+		}
+	}
+
+	return b.String()
+}
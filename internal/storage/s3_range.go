@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// rangeSkipLimit is how far ahead a seek reads through and discards the
+// open response instead of starting a new ranged request
+const rangeSkipLimit = 256 * 1024
+
+// OpenSeekable reads the object at key with ranged GET requests, a seek
+// starts a new request from the offset the next read is at
+func (s *S3) OpenSeekable(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	info, err := s.Stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3RangeReader{ctx: ctx, s: s, key: s.fullKey(info.Key), size: info.Size}, nil
+}
+
+// s3RangeReader is an io.ReadSeekCloser over an object in a bucket. Only
+// the response for the current position is kept open.
+type s3RangeReader struct {
+	ctx  context.Context
+	s    *S3
+	key  string
+	size int64
+
+	// offset is where the next Read starts, body is positioned at bodyAt
+	offset int64
+	body   io.ReadCloser
+	bodyAt int64
+}
+
+func (r *s3RangeReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.body != nil && r.bodyAt != r.offset {
+		if skip := r.offset - r.bodyAt; skip > 0 && skip <= rangeSkipLimit {
+			n, err := io.CopyN(io.Discard, r.body, skip)
+			r.bodyAt += n
+			if err != nil {
+				r.closeBody()
+			}
+		} else {
+			r.closeBody()
+		}
+	}
+
+	if r.body == nil {
+		header := http.Header{}
+		header.Set("Range", fmt.Sprintf("bytes=%d-", r.offset))
+
+		res, err := r.s.do(r.ctx, http.MethodGet, r.key, nil, header, nil, 0)
+		if err != nil {
+			return 0, err
+		}
+
+		r.body = res.Body
+		r.bodyAt = r.offset
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	r.bodyAt += int64(n)
+	if errors.Is(err, io.EOF) {
+		r.closeBody()
+		if r.offset < r.size {
+			err = io.ErrUnexpectedEOF
+		}
+	}
+
+	return n, err
+}
+
+func (r *s3RangeReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, fmt.Errorf("s3 seek %s: invalid whence %d", r.key, whence)
+	}
+
+	if offset < 0 {
+		return 0, fmt.Errorf("s3 seek %s: negative position", r.key)
+	}
+
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *s3RangeReader) Close() error {
+	r.closeBody()
+	return nil
+}
+
+func (r *s3RangeReader) closeBody() {
+	if r.body != nil {
+		_ = r.body.Close()
+		r.body = nil
+	}
+}
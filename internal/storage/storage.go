@@ -0,0 +1,275 @@
+// Package storage abstracts where image files live. Files are objects
+// addressed by slash separated keys like "images/<uid>/photo.jpg", so the
+// same layout works on a local disk and in an S3-compatible bucket.
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"viz/internal/config"
+)
+
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+var (
+	// ErrNotExist is returned, possibly wrapped, when an object doesn't
+	// exist. It's fs.ErrNotExist so os.IsNotExist style checks keep working.
+	ErrNotExist = fs.ErrNotExist
+	// ErrInvalidKey is returned for keys that are empty where an object is
+	// expected or that try to escape the storage root
+	ErrInvalidKey = errors.New("storage: invalid key")
+)
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Storage stores the files of the library: originals and sidecars under
+// an image's folder, its cached transforms in a folder below that, and
+// deleted images in the trash. Folders are key prefixes, they exist for as
+// long as there are objects in them.
+type Storage interface {
+	// Open returns a reader for the object at key, callers close it
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Stat returns the size and modification time of the object at key
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	// Put stores the object at key, replacing any existing one. Readers
+	// never see a partially written object.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Delete removes the object at key, it's not an error if it's missing
+	Delete(ctx context.Context, key string) error
+	// DeleteAll removes every object in the folder prefix
+	DeleteAll(ctx context.Context, prefix string) error
+	// List calls fn for every object in the folder prefix and the folders
+	// below it, an empty prefix lists everything. Listing stops at the
+	// first error fn returns.
+	List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error
+	// Move moves every object in the folder src to the folder dst
+	Move(ctx context.Context, src, dst string) error
+	// Name describes the backend for logs and the admin API
+	Name() string
+}
+
+// New creates the storage backend selected in the config. The local
+// backend keeps files in baseDir.
+func New(cfg config.StorageConfig, baseDir string) (Storage, error) {
+	return NewLocation(config.StorageLocation{Backend: cfg.Backend, S3: cfg.S3}, baseDir)
+}
+
+// NewLocation creates the backend of a named storage location. A local
+// location without a directory keeps files in baseDir.
+func NewLocation(cfg config.StorageLocation, baseDir string) (Storage, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.Backend)) {
+	case "", BackendLocal:
+		dir := cfg.Directory
+		if dir == "" {
+			dir = baseDir
+		} else if !filepath.IsAbs(dir) {
+			dir = filepath.Join(baseDir, dir)
+		}
+
+		return NewLocal(dir), nil
+	case BackendS3:
+		return NewS3(cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q, use %q or %q", cfg.Backend, BackendLocal, BackendS3)
+	}
+}
+
+// SeekOpener is implemented by backends whose Open can't seek but which can
+// still read an object from any offset without downloading all of it
+type SeekOpener interface {
+	OpenSeekable(ctx context.Context, key string) (io.ReadSeekCloser, error)
+}
+
+// OpenSeekable opens the object at key for random access, for decoders of
+// large images that read the parts they need instead of the whole file.
+// Objects of backends that can't seek are read into memory.
+func OpenSeekable(ctx context.Context, s Storage, key string) (io.ReadSeekCloser, error) {
+	if opener, ok := s.(SeekOpener); ok {
+		return opener.OpenSeekable(ctx, key)
+	}
+
+	r, err := s.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if rs, ok := r.(io.ReadSeekCloser); ok {
+		return rs, nil
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return nopSeekCloser{bytes.NewReader(data)}, nil
+}
+
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error {
+	return nil
+}
+
+// ReadAll reads the whole object at key
+func ReadAll(ctx context.Context, s Storage, key string) ([]byte, error) {
+	r, err := s.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+// PutBytes stores data as the object at key
+func PutBytes(ctx context.Context, s Storage, key string, data []byte) error {
+	return s.Put(ctx, key, bytes.NewReader(data), int64(len(data)))
+}
+
+// Exists reports whether there's an object at key
+func Exists(ctx context.Context, s Storage, key string) (bool, error) {
+	if _, err := s.Stat(ctx, key); err != nil {
+		if errors.Is(err, ErrNotExist) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Copy copies every object in the folder prefix from src to dst, keeping
+// their keys, and returns how many objects were copied
+func Copy(ctx context.Context, src, dst Storage, prefix string) (int, error) {
+	copied := 0
+	err := src.List(ctx, prefix, func(obj ObjectInfo) error {
+		r, err := src.Open(ctx, obj.Key)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		if err := dst.Put(ctx, obj.Key, r, obj.Size); err != nil {
+			return fmt.Errorf("failed to copy %s: %w", obj.Key, err)
+		}
+
+		copied++
+		return nil
+	})
+
+	return copied, err
+}
+
+// SameLocation reports whether a and b keep their objects in the same place:
+// local backends with the same directory, or S3 backends with the same
+// endpoint, bucket and prefix. Differently named locations can still be the
+// same place, so moving files from one to the other and deleting the source
+// would delete them.
+func SameLocation(a, b Storage) bool {
+	switch a := a.(type) {
+	case *Local:
+		b, ok := b.(*Local)
+		return ok && resolveDir(a.root) == resolveDir(b.root)
+	case *S3:
+		b, ok := b.(*S3)
+		return ok && strings.EqualFold(a.endpoint.Host, b.endpoint.Host) &&
+			strings.Trim(a.endpoint.Path, "/") == strings.Trim(b.endpoint.Path, "/") &&
+			a.bucket == b.bucket && a.prefix == b.prefix
+	default:
+		return a == b
+	}
+}
+
+// resolveDir makes dir absolute and follows symlinks where it exists
+func resolveDir(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+
+	return filepath.Clean(dir)
+}
+
+// Join joins key segments with slashes, it doesn't clean the result
+func Join(elem ...string) string {
+	parts := make([]string, 0, len(elem))
+	for _, e := range elem {
+		e = strings.Trim(e, "/")
+		if e != "" {
+			parts = append(parts, e)
+		}
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// SplitFolder splits a key listed in the folder prefix into the name of
+// the folder directly below prefix and the rest of the key. ok is false for
+// keys outside prefix or directly in it.
+func SplitFolder(prefix, key string) (folder, rest string, ok bool) {
+	if prefix != "" {
+		key, ok = strings.CutPrefix(key, prefix+"/")
+		if !ok {
+			return "", "", false
+		}
+	}
+
+	return strings.Cut(key, "/")
+}
+
+// cleanKey normalises a key and rejects ones that would escape the
+// storage root. The empty key is the root itself.
+func cleanKey(key string) (string, error) {
+	key = strings.ReplaceAll(key, "\\", "/")
+	if key == "" || key == "/" || key == "." {
+		return "", nil
+	}
+
+	clean := path.Clean("/" + key)[1:]
+	for _, segment := range strings.Split(key, "/") {
+		if segment == ".." {
+			return "", fmt.Errorf("%w: %s", ErrInvalidKey, key)
+		}
+	}
+
+	return clean, nil
+}
+
+// objectKey is cleanKey for keys that have to name an object
+func objectKey(key string) (string, error) {
+	clean, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	if clean == "" {
+		return "", fmt.Errorf("%w: empty key", ErrInvalidKey)
+	}
+
+	return clean, nil
+}
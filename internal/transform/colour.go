@@ -0,0 +1,180 @@
+package transform
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Output profiles built in, any other profile name is an uploaded ICC
+// profile
+const (
+	// ProfileSRGB is the default, what browsers assume untagged images are
+	ProfileSRGB = "srgb"
+	// ProfileDisplayP3 is the wide gamut of recent displays
+	ProfileDisplayP3 = "p3"
+	// ProfileAdobeRGB is the usual print working space
+	ProfileAdobeRGB = "adobe-rgb"
+	// ProfileOriginal leaves the pixels in the original's colour space,
+	// with its embedded profile
+	ProfileOriginal = "original"
+)
+
+// ProofCMYK soft-proofs against the generic CMYK profile of libvips
+const ProofCMYK = "cmyk"
+
+// Rendering intents, how colours outside the output gamut are mapped
+const (
+	IntentPerceptual = "perceptual"
+	IntentRelative   = "relative"
+	IntentSaturation = "saturation"
+	IntentAbsolute   = "absolute"
+)
+
+// ICCStrip leaves the profile out of a transform, which is then read as
+// sRGB whatever it was converted to
+const ICCStrip = "strip"
+
+// ColourProfile is an uploaded ICC profile a transform converts to or
+// proofs against. Like the watermark it's never part of the query string,
+// it's resolved from the profile's name before the transform is rendered.
+type ColourProfile struct {
+	// Checksum is part of the ETag so transforms follow a replaced profile
+	Checksum string
+	// Path is the local file libvips reads the profile from
+	Path string
+}
+
+var profileNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+// IsBuiltinProfile reports whether a profile name is one of the built in
+// output or proof profiles rather than an uploaded one
+func IsBuiltinProfile(name string) bool {
+	switch name {
+	case ProfileSRGB, ProfileDisplayP3, ProfileAdobeRGB, ProfileOriginal, ProofCMYK:
+		return true
+	default:
+		return false
+	}
+}
+
+// ValidProfileName reports whether name can name an uploaded profile
+func ValidProfileName(name string) bool {
+	return profileNamePattern.MatchString(name) && !IsBuiltinProfile(name)
+}
+
+// ParseProfile validates an output profile, the empty string is sRGB
+func ParseProfile(s string) (string, error) {
+	if s == "" || s == ProfileSRGB || s == ProfileDisplayP3 || s == ProfileAdobeRGB || s == ProfileOriginal || ValidProfileName(s) {
+		return s, nil
+	}
+
+	return "", fmt.Errorf("unknown profile %q", s)
+}
+
+// ParseProof validates the profile to soft-proof against, the empty
+// string is no proofing
+func ParseProof(s string) (string, error) {
+	if s == "" || s == ProofCMYK || ValidProfileName(s) {
+		return s, nil
+	}
+
+	return "", fmt.Errorf("unknown proof profile %q", s)
+}
+
+// ParseIntent validates a rendering intent, the empty string is
+// perceptual
+func ParseIntent(s string) (string, error) {
+	switch s {
+	case "", IntentPerceptual, IntentRelative, IntentSaturation, IntentAbsolute:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unknown intent %q", s)
+	}
+}
+
+// ParseDepth validates a bit depth, 0 leaves it to the format
+func ParseDepth(s string) (int, error) {
+	switch s {
+	case "":
+		return 0, nil
+	case "8", "16":
+		return strconv.Atoi(s)
+	default:
+		return 0, fmt.Errorf("depth is 8 or 16, not %q", s)
+	}
+}
+
+// ParseICC validates what's done with the profile of the output, embedding
+// it or stripping it
+func ParseICC(s string) (bool, error) {
+	switch s {
+	case "", "embed":
+		return false, nil
+	case ICCStrip:
+		return true, nil
+	default:
+		return false, fmt.Errorf("icc is embed or strip, not %q", s)
+	}
+}
+
+// validateColour checks the colour options fit together and the format
+func (p *TransformParams) validateColour() error {
+	if p.Depth == 16 && p.Format != "png" && p.Format != "tiff" {
+		return fmt.Errorf("16-bit output needs format png or tiff")
+	}
+
+	if p.Proof != "" && p.Profile == ProfileOriginal {
+		return fmt.Errorf("soft-proofing needs an output profile")
+	}
+
+	return nil
+}
+
+// UploadedProfiles returns the names of the uploaded profiles the
+// transform converts to or proofs against
+func (p *TransformParams) UploadedProfiles() []string {
+	var names []string
+	if p.Profile != "" && !IsBuiltinProfile(p.Profile) {
+		names = append(names, p.Profile)
+	}
+	if p.Proof != "" && !IsBuiltinProfile(p.Proof) && p.Proof != p.Profile {
+		names = append(names, p.Proof)
+	}
+	return names
+}
+
+// ManagesColour reports whether the transform asks for anything but the
+// default sRGB output
+func (p *TransformParams) ManagesColour() bool {
+	return (p.Profile != "" && p.Profile != ProfileSRGB) || p.Intent != "" || p.StripProfile || p.Depth == 16 || p.Proof != ""
+}
+
+// colourEtag is the part of the ETag for the colour options, empty for
+// the default so other transforms keep their ETags
+func (p *TransformParams) colourEtag() string {
+	profile := func(name string) string {
+		if cp, ok := p.ColourProfiles[name]; ok {
+			return name + "@" + cp.Checksum
+		}
+		return name
+	}
+
+	var etag string
+	if p.Profile != "" && p.Profile != ProfileSRGB {
+		etag += "-profile:" + profile(p.Profile)
+	}
+	if p.Intent != "" {
+		etag += "-intent:" + p.Intent
+	}
+	if p.StripProfile {
+		etag += "-icc:" + ICCStrip
+	}
+	if p.Depth == 16 {
+		etag += "-depth:16"
+	}
+	if p.Proof != "" {
+		etag += "-proof:" + profile(p.Proof)
+	}
+	return etag
+}
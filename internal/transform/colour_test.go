@@ -0,0 +1,95 @@
+package transform
+
+import (
+	"net/url"
+	"testing"
+
+	"viz/internal/dto"
+	"viz/internal/entities"
+)
+
+func TestParseColour(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    TransformParams
+		wantErr bool
+	}{
+		{"format=jpg", TransformParams{Format: "jpg"}, false},
+		{"format=jpg&profile=p3&intent=relative", TransformParams{Format: "jpg", Profile: ProfileDisplayP3, Intent: IntentRelative}, false},
+		{"format=tiff&profile=adobe-rgb&depth=16&icc=strip", TransformParams{Format: "tiff", Profile: ProfileAdobeRGB, Depth: 16, StripProfile: true}, false},
+		{"format=jpg&profile=p3&proof=fogra39", TransformParams{Format: "jpg", Profile: ProfileDisplayP3, Proof: "fogra39"}, false},
+		{"format=jpg&proof=cmyk", TransformParams{Format: "jpg", Proof: ProofCMYK}, false},
+		{"format=jpg&depth=16", TransformParams{}, true},
+		{"depth=16", TransformParams{}, true},
+		{"format=png&depth=12", TransformParams{}, true},
+		{"format=jpg&intent=vivid", TransformParams{}, true},
+		{"format=jpg&profile=Adobe%20RGB", TransformParams{}, true},
+		{"format=jpg&proof=p3", TransformParams{}, true},
+		{"format=jpg&profile=original&proof=cmyk", TransformParams{}, true},
+		{"format=jpg&icc=drop", TransformParams{}, true},
+	}
+	for _, tt := range tests {
+		q, _ := url.ParseQuery(tt.query)
+		got, err := ParseQuery(q)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseQuery(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got.Profile != tt.want.Profile || got.Intent != tt.want.Intent || got.StripProfile != tt.want.StripProfile || got.Depth != tt.want.Depth || got.Proof != tt.want.Proof {
+			t.Errorf("ParseQuery(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+
+		// the query string round trips
+		again, err := ParseQuery(mustParseQuery(got.ToQueryString()))
+		if err != nil || again.ToQueryString() != got.ToQueryString() {
+			t.Errorf("ParseQuery(%q) doesn't round trip: %v", got.ToQueryString(), err)
+		}
+	}
+}
+
+func mustParseQuery(s string) url.Values {
+	q, _ := url.ParseQuery(s)
+	return q
+}
+
+func TestUploadedProfiles(t *testing.T) {
+	params := TransformParams{Profile: "fogra39", Proof: "fogra39"}
+	if got := params.UploadedProfiles(); len(got) != 1 || got[0] != "fogra39" {
+		t.Errorf("UploadedProfiles() = %v", got)
+	}
+
+	params = TransformParams{Profile: ProfileDisplayP3, Proof: ProofCMYK}
+	if got := params.UploadedProfiles(); len(got) != 0 {
+		t.Errorf("UploadedProfiles() = %v, want none for built in profiles", got)
+	}
+}
+
+func TestColourEtag(t *testing.T) {
+	img := entities.ImageAsset{ImageMetadata: &dto.ImageMetadata{Checksum: "abc"}}
+	plain := *CreateTransformEtag(img, &TransformParams{Width: 400})
+
+	// asking for the default changes nothing
+	if got := *CreateTransformEtag(img, &TransformParams{Width: 400, Profile: ProfileSRGB}); got != plain {
+		t.Errorf("explicit sRGB changed the ETag: %s", got)
+	}
+
+	params := &TransformParams{Width: 400, Profile: "fogra39", ColourProfiles: map[string]ColourProfile{"fogra39": {Checksum: "111"}}}
+	first := *CreateTransformEtag(img, params)
+	if first == plain {
+		t.Error("profile didn't change the ETag")
+	}
+
+	params.ColourProfiles["fogra39"] = ColourProfile{Checksum: "222"}
+	if *CreateTransformEtag(img, params) == first {
+		t.Error("replacing the uploaded profile didn't change the ETag")
+	}
+
+	for _, p := range []TransformParams{{Width: 400, Intent: IntentRelative}, {Width: 400, StripProfile: true}, {Width: 400, Proof: ProofCMYK}} {
+		if *CreateTransformEtag(img, &p) == plain {
+			t.Errorf("%+v didn't change the ETag", p)
+		}
+	}
+}
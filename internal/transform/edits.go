@@ -0,0 +1,159 @@
+package transform
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"viz/internal/dto"
+)
+
+// MaxEdits is the most operations an image's edit stack can hold
+const MaxEdits = 50
+
+// ValidateEdits checks that every operation of an edit stack has the
+// values it needs and that they're in range
+func ValidateEdits(edits []dto.ImageEdit) error {
+	if len(edits) > MaxEdits {
+		return fmt.Errorf("an edit stack holds at most %d operations", MaxEdits)
+	}
+
+	for i, edit := range edits {
+		if err := validateEdit(edit); err != nil {
+			return fmt.Errorf("edit %d (%s): %w", i, edit.Op, err)
+		}
+	}
+
+	return nil
+}
+
+func validateEdit(edit dto.ImageEdit) error {
+	switch edit.Op {
+	case dto.ImageEditOpCrop:
+		c := edit.Crop
+		if c == nil {
+			return fmt.Errorf("crop is required")
+		}
+
+		if c.X < 0 || c.Y < 0 || c.Width <= 0 || c.Height <= 0 {
+			return fmt.Errorf("crop must have a positive size and start inside the image")
+		}
+	case dto.ImageEditOpStraighten:
+		return inRange("angle", edit.Angle, -45, 45)
+	case dto.ImageEditOpExposure:
+		return inRange("amount", edit.Amount, -5, 5)
+	case dto.ImageEditOpContrast, dto.ImageEditOpSaturation:
+		return inRange("amount", edit.Amount, -100, 100)
+	case dto.ImageEditOpSharpen, dto.ImageEditOpVignette:
+		return inRange("amount", edit.Amount, 0, 100)
+	case dto.ImageEditOpWhiteBalance:
+		if edit.Temperature == nil && edit.Tint == nil {
+			return fmt.Errorf("temperature or tint is required")
+		}
+
+		if edit.Temperature != nil {
+			if err := inRange("temperature", edit.Temperature, -100, 100); err != nil {
+				return err
+			}
+		}
+
+		if edit.Tint != nil {
+			return inRange("tint", edit.Tint, -100, 100)
+		}
+	default:
+		return fmt.Errorf("unknown operation")
+	}
+
+	return nil
+}
+
+func inRange(name string, value *float32, lo, hi float32) error {
+	if value == nil {
+		return fmt.Errorf("%s is required", name)
+	}
+
+	if *value < lo || *value > hi || math.IsNaN(float64(*value)) {
+		return fmt.Errorf("%s must be between %g and %g", name, lo, hi)
+	}
+
+	return nil
+}
+
+// EditsHash identifies the contents of an edit stack, it's part of the
+// ETag of every transform of an edited image
+func EditsHash(edits []dto.ImageEdit) string {
+	data, _ := json.Marshal(edits)
+	return fmt.Sprintf("%x", sha1.Sum(data))[:16]
+}
+
+// StraightenSize returns the size of the largest rectangle that fits in a
+// w x h image rotated by degrees, which is what a straightened image is
+// cropped to so no empty corners show
+func StraightenSize(w, h int, degrees float64) (int, int) {
+	if w <= 0 || h <= 0 {
+		return 0, 0
+	}
+
+	sin := math.Abs(math.Sin(degrees * math.Pi / 180))
+	cos := math.Abs(math.Cos(degrees * math.Pi / 180))
+	long, short := float64(max(w, h)), float64(min(w, h))
+
+	var cw, ch float64
+	if short <= 2*sin*cos*long || math.Abs(sin-cos) < 1e-10 {
+		// only touches the long sides, half constrained
+		x := 0.5 * short
+		if w >= h {
+			cw, ch = x/sin, x/cos
+		} else {
+			cw, ch = x/cos, x/sin
+		}
+	} else {
+		cos2 := cos*cos - sin*sin
+		cw = (float64(w)*cos - float64(h)*sin) / cos2
+		ch = (float64(h)*cos - float64(w)*sin) / cos2
+	}
+
+	return max(int(math.Floor(cw)), 1), max(int(math.Floor(ch)), 1)
+}
+
+// EditedFocalPoint maps a focal point of the auto-rotated w x h original
+// through the crops and straightening of an edit stack. Colour operations
+// don't move it.
+func EditedFocalPoint(edits []dto.ImageEdit, focal *dto.FocalPoint, w, h int64) *dto.FocalPoint {
+	if focal == nil {
+		return nil
+	}
+
+	x, y := float64(focal.X), float64(focal.Y)
+	fw, fh := float64(w), float64(h)
+
+	for _, edit := range edits {
+		switch edit.Op {
+		case dto.ImageEditOpCrop:
+			crop, ok := Crop{X: edit.Crop.X, Y: edit.Crop.Y, Width: edit.Crop.Width, Height: edit.Crop.Height}.Clamp(int64(fw), int64(fh))
+			if !ok {
+				continue
+			}
+
+			x = (x*fw - float64(crop.X)) / float64(crop.Width)
+			y = (y*fh - float64(crop.Y)) / float64(crop.Height)
+			fw, fh = float64(crop.Width), float64(crop.Height)
+		case dto.ImageEditOpStraighten:
+			angle := float64(*edit.Angle) * math.Pi / 180
+			cw, ch := StraightenSize(int(fw), int(fh), float64(*edit.Angle))
+
+			// rotate about the centre, clockwise with y pointing down
+			px, py := x*fw-fw/2, y*fh-fh/2
+			rx := px*math.Cos(angle) - py*math.Sin(angle)
+			ry := px*math.Sin(angle) + py*math.Cos(angle)
+
+			fw, fh = float64(cw), float64(ch)
+			x, y = (rx+fw/2)/fw, (ry+fh/2)/fh
+		}
+
+		x, y = clampUnit(x), clampUnit(y)
+	}
+
+	return &dto.FocalPoint{X: float32(x), Y: float32(y)}
+}
@@ -0,0 +1,103 @@
+package transform
+
+import (
+	"math"
+	"testing"
+
+	"viz/internal/dto"
+	"viz/internal/entities"
+)
+
+func f32(v float32) *float32 { return &v }
+
+func TestValidateEdits(t *testing.T) {
+	valid := []dto.ImageEdit{
+		{Op: dto.ImageEditOpCrop, Crop: &dto.ImageEditCrop{X: 0, Y: 10, Width: 100, Height: 50}},
+		{Op: dto.ImageEditOpStraighten, Angle: f32(-2.5)},
+		{Op: dto.ImageEditOpExposure, Amount: f32(0.7)},
+		{Op: dto.ImageEditOpWhiteBalance, Tint: f32(10)},
+		{Op: dto.ImageEditOpVignette, Amount: f32(40)},
+	}
+	if err := ValidateEdits(valid); err != nil {
+		t.Fatalf("ValidateEdits() = %v", err)
+	}
+
+	invalid := []dto.ImageEdit{
+		{Op: "blur", Amount: f32(1)},
+		{Op: dto.ImageEditOpCrop},
+		{Op: dto.ImageEditOpCrop, Crop: &dto.ImageEditCrop{Width: 0, Height: 10}},
+		{Op: dto.ImageEditOpStraighten, Angle: f32(90)},
+		{Op: dto.ImageEditOpContrast},
+		{Op: dto.ImageEditOpSharpen, Amount: f32(-1)},
+		{Op: dto.ImageEditOpWhiteBalance},
+		{Op: dto.ImageEditOpSaturation, Amount: f32(float32(math.NaN()))},
+	}
+	for _, edit := range invalid {
+		if err := ValidateEdits([]dto.ImageEdit{edit}); err == nil {
+			t.Errorf("ValidateEdits(%+v) succeeded", edit)
+		}
+	}
+
+	if err := ValidateEdits(make([]dto.ImageEdit, MaxEdits+1)); err == nil {
+		t.Error("ValidateEdits() of an oversized stack succeeded")
+	}
+}
+
+func TestStraightenSize(t *testing.T) {
+	if w, h := StraightenSize(400, 300, 0); w != 400 || h != 300 {
+		t.Errorf("StraightenSize() without rotation = %dx%d", w, h)
+	}
+
+	w, h := StraightenSize(400, 300, 5)
+	if w >= 400 || h >= 300 || w < 300 || h < 200 {
+		t.Errorf("StraightenSize(5°) = %dx%d", w, h)
+	}
+
+	// the crop is symmetric in the angle
+	if w2, h2 := StraightenSize(400, 300, -5); w2 != w || h2 != h {
+		t.Errorf("StraightenSize(-5°) = %dx%d, want %dx%d", w2, h2, w, h)
+	}
+}
+
+func TestEditedFocalPoint(t *testing.T) {
+	focal := &dto.FocalPoint{X: 0.75, Y: 0.5}
+	edits := []dto.ImageEdit{
+		{Op: dto.ImageEditOpExposure, Amount: f32(1)},
+		{Op: dto.ImageEditOpCrop, Crop: &dto.ImageEditCrop{X: 200, Y: 0, Width: 200, Height: 200}},
+	}
+
+	got := EditedFocalPoint(edits, focal, 400, 200)
+	if got.X != 0.5 || got.Y != 0.5 {
+		t.Errorf("EditedFocalPoint() = %+v, want the centre of the crop", got)
+	}
+
+	// the centre stays put when straightening
+	centre := EditedFocalPoint([]dto.ImageEdit{{Op: dto.ImageEditOpStraighten, Angle: f32(10)}}, &dto.FocalPoint{X: 0.5, Y: 0.5}, 400, 200)
+	if math.Abs(float64(centre.X)-0.5) > 0.01 || math.Abs(float64(centre.Y)-0.5) > 0.01 {
+		t.Errorf("EditedFocalPoint() of the centre = %+v", centre)
+	}
+}
+
+func TestTransformEtagEdits(t *testing.T) {
+	edits := []dto.ImageEdit{{Op: dto.ImageEditOpContrast, Amount: f32(20)}}
+	img := entities.ImageAsset{ImageMetadata: &dto.ImageMetadata{Checksum: "abc"}}
+	params := &TransformParams{Format: "webp", Width: 400}
+
+	plain := *CreateTransformEtag(img, params)
+
+	img.Edits = &edits
+	edited := *CreateTransformEtag(img, params)
+	if edited == plain {
+		t.Error("edits didn't change the ETag")
+	}
+
+	edits[0].Amount = f32(30)
+	if *CreateTransformEtag(img, params) == edited {
+		t.Error("changing an edit didn't change the ETag")
+	}
+
+	img.Edits = &[]dto.ImageEdit{}
+	if *CreateTransformEtag(img, params) != plain {
+		t.Error("an empty edit stack changed the ETag")
+	}
+}
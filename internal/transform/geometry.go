@@ -0,0 +1,228 @@
+package transform
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"viz/internal/dto"
+)
+
+// Fit modes, how an image is resized into a Width x Height box
+const (
+	// FitCover scales the image to cover the box and crops the overflow
+	FitCover = "cover"
+	// FitContain scales the image to fit in the box and pads the rest
+	FitContain = "contain"
+	// FitFill stretches the image to the box, ignoring its aspect ratio
+	FitFill = "fill"
+	// FitInside scales the image to fit in the box, without padding
+	FitInside = "inside"
+	// FitOutside scales the image to cover the box, without cropping
+	FitOutside = "outside"
+)
+
+// Gravities, the part of the image a cover crop keeps
+const (
+	GravityCentre    = "centre"
+	GravityNorth     = "north"
+	GravityNorthEast = "northeast"
+	GravityEast      = "east"
+	GravitySouthEast = "southeast"
+	GravitySouth     = "south"
+	GravitySouthWest = "southwest"
+	GravityWest      = "west"
+	GravityNorthWest = "northwest"
+	// GravitySmart keeps the region libvips finds most interesting
+	GravitySmart = "smart"
+)
+
+// ParseFit validates a fit mode, the empty string is the default
+func ParseFit(s string) (string, error) {
+	switch s {
+	case "", FitCover, FitContain, FitFill, FitInside, FitOutside:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unknown fit %q", s)
+	}
+}
+
+// ParseGravity validates a gravity and returns its canonical name, so
+// "center" and "attention" are stored as "centre" and "smart"
+func ParseGravity(s string) (string, error) {
+	switch s {
+	case "center":
+		return GravityCentre, nil
+	case "attention":
+		return GravitySmart, nil
+	case "", GravityCentre, GravityNorth, GravityNorthEast, GravityEast, GravitySouthEast,
+		GravitySouth, GravitySouthWest, GravityWest, GravityNorthWest, GravitySmart:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unknown gravity %q", s)
+	}
+}
+
+// Crop is a rectangle in pixels
+type Crop struct {
+	X      int64
+	Y      int64
+	Width  int64
+	Height int64
+}
+
+// ParseCrop parses a crop rectangle written as "x,y,w,h"
+func ParseCrop(s string) (*Crop, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("crop %q must be x,y,w,h", s)
+	}
+
+	var values [4]int64
+	for i, part := range parts {
+		v, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("crop %q must be x,y,w,h: %w", s, err)
+		}
+		values[i] = v
+	}
+
+	c := &Crop{X: values[0], Y: values[1], Width: values[2], Height: values[3]}
+	if c.X < 0 || c.Y < 0 || c.Width <= 0 || c.Height <= 0 {
+		return nil, fmt.Errorf("crop %q must have a positive size and start inside the image", s)
+	}
+
+	return c, nil
+}
+
+// String formats the crop the way ParseCrop reads it
+func (c Crop) String() string {
+	return fmt.Sprintf("%d,%d,%d,%d", c.X, c.Y, c.Width, c.Height)
+}
+
+// Clamp limits the crop to an image of the given size. ok is false when
+// the crop lies entirely outside it.
+func (c Crop) Clamp(imgW, imgH int64) (Crop, bool) {
+	if c.X >= imgW || c.Y >= imgH {
+		return Crop{}, false
+	}
+
+	c.Width = min(c.Width, imgW-c.X)
+	c.Height = min(c.Height, imgH-c.Y)
+	return c, true
+}
+
+// FitScale returns the horizontal and vertical scale that resize an image
+// of srcW x srcH for the transform. They differ only for FitFill.
+func (p *TransformParams) FitScale(srcW, srcH int) (hscale, vscale float64) {
+	w := float64(srcW)
+	h := float64(srcH)
+
+	switch {
+	case p.Width > 0 && p.Height > 0:
+		wScale := float64(p.Width) / w
+		hScale := float64(p.Height) / h
+
+		switch p.Fit {
+		case FitFill:
+			return wScale, hScale
+		case FitCover, FitOutside:
+			scale := max(wScale, hScale)
+			return scale, scale
+		default:
+			scale := min(wScale, hScale)
+			return scale, scale
+		}
+	case p.Width > 0:
+		scale := float64(p.Width) / w
+		return scale, scale
+	case p.Height > 0:
+		scale := float64(p.Height) / h
+		return scale, scale
+	default:
+		return 1, 1
+	}
+}
+
+// CropOffset returns where a dstW x dstH window is placed in a srcW x srcH
+// image for a gravity. An empty gravity centres the window on the focal
+// point, given as fractions of the image size, as far as the image allows.
+// The same placement, negated, positions a contained image in its box.
+func CropOffset(srcW, srcH, dstW, dstH int, gravity string, focal *dto.FocalPoint) (left, top int) {
+	spareW := srcW - dstW
+	spareH := srcH - dstH
+
+	if gravity == "" && focal != nil {
+		left = int(math.Round(float64(focal.X)*float64(srcW) - float64(dstW)/2))
+		top = int(math.Round(float64(focal.Y)*float64(srcH) - float64(dstH)/2))
+		return clampOffset(left, spareW), clampOffset(top, spareH)
+	}
+
+	left, top = spareW/2, spareH/2
+	switch gravity {
+	case GravityNorth, GravityNorthEast, GravityNorthWest:
+		top = 0
+	case GravitySouth, GravitySouthEast, GravitySouthWest:
+		top = spareH
+	}
+
+	switch gravity {
+	case GravityWest, GravityNorthWest, GravitySouthWest:
+		left = 0
+	case GravityEast, GravityNorthEast, GravitySouthEast:
+		left = spareW
+	}
+
+	return left, top
+}
+
+// FocalPointAfter maps a focal point of the imgW x imgH image a transform
+// starts from, the edited original, to the image that comes out of the
+// transform's crop, rotation and flip, the image a cover crop is taken
+// from. It's nil when there is no focal point.
+func (p *TransformParams) FocalPointAfter(focal *dto.FocalPoint, imgW, imgH int64) *dto.FocalPoint {
+	if focal == nil {
+		return nil
+	}
+
+	x, y := float64(focal.X), float64(focal.Y)
+
+	if p.Crop != nil {
+		if crop, ok := p.Crop.Clamp(imgW, imgH); ok {
+			x = (x*float64(imgW) - float64(crop.X)) / float64(crop.Width)
+			y = (y*float64(imgH) - float64(crop.Y)) / float64(crop.Height)
+		}
+	}
+
+	// rotations are clockwise
+	switch p.Rotate {
+	case 90:
+		x, y = 1-y, x
+	case 180:
+		x, y = 1-x, 1-y
+	case 270:
+		x, y = y, 1-x
+	}
+
+	switch p.Flip {
+	case "horizontal":
+		x = 1 - x
+	case "vertical":
+		y = 1 - y
+	}
+
+	return &dto.FocalPoint{X: float32(clampUnit(x)), Y: float32(clampUnit(y))}
+}
+
+func clampOffset(offset, spare int) int {
+	if spare <= 0 {
+		return spare / 2
+	}
+
+	return min(max(offset, 0), spare)
+}
+
+func clampUnit(v float64) float64 {
+	return min(max(v, 0), 1)
+}
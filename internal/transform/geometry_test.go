@@ -0,0 +1,140 @@
+package transform
+
+import (
+	"testing"
+
+	"viz/internal/dto"
+	"viz/internal/entities"
+)
+
+func TestParseCrop(t *testing.T) {
+	crop, err := ParseCrop("10, 20,300,200")
+	if err != nil {
+		t.Fatalf("ParseCrop() = %v", err)
+	}
+
+	if *crop != (Crop{X: 10, Y: 20, Width: 300, Height: 200}) || crop.String() != "10,20,300,200" {
+		t.Errorf("ParseCrop() = %+v", crop)
+	}
+
+	for _, s := range []string{"", "1,2,3", "a,b,c,d", "-1,0,10,10", "0,0,0,10"} {
+		if _, err := ParseCrop(s); err == nil {
+			t.Errorf("ParseCrop(%q) succeeded", s)
+		}
+	}
+
+	if got, ok := (Crop{X: 50, Y: 50, Width: 100, Height: 100}).Clamp(120, 80); !ok || got != (Crop{X: 50, Y: 50, Width: 70, Height: 30}) {
+		t.Errorf("Clamp() = %+v, %v", got, ok)
+	}
+
+	if _, ok := (Crop{X: 200, Y: 0, Width: 10, Height: 10}).Clamp(120, 80); ok {
+		t.Error("Clamp() of a crop outside the image succeeded")
+	}
+}
+
+func TestParseGravity(t *testing.T) {
+	tests := map[string]string{"": "", "center": GravityCentre, "attention": GravitySmart, "northwest": GravityNorthWest}
+	for in, want := range tests {
+		if got, err := ParseGravity(in); err != nil || got != want {
+			t.Errorf("ParseGravity(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+
+	if _, err := ParseGravity("up"); err == nil {
+		t.Error("ParseGravity() of an unknown gravity succeeded")
+	}
+}
+
+func TestFitScale(t *testing.T) {
+	tests := []struct {
+		fit            string
+		hscale, vscale float64
+	}{
+		{fit: "", hscale: 0.25, vscale: 0.25},
+		{fit: FitInside, hscale: 0.25, vscale: 0.25},
+		{fit: FitContain, hscale: 0.25, vscale: 0.25},
+		{fit: FitCover, hscale: 0.5, vscale: 0.5},
+		{fit: FitOutside, hscale: 0.5, vscale: 0.5},
+		{fit: FitFill, hscale: 0.25, vscale: 0.5},
+	}
+
+	for _, tt := range tests {
+		p := TransformParams{Width: 100, Height: 100, Fit: tt.fit}
+		if h, v := p.FitScale(400, 200); h != tt.hscale || v != tt.vscale {
+			t.Errorf("FitScale(%q) = %v, %v, want %v, %v", tt.fit, h, v, tt.hscale, tt.vscale)
+		}
+	}
+}
+
+func TestCropOffset(t *testing.T) {
+	tests := []struct {
+		name      string
+		gravity   string
+		focal     *dto.FocalPoint
+		left, top int
+	}{
+		{name: "centre", left: 100, top: 50},
+		{name: "north", gravity: GravityNorth, left: 100, top: 0},
+		{name: "southeast", gravity: GravitySouthEast, left: 200, top: 100},
+		{name: "focal point", focal: &dto.FocalPoint{X: 0.4, Y: 0.5}, left: 60, top: 50},
+		{name: "focal point near the edge", focal: &dto.FocalPoint{X: 0.95, Y: 0.05}, left: 200, top: 0},
+		{name: "gravity wins", gravity: GravityWest, focal: &dto.FocalPoint{X: 0.9, Y: 0.9}, left: 0, top: 50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if left, top := CropOffset(400, 200, 200, 100, tt.gravity, tt.focal); left != tt.left || top != tt.top {
+				t.Errorf("CropOffset() = %d, %d, want %d, %d", left, top, tt.left, tt.top)
+			}
+		})
+	}
+
+	// a smaller image centred in a box, as fit=contain places it
+	if left, top := CropOffset(100, 50, 200, 100, "", nil); left != -50 || top != -25 {
+		t.Errorf("CropOffset() in a larger box = %d, %d", left, top)
+	}
+}
+
+func TestFocalPointAfter(t *testing.T) {
+	focal := &dto.FocalPoint{X: 0.25, Y: 0.5}
+
+	p := TransformParams{Crop: &Crop{X: 0, Y: 50, Width: 200, Height: 100}}
+	if got := p.FocalPointAfter(focal, 400, 200); got.X != 0.5 || got.Y != 0.5 {
+		t.Errorf("FocalPointAfter() with crop = %+v", got)
+	}
+
+	p = TransformParams{Rotate: 90, Flip: "vertical"}
+	if got := p.FocalPointAfter(focal, 400, 200); got.X != 0.5 || got.Y != 0.75 {
+		t.Errorf("FocalPointAfter() with rotate and flip = %+v", got)
+	}
+
+	if p.FocalPointAfter(nil, 400, 200) != nil {
+		t.Error("FocalPointAfter() without a focal point isn't nil")
+	}
+}
+
+func TestTransformEtag(t *testing.T) {
+	img := entities.ImageAsset{ImageMetadata: &dto.ImageMetadata{Checksum: "abc"}}
+	params := &TransformParams{Format: "webp", Width: 400, Height: 400, Quality: 85}
+
+	// ETags from before fit, crop and gravity existed stay the same but
+	// for the metadata policy
+	if got := *CreateTransformEtag(img, params); got != "abc-400x400-webp-85-0---meta:none" {
+		t.Errorf("CreateTransformEtag() = %q", got)
+	}
+
+	params.Fit = FitCover
+	before := *CreateTransformEtag(img, params)
+
+	img.FocalPoint = &dto.FocalPoint{X: 0.2, Y: 0.8}
+	if *CreateTransformEtag(img, params) == before {
+		t.Error("moving the focal point didn't change the ETag of a cover crop")
+	}
+
+	params.Gravity = GravitySmart
+	withGravity := *CreateTransformEtag(img, params)
+	img.FocalPoint = &dto.FocalPoint{X: 0.5, Y: 0.5}
+	if *CreateTransformEtag(img, params) != withGravity {
+		t.Error("the focal point changed the ETag of a crop with an explicit gravity")
+	}
+}
@@ -0,0 +1,63 @@
+package transform
+
+import "fmt"
+
+// Metadata policies, how much of the original's EXIF, XMP and IPTC a
+// transform keeps, from the most to the least
+const (
+	// MetadataKeep keeps all of it
+	MetadataKeep = "keep"
+	// MetadataNoGPS keeps everything but the location
+	MetadataNoGPS = "no-gps"
+	// MetadataCopyright keeps only the creator and copyright notice
+	MetadataCopyright = "copyright"
+	// MetadataNone strips all of it, the default for transforms. The colour
+	// profile isn't metadata, it's kept unless the transform strips it.
+	MetadataNone = "none"
+)
+
+// metadataStrictness orders the policies, higher keeps less
+var metadataStrictness = map[string]int{
+	MetadataKeep:      0,
+	MetadataNoGPS:     1,
+	MetadataCopyright: 2,
+	MetadataNone:      3,
+}
+
+// ParseMetadata validates a metadata policy, the empty string is
+// MetadataNone
+func ParseMetadata(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+
+	if _, ok := metadataStrictness[s]; !ok {
+		return "", fmt.Errorf("metadata is keep, no-gps, copyright or none, not %q", s)
+	}
+
+	return s, nil
+}
+
+// StricterMetadata returns whichever of two policies keeps less, the
+// empty string, or anything unknown, counting as MetadataNone
+func StricterMetadata(a, b string) string {
+	if _, ok := metadataStrictness[a]; !ok {
+		a = MetadataNone
+	}
+	if _, ok := metadataStrictness[b]; !ok {
+		b = MetadataNone
+	}
+
+	if metadataStrictness[b] > metadataStrictness[a] {
+		return b
+	}
+	return a
+}
+
+// MetadataPolicy returns the policy the transform is rendered with
+func (p *TransformParams) MetadataPolicy() string {
+	if p.Metadata == "" {
+		return MetadataNone
+	}
+	return p.Metadata
+}
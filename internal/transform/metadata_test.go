@@ -0,0 +1,69 @@
+package transform
+
+import (
+	"net/url"
+	"testing"
+
+	"viz/internal/dto"
+	"viz/internal/entities"
+)
+
+func TestParseMetadata(t *testing.T) {
+	for _, query := range []string{"metadata=keep", "metadata=no-gps", "metadata=copyright", "metadata=none", ""} {
+		q, _ := url.ParseQuery(query)
+		params, err := ParseQuery(q)
+		if err != nil {
+			t.Errorf("ParseQuery(%q) = %v", query, err)
+			continue
+		}
+
+		if params.ToQueryString() != query {
+			t.Errorf("round trip of %q = %q", query, params.ToQueryString())
+		}
+	}
+
+	q, _ := url.ParseQuery("metadata=gps")
+	if _, err := ParseQuery(q); err == nil {
+		t.Error("ParseQuery(metadata=gps) succeeded")
+	}
+}
+
+func TestStricterMetadata(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string
+	}{
+		{MetadataKeep, MetadataKeep, MetadataKeep},
+		{MetadataKeep, MetadataNoGPS, MetadataNoGPS},
+		{MetadataCopyright, MetadataNoGPS, MetadataCopyright},
+		{MetadataKeep, "", MetadataNone},
+		{"", MetadataKeep, MetadataNone},
+		{MetadataKeep, "everything", MetadataNone},
+	}
+	for _, tt := range tests {
+		if got := StricterMetadata(tt.a, tt.b); got != tt.want {
+			t.Errorf("StricterMetadata(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestMetadataEtag(t *testing.T) {
+	img := entities.ImageAsset{ImageMetadata: &dto.ImageMetadata{Checksum: "abc"}}
+	params := &TransformParams{Format: "jpg"}
+
+	stripped := *CreateTransformEtag(img, params)
+	params.Metadata = MetadataNone
+	if *CreateTransformEtag(img, params) != stripped {
+		t.Error("an explicit metadata=none changed the ETag of the default")
+	}
+
+	seen := map[string]bool{stripped: true}
+	for _, policy := range []string{MetadataCopyright, MetadataNoGPS, MetadataKeep} {
+		params.Metadata = policy
+		etag := *CreateTransformEtag(img, params)
+		if seen[etag] {
+			t.Errorf("metadata=%s shares its ETag with another policy", policy)
+		}
+		seen[etag] = true
+	}
+}
@@ -0,0 +1,40 @@
+package transform
+
+import (
+	"fmt"
+	"strconv"
+
+	"viz/internal/dto"
+)
+
+// ParsePage validates the page of a multi-page original to render,
+// counted from 1. The empty string is 0, the first page, or the whole
+// animation of an animated original.
+func ParsePage(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	page, err := strconv.Atoi(s)
+	if err != nil || page < 1 {
+		return 0, fmt.Errorf("page is a number from 1, not %q", s)
+	}
+
+	return page, nil
+}
+
+// KeepsAnimation reports whether a transform of an animated original is
+// animated too. That takes a format holding animations and nothing the
+// frames can't each get the same way, so only resizing without a box to
+// crop or pad to. Anything else renders the first frame.
+func (p *TransformParams) KeepsAnimation(edits []dto.ImageEdit) bool {
+	if p.Format != "gif" && p.Format != "webp" {
+		return false
+	}
+
+	if p.Page > 0 || len(edits) > 0 || p.Crop != nil || p.Rotate > 0 || p.Flip != "" || p.Watermark != nil {
+		return false
+	}
+
+	return p.Fit == "" || p.Fit == FitInside || p.Fit == FitOutside || p.Fit == FitFill
+}
@@ -0,0 +1,64 @@
+package transform
+
+import (
+	"net/url"
+	"testing"
+
+	"viz/internal/dto"
+	"viz/internal/entities"
+)
+
+func TestParsePage(t *testing.T) {
+	for _, query := range []string{"page=1", "format=png&page=3", ""} {
+		q, _ := url.ParseQuery(query)
+		params, err := ParseQuery(q)
+		if err != nil {
+			t.Errorf("ParseQuery(%q) = %v", query, err)
+			continue
+		}
+
+		if params.ToQueryString() != query {
+			t.Errorf("round trip of %q = %q", query, params.ToQueryString())
+		}
+	}
+
+	for _, query := range []string{"page=0", "page=-1", "page=first"} {
+		q, _ := url.ParseQuery(query)
+		if _, err := ParseQuery(q); err == nil {
+			t.Errorf("ParseQuery(%q) succeeded", query)
+		}
+	}
+
+	img := entities.ImageAsset{ImageMetadata: &dto.ImageMetadata{Checksum: "abc"}}
+	params := &TransformParams{Format: "png"}
+	first := *CreateTransformEtag(img, params)
+	params.Page = 2
+	if *CreateTransformEtag(img, params) == first {
+		t.Error("the page didn't change the ETag")
+	}
+}
+
+func TestKeepsAnimation(t *testing.T) {
+	tests := []struct {
+		name   string
+		params TransformParams
+		edits  []dto.ImageEdit
+		want   bool
+	}{
+		{"resized webp", TransformParams{Format: "webp", Width: 400}, nil, true},
+		{"gif inside a box", TransformParams{Format: "gif", Width: 400, Height: 400, Fit: FitInside}, nil, true},
+		{"jpeg", TransformParams{Format: "jpg", Width: 400}, nil, false},
+		{"original format", TransformParams{Width: 400}, nil, false},
+		{"a page", TransformParams{Format: "webp", Page: 1}, nil, false},
+		{"cover crop", TransformParams{Format: "webp", Width: 400, Height: 400, Fit: FitCover}, nil, false},
+		{"crop", TransformParams{Format: "webp", Crop: &Crop{Width: 10, Height: 10}}, nil, false},
+		{"rotated", TransformParams{Format: "webp", Rotate: 90}, nil, false},
+		{"watermarked", TransformParams{Format: "webp", Watermark: &Watermark{}}, nil, false},
+		{"edited", TransformParams{Format: "webp"}, []dto.ImageEdit{{Op: dto.ImageEditOpCrop}}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.params.KeepsAnimation(tt.edits); got != tt.want {
+			t.Errorf("KeepsAnimation(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package transform
+
+import (
+	"fmt"
+
+	"viz/internal/dto"
+)
+
+// DevelopFull renders a transform of a RAW image from its sensor data
+// instead of the JPEG the camera embedded in it or saved next to it
+const DevelopFull = "full"
+
+// ParseDevelop validates a develop mode, the empty string is the default
+func ParseDevelop(s string) (string, error) {
+	switch s {
+	case "", DevelopFull:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unknown develop %q", s)
+	}
+}
+
+// PreviewSuffices reports whether a transform of a RAW image can be
+// rendered from the previewW x previewH JPEG embedded in it instead of
+// developing it. Crops are in the pixels of the developed image, so they
+// need it, as do transforms at full size. Previews are compared by their
+// long edge since they aren't turned upright yet.
+func (p *TransformParams) PreviewSuffices(edits []dto.ImageEdit, previewW, previewH int) bool {
+	if p.Develop == DevelopFull || p.Crop != nil || (p.Width == 0 && p.Height == 0) {
+		return false
+	}
+
+	for _, edit := range edits {
+		if edit.Op == dto.ImageEditOpCrop {
+			return false
+		}
+	}
+
+	return max(p.Width, p.Height) <= int64(max(previewW, previewH))
+}
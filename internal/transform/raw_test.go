@@ -0,0 +1,48 @@
+package transform
+
+import (
+	"testing"
+
+	"viz/internal/dto"
+	"viz/internal/entities"
+)
+
+func TestPreviewSuffices(t *testing.T) {
+	crop := []dto.ImageEdit{{Op: dto.ImageEditOpCrop, Crop: &dto.ImageEditCrop{X: 0, Y: 0, Width: 100, Height: 100}}}
+
+	tests := []struct {
+		name   string
+		params TransformParams
+		edits  []dto.ImageEdit
+		want   bool
+	}{
+		{"smaller", TransformParams{Width: 400}, nil, true},
+		// a portrait request from a landscape preview
+		{"long edge", TransformParams{Height: 1600}, nil, true},
+		{"larger", TransformParams{Width: 2400, Height: 1600}, nil, false},
+		{"full size", TransformParams{Format: "webp"}, nil, false},
+		{"develop", TransformParams{Width: 400, Develop: DevelopFull}, nil, false},
+		{"crop", TransformParams{Width: 400, Crop: &Crop{Width: 10, Height: 10}}, nil, false},
+		{"crop edit", TransformParams{Width: 400}, crop, false},
+	}
+	for _, tt := range tests {
+		if got := tt.params.PreviewSuffices(tt.edits, 1620, 1080); got != tt.want {
+			t.Errorf("PreviewSuffices(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDevelopEtag(t *testing.T) {
+	img := entities.ImageAsset{ImageMetadata: &dto.ImageMetadata{Checksum: "abc"}}
+	params := &TransformParams{Width: 400}
+	before := *CreateTransformEtag(img, params)
+
+	params.Develop = DevelopFull
+	if *CreateTransformEtag(img, params) == before {
+		t.Error("developing the RAW didn't change the ETag")
+	}
+
+	if _, err := ParseDevelop("half"); err == nil {
+		t.Error("ParseDevelop(half) succeeded")
+	}
+}
@@ -0,0 +1,116 @@
+package transform
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// FormatAuto encodes a transform in the best format the requesting browser
+// accepts, responses to it vary by the Accept header
+const FormatAuto = "auto"
+
+// NegotiateFormat returns the format a FormatAuto transform is encoded as
+// for a request's Accept header: AVIF, then WebP, and JPEG for everything
+// else. Formats with a zero quality value are refused.
+func NegotiateFormat(accept string) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+		refused := false
+		for _, param := range strings.Split(params, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(name, "q") {
+				q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				refused = err == nil && q <= 0
+			}
+		}
+
+		if mediaType != "" && !refused {
+			accepted[mediaType] = true
+		}
+	}
+
+	switch {
+	case accepted["image/avif"]:
+		return "avif"
+	case accepted["image/webp"]:
+		return "webp"
+	default:
+		return "jpg"
+	}
+}
+
+// OriginalFormat returns the format a transform without one is encoded in:
+// the original's when libvips can write it, HEIF for HEIC, and JPEG for
+// the rest, like camera RAW files and PSDs
+func OriginalFormat(fileType string) string {
+	switch strings.ToLower(fileType) {
+	case "jpg", "jpeg", "png", "webp", "avif", "heif", "jxl", "gif":
+		return strings.ToLower(fileType)
+	case "tif", "tiff":
+		return "tiff"
+	case "heic":
+		return "heif"
+	default:
+		return "jpg"
+	}
+}
+
+// DefaultFormat gives a transform without a format the OriginalFormat of
+// the image it renders, so it's rendered and cached under one ETag
+// wherever it's asked for
+func (p *TransformParams) DefaultFormat(fileType string) {
+	if p.Format == "" {
+		p.Format = OriginalFormat(fileType)
+	}
+}
+
+// SnapWidth returns the width of ladder a requested width is rendered at,
+// the narrowest at least as wide or else the widest, so near identical
+// widths share one cached transform
+func SnapWidth(width int, ladder []int) int {
+	snapped, widest := 0, 0
+	for _, w := range ladder {
+		if w >= width && (snapped == 0 || w < snapped) {
+			snapped = w
+		}
+		widest = max(widest, w)
+	}
+
+	if snapped == 0 {
+		return widest
+	}
+
+	return snapped
+}
+
+// SrcsetWidths returns the sorted widths of a srcset: breakpoints snapped
+// to ladder, or the whole ladder without breakpoints, leaving out those
+// wider than maxWidth. An image narrower than the whole ladder gets its own
+// width.
+func SrcsetWidths(ladder, breakpoints []int, maxWidth int) []int {
+	widths := ladder
+	if len(breakpoints) > 0 {
+		widths = make([]int, 0, len(breakpoints))
+		for _, b := range breakpoints {
+			widths = append(widths, SnapWidth(b, ladder))
+		}
+	}
+
+	var fit []int
+	for _, w := range widths {
+		if w > 0 && (maxWidth <= 0 || w <= maxWidth) {
+			fit = append(fit, w)
+		}
+	}
+
+	if len(fit) == 0 && maxWidth > 0 {
+		fit = []int{maxWidth}
+	}
+
+	slices.Sort(fit)
+	return slices.Compact(fit)
+}
@@ -0,0 +1,78 @@
+package transform
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"image/avif,image/webp,image/apng,image/*,*/*;q=0.8", "avif"},
+		{"image/webp,*/*", "webp"},
+		{"image/avif;q=0, image/webp;q=0.9", "webp"},
+		{"IMAGE/WEBP", "webp"},
+		{"image/png,image/*;q=0.8", "jpg"},
+		{"", "jpg"},
+	}
+	for _, tt := range tests {
+		if got := NegotiateFormat(tt.accept); got != tt.want {
+			t.Errorf("NegotiateFormat(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultFormat(t *testing.T) {
+	tests := map[string]string{
+		"jpeg": "jpeg",
+		"PNG":  "png",
+		"tif":  "tiff",
+		"heic": "heif",
+		"jxl":  "jxl",
+		"psd":  "jpg",
+		"cr3":  "jpg",
+	}
+	for fileType, want := range tests {
+		params := TransformParams{Width: 1080}
+		params.DefaultFormat(fileType)
+		if params.Format != want {
+			t.Errorf("DefaultFormat(%q) = %q, want %q", fileType, params.Format, want)
+		}
+	}
+
+	params := TransformParams{Format: "webp"}
+	params.DefaultFormat("png")
+	if params.Format != "webp" {
+		t.Errorf("DefaultFormat() replaced format webp with %q", params.Format)
+	}
+}
+
+func TestSrcsetWidths(t *testing.T) {
+	ladder := []int{320, 640, 1024, 1920}
+
+	if got := SnapWidth(700, ladder); got != 1024 {
+		t.Errorf("SnapWidth(700) = %d, want 1024", got)
+	}
+	if got := SnapWidth(4000, ladder); got != 1920 {
+		t.Errorf("SnapWidth(4000) = %d, want the widest", got)
+	}
+
+	tests := []struct {
+		name        string
+		breakpoints []int
+		maxWidth    int
+		want        []int
+	}{
+		{"ladder", nil, 0, []int{320, 640, 1024, 1920}},
+		{"capped", nil, 1500, []int{320, 640, 1024}},
+		{"snapped", []int{700, 300, 650, 1000}, 0, []int{320, 1024}},
+		{"small image", nil, 200, []int{200}},
+	}
+	for _, tt := range tests {
+		if got := SrcsetWidths(ladder, tt.breakpoints, tt.maxWidth); !slices.Equal(got, tt.want) {
+			t.Errorf("SrcsetWidths(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
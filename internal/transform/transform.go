@@ -17,6 +17,46 @@ type TransformParams struct {
 	Rotate   int
 	Flip     string
 	Kernel   string
+	// Fit is how the image is resized when both Width and Height are set,
+	// one of the Fit* constants. Empty is FitInside.
+	Fit string
+	// Crop is a rectangle of the auto-rotated original, after the image's
+	// edits, to cut out before anything else is applied
+	Crop *Crop
+	// Gravity is the part of the image kept by a cover crop and where a
+	// contained image sits in its box, one of the Gravity* constants. Empty
+	// keeps the image's focal point in frame, or the centre without one.
+	Gravity string
+	// Develop is DevelopFull to render RAW images from their sensor data,
+	// empty uses their embedded or paired JPEG where it's large enough
+	Develop string
+	// Profile is the output colour profile, one of the Profile* constants
+	// or the name of an uploaded ICC profile. Empty is sRGB.
+	Profile string
+	// Intent is the rendering intent of the conversion to Profile, one of
+	// the Intent* constants. Empty is perceptual.
+	Intent string
+	// StripProfile leaves the output profile out of the file
+	StripProfile bool
+	// Depth is 16 for 16-bit PNG and TIFF output, 0 or 8 otherwise
+	Depth int
+	// Proof is the CMYK profile the output is soft-proofed against, empty
+	// for none
+	Proof string
+	// ColourProfiles are the uploaded profiles Profile and Proof name, by
+	// name. Resolved like Watermark, never part of the query string.
+	ColourProfiles map[string]ColourProfile
+	// Metadata is how much of the original's metadata is kept, one of the
+	// Metadata* constants. Empty is MetadataNone.
+	Metadata string
+	// Page is the page of a multi-page original, or frame of an animated
+	// one, to render, counted from 1. 0 is the first page, or the whole
+	// animation where KeepsAnimation allows.
+	Page int
+	// Watermark is drawn over the finished transform, nil for none. It's
+	// never part of the query string, the route resolves it from the
+	// owner's watermark profile or the share link the image is served by.
+	Watermark *Watermark
 }
 
 // ToQueryString serializes the transform parameters into a URL query string.
@@ -43,14 +83,185 @@ func (p *TransformParams) ToQueryString() string {
 	if p.Kernel != "" {
 		q.Set("kernel", p.Kernel)
 	}
+	if p.Fit != "" {
+		q.Set("fit", p.Fit)
+	}
+	if p.Crop != nil {
+		q.Set("crop", p.Crop.String())
+	}
+	if p.Gravity != "" {
+		q.Set("gravity", p.Gravity)
+	}
+	if p.Develop != "" {
+		q.Set("develop", p.Develop)
+	}
+	if p.Profile != "" {
+		q.Set("profile", p.Profile)
+	}
+	if p.Intent != "" {
+		q.Set("intent", p.Intent)
+	}
+	if p.StripProfile {
+		q.Set("icc", ICCStrip)
+	}
+	if p.Depth > 0 {
+		q.Set("depth", strconv.Itoa(p.Depth))
+	}
+	if p.Proof != "" {
+		q.Set("proof", p.Proof)
+	}
+	if p.Metadata != "" {
+		q.Set("metadata", p.Metadata)
+	}
+	if p.Page > 0 {
+		q.Set("page", strconv.Itoa(p.Page))
+	}
 	return q.Encode()
 }
 
+// ParseQuery parses the transform parameters of a query string, the
+// inverse of ToQueryString
+func ParseQuery(q url.Values) (*TransformParams, error) {
+	params := &TransformParams{}
+	params.Format = q.Get("format")
+	params.Flip = q.Get("flip")
+	params.Kernel = q.Get("kernel")
+
+	// Check for 'w' (short for width) first, then 'width'
+	if widthParam := q.Get("w"); widthParam != "" {
+		if w, err := strconv.ParseInt(widthParam, 10, 64); err == nil {
+			params.Width = w
+		}
+	} else if widthParam := q.Get("width"); widthParam != "" {
+		if w, err := strconv.ParseInt(widthParam, 10, 64); err == nil {
+			params.Width = w
+		}
+	}
+
+	// Check for 'h' (short for height) first, then 'height'
+	if heightParam := q.Get("h"); heightParam != "" {
+		if h, err := strconv.ParseInt(heightParam, 10, 64); err == nil {
+			params.Height = h
+		}
+	} else if heightParam := q.Get("height"); heightParam != "" {
+		if h, err := strconv.ParseInt(heightParam, 10, 64); err == nil {
+			params.Height = h
+		}
+	}
+
+	if qualityParam := q.Get("quality"); qualityParam != "" {
+		if qn, err := strconv.ParseInt(qualityParam, 10, 64); err == nil {
+			params.Quality = qn
+		}
+	}
+
+	if rotateParam := q.Get("rotate"); rotateParam != "" {
+		if r, err := strconv.Atoi(rotateParam); err == nil {
+			params.Rotate = r
+		}
+	}
+
+	// Unlike the numbers above, which fall back to their defaults, these
+	// change what part of the image is shown so a typo is an error
+	var err error
+	if params.Fit, err = ParseFit(q.Get("fit")); err != nil {
+		return nil, err
+	}
+
+	if params.Gravity, err = ParseGravity(q.Get("gravity")); err != nil {
+		return nil, err
+	}
+
+	if cropParam := q.Get("crop"); cropParam != "" {
+		if params.Crop, err = ParseCrop(cropParam); err != nil {
+			return nil, err
+		}
+	}
+
+	if params.Develop, err = ParseDevelop(q.Get("develop")); err != nil {
+		return nil, err
+	}
+
+	if params.Profile, err = ParseProfile(q.Get("profile")); err != nil {
+		return nil, err
+	}
+
+	if params.Intent, err = ParseIntent(q.Get("intent")); err != nil {
+		return nil, err
+	}
+
+	if params.StripProfile, err = ParseICC(q.Get("icc")); err != nil {
+		return nil, err
+	}
+
+	if params.Depth, err = ParseDepth(q.Get("depth")); err != nil {
+		return nil, err
+	}
+
+	if params.Proof, err = ParseProof(q.Get("proof")); err != nil {
+		return nil, err
+	}
+
+	if err := params.validateColour(); err != nil {
+		return nil, err
+	}
+
+	if params.Metadata, err = ParseMetadata(q.Get("metadata")); err != nil {
+		return nil, err
+	}
+
+	if params.Page, err = ParsePage(q.Get("page")); err != nil {
+		return nil, err
+	}
+
+	return params, nil
+}
+
+// UsesFocalPoint reports whether the output depends on the image's focal
+// point, which is the case for cover crops without an explicit gravity.
+func (p *TransformParams) UsesFocalPoint() bool {
+	return p.Fit == FitCover && p.Gravity == "" && p.Width > 0 && p.Height > 0
+}
+
 // CreateTransformEtag creates a unique ETag for a given image and transform.
 func CreateTransformEtag(imgEnt entities.ImageAsset, params *TransformParams) *string {
 	checksum := "unknown"
 	if imgEnt.ImageMetadata != nil {
 		checksum = imgEnt.ImageMetadata.Checksum
 	}
-	return utils.StringPtr(fmt.Sprintf("%s-%dx%d-%s-%d-%d-%s-%s", checksum, params.Width, params.Height, params.Format, params.Quality, params.Rotate, params.Flip, params.Kernel))
+	etag := fmt.Sprintf("%s-%dx%d-%s-%d-%d-%s-%s", checksum, params.Width, params.Height, params.Format, params.Quality, params.Rotate, params.Flip, params.Kernel)
+
+	// Only appended when set so the ETags, and cache keys, of transforms
+	// without them stay the same. Edits change every transform of an image.
+	if params.Fit != "" {
+		etag += "-fit:" + params.Fit
+	}
+	if params.Crop != nil {
+		etag += "-crop:" + params.Crop.String()
+	}
+	if params.Gravity != "" {
+		etag += "-gravity:" + params.Gravity
+	}
+	if params.Develop != "" {
+		etag += "-develop:" + params.Develop
+	}
+	if params.Page > 0 {
+		etag += "-page:" + strconv.Itoa(params.Page)
+	}
+	etag += params.colourEtag()
+	// Always appended, unlike the options above, so renditions cached
+	// before the policy existed, with all of the original's metadata, are
+	// never served again
+	etag += "-meta:" + params.MetadataPolicy()
+	if imgEnt.Edits != nil && len(*imgEnt.Edits) > 0 {
+		etag += "-edits:" + EditsHash(*imgEnt.Edits)
+	}
+	if params.UsesFocalPoint() && imgEnt.FocalPoint != nil {
+		etag += fmt.Sprintf("-focus:%.4f,%.4f", imgEnt.FocalPoint.X, imgEnt.FocalPoint.Y)
+	}
+	if params.Watermark != nil {
+		etag += "-wm:" + WatermarkHash(params.Watermark.Profile)
+	}
+
+	return utils.StringPtr(etag)
 }
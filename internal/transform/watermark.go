@@ -0,0 +1,118 @@
+package transform
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"unicode/utf8"
+
+	"viz/internal/dto"
+)
+
+const (
+	// MaxWatermarkText is the longest text a watermark profile can draw
+	MaxWatermarkText = 200
+	// DefaultWatermarkColor is the colour of watermark text without one
+	DefaultWatermarkColor = "#ffffff"
+	// watermarkMargin keeps a placed watermark off the image edges, as a
+	// fraction of the image's shorter side
+	watermarkMargin = 0.02
+)
+
+var (
+	watermarkColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+	// logos are stored under their sha1 checksum, which is also all that
+	// keeps a profile from pointing anywhere else in storage
+	watermarkLogoPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+)
+
+// Watermark is a watermark profile with its logo, if it has one, loaded
+type Watermark struct {
+	Profile dto.WatermarkProfile
+	// Logo is the PNG the profile's Logo checksum refers to
+	Logo []byte
+}
+
+// ValidateWatermark checks that a watermark profile's values are in range.
+// A profile that draws nothing is valid, it's just never applied.
+func ValidateWatermark(p dto.WatermarkProfile) error {
+	if p.Opacity < 0 || p.Opacity > 1 || math.IsNaN(float64(p.Opacity)) {
+		return fmt.Errorf("opacity must be between 0 and 1")
+	}
+
+	if p.Scale <= 0 || p.Scale > 1 || math.IsNaN(float64(p.Scale)) {
+		return fmt.Errorf("scale must be above 0 and at most 1")
+	}
+
+	switch p.Position {
+	case dto.WatermarkProfilePositionCentre, dto.WatermarkProfilePositionNorth, dto.WatermarkProfilePositionNortheast,
+		dto.WatermarkProfilePositionEast, dto.WatermarkProfilePositionSoutheast, dto.WatermarkProfilePositionSouth,
+		dto.WatermarkProfilePositionSouthwest, dto.WatermarkProfilePositionWest, dto.WatermarkProfilePositionNorthwest:
+	default:
+		return fmt.Errorf("unknown position %q", p.Position)
+	}
+
+	if p.Text != nil && utf8.RuneCountInString(*p.Text) > MaxWatermarkText {
+		return fmt.Errorf("text is longer than %d characters", MaxWatermarkText)
+	}
+
+	if p.Color != nil && !watermarkColorPattern.MatchString(*p.Color) {
+		return fmt.Errorf("color %q must be written as #rrggbb", *p.Color)
+	}
+
+	if p.Logo != nil && !watermarkLogoPattern.MatchString(*p.Logo) {
+		return fmt.Errorf("logo %q is not a logo checksum", *p.Logo)
+	}
+
+	return nil
+}
+
+// WatermarkDrawsNothing reports whether a profile has neither a logo nor
+// text, or is fully transparent
+func WatermarkDrawsNothing(p dto.WatermarkProfile) bool {
+	hasLogo := p.Logo != nil && *p.Logo != ""
+	hasText := p.Text != nil && *p.Text != ""
+	return (!hasLogo && !hasText) || p.Opacity == 0
+}
+
+// WatermarkHash identifies the contents of a watermark profile, it's part
+// of the ETag of every watermarked transform
+func WatermarkHash(p dto.WatermarkProfile) string {
+	data, _ := json.Marshal(p)
+	return fmt.Sprintf("%x", sha1.Sum(data))[:16]
+}
+
+// WatermarkColor returns the RGB colour of a profile's text
+func WatermarkColor(p dto.WatermarkProfile) [3]float64 {
+	color := DefaultWatermarkColor
+	if p.Color != nil && watermarkColorPattern.MatchString(*p.Color) {
+		color = *p.Color
+	}
+
+	b, _ := hex.DecodeString(color[1:])
+	return [3]float64{float64(b[0]), float64(b[1]), float64(b[2])}
+}
+
+// WatermarkWidth returns how wide a profile draws its watermark on an
+// image imgW pixels wide
+func WatermarkWidth(p dto.WatermarkProfile, imgW int) int {
+	return max(int(math.Round(float64(p.Scale)*float64(imgW))), 1)
+}
+
+// WatermarkOffset returns where a markW x markH watermark is placed in an
+// imgW x imgH image for a position, a small margin in from the edges it's
+// placed against
+func WatermarkOffset(imgW, imgH, markW, markH int, position dto.WatermarkProfilePosition) (left, top int) {
+	margin := int(math.Round(watermarkMargin * float64(min(imgW, imgH))))
+	left, top = CropOffset(imgW-2*margin, imgH-2*margin, markW, markH, string(position), nil)
+	return left + margin, top + margin
+}
+
+// WatermarkTileSpacing returns the gap left between tiled watermarks of
+// markW x markH, so the image still shows through
+func WatermarkTileSpacing(markW, markH int) (gapX, gapY int) {
+	return max(markW/2, 1), max(markH, 1)
+}
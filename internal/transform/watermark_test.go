@@ -0,0 +1,116 @@
+package transform
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"viz/internal/dto"
+	"viz/internal/entities"
+	"viz/internal/utils"
+)
+
+func TestValidateWatermark(t *testing.T) {
+	valid := dto.WatermarkProfile{
+		Text:     utils.StringPtr("© Studio"),
+		Color:    utils.StringPtr("#FF8800"),
+		Logo:     utils.StringPtr(strings.Repeat("ab", 20)),
+		Position: dto.WatermarkProfilePositionSoutheast,
+		Opacity:  0.5,
+		Scale:    0.2,
+	}
+	if err := ValidateWatermark(valid); err != nil {
+		t.Fatalf("ValidateWatermark() = %v", err)
+	}
+
+	invalid := []func(p *dto.WatermarkProfile){
+		func(p *dto.WatermarkProfile) { p.Opacity = 1.5 },
+		func(p *dto.WatermarkProfile) { p.Opacity = float32(math.NaN()) },
+		func(p *dto.WatermarkProfile) { p.Scale = 0 },
+		func(p *dto.WatermarkProfile) { p.Position = "top" },
+		func(p *dto.WatermarkProfile) { p.Color = utils.StringPtr("white") },
+		func(p *dto.WatermarkProfile) { p.Text = utils.StringPtr(strings.Repeat("x", MaxWatermarkText+1)) },
+		func(p *dto.WatermarkProfile) { p.Logo = utils.StringPtr("../../library/secret") },
+	}
+	for i, change := range invalid {
+		p := valid
+		change(&p)
+		if err := ValidateWatermark(p); err == nil {
+			t.Errorf("ValidateWatermark() of invalid profile %d succeeded", i)
+		}
+	}
+}
+
+func TestWatermarkDrawsNothing(t *testing.T) {
+	p := dto.WatermarkProfile{Position: dto.WatermarkProfilePositionCentre, Opacity: 0.5, Scale: 0.2}
+	if !WatermarkDrawsNothing(p) {
+		t.Error("WatermarkDrawsNothing() of a profile without text or logo = false")
+	}
+
+	p.Text = utils.StringPtr("proof")
+	if WatermarkDrawsNothing(p) {
+		t.Error("WatermarkDrawsNothing() of a profile with text = true")
+	}
+
+	p.Opacity = 0
+	if !WatermarkDrawsNothing(p) {
+		t.Error("WatermarkDrawsNothing() of a transparent profile = false")
+	}
+}
+
+func TestWatermarkColor(t *testing.T) {
+	if got := WatermarkColor(dto.WatermarkProfile{}); got != [3]float64{255, 255, 255} {
+		t.Errorf("WatermarkColor() default = %v", got)
+	}
+
+	if got := WatermarkColor(dto.WatermarkProfile{Color: utils.StringPtr("#10a0FF")}); got != [3]float64{16, 160, 255} {
+		t.Errorf("WatermarkColor() = %v", got)
+	}
+}
+
+func TestWatermarkOffset(t *testing.T) {
+	// 1000x500 leaves a 10px margin
+	tests := []struct {
+		position  dto.WatermarkProfilePosition
+		left, top int
+	}{
+		{dto.WatermarkProfilePositionNorthwest, 10, 10},
+		{dto.WatermarkProfilePositionCentre, 450, 225},
+		{dto.WatermarkProfilePositionSoutheast, 890, 440},
+		{dto.WatermarkProfilePositionSouth, 450, 440},
+		{dto.WatermarkProfilePositionEast, 890, 225},
+	}
+	for _, tt := range tests {
+		left, top := WatermarkOffset(1000, 500, 100, 50, tt.position)
+		if left != tt.left || top != tt.top {
+			t.Errorf("WatermarkOffset(%s) = %d,%d, want %d,%d", tt.position, left, top, tt.left, tt.top)
+		}
+	}
+
+	if got := WatermarkWidth(dto.WatermarkProfile{Scale: 0.25}, 1000); got != 250 {
+		t.Errorf("WatermarkWidth() = %d", got)
+	}
+}
+
+func TestCreateTransformEtagWatermark(t *testing.T) {
+	img := entities.ImageAsset{}
+	params := &TransformParams{Format: "jpg"}
+	plain := *CreateTransformEtag(img, params)
+
+	params.Watermark = &Watermark{Profile: dto.WatermarkProfile{Text: utils.StringPtr("proof"), Opacity: 0.5, Scale: 0.2}}
+	marked := *CreateTransformEtag(img, params)
+	if marked == plain || !strings.HasPrefix(marked, plain+"-wm:") {
+		t.Errorf("CreateTransformEtag() with a watermark = %q, without %q", marked, plain)
+	}
+
+	// the logo bytes aren't part of it, the profile's checksum of them is
+	params.Watermark.Logo = []byte("png")
+	if got := *CreateTransformEtag(img, params); got != marked {
+		t.Errorf("CreateTransformEtag() changed with the loaded logo: %q", got)
+	}
+
+	params.Watermark.Profile.Opacity = 0.6
+	if got := *CreateTransformEtag(img, params); got == marked {
+		t.Error("CreateTransformEtag() didn't change with the watermark profile")
+	}
+}
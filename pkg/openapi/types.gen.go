@@ -30,6 +30,18 @@ const (
 	AdminUserUpdateRoleUser       AdminUserUpdateRole = "user"
 )
 
+// Defines values for ImageEditOp.
+const (
+	ImageEditOpContrast     ImageEditOp = "contrast"
+	ImageEditOpCrop         ImageEditOp = "crop"
+	ImageEditOpExposure     ImageEditOp = "exposure"
+	ImageEditOpSaturation   ImageEditOp = "saturation"
+	ImageEditOpSharpen      ImageEditOp = "sharpen"
+	ImageEditOpStraighten   ImageEditOp = "straighten"
+	ImageEditOpVignette     ImageEditOp = "vignette"
+	ImageEditOpWhiteBalance ImageEditOp = "white_balance"
+)
+
 // Defines values for ImageMetadataLabel.
 const (
 	ImageMetadataLabelBlue   ImageMetadataLabel = "Blue"
@@ -63,6 +75,32 @@ const (
 	String  SettingDefaultValueType = "string"
 )
 
+// Defines values for SearchResultType.
+const (
+	SearchResultTypeCollection SearchResultType = "collection"
+	SearchResultTypeImage      SearchResultType = "image"
+	SearchResultTypeUser       SearchResultType = "user"
+)
+
+// Defines values for StorageConfigBackend.
+const (
+	StorageConfigBackendLocal StorageConfigBackend = "local"
+	StorageConfigBackendS3    StorageConfigBackend = "s3"
+)
+
+// Defines values for StorageLocationConfigBackend.
+const (
+	StorageLocationConfigBackendLocal StorageLocationConfigBackend = "local"
+	StorageLocationConfigBackendS3    StorageLocationConfigBackend = "s3"
+)
+
+// Defines values for TransformPresetSource.
+const (
+	Builtin TransformPresetSource = "builtin"
+	Config  TransformPresetSource = "config"
+	Custom  TransformPresetSource = "custom"
+)
+
 // Defines values for UserRole.
 const (
 	UserRoleAdmin      UserRole = "admin"
@@ -71,6 +109,19 @@ const (
 	UserRoleUser       UserRole = "user"
 )
 
+// Defines values for WatermarkProfilePosition.
+const (
+	WatermarkProfilePositionCentre    WatermarkProfilePosition = "centre"
+	WatermarkProfilePositionEast      WatermarkProfilePosition = "east"
+	WatermarkProfilePositionNorth     WatermarkProfilePosition = "north"
+	WatermarkProfilePositionNortheast WatermarkProfilePosition = "northeast"
+	WatermarkProfilePositionNorthwest WatermarkProfilePosition = "northwest"
+	WatermarkProfilePositionSouth     WatermarkProfilePosition = "south"
+	WatermarkProfilePositionSoutheast WatermarkProfilePosition = "southeast"
+	WatermarkProfilePositionSouthwest WatermarkProfilePosition = "southwest"
+	WatermarkProfilePositionWest      WatermarkProfilePosition = "west"
+)
+
 // Defines values for WorkerJobCreateRequestCommand.
 const (
 	All     WorkerJobCreateRequestCommand = "all"
@@ -99,26 +150,101 @@ const (
 
 // Defines values for GetImageFileParamsFormat.
 const (
+	Auto GetImageFileParamsFormat = "auto"
 	Avif GetImageFileParamsFormat = "avif"
+	Gif  GetImageFileParamsFormat = "gif"
 	Heif GetImageFileParamsFormat = "heif"
 	Jpeg GetImageFileParamsFormat = "jpeg"
 	Jpg  GetImageFileParamsFormat = "jpg"
+	Jxl  GetImageFileParamsFormat = "jxl"
 	Png  GetImageFileParamsFormat = "png"
+	Tiff GetImageFileParamsFormat = "tiff"
 	Webp GetImageFileParamsFormat = "webp"
 )
 
+// Defines values for GetImageFileParamsFit.
+const (
+	Contain GetImageFileParamsFit = "contain"
+	Cover   GetImageFileParamsFit = "cover"
+	Fill    GetImageFileParamsFit = "fill"
+	Inside  GetImageFileParamsFit = "inside"
+	Outside GetImageFileParamsFit = "outside"
+)
+
+// Defines values for GetImageFileParamsGravity.
+const (
+	Attention GetImageFileParamsGravity = "attention"
+	Center    GetImageFileParamsGravity = "center"
+	Centre    GetImageFileParamsGravity = "centre"
+	East      GetImageFileParamsGravity = "east"
+	North     GetImageFileParamsGravity = "north"
+	Northeast GetImageFileParamsGravity = "northeast"
+	Northwest GetImageFileParamsGravity = "northwest"
+	Smart     GetImageFileParamsGravity = "smart"
+	South     GetImageFileParamsGravity = "south"
+	Southeast GetImageFileParamsGravity = "southeast"
+	Southwest GetImageFileParamsGravity = "southwest"
+	West      GetImageFileParamsGravity = "west"
+)
+
+// Defines values for GetImageFileParamsDevelop.
+const (
+	Full GetImageFileParamsDevelop = "full"
+)
+
+// Defines values for GetImageFileParamsIntent.
+const (
+	Absolute   GetImageFileParamsIntent = "absolute"
+	Perceptual GetImageFileParamsIntent = "perceptual"
+	Relative   GetImageFileParamsIntent = "relative"
+	Saturation GetImageFileParamsIntent = "saturation"
+)
+
+// Defines values for GetImageFileParamsIcc.
+const (
+	Embed GetImageFileParamsIcc = "embed"
+	Strip GetImageFileParamsIcc = "strip"
+)
+
+// Defines values for GetImageFileParamsDepth.
+const (
+	GetImageFileParamsDepthN16 GetImageFileParamsDepth = 16
+	GetImageFileParamsDepthN8  GetImageFileParamsDepth = 8
+)
+
+// Defines values for GetImageFileParamsMetadata.
+const (
+	GetImageFileParamsMetadataCopyright GetImageFileParamsMetadata = "copyright"
+	GetImageFileParamsMetadataKeep      GetImageFileParamsMetadata = "keep"
+	GetImageFileParamsMetadataNoGps     GetImageFileParamsMetadata = "no-gps"
+	GetImageFileParamsMetadataNone      GetImageFileParamsMetadata = "none"
+)
+
+// Defines values for GetImageFileParamsWatermark.
+const (
+	GetImageFileParamsWatermarkN1 GetImageFileParamsWatermark = "1"
+)
+
 // Defines values for GetImageFileParamsDownload.
 const (
-	N1 GetImageFileParamsDownload = "1"
+	GetImageFileParamsDownloadN1 GetImageFileParamsDownload = "1"
 )
 
 // Defines values for ListJobsParamsStatus.
 const (
-	Cancelled ListJobsParamsStatus = "cancelled"
-	Completed ListJobsParamsStatus = "completed"
-	Failed    ListJobsParamsStatus = "failed"
-	Queued    ListJobsParamsStatus = "queued"
-	Running   ListJobsParamsStatus = "running"
+	Cancelled  ListJobsParamsStatus = "cancelled"
+	Completed  ListJobsParamsStatus = "completed"
+	DeadLetter ListJobsParamsStatus = "dead_letter"
+	Failed     ListJobsParamsStatus = "failed"
+	Pending    ListJobsParamsStatus = "pending"
+	Queued     ListJobsParamsStatus = "queued"
+	Running    ListJobsParamsStatus = "running"
+	Skipped    ListJobsParamsStatus = "skipped"
+)
+
+// Defines values for ExecuteSearchParamsScope.
+const (
+	ExecuteSearchParamsScopeAll ExecuteSearchParamsScope = "all"
 )
 
 // APIKey defines model for APIKey.
@@ -286,8 +412,9 @@ type Collection struct {
 	Owner *User  `json:"owner,omitempty"`
 
 	// Private Is private
-	Private   *bool       `json:"private"`
-	Thumbnail *ImageAsset `json:"thumbnail,omitempty"`
+	Private     *bool        `json:"private"`
+	SavedSearch *SavedSearch `json:"saved_search,omitempty"`
+	Thumbnail   *ImageAsset  `json:"thumbnail,omitempty"`
 
 	// Uid Collection UID
 	Uid string `json:"uid"`
@@ -306,6 +433,9 @@ type CollectionCreate struct {
 
 	// Private Is private
 	Private *bool `json:"private"`
+
+	// SavedSearchUid Saved search that defines the images of a smart collection
+	SavedSearchUid *string `json:"saved_search_uid,omitempty"`
 }
 
 // CollectionDetailResponse defines model for CollectionDetailResponse.
@@ -326,8 +456,9 @@ type CollectionDetailResponse struct {
 	Owner *User  `json:"owner,omitempty"`
 
 	// Private Is private
-	Private   *bool       `json:"private"`
-	Thumbnail *ImageAsset `json:"thumbnail,omitempty"`
+	Private     *bool        `json:"private"`
+	SavedSearch *SavedSearch `json:"saved_search,omitempty"`
+	Thumbnail   *ImageAsset  `json:"thumbnail,omitempty"`
 
 	// Uid Collection UID
 	Uid string `json:"uid"`
@@ -387,10 +518,80 @@ type CollectionUpdate struct {
 	// Private Is private
 	Private *bool `json:"private,omitempty"`
 
+	// SavedSearchUID Saved search UID that makes this a smart collection (empty string to make it a regular collection)
+	SavedSearchUID *string `json:"savedSearchUID,omitempty"`
+
 	// ThumbnailUID Thumbnail image UID
 	ThumbnailUID *string `json:"thumbnailUID,omitempty"`
 }
 
+// ColourProfile An ICC profile uploaded for transforms to convert to or soft-proof against
+type ColourProfile struct {
+	// Checksum Checksum of the profile, part of the ETags of transforms using it
+	Checksum string `json:"checksum"`
+
+	// Class ICC device class, like mntr for displays and prtr for printers
+	Class string `json:"class"`
+
+	// ColourSpace Colour space of the device, RGB, CMYK or GRAY
+	ColourSpace string    `json:"colour_space"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	// Name Name transforms refer to the profile by
+	Name string `json:"name"`
+
+	// Size Size of the profile in bytes
+	Size      int64     `json:"size"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ColourProfileListResponse defines model for ColourProfileListResponse.
+type ColourProfileListResponse struct {
+	Items []ColourProfile `json:"items"`
+}
+
+// CronJob defines model for CronJob.
+type CronJob struct {
+	// Description What the job does
+	Description string `json:"description"`
+
+	// LastDurationMs How long the last run took in milliseconds
+	LastDurationMs *int64 `json:"last_duration_ms"`
+
+	// LastError Error of the last run, null if it succeeded
+	LastError *string `json:"last_error"`
+
+	// LastRunAt When the last run started
+	LastRunAt *time.Time `json:"last_run_at"`
+
+	// Name Cron job name
+	Name string `json:"name"`
+
+	// NextRunAt When the job runs next, null while paused
+	NextRunAt *time.Time `json:"next_run_at"`
+
+	// Paused Whether scheduled runs are paused
+	Paused bool `json:"paused"`
+
+	// Running Whether the job is running right now
+	Running bool `json:"running"`
+
+	// Schedule Crontab expression like "0 3 * * *" or a descriptor like "@every 1h"
+	Schedule string `json:"schedule"`
+}
+
+// CronJobsResponse defines model for CronJobsResponse.
+type CronJobsResponse struct {
+	// Items List of cron jobs
+	Items []CronJob `json:"items"`
+}
+
+// CronScheduleUpdate defines model for CronScheduleUpdate.
+type CronScheduleUpdate struct {
+	// Schedule Crontab expression like "0 3 * * *" or a descriptor like "@every 1h"
+	Schedule string `json:"schedule"`
+}
+
 // DatabaseConfig defines model for DatabaseConfig.
 type DatabaseConfig struct {
 	// Location Database location/host
@@ -491,6 +692,9 @@ type DownloadToken struct {
 
 	// UpdatedAt When this token was last updated
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// Watermark Watermark drawn on every image served through this token, regardless of the request's parameters
+	Watermark *WatermarkProfile `json:"watermark,omitempty"`
 }
 
 // ErrorResponse defines model for ErrorResponse.
@@ -520,17 +724,94 @@ type EventRecord struct {
 	Timestamp time.Time `json:"timestamp"`
 }
 
+// FocalPoint Point of interest kept in frame by cover crops, as fractions of the image width and height from the top left
+type FocalPoint struct {
+	// X Horizontal position, 0 is the left edge and 1 the right
+	X float32 `json:"x"`
+
+	// Y Vertical position, 0 is the top edge and 1 the bottom
+	Y float32 `json:"y"`
+}
+
+// IIIFConfig defines model for IIIFConfig.
+type IIIFConfig struct {
+	// PyramidMinMegapixels Tile pyramids are built for uploaded and edited images at least this large, 0 only builds them when asked through the jobs API
+	PyramidMinMegapixels *int `json:"pyramid_min_megapixels,omitempty"`
+}
+
+// IIIFImageInfo IIIF Image API 3.0 image information
+type IIIFImageInfo struct {
+	// Context JSON-LD context of the IIIF Image API 3.0
+	Context        string    `json:"@context"`
+	ExtraFeatures  *[]string `json:"extraFeatures,omitempty"`
+	ExtraFormats   *[]string `json:"extraFormats,omitempty"`
+	ExtraQualities *[]string `json:"extraQualities,omitempty"`
+
+	// Height Height of the full image
+	Height int `json:"height"`
+
+	// Id Base URI of the image's IIIF requests
+	Id string `json:"id"`
+
+	// MaxArea Most pixels a request can ask for
+	MaxArea *int `json:"maxArea,omitempty"`
+
+	// MaxHeight Largest height a request can ask for
+	MaxHeight *int `json:"maxHeight,omitempty"`
+
+	// MaxWidth Largest width a request can ask for
+	MaxWidth *int `json:"maxWidth,omitempty"`
+
+	// Profile Compliance level
+	Profile string `json:"profile"`
+
+	// Protocol Always http://iiif.io/api/image
+	Protocol string `json:"protocol"`
+
+	// Sizes Sizes of the whole image that are quick to render
+	Sizes *[]IIIFSize  `json:"sizes,omitempty"`
+	Tiles *[]IIIFTiles `json:"tiles,omitempty"`
+
+	// Type Always ImageService3
+	Type string `json:"type"`
+
+	// Width Width of the full image
+	Width int `json:"width"`
+}
+
+// IIIFSize defines model for IIIFSize.
+type IIIFSize struct {
+	Height int `json:"height"`
+	Width  int `json:"width"`
+}
+
+// IIIFTiles defines model for IIIFTiles.
+type IIIFTiles struct {
+	// Height Tile height, the width when not set
+	Height *int `json:"height,omitempty"`
+
+	// ScaleFactors Factors the image is shrunk by for its tiles
+	ScaleFactors []int `json:"scaleFactors"`
+
+	// Width Tile width in pixels of the scaled image
+	Width int `json:"width"`
+}
+
 // ImageAsset defines model for ImageAsset.
 type ImageAsset struct {
 	// CreatedAt Creation time
 	CreatedAt time.Time `json:"created_at"`
 
 	// Description Image description
-	Description *string    `json:"description,omitempty"`
-	Exif        *ImageEXIF `json:"exif,omitempty"`
+	Description *string `json:"description,omitempty"`
+
+	// Edits Non-destructive edits applied to every rendition of the image, in order
+	Edits *[]ImageEdit `json:"edits,omitempty"`
+	Exif  *ImageEXIF   `json:"exif,omitempty"`
 
 	// Favourited Is favourited
-	Favourited *bool `json:"favourited,omitempty"`
+	Favourited *bool       `json:"favourited,omitempty"`
+	FocalPoint *FocalPoint `json:"focal_point,omitempty"`
 
 	// Height Image height
 	Height        int32          `json:"height"`
@@ -547,6 +828,9 @@ type ImageAsset struct {
 	// Processed Is processed
 	Processed bool `json:"processed"`
 
+	// StorageLocation Storage location holding the image's files, the default location when empty
+	StorageLocation *string `json:"storage_location,omitempty"`
+
 	// TakenAt Taken time
 	TakenAt *time.Time `json:"taken_at"`
 
@@ -696,8 +980,56 @@ type ImageEXIF struct {
 	WhiteBalance *string `json:"white_balance,omitempty"`
 }
 
+// ImageEdit One operation of an image's edit stack. Crop rectangles are in pixels of the image as left by the operations before it.
+type ImageEdit struct {
+	// Amount Strength of the operation: stops for exposure (-5 to 5), -100 to 100 for contrast and saturation, 0 to 100 for sharpen and vignette
+	Amount *float32 `json:"amount,omitempty"`
+
+	// Angle Straighten angle in degrees (-45 to 45), positive values rotate clockwise
+	Angle *float32       `json:"angle,omitempty"`
+	Crop  *ImageEditCrop `json:"crop,omitempty"`
+
+	// Op Edit operation
+	Op ImageEditOp `json:"op"`
+
+	// Temperature White balance shift from -100 (cooler) to 100 (warmer)
+	Temperature *float32 `json:"temperature,omitempty"`
+
+	// Tint White balance shift from -100 (greener) to 100 (more magenta)
+	Tint *float32 `json:"tint,omitempty"`
+}
+
+// ImageEditOp Edit operation
+type ImageEditOp string
+
+// ImageEditCrop Crop rectangle in pixels
+type ImageEditCrop struct {
+	Height int64 `json:"height"`
+	Width  int64 `json:"width"`
+	X      int64 `json:"x"`
+	Y      int64 `json:"y"`
+}
+
+// ImageEditStack defines model for ImageEditStack.
+type ImageEditStack struct {
+	// CanUndo Whether there is an earlier stack to go back to
+	CanUndo bool `json:"can_undo"`
+
+	// Edits Edit operations, applied in order to the original
+	Edits []ImageEdit `json:"edits"`
+}
+
+// ImageEditStackUpdate defines model for ImageEditStackUpdate.
+type ImageEditStackUpdate struct {
+	// Edits Edit operations replacing the current stack
+	Edits []ImageEdit `json:"edits"`
+}
+
 // ImageMetadata defines model for ImageMetadata.
 type ImageMetadata struct {
+	// Animated The original is an animated GIF or WebP
+	Animated *bool `json:"animated,omitempty"`
+
 	// Checksum File checksum
 	Checksum string `json:"checksum"`
 
@@ -731,12 +1063,24 @@ type ImageMetadata struct {
 	// Metadata Additional metadata
 	Metadata *string `json:"metadata,omitempty"`
 
+	// MimeType Media type of the original, detected from its contents rather than its name
+	MimeType *string `json:"mime_type,omitempty"`
+
 	// OriginalFileName Original file name
 	OriginalFileName *string `json:"original_file_name,omitempty"`
 
+	// Pages Number of pages of a multi-page original, or frames of an animated one
+	Pages *int `json:"pages,omitempty"`
+
 	// Rating User-assigned rating (0-5). Null = unrated
 	Rating *int `json:"rating"`
 
+	// RawChecksum Checksum of the camera RAW file
+	RawChecksum *string `json:"raw_checksum,omitempty"`
+
+	// RawFileName File name of the camera RAW file, the original itself for RAW only images
+	RawFileName *string `json:"raw_file_name,omitempty"`
+
 	// Thumbhash Thumbhash
 	Thumbhash *string `json:"thumbhash,omitempty"`
 }
@@ -759,6 +1103,30 @@ type ImagePaths struct {
 	Thumbnail string `json:"thumbnail"`
 }
 
+// ImageSrcset Responsive image candidates for an img element
+type ImageSrcset struct {
+	// Sizes Sizes attribute for the img element
+	Sizes string `json:"sizes"`
+
+	// Sources Transform of every width in the srcset
+	Sources []ImageSrcsetSource `json:"sources"`
+
+	// Src Fallback URL for browsers without srcset support, the widest source
+	Src string `json:"src"`
+
+	// Srcset Srcset attribute for the img element
+	Srcset string `json:"srcset"`
+}
+
+// ImageSrcsetSource One width of a srcset
+type ImageSrcsetSource struct {
+	// Url Transform URL of the width
+	Url string `json:"url"`
+
+	// Width Width in pixels
+	Width int `json:"width"`
+}
+
 // ImageUpdate defines model for ImageUpdate.
 type ImageUpdate struct {
 	// Description Image description
@@ -766,7 +1134,8 @@ type ImageUpdate struct {
 	Exif        *ImageEXIF `json:"exif,omitempty"`
 
 	// Favourited Is favourited
-	Favourited    *bool `json:"favourited,omitempty"`
+	Favourited    *bool       `json:"favourited,omitempty"`
+	FocalPoint    *FocalPoint `json:"focal_point,omitempty"`
 	ImageMetadata *struct {
 		// Keywords Keywords
 		Keywords *[]string `json:"keywords,omitempty"`
@@ -853,10 +1222,15 @@ type VizConfig struct {
 	BaseDirectory  *string               `json:"base_directory,omitempty"`
 	Cache          *CacheConfig          `json:"cache,omitempty"`
 	Database       *DatabaseConfig       `json:"database,omitempty"`
+	Iiif           *IIIFConfig           `json:"iiif,omitempty"`
 	Libvips        *LibvipsConfig        `json:"libvips,omitempty"`
 	Logging        *LoggingConfig        `json:"logging,omitempty"`
 	Redis          *QueueConfig          `json:"redis,omitempty"`
+	Responsive     *ResponsiveConfig     `json:"responsive,omitempty"`
+	Storage        *StorageConfig        `json:"storage,omitempty"`
 	StorageMetrics *StorageMetricsConfig `json:"storage_metrics,omitempty"`
+	Transforms     *TransformsConfig     `json:"transforms,omitempty"`
+	Trash          *TrashConfig          `json:"trash,omitempty"`
 	Upload         *UploadConfig         `json:"upload,omitempty"`
 	UserManagement *UserManagementConfig `json:"user_management,omitempty"`
 }
@@ -906,6 +1280,84 @@ type OAuthUserData struct {
 	Picture string `json:"picture"`
 }
 
+// Pipeline defines model for Pipeline.
+type Pipeline struct {
+	// CompletedAt When the last step finished
+	CompletedAt *time.Time `json:"completed_at"`
+
+	// CreatedAt Created timestamp
+	CreatedAt time.Time `json:"created_at"`
+
+	// ImageUid Image the pipeline processes
+	ImageUid *string `json:"image_uid"`
+
+	// Name Pipeline name, e.g. upload
+	Name string `json:"name"`
+
+	// OwnerUid User the pipeline runs for
+	OwnerUid *string `json:"owner_uid"`
+
+	// Progress Overall progress from 0 to 100
+	Progress int `json:"progress"`
+
+	// Status One of running, completed, failed or cancelled
+	Status string `json:"status"`
+
+	// Steps Steps in the order they were enqueued
+	Steps []PipelineStep `json:"steps"`
+
+	// Uid Pipeline UID
+	Uid string `json:"uid"`
+}
+
+// PipelineStep defines model for PipelineStep.
+type PipelineStep struct {
+	// CompletedAt Completed timestamp
+	CompletedAt *time.Time `json:"completed_at"`
+
+	// DependsOn UIDs of the steps this one waits for
+	DependsOn []string `json:"depends_on"`
+
+	// ErrorMsg Error message if failed
+	ErrorMsg *string `json:"error_msg"`
+
+	// Progress Step progress from 0 to 100
+	Progress int `json:"progress"`
+
+	// StartedAt Started timestamp
+	StartedAt *time.Time `json:"started_at"`
+
+	// Status Worker job status, pending until the steps it depends on complete and skipped if one of them won't
+	Status string `json:"status"`
+
+	// Topic Job topic
+	Topic string `json:"topic"`
+
+	// Uid Worker job UID
+	Uid string `json:"uid"`
+}
+
+// PipelinesResponse defines model for PipelinesResponse.
+type PipelinesResponse struct {
+	// Items List of pipelines
+	Items []Pipeline `json:"items"`
+
+	// Total Total count of pipelines
+	Total int `json:"total"`
+}
+
+// PublicUser The parts of a user's profile anyone who can find them may see
+type PublicUser struct {
+	// DisplayName Full name, the username when the user hasn't set one
+	DisplayName string `json:"display_name"`
+
+	// Uid User UID
+	Uid string `json:"uid"`
+
+	// Username Username
+	Username string `json:"username"`
+}
+
 // QueueConfig defines model for QueueConfig.
 type QueueConfig struct {
 	// Db Redis DB index
@@ -942,15 +1394,140 @@ type QueueConfig struct {
 	WriteTimeoutSeconds *int `json:"write_timeout_seconds,omitempty"`
 }
 
+// ResponsiveConfig defines model for ResponsiveConfig.
+type ResponsiveConfig struct {
+	// Sizes Sizes attribute returned by the srcset endpoint when a request has none
+	Sizes *string `json:"sizes,omitempty"`
+
+	// Widths Width ladder srcset widths are picked from, requested widths are snapped to it
+	Widths *[]int `json:"widths,omitempty"`
+}
+
+// S3Config defines model for S3Config.
+type S3Config struct {
+	// AccessKeyId Access key ID
+	AccessKeyId *string `json:"access_key_id,omitempty"`
+
+	// Bucket Bucket holding the image files
+	Bucket *string `json:"bucket,omitempty"`
+
+	// Endpoint URL of the S3-compatible service, AWS in the region when empty
+	Endpoint *string `json:"endpoint,omitempty"`
+
+	// Prefix Prefix prepended to every object key
+	Prefix *string `json:"prefix,omitempty"`
+
+	// Region Bucket region
+	Region *string `json:"region,omitempty"`
+
+	// SecretAccessKey Masked secret access key
+	SecretAccessKey *string `json:"secret_access_key,omitempty"`
+
+	// UsePathStyle Address the bucket as endpoint/bucket instead of bucket.endpoint
+	UsePathStyle *bool `json:"use_path_style,omitempty"`
+}
+
+// SavedSearch A search query saved for reuse. Smart collections use one to define their images.
+type SavedSearch struct {
+	// CreatedAt Creation time
+	CreatedAt time.Time `json:"created_at"`
+
+	// Name Saved search name
+	Name  string `json:"name"`
+	Owner *User  `json:"owner,omitempty"`
+
+	// Query Raw search query, as accepted by /search
+	Query string `json:"query"`
+
+	// Uid Saved search UID
+	Uid string `json:"uid"`
+
+	// UpdatedAt Update time
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SavedSearchCreate defines model for SavedSearchCreate.
+type SavedSearchCreate struct {
+	// Name Saved search name
+	Name string `json:"name"`
+
+	// Query Raw search query
+	Query string `json:"query"`
+}
+
+// SavedSearchListResponse defines model for SavedSearchListResponse.
+type SavedSearchListResponse struct {
+	// Items List of saved searches
+	Items []SavedSearch `json:"items"`
+}
+
+// SavedSearchUpdate defines model for SavedSearchUpdate.
+type SavedSearchUpdate struct {
+	// Name Saved search name
+	Name *string `json:"name,omitempty"`
+
+	// Query Raw search query
+	Query *string `json:"query,omitempty"`
+}
+
+// SearchFacetBucket defines model for SearchFacetBucket.
+type SearchFacetBucket struct {
+	// Count Number of matching images with this value
+	Count int64 `json:"count"`
+
+	// Value Facet value
+	Value string `json:"value"`
+}
+
+// SearchHighlight defines model for SearchHighlight.
+type SearchHighlight struct {
+	// Rank Full-text relevance score (higher is better)
+	Rank float64 `json:"rank"`
+
+	// Snippet Matching text with hits wrapped in <mark> tags. The surrounding text is HTML-escaped
+	Snippet *string `json:"snippet,omitempty"`
+}
+
 // SearchListResponse defines model for SearchListResponse.
 type SearchListResponse struct {
 	// Collections List of collections found
 	Collections []Collection `json:"collections"`
 
+	// Facets Value counts for each requested facet, keyed by facet name. Only present when facets were requested
+	Facets *map[string][]SearchFacetBucket `json:"facets,omitempty"`
+
+	// Highlights Relevance and highlighted snippet for each image, keyed by image UID. Only present when the query contains free text
+	Highlights *map[string]SearchHighlight `json:"highlights,omitempty"`
+
 	// Images List of images found
 	Images []ImageAsset `json:"images"`
+
+	// NextCursor Cursor for the next page of results. Only present when scope is "all" and there are more results
+	NextCursor *string `json:"next_cursor,omitempty"`
+
+	// Results Images, collections and users ordered by relevance, then newest first. Only present when scope is "all"
+	Results *[]SearchResult `json:"results,omitempty"`
+}
+
+// SearchResult One result of a search across all types. Exactly one of image, collection or user is set, matching type
+type SearchResult struct {
+	Collection *Collection `json:"collection,omitempty"`
+	Image      *ImageAsset `json:"image,omitempty"`
+
+	// Rank Full-text relevance score (higher is better)
+	Rank float64 `json:"rank"`
+
+	// Type Type of the result
+	Type SearchResultType `json:"type"`
+
+	// Uid UID of the image, collection or user
+	Uid  string      `json:"uid"`
+	User *PublicUser `json:"user,omitempty"`
 }
 
+// SearchResultType Type of the result
+type SearchResultType string
+
 // Session defines model for Session.
 type Session struct {
 	// ClientId Client ID
@@ -1062,6 +1639,9 @@ type SignDownloadRequest struct {
 	// AllowEmbed Allow embedding images on external sites (default false to prevent hotlinking)
 	AllowEmbed *bool `json:"allow_embed,omitempty"`
 
+	// CollectionUid Include every image in this collection (including smart collections) instead of listing uids
+	CollectionUid *string `json:"collection_uid,omitempty"`
+
 	// Description Optional description of this share/download link
 	Description *string `json:"description,omitempty"`
 
@@ -1071,11 +1651,61 @@ type SignDownloadRequest struct {
 	// Password Optional password protection for the token (will be bcrypt hashed)
 	Password *string `json:"password,omitempty"`
 
-	// ShowMetadata Include EXIF and other metadata in responses (default true)
+	// ShowMetadata Include EXIF and other metadata in responses and in the files served, which are stripped of it otherwise (default true)
 	ShowMetadata *bool `json:"show_metadata,omitempty"`
 
 	// Uids Array of image UIDs to include in the download token
 	Uids *[]string `json:"uids,omitempty"`
+
+	// Watermark Draw the signer's watermark profile on every image served through the token (default false)
+	Watermark *bool `json:"watermark,omitempty"`
+}
+
+// StorageConfig defines model for StorageConfig.
+type StorageConfig struct {
+	// Backend Storage backend holding the image files
+	Backend *StorageConfigBackend `json:"backend,omitempty"`
+
+	// Locations Named storage locations next to the default one, such as the target of a storage migration
+	Locations *map[string]StorageLocationConfig `json:"locations,omitempty"`
+	S3        *S3Config                         `json:"s3,omitempty"`
+}
+
+// StorageConfigBackend Storage backend holding the image files
+type StorageConfigBackend string
+
+// StorageLocation A storage location images can be stored in
+type StorageLocation struct {
+	// Backend Storage backend of the location, local or s3
+	Backend string `json:"backend"`
+
+	// Default Whether this is the default location, where new uploads are stored
+	Default bool `json:"default"`
+
+	// ImageCount Number of images, including deleted ones in the trash, stored in the location
+	ImageCount int64 `json:"image_count"`
+
+	// Name Location name, "default" for the configured storage backend
+	Name string `json:"name"`
+}
+
+// StorageLocationConfig defines model for StorageLocationConfig.
+type StorageLocationConfig struct {
+	// Backend Storage backend of the location
+	Backend *StorageLocationConfigBackend `json:"backend,omitempty"`
+
+	// Directory Directory of a local location, relative to the base directory unless absolute
+	Directory *string   `json:"directory,omitempty"`
+	S3        *S3Config `json:"s3,omitempty"`
+}
+
+// StorageLocationConfigBackend Storage backend of the location
+type StorageLocationConfigBackend string
+
+// StorageLocationsResponse defines model for StorageLocationsResponse.
+type StorageLocationsResponse struct {
+	// Items Storage locations, the default one first
+	Items []StorageLocation `json:"items"`
 }
 
 // StorageMetricsConfig defines model for StorageMetricsConfig.
@@ -1087,6 +1717,53 @@ type StorageMetricsConfig struct {
 	IntervalSeconds *int `json:"interval_seconds,omitempty"`
 }
 
+// TransformsConfig defines model for TransformsConfig.
+type TransformsConfig struct {
+	// Presets Transform presets by name, each a query string of /images/{uid}/file. Presets saved through the admin API take precedence.
+	Presets *map[string]string `json:"presets,omitempty"`
+}
+
+// TransformPreset A named transform generated for every image, served by /images/{uid}/file?preset={name}
+type TransformPreset struct {
+	// Name Preset name, lowercase letters, digits and dashes
+	Name string `json:"name"`
+
+	// Params Transform as the query string of /images/{uid}/file, like format=webp&w=1080&h=1080&fit=cover
+	Params string `json:"params"`
+
+	// Source Where the preset is defined, builtin and config presets can't be removed through the API
+	Source TransformPresetSource `json:"source"`
+}
+
+// TransformPresetSource Where the preset is defined, builtin and config presets can't be removed through the API
+type TransformPresetSource string
+
+// TransformPresetListResponse defines model for TransformPresetListResponse.
+type TransformPresetListResponse struct {
+	// Items Built in presets first, then the others by name
+	Items []TransformPreset `json:"items"`
+}
+
+// TransformPresetSaveResponse defines model for TransformPresetSaveResponse.
+type TransformPresetSaveResponse struct {
+	Preset TransformPreset `json:"preset"`
+
+	// Regenerating Number of images the preset is being regenerated for
+	Regenerating int `json:"regenerating"`
+}
+
+// TransformPresetUpdate defines model for TransformPresetUpdate.
+type TransformPresetUpdate struct {
+	// Params Transform as the query string of /images/{uid}/file
+	Params string `json:"params"`
+}
+
+// TrashConfig defines model for TrashConfig.
+type TrashConfig struct {
+	// RetentionDays Days deleted images stay in the trash before they're purged, 0 keeps them forever
+	RetentionDays *int `json:"retention_days,omitempty"`
+}
+
 // SuperadminSetupRequest defines model for SuperadminSetupRequest.
 type SuperadminSetupRequest struct {
 	// Email Email address
@@ -1115,6 +1792,21 @@ type SuperadminSetupResponse struct {
 	User         User   `json:"user"`
 }
 
+// StorageMigrationRequest Moves the files of every image in one storage location to another
+type StorageMigrationRequest struct {
+	// Concurrency Number of images copied at the same time, 4 when not set
+	Concurrency *int `json:"concurrency,omitempty"`
+
+	// DeleteSource Delete the files in the source location once an image has been moved
+	DeleteSource *bool `json:"delete_source,omitempty"`
+
+	// Source Name of the location to move images from
+	Source string `json:"source"`
+
+	// Target Name of the location to move images to
+	Target string `json:"target"`
+}
+
 // SystemStatsResponse defines model for SystemStatsResponse.
 type SystemStatsResponse struct {
 	// AllocMemory Bytes of allocated heap objects
@@ -1123,10 +1815,13 @@ type SystemStatsResponse struct {
 	// NumGoroutine Number of running goroutines
 	NumGoroutine int `json:"num_goroutine"`
 
+	// StorageBackend Storage backend holding the image files, local or s3
+	StorageBackend string `json:"storage_backend"`
+
 	// StoragePath Path to storage directory
 	StoragePath string `json:"storage_path"`
 
-	// StorageUsedBytes Total size of files in the base directory
+	// StorageUsedBytes Total size of files in the storage backend
 	StorageUsedBytes int64 `json:"storage_used_bytes"`
 
 	// SysMemory Total bytes of memory obtained from the OS
@@ -1337,6 +2032,33 @@ type WSStatsResponse struct {
 	Timestamp time.Time `json:"timestamp"`
 }
 
+// WatermarkProfile A watermark drawn over images. The logo is drawn when there is one, the text otherwise.
+type WatermarkProfile struct {
+	// Color Colour of the text as #rrggbb (default #ffffff)
+	Color *string `json:"color,omitempty"`
+
+	// Logo Checksum of the uploaded PNG logo, set by uploading one
+	Logo *string `json:"logo,omitempty"`
+
+	// Opacity Opacity of the watermark, from 0 to 1
+	Opacity float32 `json:"opacity"`
+
+	// Position Where the watermark is placed, ignored when tiled
+	Position WatermarkProfilePosition `json:"position"`
+
+	// Scale Width of the watermark as a fraction of the image width, from 0 to 1
+	Scale float32 `json:"scale"`
+
+	// Text Text drawn when there is no logo
+	Text *string `json:"text,omitempty"`
+
+	// Tile Repeat the watermark across the whole image
+	Tile bool `json:"tile"`
+}
+
+// WatermarkProfilePosition Where the watermark is placed, ignored when tiled
+type WatermarkProfilePosition string
+
 // WorkerInfo defines model for WorkerInfo.
 type WorkerInfo struct {
 	// Concurrency Number of concurrent jobs for this worker
@@ -1354,6 +2076,9 @@ type WorkerInfo struct {
 
 // WorkerJob defines model for WorkerJob.
 type WorkerJob struct {
+	// Attempts Number of times the job has run, including retries
+	Attempts int `json:"attempts"`
+
 	// Command Job command
 	Command *string `json:"command"`
 
@@ -1372,9 +2097,18 @@ type WorkerJob struct {
 	// ImageUid Related image UID
 	ImageUid *string `json:"image_uid"`
 
+	// OwnerUid User the job runs for, jobs are shared fairly between owners
+	OwnerUid *string `json:"owner_uid"`
+
 	// Payload Job payload
 	Payload *string `json:"payload"`
 
+	// PipelineUid Pipeline the job is a step of
+	PipelineUid *string `json:"pipeline_uid"`
+
+	// Priority Scheduling priority, higher runs first (interactive 10, normal 0, backfill -10)
+	Priority int `json:"priority"`
+
 	// StartedAt Started timestamp
 	StartedAt *time.Time `json:"started_at"`
 
@@ -1396,7 +2130,7 @@ type WorkerJobCreateRequest struct {
 	// Command Command to execute (all=process all, missing=process missing)
 	Command WorkerJobCreateRequestCommand `json:"command"`
 
-	// Type Job topic (e.g., exif_process, image_process)
+	// Type Job topic (e.g., exif_process, image_process, tile_pyramid)
 	Type string `json:"type"`
 
 	// Uids Image UIDs to process (optional, if omitted all images are considered)
@@ -1417,12 +2151,21 @@ type WorkerJobEnqueueResponse struct {
 
 // WorkerJobStatsResponse defines model for WorkerJobStatsResponse.
 type WorkerJobStatsResponse struct {
+	// QueuedByOwner Jobs waiting for a slot by topic, then owner UID ("system" for jobs without an owner)
+	QueuedByOwner map[string]map[string]int `json:"queued_by_owner"`
+
+	// QueuedByPriority Jobs waiting for a slot by topic, then priority (interactive, normal, backfill or the number)
+	QueuedByPriority map[string]map[string]int `json:"queued_by_priority"`
+
 	// QueuedByTopic Queued jobs by topic
 	QueuedByTopic map[string]int `json:"queued_by_topic"`
 
 	// Running Total running jobs
 	Running int `json:"running"`
 
+	// RunningByOwner Running jobs by topic, then owner UID ("system" for jobs without an owner)
+	RunningByOwner map[string]map[string]int `json:"running_by_owner"`
+
 	// RunningByTopic Running jobs by topic
 	RunningByTopic map[string]int `json:"running_by_topic"`
 }
@@ -1585,7 +2328,9 @@ type GetImageExifParams struct {
 
 // GetImageFileParams defines parameters for GetImageFile.
 type GetImageFileParams struct {
-	// Format Output format for transformation
+	// Format Output format for transformation. auto picks AVIF, WebP or JPEG
+	// from the formats the request's Accept header lists, responses to
+	// it vary by Accept.
 	Format *GetImageFileParamsFormat `form:"format,omitempty" json:"format,omitempty"`
 
 	// Width Width for transformation
@@ -1597,6 +2342,56 @@ type GetImageFileParams struct {
 	// Quality Quality for transformation (0-100)
 	Quality *int `form:"quality,omitempty" json:"quality,omitempty"`
 
+	// Fit How the image is resized when both width and height are set, inside when not set
+	Fit *GetImageFileParamsFit `form:"fit,omitempty" json:"fit,omitempty"`
+
+	// Crop Rectangle of the image, after its edits, to cut out first, as x,y,w,h in pixels
+	Crop *string `form:"crop,omitempty" json:"crop,omitempty"`
+
+	// Gravity Part of the image kept by fit=cover and where fit=contain places it. When not set, cover keeps the image's focal point in frame.
+	Gravity *GetImageFileParamsGravity `form:"gravity,omitempty" json:"gravity,omitempty"`
+
+	// Develop Set to "full" to render a RAW image by developing its sensor data
+	Develop *GetImageFileParamsDevelop `form:"develop,omitempty" json:"develop,omitempty"`
+
+	// Profile Output colour profile: srgb (the default), p3, adobe-rgb, original to
+	// keep the original's colour space and embedded profile, or the name
+	// of an ICC profile uploaded through /admin/colour-profiles.
+	Profile *string `form:"profile,omitempty" json:"profile,omitempty"`
+
+	// Intent Rendering intent of the conversion to profile, perceptual when not set
+	Intent *GetImageFileParamsIntent `form:"intent,omitempty" json:"intent,omitempty"`
+
+	// Icc Set to "strip" to leave the output profile out of the file
+	Icc *GetImageFileParamsIcc `form:"icc,omitempty" json:"icc,omitempty"`
+
+	// Depth Bits per channel, 16 needs format png or tiff
+	Depth *GetImageFileParamsDepth `form:"depth,omitempty" json:"depth,omitempty"`
+
+	// Proof CMYK profile to soft-proof against, cmyk for the generic one of
+	// libvips or the name of an uploaded profile
+	Proof *string `form:"proof,omitempty" json:"proof,omitempty"`
+
+	// Metadata Metadata kept from the original: keep for all of it, no-gps for all but the
+	// location, copyright for the creator and copyright notice only, none
+	// for nothing. Transforms default to none, originals to keep. Viewers
+	// other than the owner get no more than the owner's privacy policy
+	// allows, and share links hiding metadata get none.
+	Metadata *GetImageFileParamsMetadata `form:"metadata,omitempty" json:"metadata,omitempty"`
+
+	// Page Page of a multi-page original, like a TIFF, to render, counted from 1.
+	// When not set the first page is rendered, or all frames of an animated
+	// GIF or WebP if the transform to gif or webp only resizes it.
+	Page *int `form:"page,omitempty" json:"page,omitempty"`
+
+	// Preset Name of a transform preset to serve, replacing the other transform
+	// parameters. Presets are generated with the image, see
+	// /admin/presets.
+	Preset *string `form:"preset,omitempty" json:"preset,omitempty"`
+
+	// Watermark Set to "1" to draw the image owner's watermark profile
+	Watermark *GetImageFileParamsWatermark `form:"watermark,omitempty" json:"watermark,omitempty"`
+
 	// Download Set to "1" to force download mode (requires token)
 	Download *GetImageFileParamsDownload `form:"download,omitempty" json:"download,omitempty"`
 
@@ -1610,6 +2405,30 @@ type GetImageFileParams struct {
 // GetImageFileParamsFormat defines parameters for GetImageFile.
 type GetImageFileParamsFormat string
 
+// GetImageFileParamsFit defines parameters for GetImageFile.
+type GetImageFileParamsFit string
+
+// GetImageFileParamsGravity defines parameters for GetImageFile.
+type GetImageFileParamsGravity string
+
+// GetImageFileParamsDevelop defines parameters for GetImageFile.
+type GetImageFileParamsDevelop string
+
+// GetImageFileParamsIntent defines parameters for GetImageFile.
+type GetImageFileParamsIntent string
+
+// GetImageFileParamsIcc defines parameters for GetImageFile.
+type GetImageFileParamsIcc string
+
+// GetImageFileParamsDepth defines parameters for GetImageFile.
+type GetImageFileParamsDepth int
+
+// GetImageFileParamsMetadata defines parameters for GetImageFile.
+type GetImageFileParamsMetadata string
+
+// GetImageFileParamsWatermark defines parameters for GetImageFile.
+type GetImageFileParamsWatermark string
+
 // GetImageFileParamsDownload defines parameters for GetImageFile.
 type GetImageFileParamsDownload string
 
@@ -1631,9 +2450,49 @@ type ListJobsParams struct {
 // ListJobsParamsStatus defines parameters for ListJobs.
 type ListJobsParamsStatus string
 
+// ListDeadLetterJobsParams defines parameters for ListDeadLetterJobs.
+type ListDeadLetterJobsParams struct {
+	// Topic Filter by the job's original topic
+	Topic *string `form:"topic,omitempty" json:"topic,omitempty"`
+
+	// Limit Number of jobs per page
+	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Page Page index (0-based)
+	Page *int `form:"page,omitempty" json:"page,omitempty"`
+}
+
+// ReplayDeadLetterJobsParams defines parameters for ReplayDeadLetterJobs.
+type ReplayDeadLetterJobsParams struct {
+	// Topic Only replay jobs from this topic
+	Topic *string `form:"topic,omitempty" json:"topic,omitempty"`
+}
+
+// ListPipelinesParams defines parameters for ListPipelines.
+type ListPipelinesParams struct {
+	// Status Filter by pipeline status (running, completed, failed or cancelled)
+	Status *string `form:"status,omitempty" json:"status,omitempty"`
+
+	// ImageUid Filter by image
+	ImageUid *string `form:"image_uid,omitempty" json:"image_uid,omitempty"`
+
+	// Limit Number of pipelines per page
+	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Page Page index (0-based)
+	Page *int `form:"page,omitempty" json:"page,omitempty"`
+}
+
 // ExecuteSearchParams defines parameters for ExecuteSearch.
 type ExecuteSearchParams struct {
-	// Q Search query string (e.g. "johannesburg rating:>=4")
+	// Q Search query string (e.g. "johannesburg rating:>=4").
+	// Terms are AND-ed by default and can be combined with OR, NOT (or a leading "-")
+	// and parentheses, e.g. `make:canon OR make:nikon -is:private (rating:>=4 OR favourited:true)`
+	// Numeric filters (iso, f, focal, shutter, width, height, rating) accept comparisons and
+	// inclusive ranges, e.g. `iso:>=1600 f:<2.8 focal:24..70 shutter:<1/60`.
+	// Date filters (taken, date, year, month, after, before, uploaded) accept ISO dates, keywords
+	// and relative dates resolved in the user's timezone, e.g. `taken:2024-01..2024-03`,
+	// `after:"7 days ago"`, `date:yesterday`, `uploaded:>=2024-06`.
 	Q string `form:"q" json:"q"`
 
 	// Limit Max items per category
@@ -1641,8 +2500,24 @@ type ExecuteSearchParams struct {
 
 	// Page Page number
 	Page *int `form:"page,omitempty" json:"page,omitempty"`
+
+	// Facets Comma separated facets to count over the whole image result set (not just the current page), or "all".
+	// One of make, model, lens, year, month, rating, label, type, orientation, owner.
+	Facets *string `form:"facets,omitempty" json:"facets,omitempty"`
+
+	// Scope "all" returns images, collections and users as one list of typed results in `results`,
+	// paged with `cursor` instead of `page`. Filters only some types support (e.g. `iso:` or `count:`)
+	// exclude the other types. Collections also accept `has:image:<uid>`, `contains:"keyword"`,
+	// `count:>50`, `created:` and `updated:`; users accept `joined:`.
+	Scope *ExecuteSearchParamsScope `form:"scope,omitempty" json:"scope,omitempty"`
+
+	// Cursor The `next_cursor` of the previous page, when `scope` is "all"
+	Cursor *string `form:"cursor,omitempty" json:"cursor,omitempty"`
 }
 
+// ExecuteSearchParamsScope defines parameters for ExecuteSearch.
+type ExecuteSearchParamsScope string
+
 // RegisterUserJSONRequestBody defines body for RegisterUser for application/json ContentType.
 type RegisterUserJSONRequestBody = UserCreate
 
@@ -1661,6 +2536,12 @@ type UpdateUserSettingJSONRequestBody UpdateUserSettingJSONBody
 // UpdateUserSettingsBatchJSONRequestBody defines body for UpdateUserSettingsBatch for application/json ContentType.
 type UpdateUserSettingsBatchJSONRequestBody = UserSettingUpdateRequest
 
+// StartStorageMigrationJSONRequestBody defines body for StartStorageMigration for application/json ContentType.
+type StartStorageMigrationJSONRequestBody = StorageMigrationRequest
+
+// SaveTransformPresetJSONRequestBody defines body for SaveTransformPreset for application/json ContentType.
+type SaveTransformPresetJSONRequestBody = TransformPresetUpdate
+
 // AdminCreateUserJSONRequestBody defines body for AdminCreateUser for application/json ContentType.
 type AdminCreateUserJSONRequestBody = AdminUserCreate
 
@@ -1712,12 +2593,24 @@ type UploadImageByUrlTextRequestBody = UploadImageByUrlTextBody
 // UpdateImageJSONRequestBody defines body for UpdateImage for application/json ContentType.
 type UpdateImageJSONRequestBody = ImageUpdate
 
+// ReplaceImageEditsJSONRequestBody defines body for ReplaceImageEdits for application/json ContentType.
+type ReplaceImageEditsJSONRequestBody = ImageEditStackUpdate
+
 // CreateJobJSONRequestBody defines body for CreateJob for application/json ContentType.
 type CreateJobJSONRequestBody = WorkerJobCreateRequest
 
+// RescheduleCronJobJSONRequestBody defines body for RescheduleCronJob for application/json ContentType.
+type RescheduleCronJobJSONRequestBody = CronScheduleUpdate
+
 // RegisterWorkerJSONRequestBody defines body for RegisterWorker for application/json ContentType.
 type RegisterWorkerJSONRequestBody = WorkerRegisterRequest
 
+// CreateSavedSearchJSONRequestBody defines body for CreateSavedSearch for application/json ContentType.
+type CreateSavedSearchJSONRequestBody = SavedSearchCreate
+
+// UpdateSavedSearchJSONRequestBody defines body for UpdateSavedSearch for application/json ContentType.
+type UpdateSavedSearchJSONRequestBody = SavedSearchUpdate
+
 // UpdateSessionJSONRequestBody defines body for UpdateSession for application/json ContentType.
 type UpdateSessionJSONRequestBody = SessionUpdate
 
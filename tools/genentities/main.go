@@ -148,6 +148,12 @@ func main() {
 		}
 
 		openapiIncludes = extractOpenAPIEntities(doc)
+
+		// schemas marked x-entity: false have a UID but aren't stored
+		excluded := extractOpenAPIExclusions(doc)
+		discovered = slices.DeleteFunc(discovered, func(e EntityConfig) bool {
+			return excluded[e.Name]
+		})
 	}
 
 	// for DTOs we want to explicitly define as entities (CLI -include has lowest priority)
@@ -596,3 +602,29 @@ func extractOpenAPIEntities(doc map[string]any) map[string]EntityConfig {
 	}
 	return entities
 }
+
+// extractOpenAPIExclusions returns the schemas marked x-entity: false, which
+// are left out even when they look like entities
+func extractOpenAPIExclusions(doc map[string]any) map[string]bool {
+	excluded := make(map[string]bool)
+	comp, ok := doc["components"].(map[string]any)
+	if !ok {
+		return excluded
+	}
+	schemas, ok := comp["schemas"].(map[string]any)
+	if !ok {
+		return excluded
+	}
+
+	for name, raw := range schemas {
+		schemaMap, ok := raw.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		if isEntity, ok := schemaMap["x-entity"].(bool); ok && !isEntity {
+			excluded[name] = true
+		}
+	}
+	return excluded
+}
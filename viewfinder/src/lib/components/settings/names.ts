@@ -10,9 +10,14 @@ export enum SettingNames {
     ViewMode = "ui_default_view_mode",
     ImageDownloadQuality = "image_download_quality",
     ImageDownloadFormat = "image_download_format",
+    ImageDownloadProfile = "image_download_profile",
+    ImageDownloadIntent = "image_download_intent",
+    ImageDownloadICC = "image_download_icc",
+    ImageDownloadBitDepth = "image_download_bit_depth",
     ImagePreviewFormat = "image_preview_format",
     ImageResizeKernel = "image_resize_kernel",
     ImageVisibleMetadata = "image_visible_metadata",
     StripMetadata = "privacy_download_strip_metadata",
+    MetadataPolicy = "privacy_metadata_policy",
     OnboardingComplete = "onboarding_complete"
 } 
\ No newline at end of file
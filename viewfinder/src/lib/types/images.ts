@@ -20,12 +20,20 @@ import type { User } from "./users";
 import type { APIPagination } from "$lib/api/adapters";
 import type CollectionData from "$lib/entities/collection";
 
-export type SupportedImageTypes = "jpeg" | "jpg" | "png" | "tiff";
+export type SupportedImageTypes = "jpeg" | "jpg" | "png" | "tiff" | "tif" | "gif" | "webp" | "heic" | "heif" | "avif" | "jxl" | "psd";
 export const SUPPORTED_IMAGE_TYPES: SupportedImageTypes[] = [
     "jpeg",
     "jpg",
     "png",
-    "tiff"
+    "tiff",
+    "tif",
+    "gif",
+    "webp",
+    "heic",
+    "heif",
+    "avif",
+    "jxl",
+    "psd"
 ];
 
 /**